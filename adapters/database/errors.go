@@ -0,0 +1,37 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/lib/pq"
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqInvalidTextRepresentation pq.ErrorCode = "22P02"
+	pqNotNullViolation          pq.ErrorCode = "23502"
+	pqForeignKeyViolation       pq.ErrorCode = "23503"
+	pqUniqueViolation           pq.ErrorCode = "23505"
+	pqCheckViolation            pq.ErrorCode = "23514"
+	pqExclusionViolation        pq.ErrorCode = "23P01"
+)
+
+// mapError wraps constraint violations in the matching domain error, keeping the driver error for logs
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case pqUniqueViolation, pqExclusionViolation:
+		return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+	case pqForeignKeyViolation:
+		return fmt.Errorf("%w: %w", domain.ErrNotFound, err)
+	case pqInvalidTextRepresentation, pqNotNullViolation, pqCheckViolation:
+		// e.g. a config that isn't valid JSON for a JSONB column
+		return fmt.Errorf("%w: %w", domain.ErrValidation, err)
+	}
+	return err
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapError(t *testing.T) {
+	wrapped := func(code pq.ErrorCode) error {
+		return fmt.Errorf("failed to insert: %w", &pq.Error{Code: code})
+	}
+	require.ErrorIs(t, mapError(wrapped(pqUniqueViolation)), domain.ErrConflict)
+	require.ErrorIs(t, mapError(wrapped(pqExclusionViolation)), domain.ErrConflict)
+	require.ErrorIs(t, mapError(wrapped(pqForeignKeyViolation)), domain.ErrNotFound)
+	require.ErrorIs(t, mapError(wrapped(pqInvalidTextRepresentation)), domain.ErrValidation)
+	require.ErrorIs(t, mapError(wrapped(pqCheckViolation)), domain.ErrValidation)
+
+	var pqErr *pq.Error
+	require.ErrorAs(t, mapError(wrapped(pqUniqueViolation)), &pqErr, "the driver error is kept")
+
+	other := wrapped("40001")
+	require.Equal(t, other, mapError(other))
+	plain := errors.New("boom")
+	require.Equal(t, plain, mapError(plain))
+	require.NoError(t, mapError(nil))
+}
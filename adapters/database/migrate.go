@@ -0,0 +1,350 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationLockID is the postgres advisory lock serializing migrations across hub instances
+const migrationLockID = 0x6275696c646572 // "builder"
+
+var (
+	ErrSchemaBehind      = errors.New("database schema is behind the binary, run `migrate up`")
+	ErrSchemaChecksum    = errors.New("an applied migration differs from the embedded one")
+	ErrUnversionedSchema = errors.New("database has tables but no migration history, run `migrate baseline`")
+)
+
+// Migration is a numbered schema change with its reverse
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes an embedded or applied migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set if the applied checksum differs from the embedded migration
+	Modified bool
+	// Unknown is set for applied migrations the binary doesn't know about (the DB is ahead)
+	Unknown bool
+}
+
+type appliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// LoadMigrations reads NNN_name.sql up migrations and down/NNN_name.sql down migrations from fsys
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with a version number: %w", file, err)
+		}
+		up, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		down, err := fs.ReadFile(fsys, path.Join("down", file))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		sum := sha256.Sum256(up)
+		migrations = append(migrations, Migration{
+			Version:  version,
+			Name:     name,
+			Up:       string(up),
+			Down:     string(down),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations and tracks them in the schema_migrations table
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sqlx.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations
+		(
+			version    INT PRIMARY KEY,
+			name       TEXT                     NOT NULL,
+			checksum   TEXT                     NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	return err
+}
+
+// appliedIfTracked returns the applied migrations without creating the history table, none if it doesn't exist
+func (m *Migrator) appliedIfTracked(ctx context.Context) (map[int]appliedMigration, error) {
+	var tracked bool
+	if err := m.db.GetContext(ctx, &tracked, `SELECT to_regclass('schema_migrations') IS NOT NULL`); err != nil {
+		return nil, err
+	}
+	if !tracked {
+		return map[int]appliedMigration{}, nil
+	}
+	return m.applied(ctx, m.db)
+}
+
+func (m *Migrator) applied(ctx context.Context, q sqlx.QueryerContext) (map[int]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := sqlx.SelectContext(ctx, q, &rows, `SELECT version, name, checksum, applied_at FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+	res := make(map[int]appliedMigration, len(rows))
+	for _, row := range rows {
+		res[row.Version] = row
+	}
+	return res, nil
+}
+
+// checkVersioned refuses to migrate a schema created before migrations were tracked
+func (m *Migrator) checkVersioned(ctx context.Context, q sqlx.QueryerContext, applied map[int]appliedMigration) error {
+	if len(applied) > 0 {
+		return nil
+	}
+	var exists bool
+	if err := sqlx.GetContext(ctx, q, &exists, `SELECT to_regclass('builders') IS NOT NULL`); err != nil {
+		return err
+	}
+	if exists {
+		return ErrUnversionedSchema
+	}
+	return nil
+}
+
+// Status lists the embedded migrations and any applied migrations unknown to this binary. It doesn't write to the
+// database.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.appliedIfTracked(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[int]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &a.AppliedAt
+			status.Modified = a.Checksum != mig.Checksum
+		}
+		res = append(res, status)
+	}
+	for _, a := range applied {
+		if !known[a.Version] {
+			res = append(res, MigrationStatus{Version: a.Version, Name: a.Name, Applied: true, AppliedAt: &a.AppliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+// Check returns an error unless every embedded migration is applied unmodified. It doesn't write to the database,
+// so it works with a read-only role too.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.appliedIfTracked(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.checkVersioned(ctx, m.db, applied); err != nil {
+		return err
+	}
+	var pending []string
+	for _, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		if !ok {
+			pending = append(pending, mig.Name)
+			continue
+		}
+		if a.Checksum != mig.Checksum {
+			return fmt.Errorf("%w: %s", ErrSchemaChecksum, mig.Name)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending %s", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// inLockedTx runs fn in a transaction holding the migration lock, with the applied migrations read under the lock
+func (m *Migrator) inLockedTx(ctx context.Context, fn func(tx *sqlx.Tx, applied map[int]appliedMigration) error) error {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx, applied); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies all pending migrations in order, each in its own transaction, and returns the applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations {
+		ran := false
+		err := m.inLockedTx(ctx, func(tx *sqlx.Tx, applied map[int]appliedMigration) error {
+			if err := m.checkVersioned(ctx, tx, applied); err != nil {
+				return err
+			}
+			if a, ok := applied[mig.Version]; ok {
+				if a.Checksum != mig.Checksum {
+					return fmt.Errorf("%w: %s", ErrSchemaChecksum, mig.Name)
+				}
+				return nil
+			}
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return fmt.Errorf("migration %s failed: %w", mig.Name, err)
+			}
+			ran = true
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, mig.Version, mig.Name, mig.Checksum)
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		byVersion[mig.Version] = mig
+	}
+
+	var done []Migration
+	for i := 0; i < steps; i++ {
+		var reverted *Migration
+		err := m.inLockedTx(ctx, func(tx *sqlx.Tx, applied map[int]appliedMigration) error {
+			latest := -1
+			for v := range applied {
+				latest = max(latest, v)
+			}
+			if latest < 0 {
+				return nil
+			}
+			mig, ok := byVersion[latest]
+			if !ok {
+				return fmt.Errorf("applied migration %s is unknown to this binary", applied[latest].Name)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %s has no down migration", mig.Name)
+			}
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return fmt.Errorf("down migration %s failed: %w", mig.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+				return err
+			}
+			reverted = &mig
+			return nil
+		})
+		if err != nil {
+			return done, err
+		}
+		if reverted == nil {
+			break
+		}
+		done = append(done, *reverted)
+	}
+	return done, nil
+}
+
+// Baseline records the migrations up to and including version as applied without running them.
+// It is meant for databases whose schema was applied by hand before migrations were tracked.
+func (m *Migrator) Baseline(ctx context.Context, version int) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err := m.inLockedTx(ctx, func(tx *sqlx.Tx, applied map[int]appliedMigration) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
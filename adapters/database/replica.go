@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Config configures the database connection pools and the optional read replica
+type Config struct {
+	DSN string
+	// ReplicaDSN enables a read replica for the read-heavy paths (measurements and peer lists)
+	ReplicaDSN string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+
+	// MaxReplicaLag is the replication lag above which reads go to the primary
+	MaxReplicaLag time.Duration
+	// ReplicaCheckInterval is how often the replica health and lag are checked
+	ReplicaCheckInterval time.Duration
+
+	// QueryTimeout bounds every storage operation (including its transaction), zero disables it
+	QueryTimeout time.Duration
+}
+
+// DefaultConfig returns the pool settings the hub has always used
+func DefaultConfig(dsn string) Config {
+	return Config{
+		DSN:                  dsn,
+		MaxOpenConns:         50,
+		MaxIdleConns:         10,
+		MaxReplicaLag:        5 * time.Second,
+		ReplicaCheckInterval: 5 * time.Second,
+		QueryTimeout:         10 * time.Second,
+	}
+}
+
+func configurePool(db *sqlx.DB, cfg Config) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+}
+
+func connect(dsn string, cfg Config) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	configurePool(db, cfg)
+	return db, nil
+}
+
+// replicaLagQuery returns 0 when the replica has replayed everything it received, otherwise the age of
+// the last replayed transaction. Comparing LSNs first avoids reporting lag on an idle primary.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+type replica struct {
+	db      *sqlx.DB
+	maxLag  time.Duration
+	lag     func(ctx context.Context) (time.Duration, error)
+	healthy atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newReplica(db *sqlx.DB, cfg Config) *replica {
+	r := &replica{
+		db:     db,
+		maxLag: cfg.MaxReplicaLag,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	r.lag = r.queryLag
+	r.check(context.Background())
+	go r.run(cfg.ReplicaCheckInterval)
+	return r
+}
+
+func (r *replica) queryLag(ctx context.Context) (time.Duration, error) {
+	var seconds float64
+	if err := r.db.GetContext(ctx, &seconds, replicaLagQuery); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// check marks the replica healthy if it is reachable and its lag is within bounds
+func (r *replica) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	lag, err := r.lag(ctx)
+	r.healthy.Store(err == nil && lag <= r.maxLag)
+}
+
+func (r *replica) run(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check(context.Background())
+		}
+	}
+}
+
+func (r *replica) close() error {
+	close(r.stop)
+	<-r.done
+	return r.db.Close()
+}
+
+// withReader runs a read-only query on the replica if it is healthy, falling back to the primary
+// when there is no replica or the replica query fails
+func (s *Service) withReader(ctx context.Context, query func(db *sqlx.DB) error) error {
+	if s.replica != nil && s.replica.healthy.Load() {
+		err := query(s.replica.db)
+		if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+			return err
+		}
+		// stay on the primary until the next successful health check
+		s.replica.healthy.Store(false)
+	}
+	return query(s.DB)
+}
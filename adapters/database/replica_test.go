@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// fakeDB returns an in-memory database answering "SELECT name FROM source" with name
+func fakeDB(t *testing.T, name string) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Connect("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE source (name TEXT); INSERT INTO source VALUES (?)`, name)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func readSource(t *testing.T, s *Service) string {
+	t.Helper()
+	var name string
+	require.NoError(t, s.withReader(context.Background(), func(db *sqlx.DB) error {
+		return db.Get(&name, `SELECT name FROM source`)
+	}))
+	return name
+}
+
+func TestReplicaRouting(t *testing.T) {
+	var lag time.Duration
+	var lagErr error
+	r := &replica{
+		db:     fakeDB(t, "replica"),
+		maxLag: time.Second,
+		lag:    func(context.Context) (time.Duration, error) { return lag, lagErr },
+	}
+	s := &Service{DB: fakeDB(t, "primary"), replica: r}
+	ctx := context.Background()
+
+	r.check(ctx)
+	require.Equal(t, "replica", readSource(t, s))
+
+	// lagging replica
+	lag = 2 * time.Second
+	r.check(ctx)
+	require.Equal(t, "primary", readSource(t, s))
+
+	// caught up again
+	lag = 0
+	r.check(ctx)
+	require.Equal(t, "replica", readSource(t, s))
+
+	// unreachable replica
+	lagErr = errors.New("connection refused")
+	r.check(ctx)
+	require.Equal(t, "primary", readSource(t, s))
+	lagErr = nil
+	r.check(ctx)
+
+	// not found on the replica is an answer, not a failure
+	err := s.withReader(ctx, func(db *sqlx.DB) error {
+		var name string
+		return db.Get(&name, `SELECT name FROM source WHERE name = 'nobody'`)
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.True(t, r.healthy.Load())
+
+	// a failing replica query falls back to the primary and takes the replica out until the next check
+	require.NoError(t, r.db.Close())
+	require.Equal(t, "primary", readSource(t, s))
+	require.False(t, r.healthy.Load())
+}
+
+func TestNoReplica(t *testing.T) {
+	s := &Service{DB: fakeDB(t, "primary")}
+	require.Equal(t, "primary", readSource(t, s))
+}
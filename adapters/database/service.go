@@ -8,36 +8,66 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/flashbots/builder-hub/domain"
 	"github.com/jackc/pgtype"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Service struct {
+	// DB is the primary, all writes go here
 	DB *sqlx.DB
+
+	replica      *replica
+	queryTimeout time.Duration
 }
 
 func NewDatabaseService(dsn string) (*Service, error) {
-	db, err := sqlx.Connect("postgres", dsn)
+	return NewDatabaseServiceWithConfig(DefaultConfig(dsn))
+}
+
+// NewDatabaseServiceWithConfig connects to the primary and, if configured, the read replica.
+// An unreachable replica is not fatal, reads go to the primary until it becomes healthy.
+func NewDatabaseServiceWithConfig(cfg Config) (*Service, error) {
+	db, err := connect(cfg.DSN, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(50)
-	db.SetMaxIdleConns(10)
-	db.SetConnMaxIdleTime(0)
-
-	dbService := &Service{DB: db} //nolint:exhaustruct
-	return dbService, err
+	dbService := &Service{DB: db, queryTimeout: cfg.QueryTimeout} //nolint:exhaustruct
+	if cfg.ReplicaDSN != "" {
+		replicaDB, err := sqlx.Open("postgres", cfg.ReplicaDSN)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("invalid replica DSN: %w", err)
+		}
+		configurePool(replicaDB, cfg)
+		dbService.replica = newReplica(replicaDB, cfg)
+	}
+	return dbService, nil
 }
 
 func (s *Service) Close() error {
+	if s.replica != nil {
+		_ = s.replica.close()
+	}
 	return s.DB.Close()
 }
 
+// withTimeout bounds a storage operation by the configured query timeout
+func (s *Service) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// GetActiveMeasurementsByType is used to authenticate instances and always reads from the primary
 func (s *Service) GetActiveMeasurementsByType(ctx context.Context, attestationType string) ([]domain.Measurement, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var measurements []Measurement
 	err := s.DB.SelectContext(ctx, &measurements, `SELECT * FROM measurements_whitelist WHERE is_active=true AND attestation_type=$1`, attestationType)
 	var domainMeasurements []domain.Measurement
@@ -51,8 +81,11 @@ func (s *Service) GetActiveMeasurementsByType(ctx context.Context, attestationTy
 	return domainMeasurements, err
 }
 
-// GetBuilderByIP retrieves a builder by IP address
-func (s *Service) GetBuilderByIP(ip net.IP) (*domain.Builder, error) {
+// GetBuilderByIP retrieves a builder by IP address. It authenticates instances, so it reads from the primary: a
+// lagging replica would still accept a deactivated builder.
+func (s *Service) GetBuilderByIP(ctx context.Context, ip net.IP) (*domain.Builder, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var paramIP pgtype.Inet
 	err := paramIP.Set(ip)
 	if err != nil {
@@ -60,7 +93,7 @@ func (s *Service) GetBuilderByIP(ip net.IP) (*domain.Builder, error) {
 	}
 
 	var b Builder
-	err = s.DB.Get(&b, `
+	err = s.DB.GetContext(ctx, &b, `
 		SELECT * FROM builders
 		WHERE ip_address = $1 and is_active = true
 	`, paramIP)
@@ -75,8 +108,13 @@ func (s *Service) GetBuilderByIP(ip net.IP) (*domain.Builder, error) {
 
 // GetActiveMeasurements retrieves all measurements
 func (s *Service) GetActiveMeasurements(ctx context.Context) ([]domain.Measurement, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	var measurements []Measurement
-	err := s.DB.SelectContext(ctx, &measurements, `SELECT * FROM measurements_whitelist WHERE is_active=true`)
+	err := s.withReader(ctx, func(db *sqlx.DB) error {
+		measurements = nil
+		return db.SelectContext(ctx, &measurements, `SELECT * FROM measurements_whitelist WHERE is_active=true`)
+	})
 	var domainMeasurements []domain.Measurement
 	for _, m := range measurements {
 		domainM, err := convertMeasurementToDomain(m)
@@ -88,9 +126,51 @@ func (s *Service) GetActiveMeasurements(ctx context.Context) ([]domain.Measureme
 	return domainMeasurements, err
 }
 
+// GetAllMeasurements retrieves all measurements, active or not, ordered by name
+func (s *Service) GetAllMeasurements(ctx context.Context) ([]domain.MeasurementWithStatus, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var measurements []Measurement
+	err := s.DB.SelectContext(ctx, &measurements, `SELECT * FROM measurements_whitelist ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.MeasurementWithStatus, 0, len(measurements))
+	for _, m := range measurements {
+		domainM, err := convertMeasurementToDomain(m)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, domain.MeasurementWithStatus{Measurement: *domainM, IsActive: m.IsActive})
+	}
+	return res, nil
+}
+
+// GetAllBuilders retrieves all builders, active or not, ordered by name
+func (s *Service) GetAllBuilders(ctx context.Context) ([]domain.Builder, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var builders []Builder
+	err := s.DB.SelectContext(ctx, &builders, `SELECT * FROM builders ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Builder, 0, len(builders))
+	for _, b := range builders {
+		domainB, err := convertBuilderToDomain(b)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *domainB)
+	}
+	return res, nil
+}
+
 // RegisterCredentialsForBuilder registers new credentials for a builder, deprecating all previous credentials
 // It uses hash and attestation_type to fetch the corresponding measurement_id via a subquery.
 func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	// Start a transaction
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -101,7 +181,7 @@ func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName
 	}() // Rollback the transaction if it's not committed
 
 	// Deprecate all previous credentials for this builder and service
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
         UPDATE service_credential_registrations
         SET is_active = false, deprecated_at = NOW()
         WHERE builder_name = $1 AND service = $2
@@ -116,7 +196,7 @@ func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName
 		nullableTLSCert = sql.NullString{String: tlsCert, Valid: true}
 	}
 
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
         INSERT INTO service_credential_registrations
         (builder_name, service, tls_cert, ecdsa_pubkey, is_active, measurement_id, region)
         VALUES ($1, $2, $3, $4, true,
@@ -125,7 +205,7 @@ func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName
         )
     `, builderName, service, nullableTLSCert, ecdsaPubKey, measurementName, attestationType, region)
 	if err != nil {
-		return fmt.Errorf("failed to insert credentials for builder %s: %w", builderName, err)
+		return fmt.Errorf("failed to insert credentials for builder %s: %w", builderName, mapError(err))
 	}
 
 	// Commit the transaction
@@ -136,21 +216,77 @@ func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName
 	return nil
 }
 
-// GetActiveConfigForBuilder retrieves the active config for a builder by name
+// GetActiveConfigForBuilder retrieves the effective config for a builder by name: its active config merged
+// over its network and group layers
 func (s *Service) GetActiveConfigForBuilder(ctx context.Context, builderName string) (json.RawMessage, error) {
+	layers, err := s.GetConfigLayersForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+	return domain.ActiveConfigFromLayers(layers)
+}
+
+// GetConfigLayersForBuilder returns the config layers of a builder in merge order, the last one is the builder's
+// active config if it has one. Shared layers in an active rollout targeting the builder are replaced by the new version.
+func (s *Service) GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]domain.ConfigLayer, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var network string
+	err := s.DB.GetContext(ctx, &network, `SELECT network FROM builders WHERE name = $1`, builderName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.GetConfigGroupsForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ConfigLayer
+	err = s.DB.SelectContext(ctx, &rows, `
+		SELECT kind, name, config FROM config_layers
+		WHERE (kind = 'network' AND name = $1)
+			OR (kind = 'group' AND name IN (SELECT group_name FROM builder_config_groups WHERE builder_name = $2))
+	`, network, builderName)
+	if err != nil {
+		return nil, err
+	}
+	shared := make([]domain.ConfigLayer, 0, len(rows))
+	for _, row := range rows {
+		shared = append(shared, domain.ConfigLayer{Kind: row.Kind, Name: row.Name, Config: row.Config})
+	}
+	rollouts, err := s.selectRollouts(ctx, `WHERE status = 'active'`)
+	if err != nil {
+		return nil, err
+	}
+
 	var config BuilderConfig
-	err := s.DB.GetContext(ctx, &config, `
+	err = s.DB.GetContext(ctx, &config, `
 		SELECT * FROM builder_configs
 		WHERE builder_name = $1 AND is_active = true
 	`, builderName)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, domain.ErrNotFound
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
 	}
-	return config.Config, err
+	return domain.BuilderConfigLayers(domain.Builder{Name: builderName, Network: network}, groups,
+		domain.RolloutLayers(builderName, shared, rollouts), config.Config), nil
 }
 
 func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]domain.BuilderWithServices, error) {
-	rows, err := s.DB.QueryContext(ctx, `
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var builders []domain.BuilderWithServices
+	err := s.withReader(ctx, func(db *sqlx.DB) (err error) {
+		builders, err = getActiveBuildersWithServiceCredentials(ctx, db, network)
+		return err
+	})
+	return builders, err
+}
+
+func getActiveBuildersWithServiceCredentials(ctx context.Context, db *sqlx.DB, network string) ([]domain.BuilderWithServices, error) {
+	rows, err := db.QueryContext(ctx, `
         SELECT
             b.name,
             b.ip_address,
@@ -173,7 +309,8 @@ func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, n
 	}
 	defer rows.Close() //nolint:errcheck
 
-	buildersMap := make(map[string]*BuilderWithCredentials)
+	// rows are ordered by builder name, so a builder's credentials are consecutive
+	var ordered []*BuilderWithCredentials
 
 	for rows.Next() {
 		var ipAddress pgtype.Inet
@@ -189,15 +326,14 @@ func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, n
 			return nil, err
 		}
 
-		builder, exists := buildersMap[builderName]
-		if !exists {
-			builder = &BuilderWithCredentials{
+		if len(ordered) == 0 || ordered[len(ordered)-1].Name != builderName {
+			ordered = append(ordered, &BuilderWithCredentials{
 				Name:      builderName,
 				IPAddress: ipAddress,
 				DNSName:   dnsName,
-			}
-			buildersMap[builderName] = builder
+			})
 		}
+		builder := ordered[len(ordered)-1]
 
 		if service.Valid {
 			builder.Credentials = append(builder.Credentials, ServiceCredential{
@@ -213,9 +349,8 @@ func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, n
 		return nil, err
 	}
 
-	// Convert map to slice
-	builders := make([]domain.BuilderWithServices, 0, len(buildersMap))
-	for _, builder := range buildersMap {
+	builders := make([]domain.BuilderWithServices, 0, len(ordered))
+	for _, builder := range ordered {
 		dBuilder, err := toDomainBuilderWithCredentials(*builder)
 		if err != nil {
 			return nil, err
@@ -229,6 +364,8 @@ func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, n
 // LogEvent creates a new log entry in the event_log table.
 // It uses hash and attestation_type to fetch the corresponding measurement_id via a subquery.
 func (s *Service) LogEvent(ctx context.Context, eventName, builderName, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	// Insert new event log entry with a subquery to fetch the measurement_id
 	_, err := s.DB.ExecContext(ctx, `
         INSERT INTO event_log
@@ -238,25 +375,59 @@ func (s *Service) LogEvent(ctx context.Context, eventName, builderName, name str
         )
     `, eventName, builderName, name)
 	if err != nil {
-		return fmt.Errorf("failed to insert event log for builder %s: %w", builderName, err)
+		return fmt.Errorf("failed to insert event log for builder %s: %w", builderName, mapError(err))
 	}
 
 	return nil
 }
 
 func (s *Service) AddMeasurement(ctx context.Context, measurement domain.Measurement, enabled bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	bts, err := json.Marshal(measurement.Measurement)
 	if err != nil {
 		return err
 	}
-	_, err = s.DB.ExecContext(ctx, `
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	_, err = tx.ExecContext(ctx, `
 		INSERT INTO measurements_whitelist (name, attestation_type, measurement, is_active)
 		VALUES ($1, $2, $3, $4)
 	`, measurement.Name, measurement.AttestationType, bts, enabled)
-	return err
+	if err != nil {
+		return mapError(err)
+	}
+
+	change := domain.MeasurementChange{
+		Action:          domain.MeasurementActionAdd,
+		Name:            measurement.Name,
+		AttestationType: measurement.AttestationType,
+		Measurements:    bts,
+		Timestamp:       time.Now().Unix(),
+	}
+	if err = appendMeasurementLog(ctx, tx, change); err != nil {
+		return err
+	}
+	if enabled {
+		change.Action = domain.MeasurementActionActivate
+		if err = appendMeasurementLog(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (s *Service) AddBuilder(ctx context.Context, builder domain.Builder) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	bIP := pgtype.Inet{}
 	err := bIP.Set(builder.IPAddress)
 	if err != nil {
@@ -266,29 +437,128 @@ func (s *Service) AddBuilder(ctx context.Context, builder domain.Builder) error
 		INSERT INTO builders (name, ip_address, is_active, network, dns_name)
 		VALUES ($1, $2, $3, $4, $5)
 	`, builder.Name, bIP, builder.IsActive, builder.Network, sql.NullString{String: builder.DNSName, Valid: builder.DNSName != ""})
-	return err
+	return mapError(err)
 }
 
 func (s *Service) ChangeActiveStatusForBuilder(ctx context.Context, builderName string, isActive bool) error {
-	_, err := s.DB.ExecContext(ctx, `
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx, `
 		UPDATE builders
 		SET is_active = $1
 		WHERE name = $2
 	`, isActive, builderName)
-	return err
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	return nil
 }
 
 func (s *Service) ChangeActiveStatusForMeasurement(ctx context.Context, measurementName string, isActive bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
 	// NOTE: we currently enforce uniqueness per name and attestation type not just by name
-	_, err := s.DB.ExecContext(ctx, `
+	// only rows whose status changes are logged, setting the current status again is a no-op
+	var changed []Measurement
+	err = tx.SelectContext(ctx, &changed, `
 		UPDATE measurements_whitelist
 		SET is_active = $1
-		WHERE name = $2
+		WHERE name = $2 AND is_active IS DISTINCT FROM $1
+		RETURNING *
 	`, isActive, measurementName)
-	return err
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		var exists bool
+		err = tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM measurements_whitelist WHERE name = $1)`, measurementName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("measurement %s: %w", measurementName, domain.ErrNotFound)
+		}
+		return nil
+	}
+
+	action := domain.MeasurementActionDeactivate
+	if isActive {
+		action = domain.MeasurementActionActivate
+	}
+	for _, m := range changed {
+		err = appendMeasurementLog(ctx, tx, domain.MeasurementChange{
+			Action:          action,
+			Name:            m.Name,
+			AttestationType: m.AttestationType,
+			Measurements:    m.Measurement,
+			Timestamp:       time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// appendMeasurementLog appends a change to the measurement transparency log within tx.
+// The table lock serializes appends so that leaf indices stay dense. Leaves are the json.Marshal encoding of the
+// change, the leaves seeded by migration 005 use the json_build_object encoding and are never re-encoded.
+func appendMeasurementLog(ctx context.Context, tx *sqlx.Tx, change domain.MeasurementChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `LOCK TABLE measurements_log IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO measurements_log (leaf_index, data)
+		SELECT COALESCE(MAX(leaf_index) + 1, 0), $1 FROM measurements_log
+	`, data)
+	if err != nil {
+		return fmt.Errorf("failed to append measurement log entry: %w", err)
+	}
+	return nil
+}
+
+// GetMeasurementLogEntries returns up to limit transparency log entries starting at leaf index start
+func (s *Service) GetMeasurementLogEntries(ctx context.Context, start uint64, limit int) ([]domain.MeasurementLogEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []MeasurementLogEntry
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT leaf_index, data FROM measurements_log
+		WHERE leaf_index >= $1
+		ORDER BY leaf_index
+		LIMIT $2
+	`, start, limit)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]domain.MeasurementLogEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, domain.MeasurementLogEntry{Index: uint64(row.LeafIndex), Data: row.Data}) //nolint:gosec
+	}
+	return entries, nil
 }
 
 func (s *Service) AddBuilderConfig(ctx context.Context, builderName string, config json.RawMessage) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 	// Start a transaction
 	tx, err := s.DB.BeginTx(ctx, nil)
 	if err != nil {
@@ -299,7 +569,7 @@ func (s *Service) AddBuilderConfig(ctx context.Context, builderName string, conf
 	}() // Rollback the transaction if it's not committed
 
 	// Deactivate any previous configurations for this builder
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
         UPDATE builder_configs
         SET is_active = false, updated_at = NOW()
         WHERE builder_name = $1 AND is_active = true
@@ -309,12 +579,12 @@ func (s *Service) AddBuilderConfig(ctx context.Context, builderName string, conf
 	}
 
 	// Insert the new configuration as active
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx, `
         INSERT INTO builder_configs (builder_name, config, is_active)
         VALUES ($1, $2, true)
     `, builderName, config)
 	if err != nil {
-		return fmt.Errorf("failed to insert new config for builder %s: %w", builderName, err)
+		return fmt.Errorf("failed to insert new config for builder %s: %w", builderName, mapError(err))
 	}
 
 	// Commit the transaction
@@ -324,3 +594,430 @@ func (s *Service) AddBuilderConfig(ctx context.Context, builderName string, conf
 
 	return nil
 }
+
+// SetConfigSchema registers the schema of a scope, replacing the previous one
+func (s *Service) SetConfigSchema(ctx context.Context, schema domain.ConfigSchema) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_schemas (scope, target, schema)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (scope, target) DO UPDATE SET schema = EXCLUDED.schema, updated_at = NOW()
+	`, schema.Scope, schema.Target, schema.Schema)
+	return mapError(err)
+}
+
+func (s *Service) DeleteConfigSchema(ctx context.Context, scope, target string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM config_schemas WHERE scope = $1 AND target = $2`, scope, target)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s schema %s: %w", scope, target, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetConfigSchemas returns all registered schemas ordered by scope and target
+func (s *Service) GetConfigSchemas(ctx context.Context) ([]domain.ConfigSchema, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []ConfigSchema
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT scope, target, schema FROM config_schemas ORDER BY scope, target`); err != nil {
+		return nil, err
+	}
+	schemas := make([]domain.ConfigSchema, 0, len(rows))
+	for _, row := range rows {
+		schemas = append(schemas, domain.ConfigSchema{Scope: row.Scope, Target: row.Target, Schema: row.Schema})
+	}
+	return schemas, nil
+}
+
+// SetConfigLayer creates or replaces a network or group layer
+func (s *Service) SetConfigLayer(ctx context.Context, layer domain.ConfigLayer) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_layers (kind, name, config)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, name) DO UPDATE SET config = EXCLUDED.config, updated_at = NOW()
+	`, layer.Kind, layer.Name, layer.Config)
+	return mapError(err)
+}
+
+func (s *Service) DeleteConfigLayer(ctx context.Context, kind, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM config_layers WHERE kind = $1 AND name = $2`, kind, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s config layer %s: %w", kind, name, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetConfigLayers returns the network and group layers ordered by kind and name
+func (s *Service) GetConfigLayers(ctx context.Context) ([]domain.ConfigLayer, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []ConfigLayer
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT kind, name, config FROM config_layers ORDER BY kind, name`); err != nil {
+		return nil, err
+	}
+	layers := make([]domain.ConfigLayer, 0, len(rows))
+	for _, row := range rows {
+		layers = append(layers, domain.ConfigLayer{Kind: row.Kind, Name: row.Name, Config: row.Config})
+	}
+	return layers, nil
+}
+
+// SetConfigGroupsForBuilder replaces the group layers of a builder, groups are merged in the given order
+func (s *Service) SetConfigGroupsForBuilder(ctx context.Context, builderName string, groups []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	var exists bool
+	if err = tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM builders WHERE name = $1)`, builderName); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM builder_config_groups WHERE builder_name = $1`, builderName); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO builder_config_groups (builder_name, group_name, position)
+			VALUES ($1, $2, $3)
+		`, builderName, group, i)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetConfigGroupsForBuilder returns the group layers assigned to a builder in merge order
+func (s *Service) GetConfigGroupsForBuilder(ctx context.Context, builderName string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	groups := []string{}
+	err := s.DB.SelectContext(ctx, &groups, `
+		SELECT group_name FROM builder_config_groups
+		WHERE builder_name = $1
+		ORDER BY position
+	`, builderName)
+	return groups, err
+}
+
+func (s *Service) selectRollouts(ctx context.Context, where string, args ...any) ([]domain.Rollout, error) {
+	var rows []ConfigRollout
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT * FROM config_rollouts `+where+` ORDER BY id`, args...); err != nil {
+		return nil, err
+	}
+	res := make([]domain.Rollout, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.toDomain())
+	}
+	return res, nil
+}
+
+// CreateRollout starts a rollout, there can be only one active rollout per layer
+func (s *Service) CreateRollout(ctx context.Context, r domain.Rollout) (*domain.Rollout, error) {
+	if err := domain.ValidRollout(r); err != nil {
+		return nil, err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var row ConfigRollout
+	err := s.DB.GetContext(ctx, &row, `
+		INSERT INTO config_rollouts (layer_kind, layer_name, config, builders, percent)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	`, r.Layer.Kind, r.Layer.Name, []byte(r.Layer.Config), pq.StringArray(append([]string{}, r.Builders...)), r.Percent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollout of %s: %w", r.Layer.ID(), mapError(err))
+	}
+	res := row.toDomain()
+	return &res, nil
+}
+
+// GetRollouts returns all rollouts ordered by ID
+func (s *Service) GetRollouts(ctx context.Context) ([]domain.Rollout, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	return s.selectRollouts(ctx, "")
+}
+
+func (s *Service) GetRollout(ctx context.Context, id int64) (*domain.Rollout, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	rollouts, err := s.selectRollouts(ctx, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rollouts) == 0 {
+		return nil, fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	return &rollouts[0], nil
+}
+
+// PromoteRollout replaces the shared layer with the new version of an active rollout
+func (s *Service) PromoteRollout(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	var row ConfigRollout
+	err = tx.GetContext(ctx, &row, `SELECT * FROM config_rollouts WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if row.Status != domain.RolloutActive {
+		return fmt.Errorf("rollout %d is %s: %w", id, row.Status, domain.ErrConflict)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO config_layers (kind, name, config)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, name) DO UPDATE SET config = excluded.config, updated_at = NOW()
+	`, row.LayerKind, row.LayerName, row.Config)
+	if err != nil {
+		return mapError(err)
+	}
+	if err = finishRollout(ctx, tx, id, domain.RolloutPromoted); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AbortRollout ends an active rollout, the targeted builders get the current layer again
+func (s *Service) AbortRollout(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	if err = finishRollout(ctx, tx, id, domain.RolloutAborted); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func finishRollout(ctx context.Context, tx *sqlx.Tx, id int64, status string) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE config_rollouts SET status = $1, finished_at = NOW()
+		WHERE id = $2 AND status = 'active'
+	`, status, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+	var current string
+	err = tx.GetContext(ctx, &current, `SELECT status FROM config_rollouts WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("rollout %d is %s: %w", id, current, domain.ErrConflict)
+}
+
+// RecordRolloutFetch records that the builder fetched a config with the new layer of the rollout, the first
+// fetch is kept
+func (s *Service) RecordRolloutFetch(ctx context.Context, id int64, builderName string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_rollout_fetches (rollout_id, builder_name) VALUES ($1, $2)
+		ON CONFLICT (rollout_id, builder_name) DO NOTHING
+	`, id, builderName)
+	if err != nil {
+		return fmt.Errorf("failed to record fetch of rollout %d by builder %s: %w", id, builderName, mapError(err))
+	}
+	return nil
+}
+
+// GetRolloutFetches returns the first fetch of the rollout's new layer by each builder
+func (s *Service) GetRolloutFetches(ctx context.Context, id int64) (map[string]time.Time, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []struct {
+		BuilderName string    `db:"builder_name"`
+		FetchedAt   time.Time `db:"fetched_at"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, fetched_at FROM config_rollout_fetches WHERE rollout_id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		res[row.BuilderName] = row.FetchedAt
+	}
+	return res, nil
+}
+
+// SetRotationPolicy creates or updates the rotation policy of a builder secret, the rotation state is kept
+func (s *Service) SetRotationPolicy(ctx context.Context, p domain.RotationPolicy) error {
+	if err := domain.ValidRotationPolicy(p); err != nil {
+		return err
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO secret_rotation_policies (builder_name, key, generator, length, interval_seconds, overlap_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (builder_name, key) DO UPDATE SET
+			generator = excluded.generator, length = excluded.length, interval_seconds = excluded.interval_seconds,
+			overlap_seconds = excluded.overlap_seconds, updated_at = NOW()
+	`, p.BuilderName, p.Key, p.Generator, p.Length, int64(time.Duration(p.Interval)/time.Second), int64(time.Duration(p.Overlap)/time.Second))
+	if err != nil {
+		return fmt.Errorf("failed to set rotation policy of %s %s: %w", p.BuilderName, p.Key, mapError(err))
+	}
+	return nil
+}
+
+func (s *Service) DeleteRotationPolicy(ctx context.Context, builderName, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM secret_rotation_policies WHERE builder_name = $1 AND key = $2`, builderName, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetRotationPolicies returns all rotation policies ordered by builder name and key
+func (s *Service) GetRotationPolicies(ctx context.Context) ([]domain.RotationPolicy, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []SecretRotationPolicy
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, key, generator, length, interval_seconds, overlap_seconds, last_rotated_at, previous_expires_at
+		FROM secret_rotation_policies
+		ORDER BY builder_name, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.RotationPolicy, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.toDomain())
+	}
+	return res, nil
+}
+
+// RecordSecretRotation updates the rotation state of the policy and logs the rotation
+func (s *Service) RecordSecretRotation(ctx context.Context, r domain.SecretRotation) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE secret_rotation_policies SET last_rotated_at = $1, previous_expires_at = $2
+		WHERE builder_name = $3 AND key = $4
+	`, r.RotatedAt, r.PreviousExpiresAt, r.BuilderName, r.Key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", r.BuilderName, r.Key, domain.ErrNotFound)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO secret_rotations (builder_name, key, trigger, rotated_at, previous_expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, r.BuilderName, r.Key, r.Trigger, r.RotatedAt, r.PreviousExpiresAt)
+	if err != nil {
+		return mapError(err)
+	}
+	return tx.Commit()
+}
+
+// ExpirePreviousSecret records that the previous value of a rotated secret was removed
+func (s *Service) ExpirePreviousSecret(ctx context.Context, builderName, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE secret_rotation_policies SET previous_expires_at = NULL
+		WHERE builder_name = $1 AND key = $2
+	`, builderName, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetSecretRotations returns the rotations of a builder's secrets, oldest first
+func (s *Service) GetSecretRotations(ctx context.Context, builderName string) ([]domain.SecretRotation, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	var rows []SecretRotation
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, key, trigger, rotated_at, previous_expires_at
+		FROM secret_rotations
+		WHERE builder_name = $1
+		ORDER BY id
+	`, builderName)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SecretRotation, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, domain.SecretRotation(row))
+	}
+	return res, nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/flashbots/builder-hub/domain"
 	"github.com/jackc/pgtype"
+	"github.com/lib/pq"
 )
 
 type Measurement struct {
@@ -20,6 +21,11 @@ type Measurement struct {
 	DeprecatedAt    *time.Time      `db:"deprecated_at"`
 }
 
+type MeasurementLogEntry struct {
+	LeafIndex int64  `db:"leaf_index"`
+	Data      []byte `db:"data"`
+}
+
 func convertMeasurementToDomain(measurement Measurement) (*domain.Measurement, error) {
 	var m domain.Measurement
 	m.AttestationType = measurement.AttestationType
@@ -110,3 +116,71 @@ func toDomainBuilderWithCredentials(builder BuilderWithCredentials) (*domain.Bui
 	}
 	return &s, nil
 }
+
+type ConfigSchema struct {
+	Scope  string `db:"scope"`
+	Target string `db:"target"`
+	Schema []byte `db:"schema"`
+}
+
+type ConfigLayer struct {
+	Kind   string `db:"kind"`
+	Name   string `db:"name"`
+	Config []byte `db:"config"`
+}
+
+type ConfigRollout struct {
+	ID         int64          `db:"id"`
+	LayerKind  string         `db:"layer_kind"`
+	LayerName  string         `db:"layer_name"`
+	Config     []byte         `db:"config"`
+	Builders   pq.StringArray `db:"builders"`
+	Percent    int            `db:"percent"`
+	Status     string         `db:"status"`
+	CreatedAt  time.Time      `db:"created_at"`
+	FinishedAt *time.Time     `db:"finished_at"`
+}
+
+func (r ConfigRollout) toDomain() domain.Rollout {
+	return domain.Rollout{
+		ID:         r.ID,
+		Layer:      domain.ConfigLayer{Kind: r.LayerKind, Name: r.LayerName, Config: r.Config},
+		Builders:   r.Builders,
+		Percent:    r.Percent,
+		Status:     r.Status,
+		CreatedAt:  r.CreatedAt,
+		FinishedAt: r.FinishedAt,
+	}
+}
+
+type SecretRotationPolicy struct {
+	BuilderName       string     `db:"builder_name"`
+	Key               string     `db:"key"`
+	Generator         string     `db:"generator"`
+	Length            int        `db:"length"`
+	IntervalSeconds   int64      `db:"interval_seconds"`
+	OverlapSeconds    int64      `db:"overlap_seconds"`
+	LastRotatedAt     *time.Time `db:"last_rotated_at"`
+	PreviousExpiresAt *time.Time `db:"previous_expires_at"`
+}
+
+func (p SecretRotationPolicy) toDomain() domain.RotationPolicy {
+	return domain.RotationPolicy{
+		BuilderName:       p.BuilderName,
+		Key:               p.Key,
+		Generator:         p.Generator,
+		Length:            p.Length,
+		Interval:          domain.Duration(time.Duration(p.IntervalSeconds) * time.Second),
+		Overlap:           domain.Duration(time.Duration(p.OverlapSeconds) * time.Second),
+		LastRotatedAt:     p.LastRotatedAt,
+		PreviousExpiresAt: p.PreviousExpiresAt,
+	}
+}
+
+type SecretRotation struct {
+	BuilderName       string     `db:"builder_name"`
+	Key               string     `db:"key"`
+	Trigger           string     `db:"trigger"`
+	RotatedAt         time.Time  `db:"rotated_at"`
+	PreviousExpiresAt *time.Time `db:"previous_expires_at"`
+}
@@ -0,0 +1,239 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// dataKeySize is the size of the AES-256 data and master keys
+const dataKeySize = 32
+
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// MasterKey wraps the data keys of the encrypted secrets. The master key itself never reaches the database.
+type MasterKey interface {
+	// CurrentKeyID is the ID of the key new data keys are wrapped with
+	CurrentKeyID(ctx context.Context) (string, error)
+	// Wrap encrypts a data key with the current key and returns the ID of that key
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped with the key with the ID, ErrUnknownMasterKey if the key is not available
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring is a MasterKey of local AES-256 keys. The first key is current, the others are kept to unwrap data keys
+// until they are re-wrapped.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring parses keys written as id:hex, separated by commas or newlines. Lines starting with # are ignored.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(spec, ",", "\n")))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, keyHex, ok := strings.Cut(line, ":")
+		if !ok || id == "" {
+			return nil, errors.New("master keys must be written as id:hex")
+		}
+		key, err := hex.DecodeString(strings.TrimPrefix(keyHex, "0x"))
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key %s must be %d hex encoded bytes", id, dataKeySize)
+		}
+		if _, ok := k.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate master key %s", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if k.current == "" {
+		return nil, errors.New("no master key")
+	}
+	return k, nil
+}
+
+// LoadKeyring reads a keyring from a file, one id:hex key per line
+func LoadKeyring(path string) (*Keyring, error) {
+	spec, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master keys: %w", err)
+	}
+	return ParseKeyring(string(spec))
+}
+
+func (k *Keyring) CurrentKeyID(context.Context) (string, error) {
+	return k.current, nil
+}
+
+func (k *Keyring) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	return k.current, wrapped, err
+}
+
+func (k *Keyring) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownMasterKey, keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// PluginMasterKey delegates wrapping to an external program, e.g. a client of a KMS. The program is run with the
+// operation as its argument and exchanges JSON on stdin and stdout, byte values are base64 encoded:
+//
+//	current: {} -> {"key_id": "..."}
+//	wrap:    {"plaintext": "..."} -> {"key_id": "...", "ciphertext": "..."}
+//	unwrap:  {"key_id": "...", "ciphertext": "..."} -> {"plaintext": "..."}
+type PluginMasterKey struct {
+	path string
+}
+
+type pluginMessage struct {
+	KeyID      string `json:"key_id,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func NewPluginMasterKey(path string) *PluginMasterKey {
+	return &PluginMasterKey{path: path}
+}
+
+func (p *PluginMasterKey) call(ctx context.Context, op string, req pluginMessage) (*pluginMessage, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path, op)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(in), &stdout, &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("master key plugin %s failed: %w: %s", op, err, strings.TrimSpace(stderr.String()))
+	}
+	res := &pluginMessage{}
+	if err = json.Unmarshal(stdout.Bytes(), res); err != nil {
+		return nil, fmt.Errorf("master key plugin %s returned invalid JSON: %w", op, err)
+	}
+	return res, nil
+}
+
+func (p *PluginMasterKey) CurrentKeyID(ctx context.Context) (string, error) {
+	res, err := p.call(ctx, "current", pluginMessage{})
+	if err != nil {
+		return "", err
+	}
+	if res.KeyID == "" {
+		return "", errors.New("master key plugin returned no key ID")
+	}
+	return res.KeyID, nil
+}
+
+func (p *PluginMasterKey) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	res, err := p.call(ctx, "wrap", pluginMessage{Plaintext: dataKey})
+	if err != nil {
+		return "", nil, err
+	}
+	if res.KeyID == "" || len(res.Ciphertext) == 0 {
+		return "", nil, errors.New("master key plugin returned no wrapped key")
+	}
+	return res.KeyID, res.Ciphertext, nil
+}
+
+func (p *PluginMasterKey) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	res, err := p.call(ctx, "unwrap", pluginMessage{KeyID: keyID, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Plaintext) != dataKeySize {
+		return nil, errors.New("master key plugin returned an invalid data key")
+	}
+	return res.Plaintext, nil
+}
+
+// envelope is a value encrypted with its own data key, the data key is wrapped by the master key
+type envelope struct {
+	MasterKeyID string `db:"master_key_id"`
+	WrappedKey  []byte `db:"wrapped_key"`
+	Ciphertext  []byte `db:"ciphertext"`
+}
+
+// sealEnvelope encrypts the plaintext with a new data key, aad binds the ciphertext to where it is stored
+func sealEnvelope(ctx context.Context, mk MasterKey, plaintext, aad []byte) (*envelope, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(aead, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := mk.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &envelope{MasterKeyID: keyID, WrappedKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+func openEnvelope(ctx context.Context, mk MasterKey, e envelope, aad []byte) ([]byte, error) {
+	dataKey, err := mk.Unwrap(ctx, e.MasterKeyID, e.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, e.Ciphertext, aad)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, aad []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, errors.New("failed to decrypt, the ciphertext or its location was modified")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKeyOld = "old:" + "1111111111111111111111111111111111111111111111111111111111111111"
+	testKeyNew = "new:" + "2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// TestMain runs the test binary as a master key plugin when the tests call it
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_MASTER_KEY_PLUGIN") == "1" {
+		runTestPlugin(os.Args[len(os.Args)-1])
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runTestPlugin(op string) {
+	ctx := context.Background()
+	keyring, _ := ParseKeyring(testKeyNew)
+	var req pluginMessage
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(1)
+	}
+	var res pluginMessage
+	switch op {
+	case "current":
+		res.KeyID, _ = keyring.CurrentKeyID(ctx)
+	case "wrap":
+		res.KeyID, res.Ciphertext, _ = keyring.Wrap(ctx, req.Plaintext)
+	case "unwrap":
+		plaintext, err := keyring.Unwrap(ctx, req.KeyID, req.Ciphertext)
+		if err != nil {
+			_, _ = os.Stderr.WriteString(err.Error())
+			os.Exit(1)
+		}
+		res.Plaintext = plaintext
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func TestParseKeyring(t *testing.T) {
+	k, err := ParseKeyring(testKeyNew + "," + testKeyOld)
+	require.NoError(t, err)
+	current, err := k.CurrentKeyID(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "new", current)
+
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("# rotated 2025-01-01\n"+testKeyNew+"\n\n"+testKeyOld+"\n"), 0o600))
+	k, err = LoadKeyring(path)
+	require.NoError(t, err)
+	require.Len(t, k.keys, 2)
+
+	for _, spec := range []string{"", "1111", "a:1234", testKeyOld + "," + testKeyOld, ":" + strings.Repeat("11", 32)} {
+		_, err = ParseKeyring(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	ctx := context.Background()
+	old, err := ParseKeyring(testKeyOld)
+	require.NoError(t, err)
+	e, err := sealEnvelope(ctx, old, []byte(`{"key": "value"}`), secretAAD(secretKindBuilder, "b-1"))
+	require.NoError(t, err)
+	require.Equal(t, "old", e.MasterKeyID)
+	require.NotContains(t, string(e.Ciphertext), "value")
+
+	plaintext, err := openEnvelope(ctx, old, *e, secretAAD(secretKindBuilder, "b-1"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "value"}`, string(plaintext))
+
+	_, err = openEnvelope(ctx, old, *e, secretAAD(secretKindBuilder, "b-2"))
+	require.Error(t, err, "ciphertexts are bound to their secret")
+	tampered := *e
+	tampered.Ciphertext = append([]byte{}, e.Ciphertext...)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+	_, err = openEnvelope(ctx, old, tampered, secretAAD(secretKindBuilder, "b-1"))
+	require.Error(t, err)
+
+	// after rotation the old key is still needed until the data keys are re-wrapped
+	rotated, err := ParseKeyring(testKeyNew + "," + testKeyOld)
+	require.NoError(t, err)
+	plaintext, err = openEnvelope(ctx, rotated, *e, secretAAD(secretKindBuilder, "b-1"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "value"}`, string(plaintext))
+	newOnly, err := ParseKeyring(testKeyNew)
+	require.NoError(t, err)
+	_, err = openEnvelope(ctx, newOnly, *e, secretAAD(secretKindBuilder, "b-1"))
+	require.ErrorIs(t, err, ErrUnknownMasterKey)
+
+	dataKey, err := rotated.Unwrap(ctx, e.MasterKeyID, e.WrappedKey)
+	require.NoError(t, err)
+	rewrapped := *e
+	rewrapped.MasterKeyID, rewrapped.WrappedKey, err = rotated.Wrap(ctx, dataKey)
+	require.NoError(t, err)
+	require.Equal(t, "new", rewrapped.MasterKeyID)
+	plaintext, err = openEnvelope(ctx, newOnly, rewrapped, secretAAD(secretKindBuilder, "b-1"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "value"}`, string(plaintext))
+}
+
+func TestPluginMasterKey(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEST_MASTER_KEY_PLUGIN", "1")
+	plugin := NewPluginMasterKey(os.Args[0])
+
+	current, err := plugin.CurrentKeyID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "new", current)
+	e, err := sealEnvelope(ctx, plugin, []byte("secret"), []byte("aad"))
+	require.NoError(t, err)
+	require.Equal(t, "new", e.MasterKeyID)
+	plaintext, err := openEnvelope(ctx, plugin, *e, []byte("aad"))
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(plaintext))
+
+	// the plugin and a keyring with the same key are interchangeable
+	keyring, err := ParseKeyring(testKeyNew)
+	require.NoError(t, err)
+	plaintext, err = openEnvelope(ctx, keyring, *e, []byte("aad"))
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(plaintext))
+
+	_, err = plugin.Unwrap(ctx, "old", e.WrappedKey)
+	require.ErrorContains(t, err, "unknown master key")
+}
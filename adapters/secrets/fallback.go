@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// Backend is implemented by all secrets backends, so that they can be chained and migrated between
+type Backend interface {
+	application.SecretAccessor
+	SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error
+	SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error
+	DeleteSharedSecret(ctx context.Context, name string) error
+	ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error)
+	ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error)
+	GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error)
+	RestoreSecretVersion(ctx context.Context, builderName, version string) error
+}
+
+// fallbackSecretsService reads from a primary backend and falls back to a secondary one for secrets the primary
+// doesn't have, e.g. while migrating between backends. All writes go to the primary.
+type fallbackSecretsService struct {
+	primary   Backend
+	secondary Backend
+	log       *slog.Logger
+}
+
+func NewFallbackSecretsService(primary, secondary Backend, log *slog.Logger) *fallbackSecretsService {
+	return &fallbackSecretsService{primary: primary, secondary: secondary, log: log}
+}
+
+// isMissingSecret reports whether a backend has no secrets for a builder. Empty secrets are not missing, otherwise
+// secrets emptied in the primary would be read from the secondary again.
+func isMissingSecret(err error) bool {
+	return errors.Is(err, domain.ErrMissingSecret) || errors.Is(err, domain.ErrNotFound)
+}
+
+func (s *fallbackSecretsService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
+	values, err := s.primary.GetSecretValues(ctx, builderName)
+	if !isMissingSecret(err) {
+		return values, err
+	}
+	fallback, ferr := s.secondary.GetSecretValues(ctx, builderName)
+	if isMissingSecret(ferr) {
+		return values, err
+	}
+	if ferr != nil {
+		return nil, fmt.Errorf("fallback secrets backend: %w", ferr)
+	}
+	s.log.Debug("builder secrets read from fallback backend", "builder", builderName)
+	return fallback, nil
+}
+
+func (s *fallbackSecretsService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
+	return s.primary.SetSecretValues(ctx, builderName, values)
+}
+
+func (s *fallbackSecretsService) GetSharedSecret(ctx context.Context, name string) (*domain.SharedSecret, error) {
+	secret, err := s.primary.GetSharedSecret(ctx, name)
+	if !errors.Is(err, domain.ErrNotFound) {
+		return secret, err
+	}
+	secret, err = s.secondary.GetSharedSecret(ctx, name)
+	if err == nil {
+		s.log.Debug("shared secret read from fallback backend", "name", name)
+	}
+	return secret, err
+}
+
+func (s *fallbackSecretsService) SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error {
+	return s.primary.SetSharedSecret(ctx, secret)
+}
+
+// DeleteSharedSecret deletes the secret from both backends, otherwise the secondary's copy would reappear
+func (s *fallbackSecretsService) DeleteSharedSecret(ctx context.Context, name string) error {
+	perr := s.primary.DeleteSharedSecret(ctx, name)
+	if perr != nil && !errors.Is(perr, domain.ErrNotFound) {
+		return perr
+	}
+	serr := s.secondary.DeleteSharedSecret(ctx, name)
+	if serr != nil && !errors.Is(serr, domain.ErrNotFound) {
+		return fmt.Errorf("fallback secrets backend: %w", serr)
+	}
+	if perr != nil && serr != nil {
+		return perr
+	}
+	return nil
+}
+
+// ListSharedSecrets merges the shared secrets of both backends, the primary's take precedence
+func (s *fallbackSecretsService) ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error) {
+	res, err := s.primary.ListSharedSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err := s.secondary.ListSharedSecrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fallback secrets backend: %w", err)
+	}
+	names := make(map[string]bool, len(res))
+	for _, secret := range res {
+		names[secret.Name] = true
+	}
+	for _, secret := range fallback {
+		if !names[secret.Name] {
+			res = append(res, secret)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// versionsBackend is the backend the versions of a builder's secrets are served from: the primary unless only the
+// secondary has versions. Version IDs aren't comparable between backends, so they aren't merged.
+func (s *fallbackSecretsService) versionsBackend(ctx context.Context, builderName string) (Backend, []domain.SecretVersion, error) {
+	versions, err := s.primary.ListSecretVersions(ctx, builderName)
+	if err != nil || len(versions) > 0 {
+		return s.primary, versions, err
+	}
+	fallback, err := s.secondary.ListSecretVersions(ctx, builderName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fallback secrets backend: %w", err)
+	}
+	if len(fallback) == 0 {
+		return s.primary, versions, nil
+	}
+	return s.secondary, fallback, nil
+}
+
+func (s *fallbackSecretsService) ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error) {
+	_, versions, err := s.versionsBackend(ctx, builderName)
+	return versions, err
+}
+
+func (s *fallbackSecretsService) GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error) {
+	backend, _, err := s.versionsBackend(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+	return backend.GetSecretVersion(ctx, builderName, version)
+}
+
+// RestoreSecretVersion restores a version of the secondary by writing it to the primary
+func (s *fallbackSecretsService) RestoreSecretVersion(ctx context.Context, builderName, version string) error {
+	backend, _, err := s.versionsBackend(ctx, builderName)
+	if err != nil {
+		return err
+	}
+	if backend == s.primary {
+		return s.primary.RestoreSecretVersion(ctx, builderName, version)
+	}
+	values, err := s.secondary.GetSecretVersion(ctx, builderName, version)
+	if err != nil {
+		return err
+	}
+	return s.primary.SetSecretValues(ctx, builderName, values)
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackSecretsService(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := domain.NewMockSecretService(), domain.NewMockSecretService()
+	s := NewFallbackSecretsService(primary, secondary, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	require.NoError(t, secondary.SetSecretValues(ctx, "b-1", json.RawMessage(`{"key": "old"}`)))
+	require.NoError(t, secondary.SetSecretValues(ctx, "b-2", json.RawMessage(`{"key": "old"}`)))
+	require.NoError(t, primary.SetSecretValues(ctx, "b-2", json.RawMessage(`{"key": "new"}`)))
+
+	values, err := s.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "old"}`, string(values))
+	values, err = s.GetSecretValues(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "new"}`, string(values), "the primary takes precedence")
+	_, err = s.GetSecretValues(ctx, "b-3")
+	require.ErrorIs(t, err, domain.ErrMissingSecret)
+
+	// writes go to the primary only
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{"key": "new"}`)))
+	values, err = secondary.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "old"}`, string(values))
+	values, err = s.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "new"}`, string(values))
+	// emptied secrets are not missing, the secondary's aren't used again
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{}`)))
+	values, err = s.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(values))
+
+	// versions come from the backend that has them, restoring a secondary version writes it to the primary
+	require.NoError(t, secondary.SetSecretValues(ctx, "b-4", json.RawMessage(`{"key": "v1"}`)))
+	require.NoError(t, secondary.SetSecretValues(ctx, "b-4", json.RawMessage(`{"key": "v2"}`)))
+	versions, err := s.ListSecretVersions(ctx, "b-4")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.NoError(t, s.RestoreSecretVersion(ctx, "b-4", "1"))
+	values, err = primary.GetSecretValues(ctx, "b-4")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "v1"}`, string(values))
+	versions, err = s.ListSecretVersions(ctx, "b-4")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	require.NoError(t, secondary.SetSharedSecret(ctx, domain.SharedSecret{Name: "a", Value: "old"}))
+	require.NoError(t, secondary.SetSharedSecret(ctx, domain.SharedSecret{Name: "b", Value: "old"}))
+	require.NoError(t, primary.SetSharedSecret(ctx, domain.SharedSecret{Name: "b", Value: "new"}))
+	require.NoError(t, primary.SetSharedSecret(ctx, domain.SharedSecret{Name: "c", Value: "new"}))
+	shared, err := s.ListSharedSecrets(ctx)
+	require.NoError(t, err)
+	require.Len(t, shared, 3)
+	require.Equal(t, []string{"a", "b", "c"}, []string{shared[0].Name, shared[1].Name, shared[2].Name})
+	secret, err := s.GetSharedSecret(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "old", secret.Value)
+	secret, err = s.GetSharedSecret(ctx, "b")
+	require.NoError(t, err)
+	require.Equal(t, "new", secret.Value)
+
+	// deleting removes both copies, so the secondary's doesn't reappear
+	require.NoError(t, s.DeleteSharedSecret(ctx, "b"))
+	_, err = s.GetSharedSecret(ctx, "b")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, s.DeleteSharedSecret(ctx, "a"))
+	require.ErrorIs(t, s.DeleteSharedSecret(ctx, "a"), domain.ErrNotFound)
+}
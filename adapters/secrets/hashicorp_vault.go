@@ -6,7 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/flashbots/builder-hub/domain"
 	vault "github.com/hashicorp/vault/api"
 	authkubernetes "github.com/hashicorp/vault/api/auth/kubernetes"
 )
@@ -15,40 +19,65 @@ type hashicorpVaultService struct {
 	client     *vault.Client
 	secretPath string
 	mountPath  string
+	kvVersion  int
 	log        *slog.Logger
 }
 
 type VaultConfig struct {
 	Address       string // Vault server address (e.g., http://localhost:8200)
 	Token         string // Vault token for authentication (used when AuthMethod=="token")
+	Namespace     string // Vault Enterprise namespace (optional)
 	SecretPrefix  string // Path prefix for secrets (e.g., "secrets/builder-hub")
-	MountPath     string // Vault KV v2 mount path (e.g., "secret", defaults to "secret")
-	AuthMethod    string // "token" (default), "kubernetes", or "jwt"
-	AuthMountPath string // Vault auth mount path for Kubernetes/JWT auth (e.g., "k8s/eth-l1-prod"); defaults to "kubernetes" for k8s auth, "jwt" for JWT auth
-	Role          string // Role name for Kubernetes/JWT auth (required if AuthMethod is "kubernetes" or "jwt")
+	MountPath     string // Vault KV mount path (e.g., "secret", defaults to "secret")
+	KVVersion     int    // KV engine version of the mount, 1 or 2 (default)
+	AuthMethod    string // "token" (default), "kubernetes", "jwt", "approle" or "cert"
+	AuthMountPath string // Vault auth mount path (e.g., "k8s/eth-l1-prod"); defaults to the name of the auth method
+	Role          string // Role name for Kubernetes/JWT auth (required), certificate role for cert auth (optional)
 	Jwt           string // ServiceAccount JWT for Kubernetes/JWT auth (required if AuthMethod is "kubernetes" or "jwt")
+	RoleID        string // AppRole role ID (required if AuthMethod is "approle")
+	SecretID      string // AppRole secret ID (required if AuthMethod is "approle")
+	CACert        string // PEM file of the CA of the Vault server (optional)
+	ClientCert    string // PEM file of the client certificate (required if AuthMethod is "cert")
+	ClientKey     string // PEM file of the client key (required if AuthMethod is "cert")
 }
 
 func NewHashicorpVaultService(ctx context.Context, log *slog.Logger, cfg VaultConfig) (*hashicorpVaultService, error) {
 	if cfg.MountPath == "" {
 		cfg.MountPath = "secret"
 	}
+	if cfg.KVVersion == 0 {
+		cfg.KVVersion = 2
+	}
+	if cfg.KVVersion != 1 && cfg.KVVersion != 2 {
+		return nil, fmt.Errorf("unsupported KV version %d", cfg.KVVersion)
+	}
 
-	if cfg.AuthMethod != "token" && cfg.AuthMethod != "kubernetes" && cfg.AuthMethod != "jwt" && cfg.AuthMethod != "" {
+	switch cfg.AuthMethod {
+	case "", "token", "kubernetes", "jwt", "approle", "cert":
+	default:
 		return nil, fmt.Errorf("unsupported AuthMethod %s", cfg.AuthMethod)
 	}
 
 	vcfg := vault.DefaultConfig()
 	vcfg.Address = cfg.Address
+	if cfg.CACert != "" || cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if err := vcfg.ConfigureTLS(&vault.TLSConfig{CACert: cfg.CACert, ClientCert: cfg.ClientCert, ClientKey: cfg.ClientKey}); err != nil {
+			return nil, fmt.Errorf("failed to configure Vault TLS: %w", err)
+		}
+	}
 	client, err := vault.NewClient(vcfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Vault client: %w", err)
 	}
+	if cfg.Namespace != "" {
+		client.SetNamespace(cfg.Namespace)
+	}
 
 	svc := &hashicorpVaultService{
 		client:     client,
 		secretPath: cfg.SecretPrefix,
 		mountPath:  cfg.MountPath,
+		kvVersion:  cfg.KVVersion,
 		log:        log,
 	}
 
@@ -82,26 +111,35 @@ func NewHashicorpVaultService(ctx context.Context, log *slog.Logger, cfg VaultCo
 		if cfg.Role == "" {
 			return nil, fmt.Errorf("role is required for JWT auth")
 		}
-		mount := cfg.AuthMountPath
-		if mount == "" {
-			mount = "jwt"
-		}
-		authInfo, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mount), map[string]interface{}{
+		if err := svc.login(ctx, "JWT", authMount(cfg, "jwt"), map[string]interface{}{
 			"role": cfg.Role,
 			"jwt":  cfg.Jwt,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("JWT auth failed: %w", err)
+		}); err != nil {
+			return nil, err
 		}
-		if authInfo == nil || authInfo.Auth == nil {
-			return nil, fmt.Errorf("JWT auth returned no authentication info")
+
+	case "approle":
+		if cfg.RoleID == "" || cfg.SecretID == "" {
+			return nil, fmt.Errorf("role ID and secret ID are required for AppRole auth")
 		}
-		client.SetToken(authInfo.Auth.ClientToken)
-		watcher, err := client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{Secret: authInfo})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create token lifetime watcher: %w", err)
+		if err := svc.login(ctx, "AppRole", authMount(cfg, "approle"), map[string]interface{}{
+			"role_id":   cfg.RoleID,
+			"secret_id": cfg.SecretID,
+		}); err != nil {
+			return nil, err
+		}
+
+	case "cert":
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key are required for TLS cert auth")
+		}
+		data := map[string]interface{}{}
+		if cfg.Role != "" {
+			data["name"] = cfg.Role
+		}
+		if err := svc.login(ctx, "TLS cert", authMount(cfg, "cert"), data); err != nil {
+			return nil, err
 		}
-		go svc.watchTokenRenewal(ctx, watcher)
 
 	default:
 		if cfg.Token == "" {
@@ -113,6 +151,31 @@ func NewHashicorpVaultService(ctx context.Context, log *slog.Logger, cfg VaultCo
 	return svc, nil
 }
 
+func authMount(cfg VaultConfig, method string) string {
+	if cfg.AuthMountPath != "" {
+		return cfg.AuthMountPath
+	}
+	return method
+}
+
+// login authenticates at auth/<mount>/login and keeps the token renewed
+func (s *hashicorpVaultService) login(ctx context.Context, method, mount string, data map[string]interface{}) error {
+	authInfo, err := s.client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mount), data)
+	if err != nil {
+		return fmt.Errorf("%s auth failed: %w", method, err)
+	}
+	if authInfo == nil || authInfo.Auth == nil {
+		return fmt.Errorf("%s auth returned no authentication info", method)
+	}
+	s.client.SetToken(authInfo.Auth.ClientToken)
+	watcher, err := s.client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{Secret: authInfo})
+	if err != nil {
+		return fmt.Errorf("failed to create token lifetime watcher: %w", err)
+	}
+	go s.watchTokenRenewal(ctx, watcher)
+	return nil
+}
+
 func (s *hashicorpVaultService) watchTokenRenewal(ctx context.Context, watcher *vault.LifetimeWatcher) {
 	go watcher.Start()
 	defer watcher.Stop()
@@ -134,7 +197,8 @@ func (s *hashicorpVaultService) watchTokenRenewal(ctx context.Context, watcher *
 
 func isVault404(err error) bool {
 	var responseErr *vault.ResponseError
-	return errors.As(err, &responseErr) && responseErr.StatusCode == 404
+	// the KV clients report missing secrets as ErrSecretNotFound
+	return errors.Is(err, vault.ErrSecretNotFound) || (errors.As(err, &responseErr) && responseErr.StatusCode == 404)
 }
 
 func (s *hashicorpVaultService) secretKVPath(builderName string) string {
@@ -144,24 +208,71 @@ func (s *hashicorpVaultService) secretKVPath(builderName string) string {
 	return fmt.Sprintf("%s/%s", s.secretPath, builderName)
 }
 
-// GetSecretValues retrieves secrets for a specific builder from Vault KV v2.
+// kvGet reads the data of a KV secret, nil if there is none
+func (s *hashicorpVaultService) kvGet(ctx context.Context, path string) (map[string]interface{}, error) {
+	var secret *vault.KVSecret
+	var err error
+	if s.kvVersion == 1 {
+		secret, err = s.client.KVv1(s.mountPath).Get(ctx, path)
+	} else {
+		secret, err = s.client.KVv2(s.mountPath).Get(ctx, path)
+	}
+	if err != nil {
+		if isVault404(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if secret == nil {
+		return nil, nil
+	}
+	return secret.Data, nil
+}
+
+func (s *hashicorpVaultService) kvPut(ctx context.Context, path string, data map[string]interface{}) error {
+	if s.kvVersion == 1 {
+		return s.client.KVv1(s.mountPath).Put(ctx, path, data)
+	}
+	_, err := s.client.KVv2(s.mountPath).Put(ctx, path, data)
+	return err
+}
+
+// kvDelete removes a KV secret with all its versions
+func (s *hashicorpVaultService) kvDelete(ctx context.Context, path string) error {
+	if s.kvVersion == 1 {
+		return s.client.KVv1(s.mountPath).Delete(ctx, path)
+	}
+	return s.client.KVv2(s.mountPath).DeleteMetadata(ctx, path)
+}
+
+// kvListPath is the API path listing the KV secrets in a folder
+func (s *hashicorpVaultService) kvListPath(dir string) string {
+	if s.kvVersion == 1 {
+		return s.mountPath + "/" + dir
+	}
+	return s.mountPath + "/metadata/" + dir
+}
+
+// errNoVersions is returned for version requests on KV v1 mounts
+func errNoVersions(builderName, version string) error {
+	return fmt.Errorf("secret version %s of builder %s: KV v1 keeps no versions: %w", version, builderName, domain.ErrNotFound)
+}
+
+// GetSecretValues retrieves secrets for a specific builder from Vault KV.
 // Implements application.SecretAccessor interface.
 func (s *hashicorpVaultService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
 	path := s.secretKVPath(builderName)
 
-	secret, err := s.client.KVv2(s.mountPath).Get(ctx, path)
+	data, err := s.kvGet(ctx, path)
 	if err != nil {
-		if isVault404(err) {
-			return json.RawMessage("{}"), nil
-		}
 		return nil, fmt.Errorf("failed to read secret from Vault: %w", err)
 	}
 
-	if secret == nil || secret.Data == nil {
-		return json.RawMessage("{}"), nil
+	if data == nil {
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrMissingSecret)
 	}
 
-	secretJSON, err := json.Marshal(secret.Data)
+	secretJSON, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal Vault secret: %w", err)
 	}
@@ -169,7 +280,7 @@ func (s *hashicorpVaultService) GetSecretValues(ctx context.Context, builderName
 	return json.RawMessage(secretJSON), nil
 }
 
-// SetSecretValues stores secrets for a specific builder in Vault KV v2.
+// SetSecretValues stores secrets for a specific builder in Vault KV.
 // Implements ports.AdminSecretService interface.
 func (s *hashicorpVaultService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
 	path := s.secretKVPath(builderName)
@@ -179,10 +290,170 @@ func (s *hashicorpVaultService) SetSecretValues(ctx context.Context, builderName
 		return fmt.Errorf("failed to unmarshal secret values: %w", err)
 	}
 
-	_, err := s.client.KVv2(s.mountPath).Put(ctx, path, dataMap)
-	if err != nil {
+	if err := s.kvPut(ctx, path, dataMap); err != nil {
 		return fmt.Errorf("failed to write secret to Vault: %w", err)
 	}
 
 	return nil
 }
+
+// ListSecretVersions returns the KV v2 versions of a builder's secrets, newest first. KV v1 keeps no versions.
+func (s *hashicorpVaultService) ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error) {
+	if s.kvVersion == 1 {
+		return []domain.SecretVersion{}, nil
+	}
+	metadata, err := s.client.KVv2(s.mountPath).GetMetadata(ctx, s.secretKVPath(builderName))
+	if err != nil {
+		if isVault404(err) {
+			return []domain.SecretVersion{}, nil
+		}
+		return nil, fmt.Errorf("failed to read secret versions from Vault: %w", err)
+	}
+	res := make([]domain.SecretVersion, 0, len(metadata.Versions))
+	for _, v := range metadata.Versions {
+		res = append(res, domain.SecretVersion{
+			Version:   strconv.Itoa(v.Version),
+			CreatedAt: v.CreatedTime,
+			Current:   v.Version == metadata.CurrentVersion,
+			Deleted:   v.Destroyed || !v.DeletionTime.IsZero(),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	return res, nil
+}
+
+// GetSecretVersion reads a KV v2 version of a builder's secrets, ErrNotFound if it doesn't exist or was deleted
+func (s *hashicorpVaultService) GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error) {
+	if s.kvVersion == 1 {
+		return nil, errNoVersions(builderName, version)
+	}
+	n, err := vaultVersion(builderName, version)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.client.KVv2(s.mountPath).GetVersion(ctx, s.secretKVPath(builderName), n)
+	if err != nil {
+		if isVault404(err) {
+			return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read secret version from Vault: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, domain.ErrNotFound)
+	}
+	return json.Marshal(secret.Data)
+}
+
+// RestoreSecretVersion writes an earlier KV v2 version as the new current version, the versions in between are kept
+func (s *hashicorpVaultService) RestoreSecretVersion(ctx context.Context, builderName, version string) error {
+	if s.kvVersion == 1 {
+		return errNoVersions(builderName, version)
+	}
+	n, err := vaultVersion(builderName, version)
+	if err != nil {
+		return err
+	}
+	if _, err = s.GetSecretVersion(ctx, builderName, version); err != nil {
+		return err
+	}
+	if _, err = s.client.KVv2(s.mountPath).Rollback(ctx, s.secretKVPath(builderName), n); err != nil {
+		return fmt.Errorf("failed to restore secret version in Vault: %w", err)
+	}
+	return nil
+}
+
+func vaultVersion(builderName, version string) (int, error) {
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, domain.ErrNotFound)
+	}
+	return n, nil
+}
+
+// sharedKVPath is where the shared secret referenced as shared/name is stored, next to the builder secrets
+func (s *hashicorpVaultService) sharedKVPath(name string) string {
+	return s.secretKVPath(strings.TrimSuffix(domain.SharedSecretNamespace, "/")) + "/" + name
+}
+
+// GetSharedSecret reads a shared secret, stored as {"value": ..., "policy": {...}}
+func (s *hashicorpVaultService) GetSharedSecret(ctx context.Context, name string) (*domain.SharedSecret, error) {
+	data, err := s.kvGet(ctx, s.sharedKVPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared secret from Vault: %w", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("shared secret %s: %w", name, domain.ErrNotFound)
+	}
+	bts, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Vault secret: %w", err)
+	}
+	res := &domain.SharedSecret{Name: name}
+	if err = json.Unmarshal(bts, res); err != nil {
+		return nil, fmt.Errorf("failed to decode shared secret %s: %w", name, err)
+	}
+	res.Name = name
+	return res, nil
+}
+
+func (s *hashicorpVaultService) SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error {
+	if err := domain.ValidSharedSecretName(secret.Name); err != nil {
+		return err
+	}
+	policy, err := json.Marshal(secret.Policy)
+	if err != nil {
+		return err
+	}
+	var policyMap map[string]any
+	if err = json.Unmarshal(policy, &policyMap); err != nil {
+		return err
+	}
+	err = s.kvPut(ctx, s.sharedKVPath(secret.Name), map[string]any{
+		"value":  secret.Value,
+		"policy": policyMap,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write shared secret to Vault: %w", err)
+	}
+	return nil
+}
+
+// DeleteSharedSecret removes all versions of a shared secret
+func (s *hashicorpVaultService) DeleteSharedSecret(ctx context.Context, name string) error {
+	if _, err := s.GetSharedSecret(ctx, name); err != nil {
+		return err
+	}
+	if err := s.kvDelete(ctx, s.sharedKVPath(name)); err != nil {
+		return fmt.Errorf("failed to delete shared secret from Vault: %w", err)
+	}
+	return nil
+}
+
+// ListSharedSecrets returns the shared secrets without their values ordered by name
+func (s *hashicorpVaultService) ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error) {
+	dir := s.secretKVPath(strings.TrimSuffix(domain.SharedSecretNamespace, "/"))
+	list, err := s.client.Logical().ListWithContext(ctx, s.kvListPath(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shared secrets in Vault: %w", err)
+	}
+	res := []domain.SharedSecret{}
+	if list == nil || list.Data == nil {
+		return res, nil
+	}
+	keys, _ := list.Data["keys"].([]any)
+	for _, key := range keys {
+		name, ok := key.(string)
+		// folders end with a slash
+		if !ok || strings.HasSuffix(name, "/") {
+			continue
+		}
+		secret, err := s.GetSharedSecret(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		secret.Value = ""
+		res = append(res, *secret)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
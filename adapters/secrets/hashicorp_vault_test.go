@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/signing"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault serves AppRole login, a KV v1 mount at kv/ and a Transit key at transit/keys/hub
+type fakeVault struct {
+	t   *testing.T
+	key ed25519.PrivateKey
+	mu  sync.Mutex
+	kv  map[string]json.RawMessage
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	write := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(f.t, json.NewEncoder(w).Encode(v))
+	}
+	if path == "auth/approle/login" {
+		var req map[string]string
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+		if req["role_id"] != "role" || req["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		write(map[string]any{"auth": map[string]any{"client_token": "approle-token", "renewable": false}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != "approle-token" || r.Header.Get("X-Vault-Namespace") != "team/" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case path == "transit/keys/hub":
+		write(map[string]any{"data": map[string]any{
+			"type":           "ed25519",
+			"latest_version": 2,
+			"keys": map[string]any{
+				"1": map[string]any{"public_key": "old"},
+				"2": map[string]any{"public_key": base64.StdEncoding.EncodeToString(f.key.Public().(ed25519.PublicKey))},
+			},
+		}})
+	case path == "transit/sign/hub":
+		var req struct {
+			Input      string `json:"input"`
+			KeyVersion int    `json:"key_version"`
+		}
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(f.t, 2, req.KeyVersion)
+		input, err := base64.StdEncoding.DecodeString(req.Input)
+		require.NoError(f.t, err)
+		write(map[string]any{"data": map[string]any{"signature": "vault:v2:" + base64.StdEncoding.EncodeToString(ed25519.Sign(f.key, input))}})
+	case strings.HasPrefix(path, "kv/"):
+		key := strings.TrimPrefix(path, "kv/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("list") == "true":
+			var keys []string
+			for k := range f.kv {
+				if name, ok := strings.CutPrefix(k, key+"/"); ok {
+					keys = append(keys, name)
+				}
+			}
+			write(map[string]any{"data": map[string]any{"keys": keys}})
+		case r.Method == http.MethodGet:
+			data, ok := f.kv[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			write(map[string]any{"data": data})
+		case r.Method == http.MethodPut || r.Method == http.MethodPost:
+			bts, err := io.ReadAll(r.Body)
+			require.NoError(f.t, err)
+			f.kv[key] = bts
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			delete(f.kv, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestVaultAppRoleKVv1AndTransit(t *testing.T) {
+	ctx := context.Background()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	server := httptest.NewServer(&fakeVault{t: t, key: key, kv: make(map[string]json.RawMessage)})
+	defer server.Close()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cfg := VaultConfig{
+		Address:      server.URL,
+		Namespace:    "team/",
+		SecretPrefix: "builder-hub",
+		MountPath:    "kv",
+		KVVersion:    1,
+		AuthMethod:   "approle",
+		RoleID:       "role",
+		SecretID:     "wrong",
+	}
+	_, err = NewHashicorpVaultService(ctx, log, cfg)
+	require.ErrorContains(t, err, "AppRole auth failed")
+	cfg.SecretID = "secret"
+	s, err := NewHashicorpVaultService(ctx, log, cfg)
+	require.NoError(t, err)
+
+	_, err = s.GetSecretValues(ctx, "b-1")
+	require.ErrorIs(t, err, domain.ErrMissingSecret)
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "v1"}}`)))
+	values, err := s.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "v1"}}`, string(values))
+
+	versions, err := s.ListSecretVersions(ctx, "b-1")
+	require.NoError(t, err)
+	require.Empty(t, versions)
+	_, err = s.GetSecretVersion(ctx, "b-1", "1")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, s.SetSharedSecret(ctx, domain.SharedSecret{Name: "relay", Value: "s", Policy: domain.SecretPolicy{Networks: []string{"testnet"}}}))
+	shared, err := s.ListSharedSecrets(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []domain.SharedSecret{{Name: "relay", Policy: domain.SecretPolicy{Networks: []string{"testnet"}}}}, shared)
+	require.NoError(t, s.DeleteSharedSecret(ctx, "relay"))
+	require.ErrorIs(t, s.DeleteSharedSecret(ctx, "relay"), domain.ErrNotFound)
+
+	signer, err := s.TransitSigner(ctx, "", "hub")
+	require.NoError(t, err)
+	require.Equal(t, signing.AlgorithmEd25519, signer.Algorithm())
+	require.Equal(t, []byte(key.Public().(ed25519.PublicKey)), signer.PublicKey())
+	sig, err := signer.Sign(ctx, []byte("measurements"))
+	require.NoError(t, err)
+	require.NoError(t, signing.Verify(signer.Algorithm(), signer.PublicKey(), []byte("measurements"), sig))
+}
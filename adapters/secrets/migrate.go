@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// Actions of a migration
+const (
+	MigrationCopied    = "copied"
+	MigrationUnchanged = "unchanged"
+	MigrationMissing   = "missing"
+	MigrationConflict  = "conflict"
+)
+
+var ErrMigrationVerification = errors.New("migrated secret differs from the source")
+
+// MigrationResult is what a migration did, or would do in a dry run, with a secret. Values are never included.
+type MigrationResult struct {
+	Target string `json:"target"`
+	Action string `json:"action"`
+}
+
+type MigrateOptions struct {
+	// DryRun only reports what would be copied
+	DryRun bool
+	// Overwrite replaces secrets that differ in the destination, they are conflicts otherwise
+	Overwrite bool
+}
+
+// Migrate copies the secrets of the builders and all shared secrets from one backend to another. Every copied secret
+// is read back from the destination and compared to the source. Secrets the destination already has with other
+// values are reported as conflicts and left alone, unless opts.Overwrite is set.
+func Migrate(ctx context.Context, from, to Backend, builderNames []string, opts MigrateOptions) ([]MigrationResult, error) {
+	res := make([]MigrationResult, 0, len(builderNames))
+	for _, name := range builderNames {
+		target := "builder " + name
+		values, err := from.GetSecretValues(ctx, name)
+		if isMissingSecret(err) {
+			res = append(res, MigrationResult{Target: target, Action: MigrationMissing})
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("failed to read secrets of %s: %w", target, err)
+		}
+		current, err := to.GetSecretValues(ctx, name)
+		missing := isMissingSecret(err)
+		if err != nil && !missing {
+			return res, fmt.Errorf("failed to read destination secrets of %s: %w", target, err)
+		}
+		action := migrationAction(!missing && application.SameJSON(values, current), missing, opts.Overwrite)
+		res = append(res, MigrationResult{Target: target, Action: action})
+		if action != MigrationCopied || opts.DryRun {
+			continue
+		}
+		if err = to.SetSecretValues(ctx, name, values); err != nil {
+			return res, fmt.Errorf("failed to write secrets of %s: %w", target, err)
+		}
+		if written, err := to.GetSecretValues(ctx, name); err != nil || !application.SameJSON(values, written) {
+			return res, fmt.Errorf("%s: %w", target, errors.Join(ErrMigrationVerification, err))
+		}
+	}
+
+	shared, err := from.ListSharedSecrets(ctx)
+	if err != nil {
+		return res, fmt.Errorf("failed to list shared secrets: %w", err)
+	}
+	for _, listed := range shared {
+		target := "shared secret " + listed.Name
+		secret, err := from.GetSharedSecret(ctx, listed.Name)
+		if err != nil {
+			return res, fmt.Errorf("failed to read %s: %w", target, err)
+		}
+		current, err := to.GetSharedSecret(ctx, listed.Name)
+		missing := errors.Is(err, domain.ErrNotFound)
+		if err != nil && !missing {
+			return res, fmt.Errorf("failed to read destination %s: %w", target, err)
+		}
+		action := migrationAction(!missing && sameSharedSecret(secret, current), missing, opts.Overwrite)
+		res = append(res, MigrationResult{Target: target, Action: action})
+		if action != MigrationCopied || opts.DryRun {
+			continue
+		}
+		if err = to.SetSharedSecret(ctx, *secret); err != nil {
+			return res, fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		if written, err := to.GetSharedSecret(ctx, listed.Name); err != nil || !sameSharedSecret(secret, written) {
+			return res, fmt.Errorf("%s: %w", target, errors.Join(ErrMigrationVerification, err))
+		}
+	}
+	return res, nil
+}
+
+func migrationAction(same, missing, overwrite bool) string {
+	switch {
+	case same:
+		return MigrationUnchanged
+	case missing || overwrite:
+		return MigrationCopied
+	default:
+		return MigrationConflict
+	}
+}
+
+// sameSharedSecret compares shared secrets by their JSON encoding, backends may decode empty policies differently
+func sameSharedSecret(a, b *domain.SharedSecret) bool {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return application.SameJSON(ja, jb)
+}
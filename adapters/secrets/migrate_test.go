@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// lossyBackend drops writes, to test the verification of migrated secrets
+type lossyBackend struct {
+	*domain.InmemorySecretService
+}
+
+func (lossyBackend) SetSecretValues(context.Context, string, json.RawMessage) error {
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	from, to := domain.NewMockSecretService(), domain.NewMockSecretService()
+	require.NoError(t, from.SetSecretValues(ctx, "b-1", json.RawMessage(`{"key": "v1"}`)))
+	require.NoError(t, from.SetSecretValues(ctx, "b-2", json.RawMessage(`{"a": 1, "b": 2}`)))
+	require.NoError(t, from.SetSecretValues(ctx, "b-3", json.RawMessage(`{"key": "v3"}`)))
+	require.NoError(t, from.SetSharedSecret(ctx, domain.SharedSecret{Name: "relay", Value: "s", Policy: domain.SecretPolicy{Networks: []string{"testnet"}}}))
+	require.NoError(t, to.SetSecretValues(ctx, "b-2", json.RawMessage(`{"b": 2, "a": 1}`)))
+	require.NoError(t, to.SetSecretValues(ctx, "b-3", json.RawMessage(`{"key": "newer"}`)))
+	builders := []string{"b-1", "b-2", "b-3", "b-4"}
+
+	expected := []MigrationResult{
+		{Target: "builder b-1", Action: MigrationCopied},
+		{Target: "builder b-2", Action: MigrationUnchanged},
+		{Target: "builder b-3", Action: MigrationConflict},
+		{Target: "builder b-4", Action: MigrationMissing},
+		{Target: "shared secret relay", Action: MigrationCopied},
+	}
+	res, err := Migrate(ctx, from, to, builders, MigrateOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+	_, err = to.GetSecretValues(ctx, "b-1")
+	require.ErrorIs(t, err, domain.ErrMissingSecret, "dry runs don't write")
+
+	res, err = Migrate(ctx, from, to, builders, MigrateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+	values, err := to.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "v1"}`, string(values))
+	values, err = to.GetSecretValues(ctx, "b-3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "newer"}`, string(values), "conflicts are left alone")
+	secret, err := to.GetSharedSecret(ctx, "relay")
+	require.NoError(t, err)
+	require.Equal(t, "s", secret.Value)
+
+	res, err = Migrate(ctx, from, to, builders, MigrateOptions{Overwrite: true})
+	require.NoError(t, err)
+	require.Equal(t, MigrationCopied, res[2].Action)
+	require.Equal(t, MigrationUnchanged, res[4].Action)
+	values, err = to.GetSecretValues(ctx, "b-3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "v3"}`, string(values))
+
+	_, err = Migrate(ctx, from, lossyBackend{domain.NewMockSecretService()}, builders, MigrateOptions{})
+	require.ErrorIs(t, err, ErrMigrationVerification)
+}
@@ -0,0 +1,266 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// kinds of encrypted secrets
+const (
+	secretKindBuilder = "builder"
+	secretKindShared  = "shared"
+)
+
+// DefaultMaxSecretVersions is the number of versions kept per secret, older versions are removed on write
+const DefaultMaxSecretVersions = 20
+
+// postgresSecretsService stores envelope encrypted secrets in the hub's Postgres database, so that small
+// deployments don't need Vault or AWS Secrets Manager
+type postgresSecretsService struct {
+	db          *sqlx.DB
+	masterKey   MasterKey
+	maxVersions int
+}
+
+type encryptedSecret struct {
+	Version   int       `db:"version"`
+	CreatedAt time.Time `db:"created_at"`
+	envelope
+}
+
+func NewPostgresSecretsService(db *sqlx.DB, masterKey MasterKey, maxVersions int) *postgresSecretsService {
+	if maxVersions <= 0 {
+		maxVersions = DefaultMaxSecretVersions
+	}
+	return &postgresSecretsService{db: db, masterKey: masterKey, maxVersions: maxVersions}
+}
+
+// secretAAD binds a ciphertext to the secret it belongs to, so that ciphertexts can't be swapped between rows
+func secretAAD(kind, name string) []byte {
+	return []byte(kind + "/" + name)
+}
+
+// read decrypts a version of a secret, the latest if version is 0
+func (s *postgresSecretsService) read(ctx context.Context, kind, name string, version int) ([]byte, error) {
+	var row encryptedSecret
+	err := s.db.GetContext(ctx, &row, `
+		SELECT version, created_at, master_key_id, wrapped_key, ciphertext FROM encrypted_secrets
+		WHERE kind = $1 AND name = $2 AND ($3 = 0 OR version = $3)
+		ORDER BY version DESC
+		LIMIT 1
+	`, kind, name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openEnvelope(ctx, s.masterKey, row.envelope, secretAAD(kind, name))
+}
+
+// write encrypts a new version of a secret and removes the versions beyond maxVersions
+func (s *postgresSecretsService) write(ctx context.Context, kind, name string, plaintext []byte) error {
+	e, err := sealEnvelope(ctx, s.masterKey, plaintext, secretAAD(kind, name))
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	var version int
+	err = tx.GetContext(ctx, &version, `
+		INSERT INTO encrypted_secrets (kind, name, version, master_key_id, wrapped_key, ciphertext)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, $3, $4, $5 FROM encrypted_secrets WHERE kind = $1 AND name = $2
+		RETURNING version
+	`, kind, name, e.MasterKeyID, e.WrappedKey, e.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM encrypted_secrets WHERE kind = $1 AND name = $2 AND version <= $3
+	`, kind, name, version-s.maxVersions)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSecretValues returns the latest secrets of a builder, ErrMissingSecret if there are none
+func (s *postgresSecretsService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
+	values, err := s.read(ctx, secretKindBuilder, builderName, 0)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrMissingSecret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets of builder %s: %w", builderName, err)
+	}
+	return values, nil
+}
+
+// SetSecretValues stores a new version of a builder's secrets
+func (s *postgresSecretsService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
+	if !json.Valid(values) {
+		return fmt.Errorf("%w secret values of builder %s", domain.ErrValidation, builderName)
+	}
+	return s.write(ctx, secretKindBuilder, builderName, values)
+}
+
+// ListSecretVersions returns the stored versions of a builder's secrets, newest first
+func (s *postgresSecretsService) ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error) {
+	var rows []encryptedSecret
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT version, created_at FROM encrypted_secrets
+		WHERE kind = $1 AND name = $2
+		ORDER BY version DESC
+	`, secretKindBuilder, builderName)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SecretVersion, 0, len(rows))
+	for i, row := range rows {
+		res = append(res, domain.SecretVersion{Version: strconv.Itoa(row.Version), CreatedAt: row.CreatedAt, Current: i == 0})
+	}
+	return res, nil
+}
+
+func (s *postgresSecretsService) GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error) {
+	n, err := strconv.Atoi(version)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, domain.ErrNotFound)
+	}
+	values, err := s.read(ctx, secretKindBuilder, builderName, n)
+	if err != nil {
+		return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, err)
+	}
+	return values, nil
+}
+
+// RestoreSecretVersion stores an earlier version again as the latest one
+func (s *postgresSecretsService) RestoreSecretVersion(ctx context.Context, builderName, version string) error {
+	values, err := s.GetSecretVersion(ctx, builderName, version)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, secretKindBuilder, builderName, values)
+}
+
+// GetSharedSecret decrypts a shared secret, stored as {"value": ..., "policy": {...}}
+func (s *postgresSecretsService) GetSharedSecret(ctx context.Context, name string) (*domain.SharedSecret, error) {
+	bts, err := s.read(ctx, secretKindShared, name, 0)
+	if err != nil {
+		return nil, fmt.Errorf("shared secret %s: %w", name, err)
+	}
+	res := &domain.SharedSecret{}
+	if err = json.Unmarshal(bts, res); err != nil {
+		return nil, fmt.Errorf("failed to decode shared secret %s: %w", name, err)
+	}
+	res.Name = name
+	return res, nil
+}
+
+func (s *postgresSecretsService) SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error {
+	if err := domain.ValidSharedSecretName(secret.Name); err != nil {
+		return err
+	}
+	bts, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, secretKindShared, secret.Name, bts)
+}
+
+// DeleteSharedSecret removes all versions of a shared secret
+func (s *postgresSecretsService) DeleteSharedSecret(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM encrypted_secrets WHERE kind = $1 AND name = $2`, secretKindShared, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("shared secret %s: %w", name, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// ListSharedSecrets returns the shared secrets without their values ordered by name
+func (s *postgresSecretsService) ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error) {
+	var names []string
+	err := s.db.SelectContext(ctx, &names, `
+		SELECT DISTINCT name FROM encrypted_secrets WHERE kind = $1 ORDER BY name
+	`, secretKindShared)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SharedSecret, 0, len(names))
+	for _, name := range names {
+		secret, err := s.GetSharedSecret(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		secret.Value = ""
+		res = append(res, *secret)
+	}
+	return res, nil
+}
+
+// Rewrap wraps the data keys of all secret versions that aren't wrapped with the current master key again with
+// it, so that retired master keys can be removed. The secrets themselves are not re-encrypted. It returns the
+// number of re-wrapped data keys.
+func (s *postgresSecretsService) Rewrap(ctx context.Context) (int, error) {
+	current, err := s.masterKey.CurrentKeyID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var rows []struct {
+		Kind    string `db:"kind"`
+		Name    string `db:"name"`
+		Version int    `db:"version"`
+		envelope
+	}
+	err = s.db.SelectContext(ctx, &rows, `
+		SELECT kind, name, version, master_key_id, wrapped_key, ciphertext FROM encrypted_secrets
+		WHERE master_key_id <> $1
+	`, current)
+	if err != nil {
+		return 0, err
+	}
+	rewrapped := 0
+	for _, row := range rows {
+		dataKey, err := s.masterKey.Unwrap(ctx, row.MasterKeyID, row.WrappedKey)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to unwrap data key of %s %s version %d: %w", row.Kind, row.Name, row.Version, err)
+		}
+		keyID, wrapped, err := s.masterKey.Wrap(ctx, dataKey)
+		if err != nil {
+			return rewrapped, fmt.Errorf("failed to wrap data key: %w", err)
+		}
+		// a concurrent re-wrap may have been faster
+		res, err := s.db.ExecContext(ctx, `
+			UPDATE encrypted_secrets SET master_key_id = $1, wrapped_key = $2
+			WHERE kind = $3 AND name = $4 AND version = $5 AND master_key_id = $6
+		`, keyID, wrapped, row.Kind, row.Name, row.Version, row.MasterKeyID)
+		if err != nil {
+			return rewrapped, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return rewrapped, err
+		} else if n > 0 {
+			rewrapped++
+		}
+	}
+	return rewrapped, nil
+}
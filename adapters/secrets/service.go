@@ -5,23 +5,75 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
-	"github.com/flashbots/builder-hub/application"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/flashbots/builder-hub/domain"
 )
 
+// AWSConfig configures the AWS Secrets Manager backend. Each builder's secrets are stored as one secret named
+// SecretPrefix/builderName.
+type AWSConfig struct {
+	SecretPrefix string
+	// Region defaults to us-east-2
+	Region string
+	// Endpoint overrides the Secrets Manager endpoint, e.g. for a local stand-in like LocalStack
+	Endpoint string
+	// KMSKeyID encrypts the secrets the hub creates, the AWS managed key is used if empty
+	KMSKeyID string
+	// Tags are added to the secrets the hub creates
+	Tags map[string]string
+}
+
+const defaultAWSRegion = "us-east-2"
+
+// tag keys identifying the secrets the hub creates
+const (
+	awsBuilderTag      = "builder-hub:builder"
+	awsSharedSecretTag = "builder-hub:shared-secret"
+)
+
+// builder secrets written by the hub are tagged builder-hub:format=v2. Secrets written by earlier versions of
+// the hub have no format tag and nest the values under the builder name, {"builderName": {...}}. When the hub
+// updates such a secret it tags it and records the first version without the nesting in builder-hub:format-since.
+const (
+	awsFormatTag      = "builder-hub:format"
+	awsFormatSinceTag = "builder-hub:format-since"
+	awsFormatV2       = "v2"
+)
+
+// awsTagAttempts is how often the format tags of a migrated secret are written before the migration is rolled back
+const awsTagAttempts = 3
+
 type awsSecretsService struct {
-	sm           *secretsmanager.SecretsManager
+	sm           secretsmanageriface.SecretsManagerAPI
 	secretPrefix string
+	kmsKeyID     string
+	tags         map[string]string
+	// formats caches the format of the tagged builder secrets by name, the hub never removes the tag
+	formats sync.Map
+	// tagRetryDelay is the wait between attempts to tag a migrated secret
+	tagRetryDelay time.Duration
 }
 
-func NewAWSSecretsManagerService(secretPrefix string) (*awsSecretsService, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-east-2"),
-	})
+func NewAWSSecretsManagerService(cfg AWSConfig) (*awsSecretsService, error) {
+	if cfg.Region == "" {
+		cfg.Region = defaultAWSRegion
+	}
+	awsConfig := &aws.Config{Region: aws.String(cfg.Region)}
+	if cfg.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(cfg.Endpoint)
+	}
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -29,93 +81,358 @@ func NewAWSSecretsManagerService(secretPrefix string) (*awsSecretsService, error
 	// Create a Secrets Manager client
 	svc := secretsmanager.New(sess)
 
-	return &awsSecretsService{sm: svc, secretPrefix: secretPrefix}, nil
+	return newAWSSecretsService(svc, cfg), nil
+}
+
+func newAWSSecretsService(sm secretsmanageriface.SecretsManagerAPI, cfg AWSConfig) *awsSecretsService {
+	return &awsSecretsService{sm: sm, secretPrefix: cfg.SecretPrefix, kmsKeyID: cfg.KMSKeyID, tags: cfg.Tags, tagRetryDelay: time.Second}
 }
 
 func (s *awsSecretsService) secretName(builderName string) string {
 	return s.secretPrefix + "/" + builderName
 }
 
-func (s *awsSecretsService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(s.secretName(builderName)),
-	}
+// builderSecretFormat is how the versions of a builder's secret are stored
+type builderSecretFormat struct {
+	// legacy secrets have no format tag, all versions nest the values under the builder name
+	legacy bool
+	// since is the first version without the nesting of a migrated secret, earlier versions are nested
+	since string
+}
 
-	result, err := s.sm.GetSecretValueWithContext(ctx, input)
+// builderSecretFormat reads the format tags of a builder's secret. A secret that doesn't exist yet is created
+// with the format tag.
+func (s *awsSecretsService) builderSecretFormat(ctx context.Context, name string) (builderSecretFormat, error) {
+	if f, ok := s.formats.Load(name); ok {
+		return f.(builderSecretFormat), nil
+	}
+	out, err := s.sm.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(name)})
+	if isAWSNotFound(err) {
+		return builderSecretFormat{}, nil
+	}
 	if err != nil {
-		// If the secret doesn't exist, return empty JSON for new builders
-		var awsErr awserr.Error
-		if errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
-			return json.RawMessage("{}"), nil
+		return builderSecretFormat{}, err
+	}
+	f := builderSecretFormat{legacy: true}
+	for _, t := range out.Tags {
+		switch aws.StringValue(t.Key) {
+		case awsFormatTag:
+			f.legacy = aws.StringValue(t.Value) != awsFormatV2
+		case awsFormatSinceTag:
+			f.since = aws.StringValue(t.Value)
 		}
-		return nil, err
 	}
-	secretData := make(map[string]json.RawMessage)
-	err = json.Unmarshal([]byte(*result.SecretString), &secretData)
+	if !f.legacy {
+		s.formats.Store(name, f)
+	}
+	return f, nil
+}
+
+// isLegacyVersion tells whether a version of a builder's secret nests the values under the builder name
+func (s *awsSecretsService) isLegacyVersion(ctx context.Context, name string, version *secretsmanager.GetSecretValueOutput) (bool, error) {
+	f, err := s.builderSecretFormat(ctx, name)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	// the current version of a tagged secret is always written without the nesting
+	if f.legacy || f.since == "" || aws.StringValue(version.VersionId) == f.since ||
+		slices.Contains(aws.StringValueSlice(version.VersionStages), awsCurrentStage) {
+		return f.legacy, nil
+	}
+	first, err := s.sm.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String(name),
+		VersionId: aws.String(f.since),
+	})
+	if isAWSNotFound(err) || isAWSInvalidVersion(err) {
+		// AWS removes the oldest versions first, the remaining ones are all newer
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
+	return aws.TimeValue(version.CreatedDate).Before(aws.TimeValue(first.CreatedDate)), nil
+}
 
-	builderSecret, ok := secretData[builderName]
+// decodeBuilderSecret returns the values of a version of a builder's secret
+func (s *awsSecretsService) decodeBuilderSecret(ctx context.Context, builderName string, version *secretsmanager.GetSecretValueOutput) (json.RawMessage, error) {
+	name := s.secretName(builderName)
+	legacy, err := s.isLegacyVersion(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	values := json.RawMessage(aws.StringValue(version.SecretString))
+	if !legacy {
+		return values, nil
+	}
+	var secretData map[string]json.RawMessage
+	if err := json.Unmarshal(values, &secretData); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets of builder %s: %w", builderName, err)
+	}
+	nested, ok := secretData[builderName]
 	if !ok {
-		return nil, application.ErrMissingSecret
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrMissingSecret)
 	}
+	return nested, nil
+}
 
-	return builderSecret, nil
+// createSecret creates a secret with the configured KMS key and tags
+func (s *awsSecretsService) createSecret(ctx context.Context, name, value string, tags map[string]string) (string, error) {
+	input := &secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		SecretString: aws.String(value),
+	}
+	if s.kmsKeyID != "" {
+		input.KmsKeyId = aws.String(s.kmsKeyID)
+	}
+	keys := make([]string, 0, len(s.tags)+len(tags))
+	all := make(map[string]string, len(s.tags)+len(tags))
+	for _, m := range []map[string]string{s.tags, tags} {
+		for k, v := range m {
+			if _, ok := all[k]; !ok {
+				keys = append(keys, k)
+			}
+			all[k] = v
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		input.Tags = append(input.Tags, &secretsmanager.Tag{Key: aws.String(k), Value: aws.String(all[k])})
+	}
+	out, err := s.sm.CreateSecretWithContext(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.VersionId), nil
 }
 
-func (s *awsSecretsService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
-	secretName := s.secretName(builderName)
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
+// putSecret writes a new version of a secret, creating it if it doesn't exist yet, and returns the version
+func (s *awsSecretsService) putSecret(ctx context.Context, name, value string, tags map[string]string) (string, error) {
+	out, err := s.sm.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(value),
+	})
+	if isAWSNotFound(err) {
+		return s.createSecret(ctx, name, value, tags)
 	}
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.VersionId), nil
+}
 
-	result, err := s.sm.GetSecretValueWithContext(ctx, input)
-	var secretData map[string]json.RawMessage
+// GetSecretValues returns the secrets of a builder, ErrMissingSecret if there is no secret for the builder yet
+func (s *awsSecretsService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
+	result, err := s.sm.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretName(builderName)),
+	})
+	if isAWSNotFound(err) {
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrMissingSecret)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeBuilderSecret(ctx, builderName, result)
+}
 
+func (s *awsSecretsService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
+	if !json.Valid(values) {
+		return fmt.Errorf("%w secret values of builder %s", domain.ErrValidation, builderName)
+	}
+	name := s.secretName(builderName)
+	f, err := s.builderSecretFormat(ctx, name)
 	if err != nil {
-		// If the secret doesn't exist, create it
-		var awsErr awserr.Error
-		if errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
-			// Create a new secret with the builder's values
-			secretData = map[string]json.RawMessage{builderName: values}
-			newSecretString, marshalErr := json.Marshal(secretData)
-			if marshalErr != nil {
-				return marshalErr
-			}
+		return err
+	}
+	version, err := s.putSecret(ctx, name, string(values), map[string]string{awsBuilderTag: builderName, awsFormatTag: awsFormatV2})
+	if err != nil || !f.legacy {
+		return err
+	}
+	// migrate a secret of an earlier version of the hub, its earlier versions stay nested
+	if err = s.tagMigratedSecret(ctx, name, version); err == nil {
+		s.formats.Store(name, builderSecretFormat{since: version})
+		return nil
+	}
+	err = fmt.Errorf("failed to tag migrated secret of builder %s: %w", builderName, err)
+	// without the tags the new version is read as nested, so write the values nested again and migrate next time
+	nested, mErr := json.Marshal(map[string]json.RawMessage{builderName: values})
+	if mErr == nil {
+		_, mErr = s.putSecret(ctx, name, string(nested), nil)
+	}
+	if mErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to roll back migrated secret of builder %s: %w", builderName, mErr))
+	}
+	return err
+}
 
-			createInput := &secretsmanager.CreateSecretInput{
-				Name:         aws.String(secretName),
-				SecretString: aws.String(string(newSecretString)),
+// tagMigratedSecret tags a builder secret with the format and the first version without the nesting, retrying
+// failed attempts
+func (s *awsSecretsService) tagMigratedSecret(ctx context.Context, name, version string) error {
+	var err error
+	for attempt := 0; attempt < awsTagAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(s.tagRetryDelay):
 			}
-			_, createErr := s.sm.CreateSecretWithContext(ctx, createInput)
-			return createErr
 		}
-		return err
+		_, err = s.sm.TagResourceWithContext(ctx, &secretsmanager.TagResourceInput{
+			SecretId: aws.String(name),
+			Tags: []*secretsmanager.Tag{
+				{Key: aws.String(awsFormatTag), Value: aws.String(awsFormatV2)},
+				{Key: aws.String(awsFormatSinceTag), Value: aws.String(version)},
+			},
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// ListSecretVersions returns the versions of a builder's secret still kept by Secrets Manager, newest first.
+// Versions without a staging label are removed by AWS over time.
+func (s *awsSecretsService) ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error) {
+	res := []domain.SecretVersion{}
+	err := s.sm.ListSecretVersionIdsPagesWithContext(ctx, &secretsmanager.ListSecretVersionIdsInput{
+		SecretId:          aws.String(s.secretName(builderName)),
+		IncludeDeprecated: aws.Bool(true),
+	}, func(page *secretsmanager.ListSecretVersionIdsOutput, _ bool) bool {
+		for _, v := range page.Versions {
+			stages := aws.StringValueSlice(v.VersionStages)
+			res = append(res, domain.SecretVersion{
+				Version:   aws.StringValue(v.VersionId),
+				CreatedAt: aws.TimeValue(v.CreatedDate),
+				Current:   slices.Contains(stages, awsCurrentStage),
+				Stages:    stages,
+			})
+		}
+		return true
+	})
+	if isAWSNotFound(err) {
+		return res, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	return res, nil
+}
 
-	// Secret exists, update it
-	secretData = make(map[string]json.RawMessage)
-	err = json.Unmarshal([]byte(*result.SecretString), &secretData)
+// GetSecretVersion reads a version of a builder's secret, ErrNotFound if AWS doesn't keep it (anymore)
+func (s *awsSecretsService) GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error) {
+	result, err := s.sm.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String(s.secretName(builderName)),
+		VersionId: aws.String(version),
+	})
+	if isAWSNotFound(err) || isAWSInvalidVersion(err) {
+		return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, domain.ErrNotFound)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.decodeBuilderSecret(ctx, builderName, result)
+}
 
-	secretData[builderName] = values
-	newSecretString, err := json.Marshal(secretData)
+// RestoreSecretVersion writes an earlier version as a new version, so that AWSPREVIOUS points to the version
+// that was replaced
+func (s *awsSecretsService) RestoreSecretVersion(ctx context.Context, builderName, version string) error {
+	values, err := s.GetSecretVersion(ctx, builderName, version)
 	if err != nil {
 		return err
 	}
+	return s.SetSecretValues(ctx, builderName, values)
+}
+
+const awsCurrentStage = "AWSCURRENT"
 
-	sv := &secretsmanager.PutSecretValueInput{
-		SecretId:     aws.String(secretName),
-		SecretString: aws.String(string(newSecretString)),
+func isAWSInvalidVersion(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeInvalidParameterException
+}
+
+// sharedSecretName is the AWS secret of the shared secret referenced as shared/name
+func (s *awsSecretsService) sharedSecretName(name string) string {
+	return s.secretPrefix + "/" + domain.SharedSecretNamespace + name
+}
+
+func isAWSNotFound(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == secretsmanager.ErrCodeResourceNotFoundException
+}
+
+// GetSharedSecret reads a shared secret, stored as {"value": ..., "policy": {...}}
+func (s *awsSecretsService) GetSharedSecret(ctx context.Context, name string) (*domain.SharedSecret, error) {
+	result, err := s.sm.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.sharedSecretName(name)),
+	})
+	if isAWSNotFound(err) {
+		return nil, fmt.Errorf("shared secret %s: %w", name, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &domain.SharedSecret{}
+	if err = json.Unmarshal([]byte(aws.StringValue(result.SecretString)), res); err != nil {
+		return nil, fmt.Errorf("failed to decode shared secret %s: %w", name, err)
+	}
+	res.Name = name
+	return res, nil
+}
+
+func (s *awsSecretsService) SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error {
+	if err := domain.ValidSharedSecretName(secret.Name); err != nil {
+		return err
 	}
-	_, err = s.sm.PutSecretValueWithContext(ctx, sv)
+	bts, err := json.Marshal(secret)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = s.putSecret(ctx, s.sharedSecretName(secret.Name), string(bts), map[string]string{awsSharedSecretTag: secret.Name})
+	return err
+}
+
+// DeleteSharedSecret deletes a shared secret without a recovery window, so that the name can be reused right away
+func (s *awsSecretsService) DeleteSharedSecret(ctx context.Context, name string) error {
+	_, err := s.sm.DeleteSecretWithContext(ctx, &secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(s.sharedSecretName(name)),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if isAWSNotFound(err) {
+		return fmt.Errorf("shared secret %s: %w", name, domain.ErrNotFound)
+	}
+	return err
+}
+
+// ListSharedSecrets returns the shared secrets without their values ordered by name
+func (s *awsSecretsService) ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error) {
+	prefix := s.sharedSecretName("")
+	var names []string
+	err := s.sm.ListSecretsPagesWithContext(ctx, &secretsmanager.ListSecretsInput{
+		Filters: []*secretsmanager.Filter{{Key: aws.String(secretsmanager.FilterNameStringTypeName), Values: []*string{aws.String(prefix)}}},
+	}, func(page *secretsmanager.ListSecretsOutput, _ bool) bool {
+		for _, entry := range page.SecretList {
+			// the name filter matches prefixes
+			if name, ok := strings.CutPrefix(aws.StringValue(entry.Name), prefix); ok && name != "" {
+				names = append(names, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	res := make([]domain.SharedSecret, 0, len(names))
+	for _, name := range names {
+		secret, err := s.GetSharedSecret(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		secret.Value = ""
+		res = append(res, *secret)
+	}
+	return res, nil
 }
 
 func MergeSecrets(defaultSecrets, secrets map[string]string) map[string]string {
@@ -0,0 +1,200 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretsManager keeps the current value, the versions and the tags of each secret and the create requests
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	values   map[string]string
+	versions map[string][]*secretsmanager.GetSecretValueOutput
+	tags     map[string]map[string]string
+	created  []*secretsmanager.CreateSecretInput
+	// tagErrors is the number of TagResource calls that fail
+	tagErrors int
+}
+
+func newFakeSecretsManager() *fakeSecretsManager {
+	return &fakeSecretsManager{
+		values:   make(map[string]string),
+		versions: make(map[string][]*secretsmanager.GetSecretValueOutput),
+		tags:     make(map[string]map[string]string),
+	}
+}
+
+// put adds a version of a secret as AWSCURRENT
+func (f *fakeSecretsManager) put(name, value string) string {
+	id := fmt.Sprintf("v%d", len(f.versions[name])+1)
+	for _, v := range f.versions[name] {
+		v.VersionStages = nil
+	}
+	f.values[name] = value
+	f.versions[name] = append(f.versions[name], &secretsmanager.GetSecretValueOutput{
+		VersionId:     aws.String(id),
+		SecretString:  aws.String(value),
+		CreatedDate:   aws.Time(time.Unix(int64(len(f.versions[name])), 0)),
+		VersionStages: aws.StringSlice([]string{awsCurrentStage}),
+	})
+	return id
+}
+
+func (f *fakeSecretsManager) GetSecretValueWithContext(_ aws.Context, in *secretsmanager.GetSecretValueInput, _ ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	versions, ok := f.versions[aws.StringValue(in.SecretId)]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	if in.VersionId == nil {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if aws.StringValue(v.VersionId) == aws.StringValue(in.VersionId) {
+			return v, nil
+		}
+	}
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+}
+
+func (f *fakeSecretsManager) PutSecretValueWithContext(_ aws.Context, in *secretsmanager.PutSecretValueInput, _ ...request.Option) (*secretsmanager.PutSecretValueOutput, error) {
+	if _, ok := f.versions[aws.StringValue(in.SecretId)]; !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	id := f.put(aws.StringValue(in.SecretId), aws.StringValue(in.SecretString))
+	return &secretsmanager.PutSecretValueOutput{VersionId: aws.String(id)}, nil
+}
+
+func (f *fakeSecretsManager) CreateSecretWithContext(_ aws.Context, in *secretsmanager.CreateSecretInput, _ ...request.Option) (*secretsmanager.CreateSecretOutput, error) {
+	id := f.put(aws.StringValue(in.Name), aws.StringValue(in.SecretString))
+	f.tags[aws.StringValue(in.Name)] = map[string]string{}
+	for _, t := range in.Tags {
+		f.tags[aws.StringValue(in.Name)][aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	f.created = append(f.created, in)
+	return &secretsmanager.CreateSecretOutput{VersionId: aws.String(id)}, nil
+}
+
+func (f *fakeSecretsManager) DescribeSecretWithContext(_ aws.Context, in *secretsmanager.DescribeSecretInput, _ ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	if _, ok := f.versions[aws.StringValue(in.SecretId)]; !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	out := &secretsmanager.DescribeSecretOutput{Name: in.SecretId}
+	for k, v := range f.tags[aws.StringValue(in.SecretId)] {
+		out.Tags = append(out.Tags, &secretsmanager.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) TagResourceWithContext(_ aws.Context, in *secretsmanager.TagResourceInput, _ ...request.Option) (*secretsmanager.TagResourceOutput, error) {
+	if f.tagErrors > 0 {
+		f.tagErrors--
+		return nil, awserr.New(secretsmanager.ErrCodeInternalServiceError, "unavailable", nil)
+	}
+	if f.tags[aws.StringValue(in.SecretId)] == nil {
+		f.tags[aws.StringValue(in.SecretId)] = map[string]string{}
+	}
+	for _, t := range in.Tags {
+		f.tags[aws.StringValue(in.SecretId)][aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return &secretsmanager.TagResourceOutput{}, nil
+}
+
+func TestAWSSecretsService(t *testing.T) {
+	ctx := context.Background()
+	sm := newFakeSecretsManager()
+	s := newAWSSecretsService(sm, AWSConfig{SecretPrefix: "builders", KMSKeyID: "alias/hub", Tags: map[string]string{"team": "infra"}})
+
+	_, err := s.GetSecretValues(ctx, "b-1")
+	require.ErrorIs(t, err, domain.ErrMissingSecret)
+
+	// one secret per builder holding the values themselves
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "v1"}}`)))
+	require.JSONEq(t, `{"relay": {"key": "v1"}}`, sm.values["builders/b-1"])
+	require.Len(t, sm.created, 1)
+	require.Equal(t, "alias/hub", aws.StringValue(sm.created[0].KmsKeyId))
+	require.Equal(t, []*secretsmanager.Tag{
+		{Key: aws.String(awsBuilderTag), Value: aws.String("b-1")},
+		{Key: aws.String(awsFormatTag), Value: aws.String(awsFormatV2)},
+		{Key: aws.String("team"), Value: aws.String("infra")},
+	}, sm.created[0].Tags)
+
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "v2"}}`)))
+	require.Len(t, sm.created, 1, "existing secrets are updated")
+	values, err := s.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "v2"}}`, string(values))
+	require.ErrorIs(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{`)), domain.ErrValidation)
+
+	// secrets of earlier versions have no format tag and nest the values under the builder name
+	sm.put("builders/b-2", `{"b-2": {"relay": {"key": "legacy"}}}`)
+	legacyVersion := sm.put("builders/b-2", `{"b-2": {"relay": {"key": "legacy-2"}}}`)
+	values, err = s.GetSecretValues(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "legacy-2"}}`, string(values))
+	require.NoError(t, s.SetSecretValues(ctx, "b-2", json.RawMessage(`{"b-2": {"key": "nested"}}`)))
+	require.JSONEq(t, `{"b-2": {"key": "nested"}}`, sm.values["builders/b-2"])
+	require.Equal(t, map[string]string{awsFormatTag: awsFormatV2, awsFormatSinceTag: "v3"}, sm.tags["builders/b-2"])
+
+	// a migrated secret is read as is, even if it looks like the old nesting, its earlier versions are nested
+	for _, svc := range []*awsSecretsService{s, newAWSSecretsService(sm, AWSConfig{SecretPrefix: "builders"})} {
+		values, err = svc.GetSecretValues(ctx, "b-2")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"b-2": {"key": "nested"}}`, string(values))
+		values, err = svc.GetSecretVersion(ctx, "b-2", legacyVersion)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"relay": {"key": "legacy-2"}}`, string(values))
+	}
+	require.NoError(t, s.RestoreSecretVersion(ctx, "b-2", legacyVersion))
+	require.JSONEq(t, `{"relay": {"key": "legacy-2"}}`, sm.values["builders/b-2"])
+	values, err = s.GetSecretVersion(ctx, "b-2", "v3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"b-2": {"key": "nested"}}`, string(values))
+
+	// secrets the hub creates are tagged with the format, a value at the builder name isn't unwrapped
+	require.NoError(t, s.SetSecretValues(ctx, "b-3", json.RawMessage(`{"b-3": {"key": "value"}}`)))
+	require.Equal(t, awsFormatV2, sm.tags["builders/b-3"][awsFormatTag])
+	values, err = s.GetSecretValues(ctx, "b-3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"b-3": {"key": "value"}}`, string(values))
+}
+
+func TestAWSSecretsServiceMigrationTagFailure(t *testing.T) {
+	ctx := context.Background()
+	sm := newFakeSecretsManager()
+	s := newAWSSecretsService(sm, AWSConfig{SecretPrefix: "builders"})
+	s.tagRetryDelay = time.Millisecond
+
+	// failed attempts to tag are retried
+	sm.put("builders/b-1", `{"b-1": {"key": "legacy"}}`)
+	sm.tagErrors = awsTagAttempts - 1
+	require.NoError(t, s.SetSecretValues(ctx, "b-1", json.RawMessage(`{"key": "v2"}`)))
+	require.Equal(t, map[string]string{awsFormatTag: awsFormatV2, awsFormatSinceTag: "v2"}, sm.tags["builders/b-1"])
+
+	// if tagging keeps failing the values are written nested again, so the secret stays readable
+	sm.put("builders/b-2", `{"b-2": {"key": "legacy"}}`)
+	sm.tagErrors = awsTagAttempts
+	require.Error(t, s.SetSecretValues(ctx, "b-2", json.RawMessage(`{"key": "v2"}`)))
+	require.Empty(t, sm.tags["builders/b-2"])
+	require.JSONEq(t, `{"b-2": {"key": "v2"}}`, sm.values["builders/b-2"])
+	values, err := s.GetSecretValues(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "v2"}`, string(values))
+
+	// the next write migrates it
+	require.NoError(t, s.SetSecretValues(ctx, "b-2", json.RawMessage(`{"key": "v3"}`)))
+	require.Equal(t, awsFormatV2, sm.tags["builders/b-2"][awsFormatTag])
+	values, err = s.GetSecretValues(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key": "v3"}`, string(values))
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/flashbots/builder-hub/signing"
+)
+
+// transitSigner signs hub artifacts with an ed25519 key of Vault's Transit engine, so that the signing key never
+// leaves Vault. The key version is pinned when the signer is created, so that the signatures match the published
+// public key until the hub restarts, also if the key is rotated in Vault.
+type transitSigner struct {
+	vault      *hashicorpVaultService
+	mountPath  string
+	keyName    string
+	keyVersion int
+	publicKey  []byte
+}
+
+// TransitSigner returns a signer using the latest version of the Transit key. Only ed25519 keys are supported,
+// Transit has no secp256k1 keys.
+func (s *hashicorpVaultService) TransitSigner(ctx context.Context, mountPath, keyName string) (signing.Signer, error) {
+	if mountPath == "" {
+		mountPath = "transit"
+	}
+	key, err := s.client.Logical().ReadWithContext(ctx, fmt.Sprintf("%s/keys/%s", mountPath, keyName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Transit key %s: %w", keyName, err)
+	}
+	if key == nil || key.Data == nil {
+		return nil, fmt.Errorf("transit key %s not found", keyName)
+	}
+	if keyType, _ := key.Data["type"].(string); keyType != signing.AlgorithmEd25519 {
+		return nil, fmt.Errorf("transit key %s has type %q, only ed25519 keys are supported", keyName, keyType)
+	}
+	latest, err := json.Number(fmt.Sprint(key.Data["latest_version"])).Int64()
+	if err != nil {
+		return nil, fmt.Errorf("transit key %s has no latest version: %w", keyName, err)
+	}
+	versions, _ := key.Data["keys"].(map[string]interface{})
+	version, _ := versions[fmt.Sprint(latest)].(map[string]interface{})
+	encoded, _ := version["public_key"].(string)
+	publicKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || encoded == "" {
+		return nil, fmt.Errorf("transit key %s version %d has no public key", keyName, latest)
+	}
+	return &transitSigner{vault: s, mountPath: mountPath, keyName: keyName, keyVersion: int(latest), publicKey: publicKey}, nil
+}
+
+func (t *transitSigner) Algorithm() string {
+	return signing.AlgorithmEd25519
+}
+
+func (t *transitSigner) PublicKey() []byte {
+	return t.publicKey
+}
+
+func (t *transitSigner) Sign(ctx context.Context, message []byte) ([]byte, error) {
+	res, err := t.vault.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/sign/%s", t.mountPath, t.keyName), map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(message),
+		"key_version": t.keyVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with Transit key %s: %w", t.keyName, err)
+	}
+	if res == nil || res.Data == nil {
+		return nil, errors.New("transit returned no signature")
+	}
+	// signatures are formatted as vault:v<version>:<base64>
+	signature, _ := res.Data["signature"].(string)
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected Transit signature format %q", signature)
+	}
+	return base64.StdEncoding.DecodeString(parts[2])
+}
@@ -0,0 +1,192 @@
+-- SQLite equivalent of the postgres schema in schema/*.sql.
+-- IP addresses are stored in canonical text form (net.IP.String), so equality matches INET equality of host addresses.
+CREATE TABLE builders
+(
+    name          TEXT PRIMARY KEY,
+    ip_address    TEXT    NOT NULL,
+    is_active     INTEGER NOT NULL DEFAULT 1,
+    network       TEXT    NOT NULL DEFAULT 'production',
+    dns_name      TEXT,
+    created_at    TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deprecated_at TEXT,
+    CONSTRAINT active_only_when_not_deprecated CHECK (
+            (is_active = 1 AND deprecated_at IS NULL) OR
+            (is_active = 0)
+        )
+);
+
+CREATE INDEX idx_builders_ip_address ON builders (ip_address);
+
+CREATE TRIGGER trigger_update_builders_updated_at
+    AFTER UPDATE
+    ON builders
+BEGIN
+    UPDATE builders SET updated_at = CURRENT_TIMESTAMP WHERE name = NEW.name;
+END;
+
+CREATE TABLE measurements_whitelist
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    name             TEXT    NOT NULL,
+    attestation_type TEXT    NOT NULL,
+    measurement      TEXT    NOT NULL,
+    is_active        INTEGER NOT NULL DEFAULT 1,
+    created_at       TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deprecated_at    TEXT,
+    CONSTRAINT active_only_when_not_deprecated CHECK (
+            (is_active = 1 AND deprecated_at IS NULL) OR
+            (is_active = 0)
+        ),
+    CONSTRAINT unique_name UNIQUE (name)
+);
+
+CREATE TABLE service_credential_registrations
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    builder_name   TEXT REFERENCES builders (name),
+    service        TEXT    NOT NULL,
+    tls_cert       TEXT,
+    ecdsa_pubkey   BLOB,
+    is_active      INTEGER NOT NULL DEFAULT 1,
+    created_at     TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deprecated_at  TEXT,
+    measurement_id INTEGER REFERENCES measurements_whitelist (id),
+    region         TEXT    NOT NULL DEFAULT '',
+    CONSTRAINT active_only_when_not_deprecated CHECK (
+            (is_active = 1 AND deprecated_at IS NULL) OR
+            (is_active = 0)
+        )
+);
+
+CREATE UNIQUE INDEX idx_unique_active_credential_per_builder_service
+    ON service_credential_registrations (builder_name, service)
+    WHERE is_active = 1;
+
+CREATE TABLE builder_configs
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    builder_name TEXT REFERENCES builders (name),
+    config       TEXT    NOT NULL,
+    is_active    INTEGER NOT NULL DEFAULT 0,
+    created_at   TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Only one active config per builder, the postgres schema uses an exclusion constraint for this
+CREATE UNIQUE INDEX unique_active_config_per_builder
+    ON builder_configs (builder_name)
+    WHERE is_active = 1;
+
+CREATE TABLE event_log
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_name     TEXT NOT NULL,
+    builder_name   TEXT REFERENCES builders (name) ON DELETE CASCADE,
+    measurement_id INTEGER REFERENCES measurements_whitelist (id) ON DELETE SET NULL,
+    created_at     TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE measurements_log
+(
+    leaf_index INTEGER PRIMARY KEY CHECK (leaf_index >= 0),
+    data       BLOB NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER measurements_log_no_update
+    BEFORE UPDATE
+    ON measurements_log
+BEGIN
+    SELECT RAISE(ABORT, 'measurements_log is append-only');
+END;
+
+CREATE TRIGGER measurements_log_no_delete
+    BEFORE DELETE
+    ON measurements_log
+BEGIN
+    SELECT RAISE(ABORT, 'measurements_log is append-only');
+END;
+
+CREATE TABLE config_schemas
+(
+    scope      TEXT NOT NULL CHECK (scope IN ('global', 'network', 'builder')),
+    target     TEXT NOT NULL DEFAULT '',
+    schema     TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, target),
+    CONSTRAINT target_only_for_scoped_schemas CHECK ((scope = 'global') = (target = ''))
+);
+
+CREATE TABLE config_layers
+(
+    kind       TEXT NOT NULL CHECK (kind IN ('network', 'group')),
+    name       TEXT NOT NULL,
+    config     TEXT NOT NULL CHECK (json_type(config) = 'object'),
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, name)
+);
+
+CREATE TABLE builder_config_groups
+(
+    builder_name TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    group_name   TEXT    NOT NULL,
+    position     INTEGER NOT NULL,
+    PRIMARY KEY (builder_name, group_name),
+    CONSTRAINT unique_group_position UNIQUE (builder_name, position)
+);
+
+CREATE TABLE config_rollouts
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    layer_kind  TEXT    NOT NULL CHECK (layer_kind IN ('network', 'group')),
+    layer_name  TEXT    NOT NULL,
+    config      TEXT    NOT NULL CHECK (json_type(config) = 'object'),
+    builders    TEXT    NOT NULL DEFAULT '[]' CHECK (json_type(builders) = 'array'),
+    percent     INTEGER NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+    status      TEXT    NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'promoted', 'aborted')),
+    created_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TEXT
+);
+
+CREATE UNIQUE INDEX unique_active_rollout ON config_rollouts (layer_kind, layer_name) WHERE status = 'active';
+
+CREATE TABLE secret_rotation_policies
+(
+    builder_name        TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    key                 TEXT    NOT NULL,
+    generator           TEXT    NOT NULL CHECK (generator IN ('hex', 'ecdsa', 'password')),
+    length              INTEGER NOT NULL DEFAULT 0,
+    interval_seconds    INTEGER NOT NULL CHECK (interval_seconds > 0),
+    overlap_seconds     INTEGER NOT NULL DEFAULT 0 CHECK (overlap_seconds >= 0),
+    last_rotated_at     TEXT,
+    previous_expires_at TEXT,
+    created_at          TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (builder_name, key)
+);
+
+CREATE TABLE secret_rotations
+(
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    builder_name        TEXT NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    key                 TEXT NOT NULL,
+    trigger             TEXT NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    rotated_at          TEXT NOT NULL,
+    previous_expires_at TEXT
+);
+
+CREATE INDEX secret_rotations_builder ON secret_rotations (builder_name, id);
+
+CREATE TABLE config_rollout_fetches
+(
+    rollout_id   INTEGER NOT NULL REFERENCES config_rollouts (id) ON DELETE CASCADE,
+    builder_name TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    fetched_at   TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (rollout_id, builder_name)
+);
+
+PRAGMA user_version = 6;
@@ -0,0 +1,1207 @@
+// Package sqlite provides an embedded SQLite storage adapter for single-node and test deployments
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed schema.sql
+var schemaSQL string
+
+// schemaVersion is the PRAGMA user_version set by schema.sql
+const schemaVersion = 6
+
+// upgrades bring a database of the key version to the next one, schema.sql creates the latest version directly
+var upgrades = map[int]string{
+	1: `
+		CREATE TABLE config_schemas
+		(
+			scope      TEXT NOT NULL CHECK (scope IN ('global', 'network', 'builder')),
+			target     TEXT NOT NULL DEFAULT '',
+			schema     TEXT NOT NULL,
+			created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (scope, target),
+			CONSTRAINT target_only_for_scoped_schemas CHECK ((scope = 'global') = (target = ''))
+		);
+		PRAGMA user_version = 2;
+	`,
+	2: `
+		CREATE TABLE config_layers
+		(
+		    kind       TEXT NOT NULL CHECK (kind IN ('network', 'group')),
+		    name       TEXT NOT NULL,
+		    config     TEXT NOT NULL CHECK (json_type(config) = 'object'),
+		    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    PRIMARY KEY (kind, name)
+		);
+
+		CREATE TABLE builder_config_groups
+		(
+		    builder_name TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+		    group_name   TEXT    NOT NULL,
+		    position     INTEGER NOT NULL,
+		    PRIMARY KEY (builder_name, group_name),
+		    CONSTRAINT unique_group_position UNIQUE (builder_name, position)
+		);
+		PRAGMA user_version = 3;
+	`,
+	3: `
+		CREATE TABLE config_rollouts
+		(
+		    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		    layer_kind  TEXT    NOT NULL CHECK (layer_kind IN ('network', 'group')),
+		    layer_name  TEXT    NOT NULL,
+		    config      TEXT    NOT NULL CHECK (json_type(config) = 'object'),
+		    builders    TEXT    NOT NULL DEFAULT '[]' CHECK (json_type(builders) = 'array'),
+		    percent     INTEGER NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+		    status      TEXT    NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'promoted', 'aborted')),
+		    created_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    finished_at TEXT
+		);
+
+		CREATE UNIQUE INDEX unique_active_rollout ON config_rollouts (layer_kind, layer_name) WHERE status = 'active';
+		PRAGMA user_version = 4;
+	`,
+	4: `
+		CREATE TABLE secret_rotation_policies
+		(
+		    builder_name        TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+		    key                 TEXT    NOT NULL,
+		    generator           TEXT    NOT NULL CHECK (generator IN ('hex', 'ecdsa', 'password')),
+		    length              INTEGER NOT NULL DEFAULT 0,
+		    interval_seconds    INTEGER NOT NULL CHECK (interval_seconds > 0),
+		    overlap_seconds     INTEGER NOT NULL DEFAULT 0 CHECK (overlap_seconds >= 0),
+		    last_rotated_at     TEXT,
+		    previous_expires_at TEXT,
+		    created_at          TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    updated_at          TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    PRIMARY KEY (builder_name, key)
+		);
+
+		CREATE TABLE secret_rotations
+		(
+		    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+		    builder_name        TEXT NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+		    key                 TEXT NOT NULL,
+		    trigger             TEXT NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+		    rotated_at          TEXT NOT NULL,
+		    previous_expires_at TEXT
+		);
+
+		CREATE INDEX secret_rotations_builder ON secret_rotations (builder_name, id);
+		PRAGMA user_version = 5;
+	`,
+	5: `
+		CREATE TABLE config_rollout_fetches
+		(
+		    rollout_id   INTEGER NOT NULL REFERENCES config_rollouts (id) ON DELETE CASCADE,
+		    builder_name TEXT    NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+		    fetched_at   TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		    PRIMARY KEY (rollout_id, builder_name)
+		);
+		PRAGMA user_version = 6;
+	`,
+}
+
+type Service struct {
+	DB *sqlx.DB
+}
+
+// NewSQLiteService opens (and creates if needed) the database at path. Use ":memory:" for a throwaway database.
+func NewSQLiteService(path string) (*Service, error) {
+	db, err := sqlx.Connect("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serializes writers anyway, a single connection avoids SQLITE_BUSY and keeps ":memory:" databases shared
+	db.SetMaxOpenConns(1)
+
+	s := &Service{DB: db}
+	if err = s.ensureSchema(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Service) ensureSchema() error {
+	var version int
+	if err := s.DB.Get(&version, `PRAGMA user_version`); err != nil {
+		return err
+	}
+	switch {
+	case version == schemaVersion:
+		return nil
+	case version > schemaVersion:
+		return fmt.Errorf("sqlite schema version %d is newer than supported version %d", version, schemaVersion)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+	if version == 0 {
+		if _, err = tx.Exec(schemaSQL); err != nil {
+			return fmt.Errorf("failed to create sqlite schema: %w", err)
+		}
+		return tx.Commit()
+	}
+	for ; version < schemaVersion; version++ {
+		if _, err = tx.Exec(upgrades[version]); err != nil {
+			return fmt.Errorf("failed to upgrade sqlite schema from version %d: %w", version, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Service) Close() error {
+	return s.DB.Close()
+}
+
+type measurement struct {
+	Name            string `db:"name"`
+	AttestationType string `db:"attestation_type"`
+	Measurement     []byte `db:"measurement"`
+	IsActive        bool   `db:"is_active"`
+}
+
+func (m measurement) toDomain() (*domain.Measurement, error) {
+	res := domain.Measurement{
+		Name:            m.Name,
+		AttestationType: m.AttestationType,
+		Measurement:     make(map[string]domain.SingleMeasurement),
+	}
+	if err := json.Unmarshal(m.Measurement, &res.Measurement); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (s *Service) selectMeasurements(ctx context.Context, query string, args ...any) ([]domain.Measurement, error) {
+	var measurements []measurement
+	if err := s.DB.SelectContext(ctx, &measurements, query, args...); err != nil {
+		return nil, err
+	}
+	var domainMeasurements []domain.Measurement
+	for _, m := range measurements {
+		domainM, err := m.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		domainMeasurements = append(domainMeasurements, *domainM)
+	}
+	return domainMeasurements, nil
+}
+
+func (s *Service) GetActiveMeasurementsByType(ctx context.Context, attestationType string) ([]domain.Measurement, error) {
+	return s.selectMeasurements(ctx, `
+		SELECT name, attestation_type, measurement FROM measurements_whitelist
+		WHERE is_active = 1 AND attestation_type = ?
+	`, attestationType)
+}
+
+// GetActiveMeasurements retrieves all measurements
+func (s *Service) GetActiveMeasurements(ctx context.Context) ([]domain.Measurement, error) {
+	return s.selectMeasurements(ctx, `
+		SELECT name, attestation_type, measurement FROM measurements_whitelist
+		WHERE is_active = 1
+	`)
+}
+
+// GetAllMeasurements retrieves all measurements, active or not, ordered by name
+func (s *Service) GetAllMeasurements(ctx context.Context) ([]domain.MeasurementWithStatus, error) {
+	var measurements []measurement
+	err := s.DB.SelectContext(ctx, &measurements, `
+		SELECT name, attestation_type, measurement, is_active FROM measurements_whitelist
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.MeasurementWithStatus, 0, len(measurements))
+	for _, m := range measurements {
+		domainM, err := m.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, domain.MeasurementWithStatus{Measurement: *domainM, IsActive: m.IsActive})
+	}
+	return res, nil
+}
+
+// canonicalIP returns the text form IPs are stored and matched in, IPv4-mapped IPv6 addresses collapse to IPv4
+func canonicalIP(ip net.IP) (string, error) {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return "", domain.ErrIncorrectBuilder
+	}
+	return ip.String(), nil
+}
+
+type builder struct {
+	Name      string         `db:"name"`
+	IPAddress string         `db:"ip_address"`
+	IsActive  bool           `db:"is_active"`
+	Network   string         `db:"network"`
+	DNSName   sql.NullString `db:"dns_name"`
+}
+
+func (b builder) toDomain() (*domain.Builder, error) {
+	ip := net.ParseIP(b.IPAddress)
+	if ip == nil {
+		return nil, domain.ErrIncorrectBuilder
+	}
+	return &domain.Builder{
+		Name:      b.Name,
+		IPAddress: ip,
+		IsActive:  b.IsActive,
+		Network:   b.Network,
+		DNSName:   b.DNSName.String,
+	}, nil
+}
+
+// GetBuilderByIP retrieves a builder by IP address
+func (s *Service) GetBuilderByIP(ctx context.Context, ip net.IP) (*domain.Builder, error) {
+	ipStr, err := canonicalIP(ip)
+	if err != nil {
+		return nil, err
+	}
+	var b builder
+	err = s.DB.GetContext(ctx, &b, `
+		SELECT name, ip_address, is_active, network, dns_name FROM builders
+		WHERE ip_address = ? AND is_active = 1
+	`, ipStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.toDomain()
+}
+
+// GetAllBuilders retrieves all builders, active or not, ordered by name
+func (s *Service) GetAllBuilders(ctx context.Context) ([]domain.Builder, error) {
+	var builders []builder
+	err := s.DB.SelectContext(ctx, &builders, `
+		SELECT name, ip_address, is_active, network, dns_name FROM builders
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Builder, 0, len(builders))
+	for _, b := range builders {
+		domainB, err := b.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *domainB)
+	}
+	return res, nil
+}
+
+// RegisterCredentialsForBuilder registers new credentials for a builder, deprecating all previous credentials
+func (s *Service) RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE service_credential_registrations
+		SET is_active = 0, deprecated_at = CURRENT_TIMESTAMP
+		WHERE builder_name = ? AND service = ?
+	`, builderName, service)
+	if err != nil {
+		return err
+	}
+
+	var nullableTLSCert sql.NullString
+	if tlsCert != "" {
+		nullableTLSCert = sql.NullString{String: tlsCert, Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO service_credential_registrations
+		(builder_name, service, tls_cert, ecdsa_pubkey, is_active, measurement_id, region)
+		VALUES (?, ?, ?, ?, 1,
+			(SELECT id FROM measurements_whitelist WHERE name = ? AND attestation_type = ?),
+			?
+		)
+	`, builderName, service, nullableTLSCert, ecdsaPubKey, measurementName, attestationType, region)
+	if err != nil {
+		return fmt.Errorf("failed to insert credentials for builder %s: %w", builderName, mapError(err))
+	}
+
+	return tx.Commit()
+}
+
+// GetActiveConfigForBuilder retrieves the effective config for a builder by name: its active config merged
+// over its network and group layers
+func (s *Service) GetActiveConfigForBuilder(ctx context.Context, builderName string) (json.RawMessage, error) {
+	layers, err := s.GetConfigLayersForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+	return domain.ActiveConfigFromLayers(layers)
+}
+
+type configLayer struct {
+	Kind   string `db:"kind"`
+	Name   string `db:"name"`
+	Config []byte `db:"config"`
+}
+
+// GetConfigLayersForBuilder returns the config layers of a builder in merge order, the last one is the builder's
+// active config if it has one. Shared layers in an active rollout targeting the builder are replaced by the new version.
+func (s *Service) GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]domain.ConfigLayer, error) {
+	var network string
+	err := s.DB.GetContext(ctx, &network, `SELECT network FROM builders WHERE name = ?`, builderName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.GetConfigGroupsForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []configLayer
+	err = s.DB.SelectContext(ctx, &rows, `
+		SELECT kind, name, config FROM config_layers
+		WHERE (kind = 'network' AND name = ?)
+			OR (kind = 'group' AND name IN (SELECT group_name FROM builder_config_groups WHERE builder_name = ?))
+	`, network, builderName)
+	if err != nil {
+		return nil, err
+	}
+	shared := make([]domain.ConfigLayer, 0, len(rows))
+	for _, row := range rows {
+		shared = append(shared, domain.ConfigLayer{Kind: row.Kind, Name: row.Name, Config: row.Config})
+	}
+	rollouts, err := s.selectRollouts(ctx, `WHERE status = 'active'`)
+	if err != nil {
+		return nil, err
+	}
+
+	var config []byte
+	err = s.DB.GetContext(ctx, &config, `
+		SELECT config FROM builder_configs
+		WHERE builder_name = ? AND is_active = 1
+	`, builderName)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return domain.BuilderConfigLayers(domain.Builder{Name: builderName, Network: network}, groups,
+		domain.RolloutLayers(builderName, shared, rollouts), config), nil
+}
+
+func (s *Service) GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]domain.BuilderWithServices, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT
+			b.name,
+			b.ip_address,
+			b.dns_name,
+			scr.service,
+			scr.tls_cert,
+			scr.ecdsa_pubkey,
+			scr.region
+		FROM
+			builders b
+		LEFT JOIN
+			service_credential_registrations scr ON b.name = scr.builder_name
+		WHERE
+			b.is_active = 1 AND (scr.is_active = 1 OR scr.is_active IS NULL) AND b.network = ?
+		ORDER BY
+			b.name, scr.service
+	`, network)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	builders := make([]domain.BuilderWithServices, 0)
+	for rows.Next() {
+		var (
+			builderName string
+			ipAddress   string
+			dnsName     sql.NullString
+			service     sql.NullString
+			tlsCert     sql.NullString
+			ecdsaPubKey []byte
+			region      sql.NullString
+		)
+		if err := rows.Scan(&builderName, &ipAddress, &dnsName, &service, &tlsCert, &ecdsaPubKey, &region); err != nil {
+			return nil, err
+		}
+
+		// rows are ordered by builder name, so a builder's services are consecutive
+		if len(builders) == 0 || builders[len(builders)-1].Builder.Name != builderName {
+			ip := net.ParseIP(ipAddress)
+			if ip == nil {
+				return nil, domain.ErrIncorrectBuilder
+			}
+			builders = append(builders, domain.BuilderWithServices{
+				Builder: domain.Builder{
+					Name:      builderName,
+					IPAddress: ip,
+					IsActive:  true,
+					DNSName:   dnsName.String,
+				},
+				Services: []domain.BuilderServices{},
+			})
+		}
+		if service.Valid {
+			b := &builders[len(builders)-1]
+			b.Services = append(b.Services, domain.BuilderServices{
+				TLSCert:     tlsCert.String,
+				ECDSAPubKey: domain.Bytes2Address(ecdsaPubKey),
+				Service:     service.String,
+				Region:      region.String,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return builders, nil
+}
+
+// LogEvent creates a new log entry in the event_log table
+func (s *Service) LogEvent(ctx context.Context, eventName, builderName, name string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO event_log
+		(event_name, builder_name, measurement_id)
+		VALUES (?, ?,
+			(SELECT id FROM measurements_whitelist WHERE name = ?)
+		)
+	`, eventName, builderName, name)
+	if err != nil {
+		return fmt.Errorf("failed to insert event log for builder %s: %w", builderName, mapError(err))
+	}
+	return nil
+}
+
+func (s *Service) AddMeasurement(ctx context.Context, measurement domain.Measurement, enabled bool) error {
+	bts, err := json.Marshal(measurement.Measurement)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO measurements_whitelist (name, attestation_type, measurement, is_active)
+		VALUES (?, ?, ?, ?)
+	`, measurement.Name, measurement.AttestationType, string(bts), enabled)
+	if err != nil {
+		return mapError(err)
+	}
+
+	change := domain.MeasurementChange{
+		Action:          domain.MeasurementActionAdd,
+		Name:            measurement.Name,
+		AttestationType: measurement.AttestationType,
+		Measurements:    bts,
+		Timestamp:       time.Now().Unix(),
+	}
+	if err = appendMeasurementLog(ctx, tx, change); err != nil {
+		return err
+	}
+	if enabled {
+		change.Action = domain.MeasurementActionActivate
+		if err = appendMeasurementLog(ctx, tx, change); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Service) AddBuilder(ctx context.Context, builder domain.Builder) error {
+	ip, err := canonicalIP(builder.IPAddress)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO builders (name, ip_address, is_active, network, dns_name)
+		VALUES (?, ?, ?, ?, ?)
+	`, builder.Name, ip, builder.IsActive, builder.Network, sql.NullString{String: builder.DNSName, Valid: builder.DNSName != ""})
+	return mapError(err)
+}
+
+func (s *Service) ChangeActiveStatusForBuilder(ctx context.Context, builderName string, isActive bool) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE builders
+		SET is_active = ?
+		WHERE name = ?
+	`, isActive, builderName)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Service) ChangeActiveStatusForMeasurement(ctx context.Context, measurementName string, isActive bool) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	// only rows whose status changes are logged, setting the current status again is a no-op
+	var changed []measurement
+	err = tx.SelectContext(ctx, &changed, `
+		UPDATE measurements_whitelist
+		SET is_active = ?
+		WHERE name = ? AND is_active != ?
+		RETURNING name, attestation_type, measurement
+	`, isActive, measurementName, isActive)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		var exists bool
+		err = tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM measurements_whitelist WHERE name = ?)`, measurementName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("measurement %s: %w", measurementName, domain.ErrNotFound)
+		}
+		return nil
+	}
+
+	action := domain.MeasurementActionDeactivate
+	if isActive {
+		action = domain.MeasurementActionActivate
+	}
+	for _, m := range changed {
+		err = appendMeasurementLog(ctx, tx, domain.MeasurementChange{
+			Action:          action,
+			Name:            m.Name,
+			AttestationType: m.AttestationType,
+			Measurements:    m.Measurement,
+			Timestamp:       time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// appendMeasurementLog appends a change to the measurement transparency log within tx
+func appendMeasurementLog(ctx context.Context, tx *sqlx.Tx, change domain.MeasurementChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO measurements_log (leaf_index, data)
+		SELECT COALESCE(MAX(leaf_index) + 1, 0), ? FROM measurements_log
+	`, data)
+	if err != nil {
+		return fmt.Errorf("failed to append measurement log entry: %w", err)
+	}
+	return nil
+}
+
+// GetMeasurementLogEntries returns up to limit transparency log entries starting at leaf index start
+func (s *Service) GetMeasurementLogEntries(ctx context.Context, start uint64, limit int) ([]domain.MeasurementLogEntry, error) {
+	var rows []struct {
+		LeafIndex int64  `db:"leaf_index"`
+		Data      []byte `db:"data"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT leaf_index, data FROM measurements_log
+		WHERE leaf_index >= ?
+		ORDER BY leaf_index
+		LIMIT ?
+	`, int64(start), limit) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]domain.MeasurementLogEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, domain.MeasurementLogEntry{Index: uint64(row.LeafIndex), Data: row.Data}) //nolint:gosec
+	}
+	return entries, nil
+}
+
+func (s *Service) AddBuilderConfig(ctx context.Context, builderName string, config json.RawMessage) error {
+	// postgres stores configs as JSONB and rejects invalid JSON
+	if !json.Valid(config) {
+		return fmt.Errorf("failed to insert new config for builder %s: %w JSON", builderName, domain.ErrValidation)
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE builder_configs
+		SET is_active = 0, updated_at = CURRENT_TIMESTAMP
+		WHERE builder_name = ? AND is_active = 1
+	`, builderName)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate previous configs for builder %s: %w", builderName, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO builder_configs (builder_name, config, is_active)
+		VALUES (?, ?, 1)
+	`, builderName, string(config))
+	if err != nil {
+		return fmt.Errorf("failed to insert new config for builder %s: %w", builderName, mapError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for builder %s: %w", builderName, err)
+	}
+	return nil
+}
+
+type configSchema struct {
+	Scope  string `db:"scope"`
+	Target string `db:"target"`
+	Schema []byte `db:"schema"`
+}
+
+// SetConfigSchema registers the schema of a scope, replacing the previous one
+func (s *Service) SetConfigSchema(ctx context.Context, schema domain.ConfigSchema) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_schemas (scope, target, schema)
+		VALUES (?, ?, ?)
+		ON CONFLICT (scope, target) DO UPDATE SET schema = excluded.schema, updated_at = CURRENT_TIMESTAMP
+	`, schema.Scope, schema.Target, string(schema.Schema))
+	return mapError(err)
+}
+
+func (s *Service) DeleteConfigSchema(ctx context.Context, scope, target string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM config_schemas WHERE scope = ? AND target = ?`, scope, target)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s schema %s: %w", scope, target, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetConfigSchemas returns all registered schemas ordered by scope and target
+func (s *Service) GetConfigSchemas(ctx context.Context) ([]domain.ConfigSchema, error) {
+	var rows []configSchema
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT scope, target, schema FROM config_schemas ORDER BY scope, target`); err != nil {
+		return nil, err
+	}
+	schemas := make([]domain.ConfigSchema, 0, len(rows))
+	for _, row := range rows {
+		schemas = append(schemas, domain.ConfigSchema{Scope: row.Scope, Target: row.Target, Schema: row.Schema})
+	}
+	return schemas, nil
+}
+
+// SetConfigLayer creates or replaces a network or group layer
+func (s *Service) SetConfigLayer(ctx context.Context, layer domain.ConfigLayer) error {
+	if !json.Valid(layer.Config) {
+		return fmt.Errorf("%w config layer JSON", domain.ErrValidation)
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_layers (kind, name, config)
+		VALUES (?, ?, ?)
+		ON CONFLICT (kind, name) DO UPDATE SET config = excluded.config, updated_at = CURRENT_TIMESTAMP
+	`, layer.Kind, layer.Name, string(layer.Config))
+	return mapError(err)
+}
+
+func (s *Service) DeleteConfigLayer(ctx context.Context, kind, name string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM config_layers WHERE kind = ? AND name = ?`, kind, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%s config layer %s: %w", kind, name, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetConfigLayers returns the network and group layers ordered by kind and name
+func (s *Service) GetConfigLayers(ctx context.Context) ([]domain.ConfigLayer, error) {
+	var rows []configLayer
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT kind, name, config FROM config_layers ORDER BY kind, name`); err != nil {
+		return nil, err
+	}
+	layers := make([]domain.ConfigLayer, 0, len(rows))
+	for _, row := range rows {
+		layers = append(layers, domain.ConfigLayer{Kind: row.Kind, Name: row.Name, Config: row.Config})
+	}
+	return layers, nil
+}
+
+// SetConfigGroupsForBuilder replaces the group layers of a builder, groups are merged in the given order
+func (s *Service) SetConfigGroupsForBuilder(ctx context.Context, builderName string, groups []string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	var exists bool
+	if err = tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM builders WHERE name = ?)`, builderName); err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM builder_config_groups WHERE builder_name = ?`, builderName); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO builder_config_groups (builder_name, group_name, position)
+			VALUES (?, ?, ?)
+		`, builderName, group, i)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetConfigGroupsForBuilder returns the group layers assigned to a builder in merge order
+func (s *Service) GetConfigGroupsForBuilder(ctx context.Context, builderName string) ([]string, error) {
+	groups := []string{}
+	err := s.DB.SelectContext(ctx, &groups, `
+		SELECT group_name FROM builder_config_groups
+		WHERE builder_name = ?
+		ORDER BY position
+	`, builderName)
+	return groups, err
+}
+
+type rollout struct {
+	ID         int64          `db:"id"`
+	LayerKind  string         `db:"layer_kind"`
+	LayerName  string         `db:"layer_name"`
+	Config     []byte         `db:"config"`
+	Builders   []byte         `db:"builders"`
+	Percent    int            `db:"percent"`
+	Status     string         `db:"status"`
+	CreatedAt  string         `db:"created_at"`
+	FinishedAt sql.NullString `db:"finished_at"`
+}
+
+// parseTimestamp parses the UTC timestamps of CURRENT_TIMESTAMP
+func parseTimestamp(ts string) (time.Time, error) {
+	return time.ParseInLocation(time.DateTime, ts, time.UTC)
+}
+
+func (r rollout) toDomain() (*domain.Rollout, error) {
+	res := &domain.Rollout{
+		ID:      r.ID,
+		Layer:   domain.ConfigLayer{Kind: r.LayerKind, Name: r.LayerName, Config: r.Config},
+		Percent: r.Percent,
+		Status:  r.Status,
+	}
+	if err := json.Unmarshal(r.Builders, &res.Builders); err != nil {
+		return nil, err
+	}
+	var err error
+	if res.CreatedAt, err = parseTimestamp(r.CreatedAt); err != nil {
+		return nil, err
+	}
+	if r.FinishedAt.Valid {
+		finishedAt, err := parseTimestamp(r.FinishedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		res.FinishedAt = &finishedAt
+	}
+	return res, nil
+}
+
+func (s *Service) selectRollouts(ctx context.Context, where string, args ...any) ([]domain.Rollout, error) {
+	var rows []rollout
+	if err := s.DB.SelectContext(ctx, &rows, `SELECT * FROM config_rollouts `+where+` ORDER BY id`, args...); err != nil {
+		return nil, err
+	}
+	res := make([]domain.Rollout, 0, len(rows))
+	for _, row := range rows {
+		r, err := row.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *r)
+	}
+	return res, nil
+}
+
+// CreateRollout starts a rollout, there can be only one active rollout per layer
+func (s *Service) CreateRollout(ctx context.Context, r domain.Rollout) (*domain.Rollout, error) {
+	if err := domain.ValidRollout(r); err != nil {
+		return nil, err
+	}
+	builders, err := json.Marshal(append([]string{}, r.Builders...))
+	if err != nil {
+		return nil, err
+	}
+	var id int64
+	err = s.DB.GetContext(ctx, &id, `
+		INSERT INTO config_rollouts (layer_kind, layer_name, config, builders, percent)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, r.Layer.Kind, r.Layer.Name, string(r.Layer.Config), string(builders), r.Percent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollout of %s: %w", r.Layer.ID(), mapError(err))
+	}
+	return s.GetRollout(ctx, id)
+}
+
+// GetRollouts returns all rollouts ordered by ID
+func (s *Service) GetRollouts(ctx context.Context) ([]domain.Rollout, error) {
+	return s.selectRollouts(ctx, "")
+}
+
+func (s *Service) GetRollout(ctx context.Context, id int64) (*domain.Rollout, error) {
+	rollouts, err := s.selectRollouts(ctx, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rollouts) == 0 {
+		return nil, fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	return &rollouts[0], nil
+}
+
+// PromoteRollout replaces the shared layer with the new version of an active rollout
+func (s *Service) PromoteRollout(ctx context.Context, id int64) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	var row rollout
+	err = tx.GetContext(ctx, &row, `SELECT * FROM config_rollouts WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if row.Status != domain.RolloutActive {
+		return fmt.Errorf("rollout %d is %s: %w", id, row.Status, domain.ErrConflict)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO config_layers (kind, name, config)
+		VALUES (?, ?, ?)
+		ON CONFLICT (kind, name) DO UPDATE SET config = excluded.config, updated_at = CURRENT_TIMESTAMP
+	`, row.LayerKind, row.LayerName, string(row.Config))
+	if err != nil {
+		return mapError(err)
+	}
+	if err = finishRollout(ctx, tx, id, domain.RolloutPromoted); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AbortRollout ends an active rollout, the targeted builders get the current layer again
+func (s *Service) AbortRollout(ctx context.Context, id int64) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	if err = finishRollout(ctx, tx, id, domain.RolloutAborted); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func finishRollout(ctx context.Context, tx *sqlx.Tx, id int64, status string) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE config_rollouts SET status = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'active'
+	`, status, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+	var current string
+	err = tx.GetContext(ctx, &current, `SELECT status FROM config_rollouts WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("rollout %d: %w", id, domain.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("rollout %d is %s: %w", id, current, domain.ErrConflict)
+}
+
+// RecordRolloutFetch records that the builder fetched a config with the new layer of the rollout, the first
+// fetch is kept
+func (s *Service) RecordRolloutFetch(ctx context.Context, id int64, builderName string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO config_rollout_fetches (rollout_id, builder_name) VALUES (?, ?)
+		ON CONFLICT (rollout_id, builder_name) DO NOTHING
+	`, id, builderName)
+	if err != nil {
+		return fmt.Errorf("failed to record fetch of rollout %d by builder %s: %w", id, builderName, mapError(err))
+	}
+	return nil
+}
+
+// GetRolloutFetches returns the first fetch of the rollout's new layer by each builder
+func (s *Service) GetRolloutFetches(ctx context.Context, id int64) (map[string]time.Time, error) {
+	var rows []struct {
+		BuilderName string `db:"builder_name"`
+		FetchedAt   string `db:"fetched_at"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, fetched_at FROM config_rollout_fetches WHERE rollout_id = ?
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		if res[row.BuilderName], err = parseTimestamp(row.FetchedAt); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// formatTimestamp formats a time like CURRENT_TIMESTAMP, with second precision
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+func formatNullTimestamp(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTimestamp(*t), Valid: true}
+}
+
+func parseNullTimestamp(ts sql.NullString) (*time.Time, error) {
+	if !ts.Valid {
+		return nil, nil
+	}
+	t, err := parseTimestamp(ts.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+type rotationPolicy struct {
+	BuilderName       string         `db:"builder_name"`
+	Key               string         `db:"key"`
+	Generator         string         `db:"generator"`
+	Length            int            `db:"length"`
+	IntervalSeconds   int64          `db:"interval_seconds"`
+	OverlapSeconds    int64          `db:"overlap_seconds"`
+	LastRotatedAt     sql.NullString `db:"last_rotated_at"`
+	PreviousExpiresAt sql.NullString `db:"previous_expires_at"`
+}
+
+// SetRotationPolicy creates or updates the rotation policy of a builder secret, the rotation state is kept
+func (s *Service) SetRotationPolicy(ctx context.Context, p domain.RotationPolicy) error {
+	if err := domain.ValidRotationPolicy(p); err != nil {
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO secret_rotation_policies (builder_name, key, generator, length, interval_seconds, overlap_seconds)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (builder_name, key) DO UPDATE SET
+			generator = excluded.generator, length = excluded.length, interval_seconds = excluded.interval_seconds,
+			overlap_seconds = excluded.overlap_seconds, updated_at = CURRENT_TIMESTAMP
+	`, p.BuilderName, p.Key, p.Generator, p.Length, int64(time.Duration(p.Interval)/time.Second), int64(time.Duration(p.Overlap)/time.Second))
+	if err != nil {
+		return fmt.Errorf("failed to set rotation policy of %s %s: %w", p.BuilderName, p.Key, mapError(err))
+	}
+	return nil
+}
+
+func (s *Service) DeleteRotationPolicy(ctx context.Context, builderName, key string) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM secret_rotation_policies WHERE builder_name = ? AND key = ?`, builderName, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetRotationPolicies returns all rotation policies ordered by builder name and key
+func (s *Service) GetRotationPolicies(ctx context.Context) ([]domain.RotationPolicy, error) {
+	var rows []rotationPolicy
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, key, generator, length, interval_seconds, overlap_seconds, last_rotated_at, previous_expires_at
+		FROM secret_rotation_policies
+		ORDER BY builder_name, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.RotationPolicy, 0, len(rows))
+	for _, row := range rows {
+		p := domain.RotationPolicy{
+			BuilderName: row.BuilderName,
+			Key:         row.Key,
+			Generator:   row.Generator,
+			Length:      row.Length,
+			Interval:    domain.Duration(time.Duration(row.IntervalSeconds) * time.Second),
+			Overlap:     domain.Duration(time.Duration(row.OverlapSeconds) * time.Second),
+		}
+		if p.LastRotatedAt, err = parseNullTimestamp(row.LastRotatedAt); err != nil {
+			return nil, err
+		}
+		if p.PreviousExpiresAt, err = parseNullTimestamp(row.PreviousExpiresAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// RecordSecretRotation updates the rotation state of the policy and logs the rotation
+func (s *Service) RecordSecretRotation(ctx context.Context, r domain.SecretRotation) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}() // Rollback the transaction if it's not committed
+
+	rotatedAt, previousExpiresAt := formatTimestamp(r.RotatedAt), formatNullTimestamp(r.PreviousExpiresAt)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE secret_rotation_policies SET last_rotated_at = ?, previous_expires_at = ?
+		WHERE builder_name = ? AND key = ?
+	`, rotatedAt, previousExpiresAt, r.BuilderName, r.Key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", r.BuilderName, r.Key, domain.ErrNotFound)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO secret_rotations (builder_name, key, trigger, rotated_at, previous_expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, r.BuilderName, r.Key, r.Trigger, rotatedAt, previousExpiresAt)
+	if err != nil {
+		return mapError(err)
+	}
+	return tx.Commit()
+}
+
+// ExpirePreviousSecret records that the previous value of a rotated secret was removed
+func (s *Service) ExpirePreviousSecret(ctx context.Context, builderName, key string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE secret_rotation_policies SET previous_expires_at = NULL
+		WHERE builder_name = ? AND key = ?
+	`, builderName, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetSecretRotations returns the rotations of a builder's secrets, oldest first
+func (s *Service) GetSecretRotations(ctx context.Context, builderName string) ([]domain.SecretRotation, error) {
+	var rows []struct {
+		BuilderName       string         `db:"builder_name"`
+		Key               string         `db:"key"`
+		Trigger           string         `db:"trigger"`
+		RotatedAt         string         `db:"rotated_at"`
+		PreviousExpiresAt sql.NullString `db:"previous_expires_at"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+		SELECT builder_name, key, trigger, rotated_at, previous_expires_at
+		FROM secret_rotations
+		WHERE builder_name = ?
+		ORDER BY id
+	`, builderName)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SecretRotation, 0, len(rows))
+	for _, row := range rows {
+		r := domain.SecretRotation{BuilderName: row.BuilderName, Key: row.Key, Trigger: row.Trigger}
+		if r.RotatedAt, err = parseTimestamp(row.RotatedAt); err != nil {
+			return nil, err
+		}
+		if r.PreviousExpiresAt, err = parseNullTimestamp(row.PreviousExpiresAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
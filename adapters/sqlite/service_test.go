@@ -0,0 +1,162 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/flashbots/builder-hub/adapters/storagetest"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	s, err := NewSQLiteService(filepath.Join(t.TempDir(), "builder-hub.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestAdminFlow(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	measurement := domain.NewMeasurement("test-measurement-1", "test-type", map[string]domain.SingleMeasurement{"test": {Expected: "0x1234"}})
+	require.NoError(t, s.AddMeasurement(ctx, *measurement, false))
+	require.Error(t, s.AddMeasurement(ctx, *measurement, false), "measurement names are unique")
+
+	require.NoError(t, s.AddBuilder(ctx, domain.Builder{
+		Name:      "test-builder",
+		IPAddress: net.ParseIP("127.0.0.1"),
+		Network:   domain.ProductionNetwork,
+		DNSName:   "builder.example",
+	}))
+	require.Error(t, s.AddBuilder(ctx, domain.Builder{Name: "test-builder", IPAddress: net.ParseIP("127.0.0.2")}), "builder names are unique")
+
+	require.NoError(t, s.AddBuilderConfig(ctx, "test-builder", json.RawMessage(`{"key1":"value1"}`)))
+	require.NoError(t, s.AddBuilderConfig(ctx, "test-builder", json.RawMessage(`{"key1":"value2"}`)))
+	config, err := s.GetActiveConfigForBuilder(ctx, "test-builder")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key1":"value2"}`, string(config))
+	_, err = s.GetActiveConfigForBuilder(ctx, "unknown-builder")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	builders, err := s.GetActiveBuildersWithServiceCredentials(ctx, domain.ProductionNetwork)
+	require.NoError(t, err)
+	require.Len(t, builders, 0)
+	_, err = s.GetBuilderByIP(ctx, net.ParseIP("127.0.0.1"))
+	require.ErrorIs(t, err, domain.ErrNotFound, "inactive builders are not matched")
+
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "test-measurement-1", true))
+	require.NoError(t, s.ChangeActiveStatusForBuilder(ctx, "test-builder", true))
+
+	// INET matching: the IPv4-mapped IPv6 form matches the stored IPv4 address
+	b, err := s.GetBuilderByIP(ctx, net.ParseIP("::ffff:127.0.0.1"))
+	require.NoError(t, err)
+	require.Equal(t, "test-builder", b.Name)
+	require.Equal(t, "builder.example", b.DNSName)
+	require.True(t, b.IPAddress.Equal(net.ParseIP("127.0.0.1")))
+	_, err = s.GetBuilderByIP(ctx, net.ParseIP("127.0.0.2"))
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	measurements, err := s.GetActiveMeasurements(ctx)
+	require.NoError(t, err)
+	require.Len(t, measurements, 1)
+	require.Equal(t, "0x1234", measurements[0].Measurement["test"].Expected)
+	measurements, err = s.GetActiveMeasurementsByType(ctx, "other-type")
+	require.NoError(t, err)
+	require.Len(t, measurements, 0)
+
+	// re-registering a service deprecates the previous credentials
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "test-builder", "rbuilder", "cert-1", nil, "test-measurement-1", "test-type", "eu"))
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "test-builder", "rbuilder", "cert-2", []byte{0x01}, "test-measurement-1", "test-type", "eu"))
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "test-builder", "orderflow-proxy", "", nil, "test-measurement-1", "test-type", ""))
+	require.Error(t, s.RegisterCredentialsForBuilder(ctx, "unknown-builder", "rbuilder", "", nil, "", "", ""), "credentials require an existing builder")
+
+	builders, err = s.GetActiveBuildersWithServiceCredentials(ctx, domain.ProductionNetwork)
+	require.NoError(t, err)
+	require.Len(t, builders, 1)
+	require.Len(t, builders[0].Services, 2)
+	require.Equal(t, "orderflow-proxy", builders[0].Services[0].Service)
+	require.Equal(t, "cert-2", builders[0].Services[1].TLSCert)
+	require.Equal(t, "eu", builders[0].Services[1].Region)
+	require.NotNil(t, builders[0].Services[1].ECDSAPubKey)
+
+	require.NoError(t, s.LogEvent(ctx, domain.EventGetConfig, "test-builder", "test-measurement-1"))
+
+	// the measurement changes are in the transparency log
+	entries, err := s.GetMeasurementLogEntries(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for i, action := range []string{domain.MeasurementActionAdd, domain.MeasurementActionActivate} {
+		var change domain.MeasurementChange
+		require.Equal(t, uint64(i), entries[i].Index)
+		require.NoError(t, json.Unmarshal(entries[i].Data, &change))
+		require.Equal(t, action, change.Action)
+		require.Equal(t, "test-measurement-1", change.Name)
+	}
+	_, err = s.DB.Exec(`DELETE FROM measurements_log`)
+	require.ErrorContains(t, err, "append-only")
+}
+
+func TestSingleActiveConfig(t *testing.T) {
+	s := newTestService(t)
+	require.NoError(t, s.AddBuilder(context.Background(), domain.Builder{Name: "b", IPAddress: net.ParseIP("10.0.0.1")}))
+	_, err := s.DB.Exec(`INSERT INTO builder_configs (builder_name, config, is_active) VALUES ('b', '{}', 1), ('b', '{}', 1)`)
+	require.Error(t, err)
+	_, err = s.DB.Exec(`INSERT INTO builder_configs (builder_name, config, is_active) VALUES ('b', '{}', 0), ('b', '{}', 0), ('b', '{}', 1)`)
+	require.NoError(t, err)
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "builder-hub.db")
+	s, err := NewSQLiteService(path)
+	require.NoError(t, err)
+	require.NoError(t, s.AddBuilder(context.Background(), domain.Builder{Name: "b", IPAddress: net.ParseIP("10.0.0.1"), IsActive: true}))
+	require.NoError(t, s.Close())
+
+	s, err = NewSQLiteService(path)
+	require.NoError(t, err)
+	defer s.Close() //nolint:errcheck
+	b, err := s.GetBuilderByIP(context.Background(), net.ParseIP("10.0.0.1"))
+	require.NoError(t, err)
+	require.Equal(t, "b", b.Name)
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Store {
+		return newTestService(t)
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "builder-hub.db")
+	s, err := NewSQLiteService(path)
+	require.NoError(t, err)
+	// downgrade to a version 1 database
+	_, err = s.DB.Exec(`DROP TABLE config_rollout_fetches; DROP TABLE secret_rotations; DROP TABLE secret_rotation_policies; DROP TABLE config_rollouts; DROP TABLE builder_config_groups; DROP TABLE config_layers; DROP TABLE config_schemas; PRAGMA user_version = 1`)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = NewSQLiteService(path)
+	require.NoError(t, err)
+	defer s.Close() //nolint:errcheck
+	schemas, err := s.GetConfigSchemas(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, schemas)
+	layers, err := s.GetConfigLayers(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, layers)
+	rollouts, err := s.GetRollouts(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, rollouts)
+	policies, err := s.GetRotationPolicies(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, policies)
+	var version int
+	require.NoError(t, s.DB.Get(&version, `PRAGMA user_version`))
+	require.Equal(t, schemaVersion, version)
+}
@@ -0,0 +1,554 @@
+// Package storagetest is a conformance suite every storage backend must pass, so that the
+// Postgres, SQLite and in-memory implementations behave the same
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/ports"
+	"github.com/flashbots/builder-hub/transparency"
+	"github.com/stretchr/testify/require"
+)
+
+// Store is the storage surface used by the hub
+type Store interface {
+	application.BuilderDataAccessor
+	ports.AdminBuilderService
+	transparency.EntryStore
+}
+
+// Run runs the suite. newStore must return an empty store for every call; the measurement log may be non-empty.
+func Run(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Helper()
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Store)
+	}{
+		{"measurements", testMeasurements},
+		{"measurement log", testMeasurementLog},
+		{"builders", testBuilders},
+		{"listing", testListing},
+		{"configs", testConfigs},
+		{"credentials", testCredentials},
+		{"builder ordering", testBuilderOrdering},
+		{"events", testEvents},
+		{"config schemas", testConfigSchemas},
+		{"config layers", testConfigLayers},
+		{"config rollouts", testConfigRollouts},
+		{"secret rotation", testSecretRotation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func measurement(name, attestationType string) domain.Measurement {
+	return *domain.NewMeasurement(name, attestationType, map[string]domain.SingleMeasurement{
+		"4":  {Expected: "0x" + name},
+		"11": {ExpectedAny: []string{"0x01", "0x02"}},
+	})
+}
+
+func addBuilder(t *testing.T, s Store, name, ip, network string, active bool) {
+	t.Helper()
+	require.NoError(t, s.AddBuilder(context.Background(), domain.Builder{
+		Name:      name,
+		IPAddress: net.ParseIP(ip),
+		IsActive:  active,
+		Network:   network,
+	}))
+}
+
+func names(measurements []domain.Measurement) []string {
+	res := make([]string, 0, len(measurements))
+	for _, m := range measurements {
+		res = append(res, m.Name)
+	}
+	return res
+}
+
+func testMeasurements(t *testing.T, s Store) {
+	ctx := context.Background()
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-1", "azure-tdx"), true))
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-2", "dcap-tdx"), true))
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-3", "azure-tdx"), false))
+	require.ErrorIs(t, s.AddMeasurement(ctx, measurement("m-1", "dcap-tdx"), true), domain.ErrConflict, "measurement names are unique")
+
+	active, err := s.GetActiveMeasurements(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"m-1", "m-2"}, names(active))
+
+	byType, err := s.GetActiveMeasurementsByType(ctx, "azure-tdx")
+	require.NoError(t, err)
+	require.Equal(t, []domain.Measurement{measurement("m-1", "azure-tdx")}, byType)
+
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-3", true))
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-1", false))
+	byType, err = s.GetActiveMeasurementsByType(ctx, "azure-tdx")
+	require.NoError(t, err)
+	require.Equal(t, []string{"m-3"}, names(byType))
+
+	byType, err = s.GetActiveMeasurementsByType(ctx, "unknown-type")
+	require.NoError(t, err)
+	require.Empty(t, byType)
+
+	require.ErrorIs(t, s.ChangeActiveStatusForMeasurement(ctx, "unknown", true), domain.ErrNotFound)
+}
+
+func logEntries(t *testing.T, s Store, start uint64) []domain.MeasurementLogEntry {
+	t.Helper()
+	var res []domain.MeasurementLogEntry
+	for {
+		entries, err := s.GetMeasurementLogEntries(context.Background(), start+uint64(len(res)), 100)
+		require.NoError(t, err)
+		res = append(res, entries...)
+		if len(entries) < 100 {
+			return res
+		}
+	}
+}
+
+func testMeasurementLog(t *testing.T, s Store) {
+	ctx := context.Background()
+	start := uint64(len(logEntries(t, s, 0)))
+
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-1", "azure-tdx"), false))
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-2", "azure-tdx"), true))
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-1", true))
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-2", false))
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-2", false), "setting the current status is not logged")
+	require.NoError(t, s.ChangeActiveStatusForMeasurement(ctx, "m-1", true))
+	require.ErrorIs(t, s.ChangeActiveStatusForMeasurement(ctx, "unknown", false), domain.ErrNotFound)
+
+	expected := []struct{ action, name string }{
+		{domain.MeasurementActionAdd, "m-1"},
+		{domain.MeasurementActionAdd, "m-2"},
+		{domain.MeasurementActionActivate, "m-2"},
+		{domain.MeasurementActionActivate, "m-1"},
+		{domain.MeasurementActionDeactivate, "m-2"},
+	}
+	entries := logEntries(t, s, start)
+	require.Len(t, entries, len(expected))
+	for i, e := range expected {
+		require.Equal(t, start+uint64(i), entries[i].Index)
+		var change domain.MeasurementChange
+		require.NoError(t, json.Unmarshal(entries[i].Data, &change))
+		require.Equal(t, e.action, change.Action)
+		require.Equal(t, e.name, change.Name)
+		require.Equal(t, "azure-tdx", change.AttestationType)
+		var m map[string]domain.SingleMeasurement
+		require.NoError(t, json.Unmarshal(change.Measurements, &m))
+		require.Equal(t, measurement(e.name, "azure-tdx").Measurement, m)
+	}
+
+	limited, err := s.GetMeasurementLogEntries(ctx, start+1, 2)
+	require.NoError(t, err)
+	require.Len(t, limited, 2)
+	require.Equal(t, start+1, limited[0].Index)
+}
+
+func testBuilders(t *testing.T, s Store) {
+	ctx := context.Background()
+	addBuilder(t, s, "b-1", "10.0.0.1", domain.ProductionNetwork, false)
+	require.ErrorIs(t, s.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.2")}), domain.ErrConflict, "builder names are unique")
+
+	_, err := s.GetBuilderByIP(ctx, net.ParseIP("10.0.0.1"))
+	require.ErrorIs(t, err, domain.ErrNotFound, "inactive builders are not matched")
+
+	require.NoError(t, s.ChangeActiveStatusForBuilder(ctx, "b-1", true))
+	for _, ip := range []string{"10.0.0.1", "::ffff:10.0.0.1"} {
+		b, err := s.GetBuilderByIP(ctx, net.ParseIP(ip))
+		require.NoError(t, err, ip)
+		require.Equal(t, "b-1", b.Name)
+		require.True(t, b.IsActive)
+		require.Equal(t, domain.ProductionNetwork, b.Network)
+		require.True(t, b.IPAddress.Equal(net.ParseIP("10.0.0.1")))
+	}
+	_, err = s.GetBuilderByIP(ctx, net.ParseIP("10.0.0.2"))
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, s.AddBuilder(ctx, domain.Builder{
+		Name:      "b-6",
+		IPAddress: net.ParseIP("2001:db8::1"),
+		IsActive:  true,
+		Network:   "testnet",
+		DNSName:   "b-6.example",
+	}))
+	b, err := s.GetBuilderByIP(ctx, net.ParseIP("2001:db8:0::1"))
+	require.NoError(t, err)
+	require.Equal(t, "b-6", b.Name)
+	require.Equal(t, "b-6.example", b.DNSName)
+	require.Equal(t, "testnet", b.Network)
+
+	require.ErrorIs(t, s.ChangeActiveStatusForBuilder(ctx, "unknown", true), domain.ErrNotFound)
+}
+
+func testListing(t *testing.T, s Store) {
+	ctx := context.Background()
+	measurements, err := s.GetAllMeasurements(ctx)
+	require.NoError(t, err)
+	require.Empty(t, measurements)
+	builders, err := s.GetAllBuilders(ctx)
+	require.NoError(t, err)
+	require.Empty(t, builders)
+
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-2", "dcap-tdx"), true))
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-1", "azure-tdx"), false))
+	measurements, err = s.GetAllMeasurements(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []domain.MeasurementWithStatus{
+		{Measurement: measurement("m-1", "azure-tdx"), IsActive: false},
+		{Measurement: measurement("m-2", "dcap-tdx"), IsActive: true},
+	}, measurements)
+
+	addBuilder(t, s, "b-2", "10.0.0.2", "testnet", true)
+	require.NoError(t, s.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("2001:db8::1"), Network: domain.ProductionNetwork, DNSName: "b-1.example"}))
+	builders, err = s.GetAllBuilders(ctx)
+	require.NoError(t, err)
+	require.Len(t, builders, 2)
+	require.Equal(t, "b-1", builders[0].Name)
+	require.False(t, builders[0].IsActive)
+	require.Equal(t, "b-1.example", builders[0].DNSName)
+	require.Equal(t, domain.ProductionNetwork, builders[0].Network)
+	require.True(t, builders[0].IPAddress.Equal(net.ParseIP("2001:db8::1")))
+	require.Equal(t, "b-2", builders[1].Name)
+	require.True(t, builders[1].IsActive)
+	require.True(t, builders[1].IPAddress.Equal(net.ParseIP("10.0.0.2")))
+}
+
+func testConfigs(t *testing.T, s Store) {
+	ctx := context.Background()
+	addBuilder(t, s, "b-1", "10.0.0.1", domain.ProductionNetwork, true)
+
+	_, err := s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{"a": "1"}`)))
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{"a": "2", "b": {"c": "3"}}`)))
+	config, err := s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": "2", "b": {"c": "3"}}`, string(config), "only the latest config is active")
+
+	require.ErrorIs(t, s.AddBuilderConfig(ctx, "unknown", json.RawMessage(`{}`)), domain.ErrNotFound, "configs require an existing builder")
+	require.ErrorIs(t, s.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{`)), domain.ErrValidation, "configs must be valid JSON")
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": "2", "b": {"c": "3"}}`, string(config), "a failed update keeps the active config")
+}
+
+func testCredentials(t *testing.T, s Store) {
+	ctx := context.Background()
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-1", "azure-tdx"), true))
+	addBuilder(t, s, "b-1", "10.0.0.1", domain.ProductionNetwork, true)
+
+	pubKey := []byte{0x01, 0x02, 0x03}
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "b-1", "rbuilder", "cert-1", nil, "m-1", "azure-tdx", "eu"))
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "b-1", "rbuilder", "cert-2", pubKey, "m-1", "azure-tdx", "us"))
+	require.NoError(t, s.RegisterCredentialsForBuilder(ctx, "b-1", "orderflow-proxy", "", nil, "unknown-measurement", "azure-tdx", ""))
+	require.ErrorIs(t, s.RegisterCredentialsForBuilder(ctx, "unknown", "rbuilder", "", nil, "m-1", "azure-tdx", ""), domain.ErrNotFound, "credentials require an existing builder")
+
+	builders, err := s.GetActiveBuildersWithServiceCredentials(ctx, domain.ProductionNetwork)
+	require.NoError(t, err)
+	require.Equal(t, []domain.BuilderWithServices{{
+		Builder: domain.Builder{Name: "b-1", IPAddress: builders[0].Builder.IPAddress, IsActive: true},
+		Services: []domain.BuilderServices{
+			{Service: "orderflow-proxy"},
+			{Service: "rbuilder", TLSCert: "cert-2", ECDSAPubKey: domain.Bytes2Address(pubKey), Region: "us"},
+		},
+	}}, builders, "re-registering deprecates the previous credentials of the service")
+	require.True(t, builders[0].Builder.IPAddress.Equal(net.ParseIP("10.0.0.1")))
+}
+
+func testBuilderOrdering(t *testing.T, s Store) {
+	ctx := context.Background()
+	for _, name := range []string{"b-3", "b-1", "b-5", "b-2", "b-4"} {
+		addBuilder(t, s, name, "10.0.1."+name[2:], domain.ProductionNetwork, name != "b-4")
+	}
+	addBuilder(t, s, "b-0", "10.0.2.1", "testnet", true)
+	for _, name := range []string{"b-5", "b-2"} {
+		for _, service := range []string{"rbuilder", "orderflow-proxy", "attestation"} {
+			require.NoError(t, s.RegisterCredentialsForBuilder(ctx, name, service, "", nil, "", "", ""))
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		builders, err := s.GetActiveBuildersWithServiceCredentials(ctx, domain.ProductionNetwork)
+		require.NoError(t, err)
+		var got []string
+		for _, b := range builders {
+			got = append(got, b.Builder.Name)
+			require.NotNil(t, b.Services)
+			if b.Builder.Name == "b-5" || b.Builder.Name == "b-2" {
+				require.Len(t, b.Services, 3)
+				require.Equal(t, "attestation", b.Services[0].Service)
+				require.Equal(t, "orderflow-proxy", b.Services[1].Service)
+				require.Equal(t, "rbuilder", b.Services[2].Service)
+			} else {
+				require.Empty(t, b.Services)
+			}
+		}
+		require.Equal(t, []string{"b-1", "b-2", "b-3", "b-5"}, got, "builders are ordered by name")
+	}
+
+	builders, err := s.GetActiveBuildersWithServiceCredentials(ctx, "unknown-network")
+	require.NoError(t, err)
+	require.NotNil(t, builders)
+	require.Empty(t, builders)
+}
+
+func testEvents(t *testing.T, s Store) {
+	ctx := context.Background()
+	require.NoError(t, s.AddMeasurement(ctx, measurement("m-1", "azure-tdx"), true))
+	addBuilder(t, s, "b-1", "10.0.0.1", domain.ProductionNetwork, true)
+	require.NoError(t, s.LogEvent(ctx, domain.EventGetConfig, "b-1", "m-1"))
+	require.NoError(t, s.LogEvent(ctx, domain.EventGetConfig, "b-1", "unknown-measurement"))
+	require.ErrorIs(t, s.LogEvent(ctx, domain.EventGetConfig, "unknown", "m-1"), domain.ErrNotFound, "events require an existing builder")
+}
+
+func testConfigSchemas(t *testing.T, s Store) {
+	ctx := context.Background()
+	schemas, err := s.GetConfigSchemas(ctx)
+	require.NoError(t, err)
+	require.Empty(t, schemas)
+
+	require.NoError(t, s.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeNetwork, Target: "testnet", Schema: json.RawMessage(`{"type": "object"}`)}))
+	require.NoError(t, s.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeGlobal, Schema: json.RawMessage(`{"type": "object"}`)}))
+	require.NoError(t, s.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeGlobal, Schema: json.RawMessage(`{"required": ["a"]}`)}), "schemas are replaced")
+	require.ErrorIs(t, s.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeGlobal, Target: "x", Schema: json.RawMessage(`{}`)}), domain.ErrValidation)
+
+	schemas, err = s.GetConfigSchemas(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemas, 2)
+	require.Equal(t, domain.SchemaScopeGlobal, schemas[0].Scope)
+	require.JSONEq(t, `{"required": ["a"]}`, string(schemas[0].Schema))
+	require.Equal(t, "testnet", schemas[1].Target)
+
+	require.NoError(t, s.DeleteConfigSchema(ctx, domain.SchemaScopeNetwork, "testnet"))
+	require.ErrorIs(t, s.DeleteConfigSchema(ctx, domain.SchemaScopeNetwork, "testnet"), domain.ErrNotFound)
+	schemas, err = s.GetConfigSchemas(ctx)
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+}
+
+func testConfigLayers(t *testing.T, s Store) {
+	ctx := context.Background()
+	addBuilder(t, s, "b-1", "10.0.0.1", domain.ProductionNetwork, true)
+	addBuilder(t, s, "b-2", "10.0.0.2", "testnet", true)
+
+	require.NoError(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: domain.ProductionNetwork, Config: json.RawMessage(`{"rpc": {"url": "base", "timeout": 1}, "relays": ["a"]}`)}))
+	require.NoError(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerGroup, Name: "eu", Config: json.RawMessage(`{"rpc": {"url": "eu"}}`)}))
+	require.NoError(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerGroup, Name: "canary", Config: json.RawMessage(`{"relays": null}`)}))
+	require.ErrorIs(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: "region", Name: "eu", Config: json.RawMessage(`{}`)}), domain.ErrValidation)
+	require.ErrorIs(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerGroup, Name: "eu", Config: json.RawMessage(`[]`)}), domain.ErrValidation)
+
+	layers, err := s.GetConfigLayers(ctx)
+	require.NoError(t, err)
+	require.Len(t, layers, 3)
+	require.Equal(t, "canary", layers[0].Name)
+	require.Equal(t, domain.ConfigLayerNetwork, layers[2].Kind)
+
+	// the builder's own config is required
+	_, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	layers, err = s.GetConfigLayersForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{"rpc": {"timeout": 2}, "name": "b-1"}`)))
+	config, err := s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rpc": {"url": "base", "timeout": 2}, "relays": ["a"], "name": "b-1"}`, string(config))
+
+	require.NoError(t, s.SetConfigGroupsForBuilder(ctx, "b-1", []string{"eu", "canary", "missing"}))
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rpc": {"url": "eu", "timeout": 2}, "name": "b-1"}`, string(config), "groups are merged in order, missing ones are skipped")
+	layers, err = s.GetConfigLayersForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	var ids []string
+	for _, l := range layers {
+		ids = append(ids, l.ID())
+	}
+	require.Equal(t, []string{"network production", "group eu", "group canary", "builder b-1"}, ids)
+
+	groups, err := s.GetConfigGroupsForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"eu", "canary", "missing"}, groups)
+
+	require.NoError(t, s.SetConfigGroupsForBuilder(ctx, "b-1", []string{"canary"}))
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rpc": {"url": "base", "timeout": 2}, "name": "b-1"}`, string(config), "groups are replaced")
+	require.ErrorIs(t, s.SetConfigGroupsForBuilder(ctx, "b-1", []string{"eu", "eu"}), domain.ErrConflict)
+	groups, err = s.GetConfigGroupsForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"canary"}, groups, "a failed update keeps the groups")
+	require.ErrorIs(t, s.SetConfigGroupsForBuilder(ctx, "unknown", nil), domain.ErrNotFound)
+
+	// layers of other networks don't apply
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-2", json.RawMessage(`{"name": "b-2"}`)))
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name": "b-2"}`, string(config))
+
+	require.NoError(t, s.DeleteConfigLayer(ctx, domain.ConfigLayerNetwork, domain.ProductionNetwork))
+	require.ErrorIs(t, s.DeleteConfigLayer(ctx, domain.ConfigLayerNetwork, domain.ProductionNetwork), domain.ErrNotFound)
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rpc": {"timeout": 2}, "name": "b-1"}`, string(config))
+}
+
+func testConfigRollouts(t *testing.T, s Store) {
+	ctx := context.Background()
+	addBuilder(t, s, "b-1", "10.0.0.1", "testnet", true)
+	addBuilder(t, s, "b-2", "10.0.0.2", "testnet", true)
+	require.NoError(t, s.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: "testnet", Config: json.RawMessage(`{"relays": ["a"]}`)}))
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{"name": "b-1"}`)))
+	require.NoError(t, s.AddBuilderConfig(ctx, "b-2", json.RawMessage(`{"name": "b-2"}`)))
+
+	newLayer := domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: "testnet", Config: json.RawMessage(`{"relays": ["b"]}`)}
+	_, err := s.CreateRollout(ctx, domain.Rollout{Layer: newLayer})
+	require.ErrorIs(t, err, domain.ErrValidation, "builders or a percentage is required")
+	r, err := s.CreateRollout(ctx, domain.Rollout{Layer: newLayer, Builders: []string{"b-1"}})
+	require.NoError(t, err)
+	require.Equal(t, domain.RolloutActive, r.Status)
+	require.Equal(t, []string{"b-1"}, r.Builders)
+	require.False(t, r.CreatedAt.IsZero())
+	_, err = s.CreateRollout(ctx, domain.Rollout{Layer: newLayer, Percent: 50})
+	require.ErrorIs(t, err, domain.ErrConflict, "one active rollout per layer")
+
+	config, err := s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["b"], "name": "b-1"}`, string(config), "targeted builders get the new layer")
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["a"], "name": "b-2"}`, string(config))
+
+	layers, err := s.GetConfigLayersForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.Equal(t, []int64{r.ID}, domain.AppliedRollouts(layers))
+	layers, err = s.GetConfigLayersForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.Empty(t, domain.AppliedRollouts(layers))
+
+	require.NoError(t, s.RecordRolloutFetch(ctx, r.ID, "b-1"))
+	fetches, err := s.GetRolloutFetches(ctx, r.ID)
+	require.NoError(t, err)
+	require.Contains(t, fetches, "b-1")
+	require.NotContains(t, fetches, "b-2")
+	require.NoError(t, s.RecordRolloutFetch(ctx, r.ID, "b-1"))
+	again, err := s.GetRolloutFetches(ctx, r.ID)
+	require.NoError(t, err)
+	require.Equal(t, fetches["b-1"].Unix(), again["b-1"].Unix(), "the first fetch is kept")
+	require.ErrorIs(t, s.RecordRolloutFetch(ctx, r.ID+100, "b-1"), domain.ErrNotFound)
+	require.ErrorIs(t, s.RecordRolloutFetch(ctx, r.ID, "unknown"), domain.ErrNotFound)
+
+	require.NoError(t, s.PromoteRollout(ctx, r.ID))
+	require.ErrorIs(t, s.PromoteRollout(ctx, r.ID), domain.ErrConflict)
+	require.ErrorIs(t, s.AbortRollout(ctx, r.ID), domain.ErrConflict)
+	require.ErrorIs(t, s.PromoteRollout(ctx, r.ID+100), domain.ErrNotFound)
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["b"], "name": "b-2"}`, string(config), "promoting replaces the layer")
+
+	// aborting restores the current layer, new layers can be rolled out too
+	groupLayer := domain.ConfigLayer{Kind: domain.ConfigLayerGroup, Name: "canary", Config: json.RawMessage(`{"relays": ["c"]}`)}
+	require.NoError(t, s.SetConfigGroupsForBuilder(ctx, "b-2", []string{"canary"}))
+	r, err = s.CreateRollout(ctx, domain.Rollout{Layer: groupLayer, Percent: 100})
+	require.NoError(t, err)
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["c"], "name": "b-2"}`, string(config))
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["b"], "name": "b-1"}`, string(config), "b-1 is not in the group")
+	require.NoError(t, s.AbortRollout(ctx, r.ID))
+	config, err = s.GetActiveConfigForBuilder(ctx, "b-2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["b"], "name": "b-2"}`, string(config))
+
+	rollouts, err := s.GetRollouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, rollouts, 2)
+	require.Equal(t, domain.RolloutPromoted, rollouts[0].Status)
+	require.Equal(t, domain.RolloutAborted, rollouts[1].Status)
+	require.NotNil(t, rollouts[1].FinishedAt)
+	got, err := s.GetRollout(ctx, r.ID)
+	require.NoError(t, err)
+	require.Equal(t, 100, got.Percent)
+	require.JSONEq(t, `{"relays": ["c"]}`, string(got.Layer.Config))
+	_, err = s.GetRollout(ctx, r.ID+100)
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testSecretRotation(t *testing.T, s Store) {
+	ctx := context.Background()
+	addBuilder(t, s, "b-1", "10.0.0.1", "testnet", true)
+
+	policy := domain.RotationPolicy{
+		BuilderName: "b-1",
+		Key:         "relay.api_key",
+		Generator:   domain.GeneratorHex,
+		Length:      16,
+		Interval:    domain.Duration(24 * time.Hour),
+		Overlap:     domain.Duration(time.Hour),
+	}
+	require.NoError(t, s.SetRotationPolicy(ctx, policy))
+	require.NoError(t, s.SetRotationPolicy(ctx, domain.RotationPolicy{BuilderName: "b-1", Key: "password", Generator: domain.GeneratorPassword, Interval: domain.Duration(time.Hour)}))
+	require.ErrorIs(t, s.SetRotationPolicy(ctx, domain.RotationPolicy{BuilderName: "b-1", Key: "x", Generator: "uuid", Interval: domain.Duration(time.Hour)}), domain.ErrValidation)
+	unknown := policy
+	unknown.BuilderName = "unknown"
+	require.ErrorIs(t, s.SetRotationPolicy(ctx, unknown), domain.ErrNotFound)
+
+	policies, err := s.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	require.Equal(t, "password", policies[0].Key)
+	require.Equal(t, policy, policies[1])
+
+	rotatedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := rotatedAt.Add(time.Hour)
+	rotation := domain.SecretRotation{BuilderName: "b-1", Key: "relay.api_key", Trigger: domain.RotationScheduled, RotatedAt: rotatedAt, PreviousExpiresAt: &expiresAt}
+	require.NoError(t, s.RecordSecretRotation(ctx, rotation))
+	missing := rotation
+	missing.Key = "missing"
+	require.ErrorIs(t, s.RecordSecretRotation(ctx, missing), domain.ErrNotFound)
+
+	// updating the policy keeps the rotation state
+	policy.Interval = domain.Duration(48 * time.Hour)
+	require.NoError(t, s.SetRotationPolicy(ctx, policy))
+	policies, err = s.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Equal(t, domain.Duration(48*time.Hour), policies[1].Interval)
+	require.NotNil(t, policies[1].LastRotatedAt)
+	require.True(t, rotatedAt.Equal(*policies[1].LastRotatedAt))
+	require.NotNil(t, policies[1].PreviousExpiresAt)
+	require.True(t, expiresAt.Equal(*policies[1].PreviousExpiresAt))
+
+	require.NoError(t, s.ExpirePreviousSecret(ctx, "b-1", "relay.api_key"))
+	require.ErrorIs(t, s.ExpirePreviousSecret(ctx, "b-1", "missing"), domain.ErrNotFound)
+	policies, err = s.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Nil(t, policies[1].PreviousExpiresAt)
+
+	rotations, err := s.GetSecretRotations(ctx, "b-1")
+	require.NoError(t, err)
+	require.Len(t, rotations, 1)
+	require.Equal(t, "relay.api_key", rotations[0].Key)
+	require.Equal(t, domain.RotationScheduled, rotations[0].Trigger)
+	require.True(t, rotatedAt.Equal(rotations[0].RotatedAt))
+
+	require.NoError(t, s.DeleteRotationPolicy(ctx, "b-1", "relay.api_key"))
+	require.ErrorIs(t, s.DeleteRotationPolicy(ctx, "b-1", "relay.api_key"), domain.ErrNotFound)
+	policies, err = s.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+}
@@ -0,0 +1,211 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var errRemoteRef = errors.New("remote references are not supported, use $defs")
+
+// noLoader refuses to resolve references outside of the schema, e.g. to local files
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("%s: %w", url, errRemoteRef)
+}
+
+var printer = message.NewPrinter(language.English)
+
+// CompileConfigSchema checks that schema is a valid JSON Schema. The default draft is 2020-12.
+func CompileConfigSchema(schema json.RawMessage) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w schema JSON: %w", domain.ErrValidation, err)
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.UseLoader(noLoader{})
+	if err = c.AddResource("config-schema.json", doc); err != nil {
+		return nil, fmt.Errorf("%w schema: %w", domain.ErrValidation, err)
+	}
+	compiled, err := c.Compile("config-schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("%w schema: %w", domain.ErrValidation, err)
+	}
+	return compiled, nil
+}
+
+// ValidateConfig validates config against the schemas that apply to the builder: the global one, the one of its
+// network and its own. All violations are returned in a *domain.ValidationError.
+func ValidateConfig(schemas []domain.ConfigSchema, builder domain.Builder, config json.RawMessage) error {
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(config))
+	if err != nil {
+		return fmt.Errorf("%w config JSON: %w", domain.ErrValidation, err)
+	}
+	var violations []domain.Violation
+	for _, schema := range schemas {
+		if !appliesTo(schema, builder) {
+			continue
+		}
+		compiled, err := CompileConfigSchema(schema.Schema)
+		if err != nil {
+			return fmt.Errorf("%s schema %s: %w", schema.Scope, schema.Target, err)
+		}
+		var verr *jsonschema.ValidationError
+		if err = compiled.Validate(instance); errors.As(err, &verr) {
+			violations = append(violations, leafViolations(verr, schema)...)
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(violations) > 0 {
+		return &domain.ValidationError{Subject: "config", Violations: violations}
+	}
+	return nil
+}
+
+func appliesTo(schema domain.ConfigSchema, builder domain.Builder) bool {
+	switch schema.Scope {
+	case domain.SchemaScopeGlobal:
+		return true
+	case domain.SchemaScopeNetwork:
+		return schema.Target == builder.Network
+	case domain.SchemaScopeBuilder:
+		return schema.Target == builder.Name
+	default:
+		return false
+	}
+}
+
+// leafViolations returns the innermost errors, the others only say that a subschema failed
+func leafViolations(verr *jsonschema.ValidationError, schema domain.ConfigSchema) []domain.Violation {
+	if len(verr.Causes) == 0 {
+		scope := schema.Scope
+		if schema.Target != "" {
+			scope += " " + schema.Target
+		}
+		return []domain.Violation{{
+			Path:    jsonPointer(verr.InstanceLocation),
+			Message: fmt.Sprintf("%s (%s schema)", verr.ErrorKind.LocalizedString(printer), scope),
+		}}
+	}
+	var res []domain.Violation
+	for _, cause := range verr.Causes {
+		res = append(res, leafViolations(cause, schema)...)
+	}
+	return res
+}
+
+func jsonPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return sb.String()
+}
+
+// CheckSecretPaths checks that the secrets can be merged into config without changing its structure.
+// A secret may replace a string leaf (a placeholder), but not an object, an array or a non-string value,
+// and its path may not run through a leaf.
+func CheckSecretPaths(config, secrets json.RawMessage) error {
+	secretPaths, err := FlattenJSONFromBytes(secrets)
+	if err != nil {
+		return fmt.Errorf("%w secrets: %w", domain.ErrValidation, err)
+	}
+	var parsed any
+	if err = json.Unmarshal(config, &parsed); err != nil {
+		return fmt.Errorf("%w config JSON: %w", domain.ErrValidation, err)
+	}
+	leaves := make(map[string]any)
+	configLeaves(parsed, "", leaves)
+
+	var violations []domain.Violation
+	for path := range secretPaths {
+		if msg := secretCollision(path, leaves); msg != "" {
+			violations = append(violations, domain.Violation{Path: secretPointer(path), Message: msg})
+		}
+	}
+	if len(violations) > 0 {
+		sort.Slice(violations, func(i, j int) bool { return violations[i].Path < violations[j].Path })
+		return &domain.ValidationError{Subject: "secret paths", Violations: violations}
+	}
+	return nil
+}
+
+func secretCollision(path string, leaves map[string]any) string {
+	if value, ok := leaves[path]; ok {
+		if _, isString := value.(string); !isString {
+			return "secret replaces a non-string config value"
+		}
+		return ""
+	}
+	for leaf, value := range leaves {
+		if strings.HasPrefix(leaf, path+".") {
+			return "secret replaces a config object or array"
+		}
+		// empty objects and arrays can be filled by secrets
+		if strings.HasPrefix(path, leaf+".") && !isEmptyContainer(value) {
+			return fmt.Sprintf("secret path runs through config value %s", secretPointer(leaf))
+		}
+	}
+	return ""
+}
+
+func isEmptyContainer(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return true
+	default:
+		return false
+	}
+}
+
+// configLeaves collects the scalar values and empty containers of a config by the paths FlattenJSONFromBytes uses
+func configLeaves(v any, prefix string, leaves map[string]any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 && prefix != "" {
+			leaves[prefix] = v
+		}
+		for key, e := range v {
+			configLeaves(e, join(key), leaves)
+		}
+	case []any:
+		if len(v) == 0 {
+			leaves[prefix] = v
+		}
+		for i, e := range v {
+			configLeaves(e, join("["+strconv.Itoa(i)+"]"), leaves)
+		}
+	default:
+		leaves[prefix] = v
+	}
+}
+
+// secretPointer converts a flattened secret path (a.[0].b) to a JSON pointer (/a/0/b)
+func secretPointer(path string) string {
+	tokens := strings.Split(path, ".")
+	for i, token := range tokens {
+		if strings.HasPrefix(token, "[") && strings.HasSuffix(token, "]") {
+			tokens[i] = token[1 : len(token)-1]
+		}
+	}
+	return jsonPointer(tokens)
+}
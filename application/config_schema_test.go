@@ -0,0 +1,81 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	schemas := []domain.ConfigSchema{
+		{Scope: domain.SchemaScopeGlobal, Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {"rbuilder": {"$ref": "#/$defs/rbuilder"}},
+			"$defs": {"rbuilder": {"type": "object", "properties": {"always_seal": {"type": "boolean"}}, "additionalProperties": false}}
+		}`)},
+		{Scope: domain.SchemaScopeNetwork, Target: "testnet", Schema: json.RawMessage(`{"required": ["relays"]}`)},
+		{Scope: domain.SchemaScopeBuilder, Target: "b-2", Schema: json.RawMessage(`{"required": ["b2_only"]}`)},
+	}
+	builder := domain.Builder{Name: "b-1", Network: "testnet"}
+
+	require.NoError(t, ValidateConfig(schemas, builder, json.RawMessage(`{"rbuilder": {"always_seal": true}, "relays": []}`)))
+
+	err := ValidateConfig(schemas, builder, json.RawMessage(`{"rbuilder": {"always_seal": "yes", "alwys_seal": true}}`))
+	require.ErrorIs(t, err, domain.ErrValidation)
+	var verr *domain.ValidationError
+	require.True(t, errors.As(err, &verr))
+	var paths []string
+	for _, v := range verr.Violations {
+		paths = append(paths, v.Path)
+	}
+	require.ElementsMatch(t, []string{"/rbuilder/always_seal", "/rbuilder", ""}, paths)
+
+	require.NoError(t, ValidateConfig(schemas, domain.Builder{Name: "b-3", Network: "production"}, json.RawMessage(`{}`)), "only matching schemas apply")
+	require.Error(t, ValidateConfig(schemas, domain.Builder{Name: "b-2", Network: "production"}, json.RawMessage(`{}`)))
+}
+
+func TestCompileConfigSchema(t *testing.T) {
+	_, err := CompileConfigSchema(json.RawMessage(`{"type": "object", "properties": {"a": {"type": "integer"}}}`))
+	require.NoError(t, err)
+	for _, invalid := range []string{
+		`{`,
+		`{"type": "not-a-type"}`,
+		`{"$ref": "file:///etc/passwd"}`,
+		`{"$ref": "https://example.com/schema.json"}`,
+	} {
+		_, err = CompileConfigSchema(json.RawMessage(invalid))
+		require.ErrorIs(t, err, domain.ErrValidation, invalid)
+	}
+}
+
+func TestCheckSecretPaths(t *testing.T) {
+	config := json.RawMessage(`{
+		"orderflow_proxy": {"flashbots_of_signing_key": "0x00", "tls_hosts": ["1.2.3.4"]},
+		"rbuilder": {"always_seal": true, "relays": [{"name": "flashbots"}]},
+		"extra": {}
+	}`)
+	require.NoError(t, CheckSecretPaths(config, json.RawMessage(`{
+		"orderflow_proxy": {"flashbots_of_signing_key": "secret"},
+		"rbuilder.relays.[0].url": "secret",
+		"extra": {"key": "secret"},
+		"new": {"key": "secret"}
+	}`)))
+
+	err := CheckSecretPaths(config, json.RawMessage(`{
+		"orderflow_proxy.tls_hosts": "secret",
+		"rbuilder.always_seal": "secret",
+		"orderflow_proxy.flashbots_of_signing_key.nested": "secret"
+	}`))
+	var verr *domain.ValidationError
+	require.True(t, errors.As(err, &verr))
+	require.Equal(t, []domain.Violation{
+		{Path: "/orderflow_proxy/flashbots_of_signing_key/nested", Message: "secret path runs through config value /orderflow_proxy/flashbots_of_signing_key"},
+		{Path: "/orderflow_proxy/tls_hosts", Message: "secret replaces a config object or array"},
+		{Path: "/rbuilder/always_seal", Message: "secret replaces a non-string config value"},
+	}, verr.Violations)
+
+	require.ErrorIs(t, CheckSecretPaths(config, json.RawMessage(`{"a": 1}`)), domain.ErrValidation)
+}
@@ -211,3 +211,9 @@ func TestFlatten(t *testing.T) {
 	require.Equal(t, "Alice", flatMap["user.name"])
 	require.Equal(t, "test_value_2", flatMap["smb.smt.[0].url"])
 }
+
+func TestSameJSON(t *testing.T) {
+	require.True(t, SameJSON(json.RawMessage(`{"a": 1, "b": [1, 2]}`), json.RawMessage(`{"b":[1,2],"a":1}`)))
+	require.False(t, SameJSON(json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 2}`)))
+	require.False(t, SameJSON(json.RawMessage(`{"a": 1}`), json.RawMessage(`not json`)))
+}
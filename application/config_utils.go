@@ -2,8 +2,10 @@
 package application
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -13,16 +15,17 @@ import (
 var ErrNonStringSecret = errors.New("secret value is not a string")
 
 func MergeConfigSecrets(config json.RawMessage, secrets map[string]string) (json.RawMessage, error) {
-	// merge config and secrets
-	bts := []byte(config)
-	var err error
+	// merge config and secrets, into a copy so that the caller's config isn't changed
+	bts := bytes.Clone(config)
 	for k, v := range secrets {
-		tV := "\"" + v + "\""
-		bts, err = jsonparser.Set(bts, []byte(tV), strings.Split(k, ".")...)
+		tV, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		bts, err = jsonparser.Set(bts, tV, strings.Split(k, ".")...)
 		if err != nil {
 			return nil, err
 		}
-
 	}
 	return bts, nil
 }
@@ -35,6 +38,11 @@ func flattenJSON(data map[string]interface{}, prefix string, flatMap map[string]
 			newKey = prefix + "." + key
 		}
 
+		if ref, ok := SecretRef(value); ok {
+			// references to shared secrets are leaves, their value is the reference
+			flatMap[newKey] = ref
+			continue
+		}
 		switch v := value.(type) {
 		case map[string]interface{}:
 			err := flattenJSON(v, newKey, flatMap)
@@ -60,6 +68,10 @@ func flattenJSON(data map[string]interface{}, prefix string, flatMap map[string]
 func flattenArray(data []interface{}, prefix string, flatMap map[string]string) error {
 	for i, value := range data {
 		newKey := prefix + "." + "[" + strconv.Itoa(i) + "]"
+		if ref, ok := SecretRef(value); ok {
+			flatMap[newKey] = ref
+			continue
+		}
 		switch v := value.(type) {
 		case map[string]interface{}:
 			err := flattenJSON(v, newKey, flatMap)
@@ -94,3 +106,15 @@ func FlattenJSONFromBytes(jsonBytes []byte) (map[string]string, error) {
 	}
 	return flatMap, nil
 }
+
+// SameJSON compares JSON documents ignoring formatting and key order
+func SameJSON(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,247 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/metrics"
+)
+
+// default lengths of generated secrets, in bytes for hex secrets and characters for passwords
+const (
+	defaultHexSecretLength = 32
+	defaultPasswordLength  = 32
+)
+
+const passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_.~"
+
+// RotationStore keeps the rotation policies of builder secrets and the rotation history
+type RotationStore interface {
+	SetRotationPolicy(ctx context.Context, policy domain.RotationPolicy) error
+	DeleteRotationPolicy(ctx context.Context, builderName, key string) error
+	GetRotationPolicies(ctx context.Context) ([]domain.RotationPolicy, error)
+	RecordSecretRotation(ctx context.Context, rotation domain.SecretRotation) error
+	ExpirePreviousSecret(ctx context.Context, builderName, key string) error
+	GetSecretRotations(ctx context.Context, builderName string) ([]domain.SecretRotation, error)
+}
+
+// SecretStore reads and writes the secrets of builders
+type SecretStore interface {
+	SecretAccessor
+	SetSecretValues(ctx context.Context, builderName string, message json.RawMessage) error
+}
+
+// GenerateSecret returns a new random value for the policy's generator. ECDSA keys are hex encoded secp256k1
+// private keys.
+func GenerateSecret(p domain.RotationPolicy) (string, error) {
+	switch p.Generator {
+	case domain.GeneratorHex:
+		length := p.Length
+		if length == 0 {
+			length = defaultHexSecretLength
+		}
+		b := make([]byte, length)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	case domain.GeneratorECDSA:
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(crypto.FromECDSA(key)), nil
+	case domain.GeneratorPassword:
+		length := p.Length
+		if length == 0 {
+			length = defaultPasswordLength
+		}
+		b := make([]byte, length)
+		alphabetSize := big.NewInt(int64(len(passwordAlphabet)))
+		for i := range b {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return "", err
+			}
+			b[i] = passwordAlphabet[n.Int64()]
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("%w rotation policy generator %q", domain.ErrValidation, p.Generator)
+}
+
+// Rotator regenerates builder secrets according to their rotation policies. Builders get the new values with their
+// next config fetch. Run a single rotator per secrets backend, rotations aren't coordinated between hub instances.
+type Rotator struct {
+	store   RotationStore
+	secrets SecretStore
+	log     *slog.Logger
+	// mu serializes read-modify-write updates of builder secrets
+	mu *sync.Mutex
+}
+
+func NewRotator(store RotationStore, secrets SecretStore, log *slog.Logger) *Rotator {
+	return &Rotator{store: store, secrets: secrets, log: log, mu: &sync.Mutex{}}
+}
+
+// SecretsMutex is held while the rotator updates builder secrets, other writers of single secrets must hold it too
+func (r *Rotator) SecretsMutex() *sync.Mutex {
+	return r.mu
+}
+
+// Run rotates the due secrets immediately and then every interval until ctx is done
+func (r *Rotator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.RotateDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RotateDue rotates the secrets whose interval has passed and removes the previous values whose overlap has ended.
+// Failures are logged and retried at the next run.
+func (r *Rotator) RotateDue(ctx context.Context, now time.Time) {
+	policies, err := r.store.GetRotationPolicies(ctx)
+	if err != nil {
+		r.log.Error("failed to get rotation policies", "err", err)
+		return
+	}
+	for _, p := range policies {
+		if p.PreviousExpiresAt != nil && !now.Before(*p.PreviousExpiresAt) {
+			if err := r.expirePrevious(ctx, p); err != nil {
+				r.log.Error("failed to remove previous secret", "builder", p.BuilderName, "key", p.PreviousKey(), "err", err)
+			}
+		}
+		if !p.Due(now) {
+			continue
+		}
+		if _, err := r.Rotate(ctx, p, domain.RotationScheduled, now); err != nil {
+			r.log.Error("failed to rotate secret", "builder", p.BuilderName, "key", p.Key, "err", err)
+		}
+	}
+}
+
+// Rotate writes a new value of the policy's secret. If the policy has an overlap, the current value is kept at the
+// previous key until the overlap ends.
+func (r *Rotator) Rotate(ctx context.Context, p domain.RotationPolicy, trigger string, now time.Time) (*domain.SecretRotation, error) {
+	rotation, err := r.rotate(ctx, p, trigger, now.UTC().Truncate(time.Second))
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	metrics.RecordSecretRotation(trigger, result)
+	return rotation, err
+}
+
+func (r *Rotator) rotate(ctx context.Context, p domain.RotationPolicy, trigger string, now time.Time) (*domain.SecretRotation, error) {
+	value, err := GenerateSecret(p)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	secrets, err := r.secrets.GetSecretValues(ctx, p.BuilderName)
+	if err != nil && !errors.Is(err, ErrMissingSecret) {
+		return nil, fmt.Errorf("failed to get secrets: %w", err)
+	}
+	current, err := currentSecret(secrets, p.Key)
+	if err != nil {
+		return nil, err
+	}
+	rotation := &domain.SecretRotation{BuilderName: p.BuilderName, Key: p.Key, Trigger: trigger, RotatedAt: now}
+	switch {
+	case current != "" && p.Overlap > 0:
+		if secrets, err = SetSecretKey(secrets, p.PreviousKey(), current); err != nil {
+			return nil, err
+		}
+		expiresAt := now.Add(time.Duration(p.Overlap))
+		rotation.PreviousExpiresAt = &expiresAt
+	case p.PreviousExpiresAt != nil:
+		// the previous value of an earlier rotation is still there
+		if secrets, err = deleteSecretIfExists(secrets, p.PreviousKey()); err != nil {
+			return nil, err
+		}
+	}
+	if secrets, err = SetSecretKey(secrets, p.Key, value); err != nil {
+		return nil, err
+	}
+	if err = r.secrets.SetSecretValues(ctx, p.BuilderName, secrets); err != nil {
+		return nil, fmt.Errorf("failed to set secrets: %w", err)
+	}
+	if err = r.store.RecordSecretRotation(ctx, *rotation); err != nil {
+		return nil, fmt.Errorf("secret rotated but not recorded: %w", err)
+	}
+	r.log.Info("secret rotated", "builder", p.BuilderName, "key", p.Key, "trigger", trigger, "previous_expires_at", rotation.PreviousExpiresAt)
+	return rotation, nil
+}
+
+func (r *Rotator) expirePrevious(ctx context.Context, p domain.RotationPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	secrets, err := r.secrets.GetSecretValues(ctx, p.BuilderName)
+	if err != nil && !errors.Is(err, ErrMissingSecret) {
+		return fmt.Errorf("failed to get secrets: %w", err)
+	}
+	updated, err := deleteSecretIfExists(secrets, p.PreviousKey())
+	if err != nil {
+		return err
+	}
+	if updated != nil {
+		if err = r.secrets.SetSecretValues(ctx, p.BuilderName, updated); err != nil {
+			return fmt.Errorf("failed to set secrets: %w", err)
+		}
+	}
+	if err = r.store.ExpirePreviousSecret(ctx, p.BuilderName, p.Key); err != nil {
+		return err
+	}
+	r.log.Info("previous secret removed", "builder", p.BuilderName, "key", p.PreviousKey())
+	return nil
+}
+
+// currentSecret returns the value at the key, or "" if there is none. Shared secret references can't be rotated
+// per builder.
+func currentSecret(secrets json.RawMessage, key string) (string, error) {
+	keys, err := SecretKeys(secrets)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range keys {
+		if k.Key != key {
+			continue
+		}
+		if k.Ref != "" {
+			return "", fmt.Errorf("%w: secret %s references %s, rotate the shared secret instead", domain.ErrValidation, key, k.Ref)
+		}
+		flat, err := FlattenJSONFromBytes(secrets)
+		if err != nil {
+			return "", err
+		}
+		return flat[key], nil
+	}
+	return "", nil
+}
+
+// deleteSecretIfExists removes the secret at the key, the secrets are returned unchanged if there is none
+func deleteSecretIfExists(secrets json.RawMessage, key string) (json.RawMessage, error) {
+	updated, err := DeleteSecretKey(secrets, key)
+	if errors.Is(err, domain.ErrNotFound) {
+		return secrets, nil
+	}
+	return updated, err
+}
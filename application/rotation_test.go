@@ -0,0 +1,101 @@
+package application
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	value, err := GenerateSecret(domain.RotationPolicy{Generator: domain.GeneratorHex})
+	require.NoError(t, err)
+	require.Len(t, value, 2*defaultHexSecretLength)
+	_, err = hex.DecodeString(value)
+	require.NoError(t, err)
+
+	value, err = GenerateSecret(domain.RotationPolicy{Generator: domain.GeneratorPassword, Length: 20})
+	require.NoError(t, err)
+	require.Len(t, value, 20)
+	require.Regexp(t, `^[A-Za-z0-9._~-]+$`, value)
+
+	value, err = GenerateSecret(domain.RotationPolicy{Generator: domain.GeneratorECDSA})
+	require.NoError(t, err)
+	_, err = crypto.HexToECDSA(value)
+	require.NoError(t, err)
+
+	_, err = GenerateSecret(domain.RotationPolicy{Generator: "uuid"})
+	require.ErrorIs(t, err, domain.ErrValidation)
+}
+
+func TestRotator(t *testing.T) {
+	ctx := context.Background()
+	store := domain.NewInmemoryBuilderService()
+	secrets := domain.NewMockSecretService()
+	rotator := NewRotator(store, secrets, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "initial"}, "github_token": "gh"}`)))
+	require.NoError(t, store.SetRotationPolicy(ctx, domain.RotationPolicy{
+		BuilderName: "b-1",
+		Key:         "relay.key",
+		Generator:   domain.GeneratorHex,
+		Length:      8,
+		Interval:    domain.Duration(24 * time.Hour),
+		Overlap:     domain.Duration(time.Hour),
+	}))
+	keyOf := func(key string) (string, bool) {
+		values, err := secrets.GetSecretValues(ctx, "b-1")
+		require.NoError(t, err)
+		flat, err := FlattenJSONFromBytes(values)
+		require.NoError(t, err)
+		value, ok := flat[key]
+		return value, ok
+	}
+
+	// a new policy is due immediately, the current value stays valid during the overlap
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	rotator.RotateDue(ctx, now)
+	first, _ := keyOf("relay.key")
+	require.Len(t, first, 16)
+	previous, ok := keyOf("relay.key_previous")
+	require.True(t, ok)
+	require.Equal(t, "initial", previous)
+	token, _ := keyOf("github_token")
+	require.Equal(t, "gh", token, "other secrets are kept")
+
+	// nothing is due yet, then the overlap ends
+	rotator.RotateDue(ctx, now.Add(30*time.Minute))
+	current, _ := keyOf("relay.key")
+	require.Equal(t, first, current)
+	rotator.RotateDue(ctx, now.Add(time.Hour))
+	_, ok = keyOf("relay.key_previous")
+	require.False(t, ok, "the previous value is removed after the overlap")
+	policies, err := store.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Nil(t, policies[0].PreviousExpiresAt)
+
+	rotator.RotateDue(ctx, now.Add(24*time.Hour))
+	current, _ = keyOf("relay.key")
+	require.NotEqual(t, first, current)
+	previous, _ = keyOf("relay.key_previous")
+	require.Equal(t, first, previous)
+
+	rotations, err := store.GetSecretRotations(ctx, "b-1")
+	require.NoError(t, err)
+	require.Len(t, rotations, 2)
+	require.Equal(t, domain.RotationScheduled, rotations[1].Trigger)
+	require.Equal(t, now.Add(25*time.Hour), *rotations[1].PreviousExpiresAt)
+
+	// secrets referencing shared secrets are rotated through the shared secret
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": {"$secret": "shared/relay"}}}`)))
+	_, err = rotator.Rotate(ctx, policies[0], domain.RotationManual, now.Add(48*time.Hour))
+	require.ErrorIs(t, err, domain.ErrValidation)
+}
@@ -0,0 +1,265 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// SecretKey describes a secret without its value. Key is the dotted path used by FlattenJSONFromBytes.
+type SecretKey struct {
+	Key    string `json:"key"`
+	Length int    `json:"length,omitempty"`
+	// Ref is the shared secret the key references
+	Ref string `json:"ref,omitempty"`
+}
+
+// SecretKeys lists the secret paths of a secrets document ordered by key
+func SecretKeys(secrets json.RawMessage) ([]SecretKey, error) {
+	if len(bytes.TrimSpace(secrets)) == 0 {
+		return []SecretKey{}, nil
+	}
+	flat, err := FlattenJSONFromBytes(secrets)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]SecretKey, 0, len(flat))
+	for key, value := range flat {
+		if isSecretRef(doc, key) {
+			res = append(res, SecretKey{Key: key, Ref: value})
+			continue
+		}
+		res = append(res, SecretKey{Key: key, Length: len(value)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+// SecretValue decodes the value of a single secret, a JSON string or a reference to a shared secret
+func SecretValue(value json.RawMessage) (any, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, nil
+	}
+	var v any
+	if err := json.Unmarshal(value, &v); err == nil {
+		if ref, ok := SecretRef(v); ok {
+			if _, err = sharedSecretName(ref); err != nil {
+				return nil, err
+			}
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", domain.ErrValidation, ErrNonStringSecret)
+}
+
+// isSecretRef reports whether the value at the dotted path is a reference to a shared secret
+func isSecretRef(doc map[string]any, key string) bool {
+	var node any = doc
+	for _, token := range strings.Split(key, ".") {
+		if i, ok := arrayIndex(token); ok {
+			arr, _ := node.([]any)
+			if i >= len(arr) {
+				return false
+			}
+			node = arr[i]
+			continue
+		}
+		obj, _ := node.(map[string]any)
+		node = obj[token]
+	}
+	_, ok := SecretRef(node)
+	return ok
+}
+
+// SetSecretKey sets the secret at the dotted path to a string or a reference, creating the objects on the way.
+// Array elements can be replaced or appended.
+func SetSecretKey(secrets json.RawMessage, key string, value any) (json.RawMessage, error) {
+	tokens, err := secretKeyTokens(key)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := setSecretToken(doc, tokens, value)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", key, err)
+	}
+	return json.Marshal(updated)
+}
+
+// DeleteSecretKey removes the secret at the dotted path, ErrNotFound if there is none
+func DeleteSecretKey(secrets json.RawMessage, key string) (json.RawMessage, error) {
+	tokens, err := secretKeyTokens(key)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := deleteSecretToken(doc, tokens)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", key, err)
+	}
+	return json.Marshal(updated)
+}
+
+// HasSecretKey reports whether there is a secret at the dotted path
+func HasSecretKey(secrets json.RawMessage, key string) (bool, error) {
+	keys, err := SecretKeys(secrets)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range keys {
+		if k.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func decodeSecrets(secrets json.RawMessage) (map[string]any, error) {
+	doc := make(map[string]any)
+	if len(bytes.TrimSpace(secrets)) == 0 {
+		return doc, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(secrets))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets: %w", err)
+	}
+	return doc, nil
+}
+
+// secretKeyTokens splits a dotted path, array indexes are written as [n]
+func secretKeyTokens(key string) ([]string, error) {
+	tokens := strings.Split(key, ".")
+	for _, token := range tokens {
+		if token == "" {
+			return nil, fmt.Errorf("%w secret key %q", domain.ErrValidation, key)
+		}
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string) (int, bool) {
+	if !strings.HasPrefix(token, "[") || !strings.HasSuffix(token, "]") {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token[1 : len(token)-1])
+	return i, err == nil && i >= 0
+}
+
+func setSecretToken(node any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if _, ok := SecretRef(node); ok {
+			return value, nil
+		}
+		if _, ok := node.(map[string]any); ok {
+			return nil, fmt.Errorf("%w: the key is an object", domain.ErrValidation)
+		}
+		if _, ok := node.([]any); ok {
+			return nil, fmt.Errorf("%w: the key is an array", domain.ErrValidation)
+		}
+		return value, nil
+	}
+	token := tokens[0]
+	if i, ok := arrayIndex(token); ok {
+		arr, isArray := node.([]any)
+		if node == nil {
+			isArray = true
+		}
+		if !isArray || i > len(arr) {
+			return nil, fmt.Errorf("%w: no array element %s", domain.ErrValidation, token)
+		}
+		var child any
+		if i < len(arr) {
+			child = arr[i]
+		}
+		updated, err := setSecretToken(child, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		if i == len(arr) {
+			return append(arr, updated), nil
+		}
+		arr[i] = updated
+		return arr, nil
+	}
+	obj, ok := node.(map[string]any)
+	if node == nil {
+		obj, ok = make(map[string]any), true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: the path runs through a value", domain.ErrValidation)
+	}
+	updated, err := setSecretToken(obj[token], tokens[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	obj[token] = updated
+	return obj, nil
+}
+
+func deleteSecretToken(node any, tokens []string) (any, error) {
+	token := tokens[0]
+	if i, ok := arrayIndex(token); ok {
+		arr, isArray := node.([]any)
+		if !isArray || i >= len(arr) {
+			return nil, domain.ErrNotFound
+		}
+		if len(tokens) == 1 {
+			if !isSecretLeaf(arr[i]) {
+				return nil, domain.ErrNotFound
+			}
+			return append(arr[:i], arr[i+1:]...), nil
+		}
+		updated, err := deleteSecretToken(arr[i], tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = updated
+		return arr, nil
+	}
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	child, ok := obj[token]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	if len(tokens) == 1 {
+		if !isSecretLeaf(child) {
+			return nil, domain.ErrNotFound
+		}
+		delete(obj, token)
+		return obj, nil
+	}
+	updated, err := deleteSecretToken(child, tokens[1:])
+	if err != nil {
+		return nil, err
+	}
+	obj[token] = updated
+	return obj, nil
+}
+
+func isSecretLeaf(v any) bool {
+	if _, ok := v.(string); ok {
+		return true
+	}
+	_, ok := SecretRef(v)
+	return ok
+}
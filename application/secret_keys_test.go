@@ -0,0 +1,55 @@
+package application
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretKeys(t *testing.T) {
+	secrets := json.RawMessage(`{"relay": {"key": "abc"}, "peers": ["p1", "p2"]}`)
+	keys, err := SecretKeys(secrets)
+	require.NoError(t, err)
+	require.Equal(t, []SecretKey{{Key: "peers.[0]", Length: 2}, {Key: "peers.[1]", Length: 2}, {Key: "relay.key", Length: 3}}, keys)
+	keys, err = SecretKeys(nil)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	_, err = SecretKeys(json.RawMessage(`{"port": 1}`))
+	require.ErrorIs(t, err, ErrNonStringSecret)
+
+	updated, err := SetSecretKey(secrets, "relay.key", "new")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "new"}, "peers": ["p1", "p2"]}`, string(updated))
+	updated, err = SetSecretKey(updated, "bidding.token", "t")
+	require.NoError(t, err)
+	updated, err = SetSecretKey(updated, "peers.[2]", "p3")
+	require.NoError(t, err)
+	updated, err = SetSecretKey(updated, "tokens.[0]", "t0")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "new"}, "peers": ["p1", "p2", "p3"], "bidding": {"token": "t"}, "tokens": ["t0"]}`, string(updated))
+
+	_, err = SetSecretKey(secrets, "relay.key.nested", "x")
+	require.ErrorIs(t, err, domain.ErrValidation, "the path runs through a secret")
+	_, err = SetSecretKey(secrets, "relay", "x")
+	require.ErrorIs(t, err, domain.ErrValidation, "objects can't be replaced")
+	_, err = SetSecretKey(secrets, "peers.[5]", "x")
+	require.ErrorIs(t, err, domain.ErrValidation)
+	_, err = SetSecretKey(secrets, "relay..key", "x")
+	require.ErrorIs(t, err, domain.ErrValidation)
+
+	updated, err = DeleteSecretKey(secrets, "peers.[0]")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "abc"}, "peers": ["p2"]}`, string(updated))
+	_, err = DeleteSecretKey(secrets, "relay")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = DeleteSecretKey(secrets, "relay.missing")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = SecretValue(json.RawMessage(`{"a": "b"}`))
+	require.ErrorIs(t, err, ErrNonStringSecret)
+	value, err := SecretValue(json.RawMessage(`"s3cret"`))
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", value)
+}
@@ -0,0 +1,95 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// SecretRef returns the shared secret name of a {"$secret": "shared/name"} reference
+func SecretRef(v any) (string, bool) {
+	obj, ok := v.(map[string]any)
+	if !ok || len(obj) != 1 {
+		return "", false
+	}
+	ref, ok := obj[domain.SecretRefKey].(string)
+	return ref, ok
+}
+
+// sharedSecretName checks that a reference points into the shared namespace and returns the secret name
+func sharedSecretName(ref string) (string, error) {
+	name, ok := strings.CutPrefix(ref, domain.SharedSecretNamespace)
+	if !ok {
+		return "", fmt.Errorf("%w secret reference %q: only %s secrets can be referenced", domain.ErrValidation, ref, domain.SharedSecretNamespace)
+	}
+	return name, domain.ValidSharedSecretName(name)
+}
+
+// ResolveSecretRefs replaces the shared secret references in a builder's secrets with their values. A reference
+// the builder is not allowed to use by the secret's policy fails the whole document.
+func ResolveSecretRefs(ctx context.Context, secrets json.RawMessage, builder domain.Builder, accessor SecretAccessor) (json.RawMessage, error) {
+	if !bytes.Contains(secrets, []byte(`"`+domain.SecretRefKey+`"`)) {
+		return secrets, nil
+	}
+	doc, err := decodeSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	cache := make(map[string]string)
+	resolve := func(ref string) (string, error) {
+		if value, ok := cache[ref]; ok {
+			return value, nil
+		}
+		name, err := sharedSecretName(ref)
+		if err != nil {
+			return "", err
+		}
+		shared, err := accessor.GetSharedSecret(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("shared secret %s: %w", name, err)
+		}
+		if !shared.Policy.Allows(builder) {
+			return "", fmt.Errorf("%w: builder %s may not use shared secret %s", domain.ErrForbidden, builder.Name, name)
+		}
+		cache[ref] = shared.Value
+		return shared.Value, nil
+	}
+	resolved, err := resolveRefs(doc, resolve)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+func resolveRefs(v any, resolve func(ref string) (string, error)) (any, error) {
+	if ref, ok := SecretRef(v); ok {
+		return resolve(ref)
+	}
+	var err error
+	switch v := v.(type) {
+	case map[string]any:
+		for key, e := range v {
+			if v[key], err = resolveRefs(e, resolve); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, e := range v {
+			if v[i], err = resolveRefs(e, resolve); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// CheckSecretRefs checks that the references in a builder's secrets point to existing shared secrets the builder
+// may use
+func CheckSecretRefs(ctx context.Context, secrets json.RawMessage, builder domain.Builder, accessor SecretAccessor) error {
+	_, err := ResolveSecretRefs(ctx, secrets, builder, accessor)
+	return err
+}
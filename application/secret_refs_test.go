@@ -0,0 +1,46 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecretRefs(t *testing.T) {
+	ctx := context.Background()
+	secrets := domain.NewMockSecretService()
+	require.NoError(t, secrets.SetSharedSecret(ctx, domain.SharedSecret{
+		Name:   "relay-key",
+		Value:  "0xrelay",
+		Policy: domain.SecretPolicy{Networks: []string{"testnet"}},
+	}))
+	builder := domain.Builder{Name: "b-1", Network: "testnet"}
+
+	doc := json.RawMessage(`{"relays": [{"url": "a", "key": {"$secret": "shared/relay-key"}}], "bidding": {"key": {"$secret": "shared/relay-key"}}}`)
+	resolved, err := ResolveSecretRefs(ctx, doc, builder, secrets)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": [{"url": "a", "key": "0xrelay"}], "bidding": {"key": "0xrelay"}}`, string(resolved))
+
+	plain := json.RawMessage(`{"key":  "unchanged"}`)
+	resolved, err = ResolveSecretRefs(ctx, plain, builder, secrets)
+	require.NoError(t, err)
+	require.Equal(t, plain, resolved, "documents without references are returned as is")
+
+	_, err = ResolveSecretRefs(ctx, doc, domain.Builder{Name: "b-2", Network: "production"}, secrets)
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	_, err = ResolveSecretRefs(ctx, json.RawMessage(`{"key": {"$secret": "shared/missing"}}`), builder, secrets)
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = ResolveSecretRefs(ctx, json.RawMessage(`{"key": {"$secret": "b-2/key"}}`), builder, secrets)
+	require.ErrorIs(t, err, domain.ErrValidation)
+
+	// references are leaves of the flattened secrets
+	flat, err := FlattenJSONFromBytes(doc)
+	require.NoError(t, err)
+	require.Equal(t, "shared/relay-key", flat["bidding.key"])
+	keys, err := SecretKeys(doc)
+	require.NoError(t, err)
+	require.Contains(t, keys, SecretKey{Key: "relays.[0].key", Ref: "shared/relay-key"})
+}
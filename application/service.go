@@ -3,7 +3,6 @@ package application
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net"
 
@@ -14,16 +13,19 @@ type BuilderDataAccessor interface {
 	GetActiveMeasurements(ctx context.Context) ([]domain.Measurement, error)
 	GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]domain.BuilderWithServices, error)
 	GetActiveMeasurementsByType(ctx context.Context, attestationType string) ([]domain.Measurement, error)
-	GetBuilderByIP(ip net.IP) (*domain.Builder, error)
-	GetActiveConfigForBuilder(ctx context.Context, builderName string) (json.RawMessage, error)
+	GetBuilderByIP(ctx context.Context, ip net.IP) (*domain.Builder, error)
+	GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]domain.ConfigLayer, error)
+	RecordRolloutFetch(ctx context.Context, id int64, builderName string) error
 	RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error
 	LogEvent(ctx context.Context, eventName, builderName, name string) error
 }
 
-var ErrMissingSecret = errors.New("missing secret for builder")
+var ErrMissingSecret = domain.ErrMissingSecret
 
 type SecretAccessor interface {
 	GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error)
+	// GetSharedSecret returns a secret of the shared namespace, ErrNotFound if there is none
+	GetSharedSecret(ctx context.Context, name string) (*domain.SharedSecret, error)
 }
 
 type BuilderHub struct {
@@ -47,20 +49,49 @@ func (b *BuilderHub) LogEvent(ctx context.Context, eventName, builderName, name
 	return b.dataAccessor.LogEvent(ctx, eventName, builderName, name)
 }
 
+// LogRolloutFetches records that the builder fetched the new layers of the rollouts
+func (b *BuilderHub) LogRolloutFetches(ctx context.Context, builderName string, rollouts []int64) error {
+	for _, id := range rollouts {
+		if err := b.dataAccessor.RecordRolloutFetch(ctx, id, builderName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *BuilderHub) RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error {
 	return b.dataAccessor.RegisterCredentialsForBuilder(ctx, builderName, service, tlsCert, ecdsaPubKey, measurementName, attestationType, region)
 }
 
-func (b *BuilderHub) GetConfigWithSecrets(ctx context.Context, builderName string) ([]byte, error) {
-	_, err := b.dataAccessor.GetActiveConfigForBuilder(ctx, builderName)
+// GetConfigWithSecrets returns the builder's effective config, its own config merged over its network and group
+// layers, with its secrets set at their paths and the shared secret references resolved. The IDs of the rollouts
+// whose new layers are part of the config are returned too.
+func (b *BuilderHub) GetConfigWithSecrets(ctx context.Context, builder domain.Builder) ([]byte, []int64, error) {
+	layers, err := b.dataAccessor.GetConfigLayersForBuilder(ctx, builder.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failing to fetch config for builder %s %w", builder.Name, err)
+	}
+	config, err := domain.ActiveConfigFromLayers(layers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failing to fetch config for builder %s %w", builder.Name, err)
+	}
+	secr, err := b.secretAccessor.GetSecretValues(ctx, builder.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failing to fetch secrets for builder %s %w", builder.Name, err)
+	}
+	resolved, err := ResolveSecretRefs(ctx, secr, builder, b.secretAccessor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failing to resolve shared secrets for builder %s %w", builder.Name, err)
+	}
+	flat, err := FlattenJSONFromBytes(resolved)
 	if err != nil {
-		return nil, fmt.Errorf("failing to fetch config for builder %s %w", builderName, err)
+		return nil, nil, fmt.Errorf("failing to flatten secrets for builder %s %w", builder.Name, err)
 	}
-	secr, err := b.secretAccessor.GetSecretValues(ctx, builderName)
+	merged, err := MergeConfigSecrets(config, flat)
 	if err != nil {
-		return nil, fmt.Errorf("failing to fetch secrets for builder %s %w", builderName, err)
+		return nil, nil, fmt.Errorf("failing to merge secrets into the config of builder %s %w", builder.Name, err)
 	}
-	return secr, nil
+	return merged, domain.AppliedRollouts(layers), nil
 }
 
 func (b *BuilderHub) VerifyIPAndMeasurements(ctx context.Context, ip net.IP, measurement map[string]string, attestationType string) (*domain.Builder, string, error) {
@@ -73,7 +104,7 @@ func (b *BuilderHub) VerifyIPAndMeasurements(ctx context.Context, ip net.IP, mea
 		return nil, "", fmt.Errorf("failing to validate measurement %w", err)
 	}
 
-	builder, err := b.dataAccessor.GetBuilderByIP(ip)
+	builder, err := b.dataAccessor.GetBuilderByIP(ctx, ip)
 	if err != nil {
 		// TODO: might avoid logging ip though it should be ok, at least keep it for development state
 		return nil, "", fmt.Errorf("failing to fetch builder by ip %s %w", ip.String(), err)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/flashbots/builder-hub/manifest"
+	"github.com/urfave/cli/v2"
+)
+
+func manifestCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "manifest",
+		Usage: "export or import the hub state as a declarative manifest (uses --storage)",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "export",
+				Usage: "write builders, measurements, configs and services as a manifest",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "format", Value: manifest.FormatYAML, Usage: "manifest format, yaml or json"},
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "output file (default stdout)"},
+				},
+				Action: withManifestStorage(manifestExport),
+			},
+			{
+				Name:  "import",
+				Usage: "apply a manifest idempotently, printing the changes",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Aliases: []string{"f"}, Required: true, Usage: "manifest file, yaml or json"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "only print the changes"},
+				},
+				Action: withManifestStorage(manifestImport),
+			},
+		},
+	}
+}
+
+func withManifestStorage(fn func(cCtx *cli.Context, store storage) error) cli.ActionFunc {
+	return func(cCtx *cli.Context) error {
+		log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+		store, err := openStorage(cCtx.Context, cCtx, log)
+		if err != nil {
+			return err
+		}
+		defer store.Close() //nolint:errcheck
+		return fn(cCtx, store)
+	}
+}
+
+func manifestExport(cCtx *cli.Context, store storage) error {
+	// secret references need a secrets backend, they are only exported by the admin API
+	m, err := manifest.Export(cCtx.Context, store, nil)
+	if err != nil {
+		return err
+	}
+	bts, err := manifest.Encode(m, cCtx.String("format"))
+	if err != nil {
+		return err
+	}
+	if output := cCtx.String("output"); output != "" {
+		return os.WriteFile(output, bts, 0o600)
+	}
+	_, err = os.Stdout.Write(bts)
+	return err
+}
+
+func manifestImport(cCtx *cli.Context, store storage) error {
+	bts, err := os.ReadFile(cCtx.String("file"))
+	if err != nil {
+		return err
+	}
+	m, err := manifest.Decode(bts)
+	if err != nil {
+		return err
+	}
+	dryRun := cCtx.Bool("dry-run")
+	plan, err := manifest.Import(cCtx.Context, store, nil, m, dryRun)
+	if err != nil {
+		// with conflicts nothing was applied, show what blocks the import
+		if plan != nil && len(plan.Conflicts) > 0 {
+			printPlan(plan, true)
+		}
+		return err
+	}
+	printPlan(plan, dryRun)
+	return nil
+}
+
+func printPlan(plan *manifest.Plan, dryRun bool) {
+	verb := "applied"
+	if dryRun {
+		verb = "would apply"
+	}
+	for _, c := range plan.Changes {
+		fmt.Println(verb, c.Action, c.Target)
+	}
+	for _, w := range plan.Warnings {
+		fmt.Println("warning:", w)
+	}
+	for _, c := range plan.Conflicts {
+		fmt.Println("conflict:", c)
+	}
+	if len(plan.Changes) == 0 && len(plan.Conflicts) == 0 {
+		fmt.Println("hub is up to date")
+	}
+}
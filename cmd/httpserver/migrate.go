@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flashbots/builder-hub/adapters/database"
+	"github.com/flashbots/builder-hub/schema"
+	"github.com/urfave/cli/v2"
+)
+
+func migrateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "manage the database schema (uses --postgres-dsn)",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "up",
+				Usage:  "apply all pending migrations",
+				Action: withMigrator(migrateUp),
+			},
+			{
+				Name:  "down",
+				Usage: "revert the most recently applied migrations",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "steps", Value: 1, Usage: "number of migrations to revert"},
+				},
+				Action: withMigrator(migrateDown),
+			},
+			{
+				Name:   "status",
+				Usage:  "list migrations and whether they are applied",
+				Action: withMigrator(migrateStatus),
+			},
+			{
+				Name:  "baseline",
+				Usage: "mark migrations up to --version as applied without running them, for databases set up by hand",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "version", Required: true, Usage: "last migration version already present in the database"},
+				},
+				Action: withMigrator(migrateBaseline),
+			},
+		},
+	}
+}
+
+func withMigrator(fn func(cCtx *cli.Context, m *database.Migrator) error) cli.ActionFunc {
+	return func(cCtx *cli.Context) error {
+		db, err := database.NewDatabaseService(cCtx.String("postgres-dsn"))
+		if err != nil {
+			return err
+		}
+		defer db.Close() //nolint:errcheck
+
+		m, err := database.NewMigrator(db.DB, schema.Migrations)
+		if err != nil {
+			return err
+		}
+		return fn(cCtx, m)
+	}
+}
+
+func migrateUp(cCtx *cli.Context, m *database.Migrator) error {
+	applied, err := m.Up(cCtx.Context)
+	for _, mig := range applied {
+		fmt.Println("applied", mig.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return nil
+}
+
+func migrateDown(cCtx *cli.Context, m *database.Migrator) error {
+	reverted, err := m.Down(cCtx.Context, cCtx.Int("steps"))
+	for _, mig := range reverted {
+		fmt.Println("reverted", mig.Name)
+	}
+	return err
+}
+
+func migrateBaseline(cCtx *cli.Context, m *database.Migrator) error {
+	recorded, err := m.Baseline(cCtx.Context, cCtx.Int("version"))
+	for _, mig := range recorded {
+		fmt.Println("recorded", mig.Name)
+	}
+	return err
+}
+
+func migrateStatus(cCtx *cli.Context, m *database.Migrator) error {
+	statuses, err := m.Status(cCtx.Context)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", ""
+		if s.Applied {
+			status = "applied"
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		switch {
+		case s.Unknown:
+			status = "applied (unknown to this binary)"
+		case s.Modified:
+			status = "applied (checksum mismatch)"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	return w.Flush()
+}
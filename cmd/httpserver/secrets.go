@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/flashbots/builder-hub/adapters/database"
+	"github.com/flashbots/builder-hub/adapters/secrets"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/signing"
+	"github.com/urfave/cli/v2"
+)
+
+// names of the secrets backends
+const (
+	secretsMock     = "mock"
+	secretsVault    = "vault"
+	secretsPostgres = "postgres"
+	secretsAWS      = "aws"
+)
+
+func secretsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "maintain the secrets backend",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "rewrap",
+				Usage:  "wrap the data keys of Postgres secrets with the current master key, run after adding a new master key (uses --postgres-dsn and the master key flags)",
+				Action: secretsRewrap,
+			},
+			{
+				Name:  "migrate",
+				Usage: "copy the secrets of every builder in --storage and all shared secrets from one backend to another, verifying each copy",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Required: true, Usage: "source backend: vault, postgres or aws"},
+					&cli.StringFlag{Name: "to", Required: true, Usage: "destination backend: vault, postgres or aws"},
+					&cli.BoolFlag{Name: "dry-run", Usage: "only print what would be copied"},
+					&cli.BoolFlag{Name: "overwrite", Usage: "replace secrets that differ in the destination instead of reporting conflicts"},
+				},
+				Action: secretsMigrate,
+			},
+		},
+	}
+}
+
+// primarySecretsBackend picks the backend from the backend flags: mock > vault > postgres > aws
+func primarySecretsBackend(cCtx *cli.Context) (string, error) {
+	switch {
+	case cCtx.Bool("mock-secrets"):
+		return secretsMock, nil
+	case cCtx.Bool("vault-enabled"):
+		return secretsVault, nil
+	case cCtx.Bool("postgres-secrets"):
+		return secretsPostgres, nil
+	case cCtx.String("secret-prefix") != "":
+		return secretsAWS, nil
+	}
+	return "", errors.New("no secrets backend configured")
+}
+
+// newSecretsBackend creates a secrets backend from its flags
+func newSecretsBackend(ctx context.Context, cCtx *cli.Context, log *slog.Logger, name string, db storage) (secrets.Backend, error) {
+	switch name {
+	case secretsMock:
+		log.Info("using mock secrets storage (in-memory)")
+		return domain.NewMockSecretService(), nil
+	case secretsVault:
+		vaultConfig, err := vaultConfigFromFlags(cCtx)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("using HashiCorp Vault for secrets",
+			"address", vaultConfig.Address,
+			"namespace", vaultConfig.Namespace,
+			"secret_path", vaultConfig.SecretPrefix,
+			"mount_path", vaultConfig.MountPath,
+			"kv_version", vaultConfig.KVVersion,
+			"auth_method", vaultConfig.AuthMethod)
+		return secrets.NewHashicorpVaultService(ctx, log, vaultConfig)
+	case secretsPostgres:
+		pg, ok := db.(*database.Service)
+		if !ok {
+			return nil, errors.New("--postgres-secrets requires --storage=postgres")
+		}
+		masterKey, err := loadMasterKey(cCtx)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("using envelope encrypted secrets in Postgres")
+		return secrets.NewPostgresSecretsService(pg.DB, masterKey, cCtx.Int("postgres-secrets-max-versions")), nil
+	case secretsAWS:
+		tags, err := parseAWSTags(cCtx.StringSlice("aws-secret-tags"))
+		if err != nil {
+			return nil, err
+		}
+		awsConfig := secrets.AWSConfig{
+			SecretPrefix: cCtx.String("secret-prefix"),
+			Region:       cCtx.String("aws-region"),
+			Endpoint:     cCtx.String("aws-endpoint"),
+			KMSKeyID:     cCtx.String("aws-kms-key-id"),
+			Tags:         tags,
+		}
+		log.Info("using AWS Secrets Manager for secrets",
+			"prefix", awsConfig.SecretPrefix,
+			"region", awsConfig.Region,
+			"endpoint", awsConfig.Endpoint)
+		return secrets.NewAWSSecretsManagerService(awsConfig)
+	}
+	return nil, fmt.Errorf("unknown secrets backend %q", name)
+}
+
+// vaultConfigFromFlags reads the Vault flags, including the credential files of the auth method
+func vaultConfigFromFlags(cCtx *cli.Context) (secrets.VaultConfig, error) {
+	cfg := secrets.VaultConfig{
+		Address:       cCtx.String("vault-address"),
+		Token:         cCtx.String("vault-token"),
+		Namespace:     cCtx.String("vault-namespace"),
+		SecretPrefix:  cCtx.String("vault-secret-path"),
+		MountPath:     cCtx.String("vault-mount-path"),
+		KVVersion:     cCtx.Int("vault-kv-version"),
+		AuthMethod:    cCtx.String("vault-auth-method"),
+		AuthMountPath: cCtx.String("vault-kubernetes-auth-path"),
+		Role:          cCtx.String("vault-kubernetes-role"),
+		RoleID:        cCtx.String("vault-approle-role-id"),
+		CACert:        cCtx.String("vault-ca-cert"),
+		ClientCert:    cCtx.String("vault-client-cert"),
+		ClientKey:     cCtx.String("vault-client-key"),
+	}
+	switch cfg.AuthMethod {
+	case "kubernetes", "jwt":
+		jwtBytes, err := os.ReadFile(cCtx.String("vault-kubernetes-jwt-path"))
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read Vault JWT file: %w", err)
+		}
+		cfg.Jwt = string(jwtBytes)
+	case "approle":
+		secretID, err := os.ReadFile(cCtx.String("vault-approle-secret-id-file"))
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read Vault AppRole secret ID file: %w", err)
+		}
+		cfg.SecretID = strings.TrimSpace(string(secretID))
+	}
+	return cfg, nil
+}
+
+// loadSigner returns the signer of published lists from --signing-key-file or --vault-transit-key, nil if neither
+// is set
+func loadSigner(ctx context.Context, cCtx *cli.Context, log *slog.Logger) (signing.Signer, error) {
+	keyFile, transitKey := cCtx.String("signing-key-file"), cCtx.String("vault-transit-key")
+	switch {
+	case keyFile != "" && transitKey != "":
+		return nil, errors.New("--signing-key-file and --vault-transit-key are mutually exclusive")
+	case keyFile != "":
+		return signing.LoadSigner(cCtx.String("signing-key-type"), keyFile)
+	case transitKey != "":
+		vaultConfig, err := vaultConfigFromFlags(cCtx)
+		if err != nil {
+			return nil, err
+		}
+		vault, err := secrets.NewHashicorpVaultService(ctx, log, vaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("signing with Vault Transit", "mount", cCtx.String("vault-transit-mount"), "key", transitKey)
+		return vault.TransitSigner(ctx, cCtx.String("vault-transit-mount"), transitKey)
+	}
+	return nil, nil
+}
+
+// parseAWSTags parses key=value tags
+func parseAWSTags(values []string) (map[string]string, error) {
+	tags := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid AWS secret tag %q, expected key=value", v)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// loadMasterKey returns the master key of the Postgres secrets backend from exactly one of the master key flags
+func loadMasterKey(cCtx *cli.Context) (secrets.MasterKey, error) {
+	keys, file, plugin := cCtx.String("secrets-master-keys"), cCtx.String("secrets-master-key-file"), cCtx.String("secrets-master-key-plugin")
+	set := 0
+	for _, v := range []string{keys, file, plugin} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of --secrets-master-keys, --secrets-master-key-file and --secrets-master-key-plugin is required")
+	}
+	switch {
+	case keys != "":
+		return secrets.ParseKeyring(keys)
+	case file != "":
+		return secrets.LoadKeyring(file)
+	default:
+		return secrets.NewPluginMasterKey(plugin), nil
+	}
+}
+
+func secretsRewrap(cCtx *cli.Context) error {
+	masterKey, err := loadMasterKey(cCtx)
+	if err != nil {
+		return err
+	}
+	db, err := database.NewDatabaseService(cCtx.String("postgres-dsn"))
+	if err != nil {
+		return err
+	}
+	defer db.Close() //nolint:errcheck
+
+	n, err := secrets.NewPostgresSecretsService(db.DB, masterKey, cCtx.Int("postgres-secrets-max-versions")).Rewrap(cCtx.Context)
+	if err != nil {
+		return err
+	}
+	keyID, err := masterKey.CurrentKeyID(cCtx.Context)
+	if err != nil {
+		return err
+	}
+	fmt.Println("re-wrapped", n, "data keys with master key", keyID)
+	return nil
+}
+
+func secretsMigrate(cCtx *cli.Context) error {
+	from, to := cCtx.String("from"), cCtx.String("to")
+	if from == to {
+		return errors.New("--from and --to must be different backends")
+	}
+	if from == secretsMock || to == secretsMock {
+		return errors.New("the mock backend is in-memory and can't be migrated")
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	store, err := openStorage(cCtx.Context, cCtx, log)
+	if err != nil {
+		return err
+	}
+	defer store.Close() //nolint:errcheck
+	source, err := newSecretsBackend(cCtx.Context, cCtx, log, from, store)
+	if err != nil {
+		return err
+	}
+	destination, err := newSecretsBackend(cCtx.Context, cCtx, log, to, store)
+	if err != nil {
+		return err
+	}
+	builders, err := store.GetAllBuilders(cCtx.Context)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(builders))
+	for _, b := range builders {
+		names = append(names, b.Name)
+	}
+
+	dryRun := cCtx.Bool("dry-run")
+	results, err := secrets.Migrate(cCtx.Context, source, destination, names, secrets.MigrateOptions{
+		DryRun:    dryRun,
+		Overwrite: cCtx.Bool("overwrite"),
+	})
+	conflicts := 0
+	for _, r := range results {
+		switch {
+		case r.Action == secrets.MigrationConflict:
+			conflicts++
+			fmt.Println("conflict:", r.Target, "differs in", to)
+		case r.Action == secrets.MigrationCopied && dryRun:
+			fmt.Println("would copy", r.Target)
+		default:
+			fmt.Println(r.Action, r.Target)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d secrets differ in %s, check them and rerun with --overwrite to replace them", conflicts, to)
+	}
+	return nil
+}
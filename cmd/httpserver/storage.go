@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/flashbots/builder-hub/adapters/database"
+	"github.com/flashbots/builder-hub/adapters/sqlite"
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/ports"
+	"github.com/flashbots/builder-hub/schema"
+	"github.com/flashbots/builder-hub/transparency"
+	"github.com/urfave/cli/v2"
+)
+
+// storage is implemented by every storage backend
+type storage interface {
+	application.BuilderDataAccessor
+	ports.AdminBuilderService
+	transparency.EntryStore
+	Close() error
+}
+
+type inmemoryStorage struct {
+	*domain.InmemoryBuilderService
+}
+
+func (inmemoryStorage) Close() error {
+	return nil
+}
+
+func openStorage(ctx context.Context, cCtx *cli.Context, log *slog.Logger) (storage, error) {
+	switch backend := cCtx.String("storage"); backend {
+	case "postgres":
+		cfg := database.DefaultConfig(cCtx.String("postgres-dsn"))
+		cfg.ReplicaDSN = cCtx.String("postgres-replica-dsn")
+		cfg.MaxReplicaLag = cCtx.Duration("postgres-replica-max-lag")
+		cfg.MaxOpenConns = cCtx.Int("postgres-max-open-conns")
+		cfg.MaxIdleConns = cCtx.Int("postgres-max-idle-conns")
+		cfg.ConnMaxIdleTime = cCtx.Duration("postgres-conn-max-idle-time")
+		cfg.ConnMaxLifetime = cCtx.Duration("postgres-conn-max-lifetime")
+		cfg.QueryTimeout = cCtx.Duration("postgres-query-timeout")
+		if cfg.ReplicaDSN != "" {
+			log.Info("using Postgres read replica", "max_lag", cfg.MaxReplicaLag)
+		}
+		db, err := database.NewDatabaseServiceWithConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkPostgresSchema(ctx, cCtx, log, db); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		return db, nil
+	case "sqlite":
+		log.Info("using sqlite storage", "path", cCtx.String("sqlite-path"))
+		return sqlite.NewSQLiteService(cCtx.String("sqlite-path"))
+	case "memory":
+		log.Warn("using in-memory storage, all data is lost on restart")
+		return inmemoryStorage{domain.NewInmemoryBuilderService()}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// checkPostgresSchema refuses to start on a schema that is behind, unless --auto-migrate is set
+func checkPostgresSchema(ctx context.Context, cCtx *cli.Context, log *slog.Logger, db *database.Service) error {
+	migrator, err := database.NewMigrator(db.DB, schema.Migrations)
+	if err != nil {
+		return err
+	}
+	if cCtx.Bool("auto-migrate") {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+		for _, m := range applied {
+			log.Info("applied migration", "migration", m.Name)
+		}
+	}
+	if err = migrator.Check(ctx); err != nil {
+		return fmt.Errorf("database schema is not current, refusing to start: %w", err)
+	}
+	return nil
+}
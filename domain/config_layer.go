@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Config layers, merged in this order: the base config of the builder's network, its groups in assignment
+// order and the builder's own config
+const (
+	ConfigLayerNetwork = "network"
+	ConfigLayerGroup   = "group"
+	ConfigLayerBuilder = "builder"
+)
+
+// ConfigLayer is a partial config. Network and group layers are shared, the builder layer is the config
+// uploaded for a builder.
+type ConfigLayer struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+	// Rollout is the ID of the active rollout that staged this version of a shared layer, if any
+	Rollout int64 `json:"rollout,omitempty"`
+}
+
+// ID identifies the layer in provenance, e.g. "network production"
+func (l ConfigLayer) ID() string {
+	return l.Kind + " " + l.Name
+}
+
+// ValidSharedConfigLayer checks the kind and that the config is a JSON object
+func ValidSharedConfigLayer(layer ConfigLayer) error {
+	if layer.Kind != ConfigLayerNetwork && layer.Kind != ConfigLayerGroup {
+		return fmt.Errorf("%w config layer kind %q", ErrValidation, layer.Kind)
+	}
+	if layer.Name == "" {
+		return fmt.Errorf("%w config layer: a name is required", ErrValidation)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(layer.Config), []byte("{")) || !json.Valid(layer.Config) {
+		return fmt.Errorf("%w config layer: the config must be a JSON object", ErrValidation)
+	}
+	return nil
+}
+
+// EffectiveConfig is the merge of config layers
+type EffectiveConfig struct {
+	Config json.RawMessage `json:"config"`
+	Layers []ConfigLayer   `json:"layers"`
+	// Sources maps the JSON pointer of every value to the ID of the layer that set it
+	Sources map[string]string `json:"sources"`
+}
+
+// MergeConfigLayers deep-merges the layers in order with JSON Merge Patch semantics (RFC 7386):
+// objects are merged, any other value replaces the previous one and null removes a key
+func MergeConfigLayers(layers []ConfigLayer) (*EffectiveConfig, error) {
+	var merged any
+	sources := make(map[string]string)
+	ids := make([]ConfigLayer, 0, len(layers))
+	for _, layer := range layers {
+		dec := json.NewDecoder(bytes.NewReader(layer.Config))
+		dec.UseNumber()
+		var patch any
+		if err := dec.Decode(&patch); err != nil {
+			return nil, fmt.Errorf("%w config of %s: %w", ErrValidation, layer.ID(), err)
+		}
+		merged = mergePatch(merged, patch, "", layer.ID(), sources)
+		ids = append(ids, ConfigLayer{Kind: layer.Kind, Name: layer.Name, Rollout: layer.Rollout})
+	}
+	res := &EffectiveConfig{Layers: ids, Sources: sources}
+	// a single layer is returned as is, keeping its formatting
+	if len(layers) == 1 {
+		res.Config = layers[0].Config
+		return res, nil
+	}
+	config, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	res.Config = config
+	return res, nil
+}
+
+func mergePatch(target, patch any, path, layer string, sources map[string]string) any {
+	obj, ok := patch.(map[string]any)
+	if !ok {
+		clearSources(sources, path)
+		sources[path] = layer
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		clearSources(sources, path)
+		targetObj = make(map[string]any, len(obj))
+	}
+	for key, value := range obj {
+		keyPath := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+		if value == nil {
+			delete(targetObj, key)
+			clearSources(sources, keyPath)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value, keyPath, layer, sources)
+	}
+	return targetObj
+}
+
+// clearSources removes the provenance of path and everything below it
+func clearSources(sources map[string]string, path string) {
+	for p := range sources {
+		if p == path || strings.HasPrefix(p, path+"/") {
+			delete(sources, p)
+		}
+	}
+}
+
+// BuilderConfigLayers orders the layers that apply to a builder, own is the builder's active config if any.
+// Groups without a layer are skipped.
+func BuilderConfigLayers(builder Builder, groups []string, shared []ConfigLayer, own json.RawMessage) []ConfigLayer {
+	byID := make(map[string]ConfigLayer, len(shared))
+	for _, layer := range shared {
+		byID[layer.ID()] = layer
+	}
+	var layers []ConfigLayer
+	if layer, ok := byID[ConfigLayerNetwork+" "+builder.Network]; ok {
+		layers = append(layers, layer)
+	}
+	for _, group := range groups {
+		if layer, ok := byID[ConfigLayerGroup+" "+group]; ok {
+			layers = append(layers, layer)
+		}
+	}
+	if len(own) > 0 {
+		layers = append(layers, ConfigLayer{Kind: ConfigLayerBuilder, Name: builder.Name, Config: own})
+	}
+	return layers
+}
+
+// ActiveConfigFromLayers returns the effective config of a builder's layers, ErrNotFound if the builder has no config
+func ActiveConfigFromLayers(layers []ConfigLayer) (json.RawMessage, error) {
+	if len(layers) == 0 || layers[len(layers)-1].Kind != ConfigLayerBuilder {
+		return nil, ErrNotFound
+	}
+	effective, err := MergeConfigLayers(layers)
+	if err != nil {
+		return nil, err
+	}
+	return effective.Config, nil
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeConfigLayers(t *testing.T) {
+	effective, err := MergeConfigLayers([]ConfigLayer{
+		{Kind: ConfigLayerNetwork, Name: "production", Config: json.RawMessage(`{"rpc": {"url": "base", "timeout": 1}, "relays": ["a"], "fees": {"max": 10}, "big": 12345678901234567890}`)},
+		{Kind: ConfigLayerGroup, Name: "eu", Config: json.RawMessage(`{"rpc": {"url": "eu"}, "relays": ["b", "c"], "fees": 1}`)},
+		{Kind: ConfigLayerBuilder, Name: "b-1", Config: json.RawMessage(`{"rpc": {"timeout": null}, "fees": {"min": 2}, "a/b": "x"}`)},
+	})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"rpc": {"url": "eu"}, "relays": ["b", "c"], "fees": {"min": 2}, "big": 12345678901234567890, "a/b": "x"}`, string(effective.Config))
+	require.Equal(t, map[string]string{
+		"/rpc/url":  "group eu",
+		"/relays":   "group eu",
+		"/fees/min": "builder b-1",
+		"/big":      "network production",
+		"/a~1b":     "builder b-1",
+	}, effective.Sources)
+	require.Equal(t, []ConfigLayer{
+		{Kind: ConfigLayerNetwork, Name: "production"},
+		{Kind: ConfigLayerGroup, Name: "eu"},
+		{Kind: ConfigLayerBuilder, Name: "b-1"},
+	}, effective.Layers)
+
+	// a single layer is returned unchanged
+	raw := json.RawMessage(`{"b": 1,  "a": null}`)
+	effective, err = MergeConfigLayers([]ConfigLayer{{Kind: ConfigLayerBuilder, Name: "b-1", Config: raw}})
+	require.NoError(t, err)
+	require.Equal(t, raw, effective.Config)
+
+	_, err = MergeConfigLayers([]ConfigLayer{{Kind: ConfigLayerGroup, Name: "g", Config: json.RawMessage(`{`)}})
+	require.ErrorIs(t, err, ErrValidation)
+
+	_, err = ActiveConfigFromLayers([]ConfigLayer{{Kind: ConfigLayerNetwork, Name: "production", Config: json.RawMessage(`{}`)}})
+	require.ErrorIs(t, err, ErrNotFound, "builders without their own config have no active config")
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Config schema scopes. A config must be valid against the global schema, the schema of its network and its own.
+const (
+	SchemaScopeGlobal  = "global"
+	SchemaScopeNetwork = "network"
+	SchemaScopeBuilder = "builder"
+)
+
+// ConfigSchema is a JSON Schema builder configs are validated against
+type ConfigSchema struct {
+	Scope string `json:"scope"`
+	// Target is the network or builder name, empty for the global scope
+	Target string          `json:"target,omitempty"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// ValidSchemaScope checks the scope and that only the global scope has no target
+func ValidSchemaScope(scope, target string) error {
+	switch scope {
+	case SchemaScopeGlobal:
+		if target != "" {
+			return fmt.Errorf("%w schema target: the global scope has no target", ErrValidation)
+		}
+	case SchemaScopeNetwork, SchemaScopeBuilder:
+		if target == "" {
+			return fmt.Errorf("%w schema target: the %s scope needs a target", ErrValidation, scope)
+		}
+	default:
+		return fmt.Errorf("%w schema scope %q", ErrValidation, scope)
+	}
+	return nil
+}
+
+// Violation is a problem at a location of a validated document, Path is a JSON pointer ("" is the document)
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists all violations of a document, it is an ErrValidation
+type ValidationError struct {
+	Subject    string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "(root)"
+		}
+		msgs = append(msgs, path+": "+v.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", ErrValidation, e.Subject, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,635 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// InmemoryBuilderService mirrors the behavior of the database storage in memory, for unit tests and demos
+type InmemoryBuilderService struct {
+	mu *sync.RWMutex
+
+	measurements   map[string]*inmemoryMeasurement
+	builders       map[string]*Builder
+	credentials    map[string]map[string]BuilderServices // builder name -> service -> active credentials
+	configs        map[string]json.RawMessage            // builder name -> active config
+	events         []inmemoryEvent
+	measurementLog []MeasurementLogEntry
+	schemas        map[[2]string]json.RawMessage  // scope, target -> schema
+	layers         map[[2]string]json.RawMessage  // kind, name -> shared config layer
+	groups         map[string][]string            // builder name -> config groups
+	rollouts       []Rollout                      // ordered by ID, starting at 1
+	rolloutFetches map[int64]map[string]time.Time // rollout ID -> builder name -> first fetch of the new layer
+	rotation       map[[2]string]*RotationPolicy  // builder name, key -> rotation policy
+	rotations      []SecretRotation
+}
+
+type inmemoryMeasurement struct {
+	measurement Measurement
+	json        json.RawMessage
+	isActive    bool
+}
+
+type inmemoryEvent struct {
+	name        string
+	builderName string
+	measurement string
+	createdAt   time.Time
+}
+
+func NewInmemoryBuilderService() *InmemoryBuilderService {
+	return &InmemoryBuilderService{
+		mu:             &sync.RWMutex{},
+		measurements:   make(map[string]*inmemoryMeasurement),
+		builders:       make(map[string]*Builder),
+		credentials:    make(map[string]map[string]BuilderServices),
+		configs:        make(map[string]json.RawMessage),
+		schemas:        make(map[[2]string]json.RawMessage),
+		layers:         make(map[[2]string]json.RawMessage),
+		groups:         make(map[string][]string),
+		rolloutFetches: make(map[int64]map[string]time.Time),
+		rotation:       make(map[[2]string]*RotationPolicy),
+	}
+}
+
+func (s *InmemoryBuilderService) activeMeasurements(match func(Measurement) bool) []Measurement {
+	var res []Measurement
+	for _, m := range s.measurements {
+		if m.isActive && match(m.measurement) {
+			res = append(res, m.measurement)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (s *InmemoryBuilderService) GetActiveMeasurements(ctx context.Context) ([]Measurement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeMeasurements(func(Measurement) bool { return true }), nil
+}
+
+func (s *InmemoryBuilderService) GetActiveMeasurementsByType(ctx context.Context, attestationType string) ([]Measurement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeMeasurements(func(m Measurement) bool { return m.AttestationType == attestationType }), nil
+}
+
+// GetAllMeasurements returns all measurements, active or not, ordered by name
+func (s *InmemoryBuilderService) GetAllMeasurements(ctx context.Context) ([]MeasurementWithStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]MeasurementWithStatus, 0, len(s.measurements))
+	for _, m := range s.measurements {
+		res = append(res, MeasurementWithStatus{Measurement: m.measurement, IsActive: m.isActive})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// GetAllBuilders returns all builders, active or not, ordered by name
+func (s *InmemoryBuilderService) GetAllBuilders(ctx context.Context) ([]Builder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]Builder, 0, len(s.builders))
+	for _, name := range s.sortedBuilderNames() {
+		res = append(res, *s.builders[name])
+	}
+	return res, nil
+}
+
+// GetBuilderByIP returns the active builder with the IP, IPv4-mapped IPv6 addresses match their IPv4 form
+func (s *InmemoryBuilderService) GetBuilderByIP(ctx context.Context, ip net.IP) (*Builder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, name := range s.sortedBuilderNames() {
+		b := s.builders[name]
+		if b.IsActive && b.IPAddress.Equal(ip) {
+			res := *b
+			return &res, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *InmemoryBuilderService) sortedBuilderNames() []string {
+	names := make([]string, 0, len(s.builders))
+	for name := range s.builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetActiveConfigForBuilder returns the builder's active config merged over its network and group layers
+func (s *InmemoryBuilderService) GetActiveConfigForBuilder(ctx context.Context, builderName string) (json.RawMessage, error) {
+	layers, err := s.GetConfigLayersForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+	return ActiveConfigFromLayers(layers)
+}
+
+// GetConfigLayersForBuilder returns the config layers of a builder in merge order, the last one is the builder's
+// active config if it has one. Shared layers in an active rollout targeting the builder are replaced by the new version.
+func (s *InmemoryBuilderService) GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]ConfigLayer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.builders[builderName]
+	if !ok {
+		return nil, fmt.Errorf("builder %s: %w", builderName, ErrNotFound)
+	}
+	shared := make([]ConfigLayer, 0, len(s.layers))
+	for key, config := range s.layers {
+		shared = append(shared, ConfigLayer{Kind: key[0], Name: key[1], Config: config})
+	}
+	return BuilderConfigLayers(*b, s.groups[builderName], RolloutLayers(builderName, shared, s.rollouts), s.configs[builderName]), nil
+}
+
+// GetActiveBuildersWithServiceCredentials returns the active builders of the network ordered by name,
+// with their active credentials ordered by service
+func (s *InmemoryBuilderService) GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]BuilderWithServices, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]BuilderWithServices, 0)
+	for _, name := range s.sortedBuilderNames() {
+		b := s.builders[name]
+		if !b.IsActive || b.Network != network {
+			continue
+		}
+		services := make([]BuilderServices, 0, len(s.credentials[name]))
+		for _, creds := range s.credentials[name] {
+			services = append(services, creds)
+		}
+		sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+		res = append(res, BuilderWithServices{
+			Builder: Builder{
+				Name:      b.Name,
+				IPAddress: b.IPAddress,
+				IsActive:  true,
+				DNSName:   b.DNSName,
+			},
+			Services: services,
+		})
+	}
+	return res, nil
+}
+
+// RegisterCredentialsForBuilder replaces the active credentials of the builder for the service
+func (s *InmemoryBuilderService) RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[builderName]; !ok {
+		return fmt.Errorf("failed to insert credentials for builder %s: %w", builderName, ErrNotFound)
+	}
+	if s.credentials[builderName] == nil {
+		s.credentials[builderName] = make(map[string]BuilderServices)
+	}
+	s.credentials[builderName][service] = BuilderServices{
+		TLSCert:     tlsCert,
+		ECDSAPubKey: Bytes2Address(ecdsaPubKey),
+		Service:     service,
+		Region:      region,
+	}
+	return nil
+}
+
+func (s *InmemoryBuilderService) LogEvent(ctx context.Context, eventName, builderName, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[builderName]; !ok {
+		return fmt.Errorf("failed to insert event log for builder %s: %w", builderName, ErrNotFound)
+	}
+	s.events = append(s.events, inmemoryEvent{name: eventName, builderName: builderName, measurement: name, createdAt: time.Now()})
+	return nil
+}
+
+func (s *InmemoryBuilderService) AddMeasurement(ctx context.Context, measurement Measurement, enabled bool) error {
+	bts, err := json.Marshal(measurement.Measurement)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.measurements[measurement.Name]; ok {
+		return fmt.Errorf("measurement %s: %w", measurement.Name, ErrConflict)
+	}
+	// store a copy decoded from JSON, like a database round trip
+	stored := Measurement{Name: measurement.Name, AttestationType: measurement.AttestationType}
+	if err = json.Unmarshal(bts, &stored.Measurement); err != nil {
+		return err
+	}
+	s.measurements[measurement.Name] = &inmemoryMeasurement{measurement: stored, json: bts, isActive: enabled}
+
+	change := MeasurementChange{
+		Action:          MeasurementActionAdd,
+		Name:            measurement.Name,
+		AttestationType: measurement.AttestationType,
+		Measurements:    bts,
+		Timestamp:       time.Now().Unix(),
+	}
+	if err = s.appendMeasurementLog(change); err != nil {
+		return err
+	}
+	if enabled {
+		change.Action = MeasurementActionActivate
+		return s.appendMeasurementLog(change)
+	}
+	return nil
+}
+
+func (s *InmemoryBuilderService) AddBuilder(ctx context.Context, builder Builder) error {
+	if len(builder.IPAddress) != net.IPv4len && len(builder.IPAddress) != net.IPv6len {
+		return ErrIncorrectBuilder
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[builder.Name]; ok {
+		return fmt.Errorf("builder %s: %w", builder.Name, ErrConflict)
+	}
+	stored := builder
+	stored.IPAddress = append(net.IP{}, builder.IPAddress...)
+	s.builders[builder.Name] = &stored
+	return nil
+}
+
+func (s *InmemoryBuilderService) ChangeActiveStatusForBuilder(ctx context.Context, builderName string, isActive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.builders[builderName]
+	if !ok {
+		return fmt.Errorf("builder %s: %w", builderName, ErrNotFound)
+	}
+	b.IsActive = isActive
+	return nil
+}
+
+func (s *InmemoryBuilderService) ChangeActiveStatusForMeasurement(ctx context.Context, measurementName string, isActive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.measurements[measurementName]
+	if !ok {
+		return fmt.Errorf("measurement %s: %w", measurementName, ErrNotFound)
+	}
+	if m.isActive == isActive {
+		// setting the current status again is a no-op and isn't logged
+		return nil
+	}
+	m.isActive = isActive
+	action := MeasurementActionDeactivate
+	if isActive {
+		action = MeasurementActionActivate
+	}
+	return s.appendMeasurementLog(MeasurementChange{
+		Action:          action,
+		Name:            m.measurement.Name,
+		AttestationType: m.measurement.AttestationType,
+		Measurements:    m.json,
+		Timestamp:       time.Now().Unix(),
+	})
+}
+
+// AddBuilderConfig makes config the single active config of the builder
+func (s *InmemoryBuilderService) AddBuilderConfig(ctx context.Context, builderName string, config json.RawMessage) error {
+	if !json.Valid(config) {
+		return fmt.Errorf("failed to insert new config for builder %s: %w JSON", builderName, ErrValidation)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[builderName]; !ok {
+		return fmt.Errorf("failed to insert new config for builder %s: %w", builderName, ErrNotFound)
+	}
+	s.configs[builderName] = append(json.RawMessage{}, config...)
+	return nil
+}
+
+// appendMeasurementLog appends a change to the transparency log. Callers must hold s.mu.
+func (s *InmemoryBuilderService) appendMeasurementLog(change MeasurementChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	s.measurementLog = append(s.measurementLog, MeasurementLogEntry{Index: uint64(len(s.measurementLog)), Data: data})
+	return nil
+}
+
+func (s *InmemoryBuilderService) GetMeasurementLogEntries(ctx context.Context, start uint64, limit int) ([]MeasurementLogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if start >= uint64(len(s.measurementLog)) {
+		return []MeasurementLogEntry{}, nil
+	}
+	end := min(start+uint64(limit), uint64(len(s.measurementLog))) //nolint:gosec
+	return append([]MeasurementLogEntry{}, s.measurementLog[start:end]...), nil
+}
+
+// SetConfigSchema registers the schema of a scope, replacing the previous one
+func (s *InmemoryBuilderService) SetConfigSchema(ctx context.Context, schema ConfigSchema) error {
+	if err := ValidSchemaScope(schema.Scope, schema.Target); err != nil {
+		return err
+	}
+	if !json.Valid(schema.Schema) {
+		return fmt.Errorf("%w schema JSON", ErrValidation)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[[2]string{schema.Scope, schema.Target}] = append(json.RawMessage{}, schema.Schema...)
+	return nil
+}
+
+func (s *InmemoryBuilderService) DeleteConfigSchema(ctx context.Context, scope, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{scope, target}
+	if _, ok := s.schemas[key]; !ok {
+		return fmt.Errorf("%s schema %s: %w", scope, target, ErrNotFound)
+	}
+	delete(s.schemas, key)
+	return nil
+}
+
+// GetConfigSchemas returns all registered schemas ordered by scope and target
+func (s *InmemoryBuilderService) GetConfigSchemas(ctx context.Context) ([]ConfigSchema, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]ConfigSchema, 0, len(s.schemas))
+	for key, schema := range s.schemas {
+		res = append(res, ConfigSchema{Scope: key[0], Target: key[1], Schema: schema})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Scope != res[j].Scope {
+			return res[i].Scope < res[j].Scope
+		}
+		return res[i].Target < res[j].Target
+	})
+	return res, nil
+}
+
+// SetConfigLayer creates or replaces a network or group layer
+func (s *InmemoryBuilderService) SetConfigLayer(ctx context.Context, layer ConfigLayer) error {
+	if err := ValidSharedConfigLayer(layer); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.layers[[2]string{layer.Kind, layer.Name}] = append(json.RawMessage{}, layer.Config...)
+	return nil
+}
+
+func (s *InmemoryBuilderService) DeleteConfigLayer(ctx context.Context, kind, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{kind, name}
+	if _, ok := s.layers[key]; !ok {
+		return fmt.Errorf("%s config layer %s: %w", kind, name, ErrNotFound)
+	}
+	delete(s.layers, key)
+	return nil
+}
+
+// GetConfigLayers returns the network and group layers ordered by kind and name
+func (s *InmemoryBuilderService) GetConfigLayers(ctx context.Context) ([]ConfigLayer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]ConfigLayer, 0, len(s.layers))
+	for key, config := range s.layers {
+		res = append(res, ConfigLayer{Kind: key[0], Name: key[1], Config: config})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// SetConfigGroupsForBuilder replaces the group layers of a builder, groups are merged in the given order
+func (s *InmemoryBuilderService) SetConfigGroupsForBuilder(ctx context.Context, builderName string, groups []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[builderName]; !ok {
+		return fmt.Errorf("builder %s: %w", builderName, ErrNotFound)
+	}
+	seen := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		if seen[group] {
+			return fmt.Errorf("config group %s of builder %s: %w", group, builderName, ErrConflict)
+		}
+		seen[group] = true
+	}
+	s.groups[builderName] = append([]string{}, groups...)
+	return nil
+}
+
+// GetConfigGroupsForBuilder returns the group layers assigned to a builder in merge order
+func (s *InmemoryBuilderService) GetConfigGroupsForBuilder(ctx context.Context, builderName string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string{}, s.groups[builderName]...), nil
+}
+
+// CreateRollout starts a rollout, there can be only one active rollout per layer
+func (s *InmemoryBuilderService) CreateRollout(ctx context.Context, r Rollout) (*Rollout, error) {
+	if err := ValidRollout(r); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.rollouts {
+		if existing.Status == RolloutActive && existing.Layer.ID() == r.Layer.ID() {
+			return nil, fmt.Errorf("failed to create rollout of %s: %w", r.Layer.ID(), ErrConflict)
+		}
+	}
+	r.ID = int64(len(s.rollouts) + 1)
+	r.Layer.Config = append(json.RawMessage{}, r.Layer.Config...)
+	r.Builders = append([]string{}, r.Builders...)
+	r.Status = RolloutActive
+	r.CreatedAt = time.Now()
+	r.FinishedAt = nil
+	s.rollouts = append(s.rollouts, r)
+	return &r, nil
+}
+
+// GetRollouts returns all rollouts ordered by ID
+func (s *InmemoryBuilderService) GetRollouts(ctx context.Context) ([]Rollout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Rollout{}, s.rollouts...), nil
+}
+
+func (s *InmemoryBuilderService) GetRollout(ctx context.Context, id int64) (*Rollout, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id < 1 || id > int64(len(s.rollouts)) {
+		return nil, fmt.Errorf("rollout %d: %w", id, ErrNotFound)
+	}
+	r := s.rollouts[id-1]
+	return &r, nil
+}
+
+// PromoteRollout replaces the shared layer with the new version of an active rollout
+func (s *InmemoryBuilderService) PromoteRollout(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.finishRollout(id, RolloutPromoted)
+	if err != nil {
+		return err
+	}
+	s.layers[[2]string{r.Layer.Kind, r.Layer.Name}] = r.Layer.Config
+	return nil
+}
+
+// AbortRollout ends an active rollout, the targeted builders get the current layer again
+func (s *InmemoryBuilderService) AbortRollout(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.finishRollout(id, RolloutAborted)
+	return err
+}
+
+func (s *InmemoryBuilderService) finishRollout(id int64, status string) (*Rollout, error) {
+	if id < 1 || id > int64(len(s.rollouts)) {
+		return nil, fmt.Errorf("rollout %d: %w", id, ErrNotFound)
+	}
+	r := &s.rollouts[id-1]
+	if r.Status != RolloutActive {
+		return nil, fmt.Errorf("rollout %d is %s: %w", id, r.Status, ErrConflict)
+	}
+	now := time.Now()
+	r.Status, r.FinishedAt = status, &now
+	return r, nil
+}
+
+// RecordRolloutFetch records that the builder fetched a config with the new layer of the rollout, the first
+// fetch is kept
+func (s *InmemoryBuilderService) RecordRolloutFetch(ctx context.Context, id int64, builderName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > int64(len(s.rollouts)) {
+		return fmt.Errorf("rollout %d: %w", id, ErrNotFound)
+	}
+	if _, ok := s.builders[builderName]; !ok {
+		return fmt.Errorf("failed to record rollout fetch of builder %s: %w", builderName, ErrNotFound)
+	}
+	if s.rolloutFetches[id] == nil {
+		s.rolloutFetches[id] = make(map[string]time.Time)
+	}
+	if _, ok := s.rolloutFetches[id][builderName]; !ok {
+		s.rolloutFetches[id][builderName] = time.Now()
+	}
+	return nil
+}
+
+// GetRolloutFetches returns the first fetch of the rollout's new layer by each builder
+func (s *InmemoryBuilderService) GetRolloutFetches(ctx context.Context, id int64) (map[string]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id < 1 || id > int64(len(s.rollouts)) {
+		return nil, fmt.Errorf("rollout %d: %w", id, ErrNotFound)
+	}
+	res := make(map[string]time.Time, len(s.rolloutFetches[id]))
+	for name, fetchedAt := range s.rolloutFetches[id] {
+		res[name] = fetchedAt
+	}
+	return res, nil
+}
+
+// SetRotationPolicy creates or updates the rotation policy of a builder secret, the rotation state is kept
+func (s *InmemoryBuilderService) SetRotationPolicy(ctx context.Context, p RotationPolicy) error {
+	if err := ValidRotationPolicy(p); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.builders[p.BuilderName]; !ok {
+		return fmt.Errorf("failed to set rotation policy of %s %s: %w", p.BuilderName, p.Key, ErrNotFound)
+	}
+	// the database stores whole seconds
+	p.Interval = Duration(time.Duration(p.Interval).Truncate(time.Second))
+	p.Overlap = Duration(time.Duration(p.Overlap).Truncate(time.Second))
+	id := [2]string{p.BuilderName, p.Key}
+	if existing, ok := s.rotation[id]; ok {
+		p.LastRotatedAt, p.PreviousExpiresAt = existing.LastRotatedAt, existing.PreviousExpiresAt
+	} else {
+		p.LastRotatedAt, p.PreviousExpiresAt = nil, nil
+	}
+	s.rotation[id] = &p
+	return nil
+}
+
+func (s *InmemoryBuilderService) DeleteRotationPolicy(ctx context.Context, builderName, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := [2]string{builderName, key}
+	if _, ok := s.rotation[id]; !ok {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, ErrNotFound)
+	}
+	delete(s.rotation, id)
+	return nil
+}
+
+// GetRotationPolicies returns all rotation policies ordered by builder name and key
+func (s *InmemoryBuilderService) GetRotationPolicies(ctx context.Context) ([]RotationPolicy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]RotationPolicy, 0, len(s.rotation))
+	for _, p := range s.rotation {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].BuilderName != res[j].BuilderName {
+			return res[i].BuilderName < res[j].BuilderName
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+// RecordSecretRotation updates the rotation state of the policy and logs the rotation
+func (s *InmemoryBuilderService) RecordSecretRotation(ctx context.Context, r SecretRotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.rotation[[2]string{r.BuilderName, r.Key}]
+	if !ok {
+		return fmt.Errorf("rotation policy of %s %s: %w", r.BuilderName, r.Key, ErrNotFound)
+	}
+	rotatedAt := r.RotatedAt
+	p.LastRotatedAt, p.PreviousExpiresAt = &rotatedAt, nil
+	if r.PreviousExpiresAt != nil {
+		expiresAt := *r.PreviousExpiresAt
+		p.PreviousExpiresAt = &expiresAt
+	}
+	s.rotations = append(s.rotations, r)
+	return nil
+}
+
+// ExpirePreviousSecret records that the previous value of a rotated secret was removed
+func (s *InmemoryBuilderService) ExpirePreviousSecret(ctx context.Context, builderName, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.rotation[[2]string{builderName, key}]
+	if !ok {
+		return fmt.Errorf("rotation policy of %s %s: %w", builderName, key, ErrNotFound)
+	}
+	p.PreviousExpiresAt = nil
+	return nil
+}
+
+// GetSecretRotations returns the rotations of a builder's secrets, oldest first
+func (s *InmemoryBuilderService) GetSecretRotations(ctx context.Context, builderName string) ([]SecretRotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]SecretRotation, 0)
+	for _, r := range s.rotations {
+		if r.BuilderName == builderName {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
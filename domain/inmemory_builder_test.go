@@ -0,0 +1,14 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/flashbots/builder-hub/adapters/storagetest"
+	"github.com/flashbots/builder-hub/domain"
+)
+
+func TestInmemoryBuilderServiceConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Store {
+		return domain.NewInmemoryBuilderService()
+	})
+}
@@ -3,30 +3,132 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 type InmemorySecretService struct {
 	mu *sync.RWMutex
-	st map[string]json.RawMessage
+	// st keeps all versions of the secrets of each builder, the last one is current
+	st     map[string][]inmemorySecretVersion
+	shared map[string]SharedSecret
+}
+
+type inmemorySecretVersion struct {
+	values    json.RawMessage
+	createdAt time.Time
 }
 
 func NewMockSecretService() *InmemorySecretService {
 	return &InmemorySecretService{
-		st: make(map[string]json.RawMessage),
-		mu: &sync.RWMutex{},
+		st:     make(map[string][]inmemorySecretVersion),
+		shared: make(map[string]SharedSecret),
+		mu:     &sync.RWMutex{},
 	}
 }
 
 func (mss *InmemorySecretService) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
 	mss.mu.RLock()
 	defer mss.mu.RUnlock()
-	return mss.st[builderName], nil
+	versions := mss.st[builderName]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("builder %s: %w", builderName, ErrMissingSecret)
+	}
+	return versions[len(versions)-1].values, nil
 }
 
 func (mss *InmemorySecretService) SetSecretValues(ctx context.Context, builderName string, values json.RawMessage) error {
 	mss.mu.Lock()
 	defer mss.mu.Unlock()
-	mss.st[builderName] = values
+	mss.st[builderName] = append(mss.st[builderName], inmemorySecretVersion{values: values, createdAt: time.Now()})
+	return nil
+}
+
+// ListSecretVersions returns the versions of a builder's secrets, newest first. Versions are numbered from 1.
+func (mss *InmemorySecretService) ListSecretVersions(ctx context.Context, builderName string) ([]SecretVersion, error) {
+	mss.mu.RLock()
+	defer mss.mu.RUnlock()
+	versions := mss.st[builderName]
+	res := make([]SecretVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		res = append(res, SecretVersion{Version: strconv.Itoa(i + 1), CreatedAt: versions[i].createdAt, Current: i == len(versions)-1})
+	}
+	return res, nil
+}
+
+func (mss *InmemorySecretService) GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error) {
+	mss.mu.RLock()
+	defer mss.mu.RUnlock()
+	v, err := mss.version(builderName, version)
+	if err != nil {
+		return nil, err
+	}
+	return v.values, nil
+}
+
+// RestoreSecretVersion makes a copy of an earlier version the current one
+func (mss *InmemorySecretService) RestoreSecretVersion(ctx context.Context, builderName, version string) error {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	v, err := mss.version(builderName, version)
+	if err != nil {
+		return err
+	}
+	mss.st[builderName] = append(mss.st[builderName], inmemorySecretVersion{values: v.values, createdAt: time.Now()})
+	return nil
+}
+
+func (mss *InmemorySecretService) version(builderName, version string) (*inmemorySecretVersion, error) {
+	versions := mss.st[builderName]
+	i, err := strconv.Atoi(version)
+	if err != nil || i < 1 || i > len(versions) {
+		return nil, fmt.Errorf("secret version %s of builder %s: %w", version, builderName, ErrNotFound)
+	}
+	return &versions[i-1], nil
+}
+
+func (mss *InmemorySecretService) GetSharedSecret(ctx context.Context, name string) (*SharedSecret, error) {
+	mss.mu.RLock()
+	defer mss.mu.RUnlock()
+	secret, ok := mss.shared[name]
+	if !ok {
+		return nil, fmt.Errorf("shared secret %s: %w", name, ErrNotFound)
+	}
+	return &secret, nil
+}
+
+func (mss *InmemorySecretService) SetSharedSecret(ctx context.Context, secret SharedSecret) error {
+	if err := ValidSharedSecretName(secret.Name); err != nil {
+		return err
+	}
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	mss.shared[secret.Name] = secret
 	return nil
 }
+
+func (mss *InmemorySecretService) DeleteSharedSecret(ctx context.Context, name string) error {
+	mss.mu.Lock()
+	defer mss.mu.Unlock()
+	if _, ok := mss.shared[name]; !ok {
+		return fmt.Errorf("shared secret %s: %w", name, ErrNotFound)
+	}
+	delete(mss.shared, name)
+	return nil
+}
+
+// ListSharedSecrets returns the shared secrets without their values ordered by name
+func (mss *InmemorySecretService) ListSharedSecrets(ctx context.Context) ([]SharedSecret, error) {
+	mss.mu.RLock()
+	defer mss.mu.RUnlock()
+	res := make([]SharedSecret, 0, len(mss.shared))
+	for _, secret := range mss.shared {
+		secret.Value = ""
+		res = append(res, secret)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "context"
+
+// AdminRole is the level of access granted to an admin API caller
+type AdminRole string
+
+const (
+	// AdminRoleAdmin grants full read/write access to the admin API
+	AdminRoleAdmin AdminRole = "admin"
+	// AdminRoleReadOnly grants access to read-only (GET/HEAD) admin endpoints
+	AdminRoleReadOnly AdminRole = "readonly"
+)
+
+// ParseAdminRole converts a role name into an AdminRole
+func ParseAdminRole(s string) (AdminRole, bool) {
+	switch AdminRole(s) {
+	case AdminRoleAdmin, AdminRoleReadOnly:
+		return AdminRole(s), true
+	}
+	return "", false
+}
+
+// Allows reports whether the role grants at least the privileges of the required role.
+func (r AdminRole) Allows(required AdminRole) bool {
+	if r == AdminRoleAdmin {
+		return true
+	}
+	return r == required
+}
+
+// AdminPrincipal is the authenticated identity behind an admin API request
+type AdminPrincipal struct {
+	Subject    string
+	Role       AdminRole
+	AuthMethod string
+}
+
+type adminPrincipalKey struct{}
+
+// ContextWithAdminPrincipal attaches the authenticated admin principal to the context
+func ContextWithAdminPrincipal(ctx context.Context, p AdminPrincipal) context.Context {
+	return context.WithValue(ctx, adminPrincipalKey{}, p)
+}
+
+// AdminPrincipalFromContext returns the admin principal attached by the auth middleware
+func AdminPrincipalFromContext(ctx context.Context) (AdminPrincipal, bool) {
+	p, ok := ctx.Value(adminPrincipalKey{}).(AdminPrincipal)
+	return p, ok
+}
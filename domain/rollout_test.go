@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutTargets(t *testing.T) {
+	r := Rollout{ID: 7, Percent: 20}
+	targeted := 0
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("builder-%d", i)
+		if r.Targets(name) {
+			targeted++
+			require.True(t, r.Targets(name), "the selection is stable")
+		}
+	}
+	require.InDelta(t, 200, targeted, 50)
+	require.True(t, Rollout{Percent: 100}.Targets("any"))
+	require.False(t, Rollout{Builders: []string{"b-1"}}.Targets("b-2"))
+
+	current := []ConfigLayer{{Kind: ConfigLayerNetwork, Name: "testnet", Config: json.RawMessage(`{"a": 1}`)}}
+	rollouts := []Rollout{
+		{Layer: ConfigLayer{Kind: ConfigLayerNetwork, Name: "testnet", Config: json.RawMessage(`{"a": 2}`)}, Builders: []string{"b-1"}, Status: RolloutActive},
+		{Layer: ConfigLayer{Kind: ConfigLayerGroup, Name: "eu", Config: json.RawMessage(`{"b": 1}`)}, Percent: 100, Status: RolloutAborted},
+	}
+	require.Equal(t, current, RolloutLayers("b-2", current, rollouts))
+	require.Equal(t, []ConfigLayer{rollouts[0].Layer}, RolloutLayers("b-1", current, rollouts))
+}
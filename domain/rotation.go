@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Secret generators of rotation policies
+const (
+	GeneratorHex      = "hex"
+	GeneratorECDSA    = "ecdsa"
+	GeneratorPassword = "password"
+)
+
+// Rotation triggers
+const (
+	RotationScheduled = "scheduled"
+	RotationManual    = "manual"
+)
+
+// PreviousSecretSuffix is appended to the last key of a rotated secret to keep the previous value during the overlap
+const PreviousSecretSuffix = "_previous"
+
+// MaxSecretLength bounds the length of generated secrets
+const MaxSecretLength = 1024
+
+// Duration is a time.Duration encoded as a string like "24h" in JSON
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w duration: %w", ErrValidation, err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%w duration: %w", ErrValidation, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// RotationPolicy regenerates the secret at Key of a builder's secrets every Interval. The previous value stays
+// available at Key+PreviousSecretSuffix for Overlap after each rotation.
+type RotationPolicy struct {
+	BuilderName string `json:"builder_name"`
+	Key         string `json:"key"`
+	Generator   string `json:"generator"`
+	// Length is the number of random bytes of hex secrets and the number of characters of passwords
+	Length   int      `json:"length,omitempty"`
+	Interval Duration `json:"interval"`
+	Overlap  Duration `json:"overlap,omitempty"`
+
+	LastRotatedAt     *time.Time `json:"last_rotated_at,omitempty"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+}
+
+// Due reports whether the secret has never been rotated or its interval has passed
+func (p RotationPolicy) Due(now time.Time) bool {
+	return p.LastRotatedAt == nil || !now.Before(p.LastRotatedAt.Add(time.Duration(p.Interval)))
+}
+
+// PreviousKey is the key the previous value is kept at during the overlap
+func (p RotationPolicy) PreviousKey() string {
+	return p.Key + PreviousSecretSuffix
+}
+
+func ValidRotationPolicy(p RotationPolicy) error {
+	if p.BuilderName == "" {
+		return fmt.Errorf("%w rotation policy: builder name is required", ErrValidation)
+	}
+	for _, token := range strings.Split(p.Key, ".") {
+		if token == "" {
+			return fmt.Errorf("%w rotation policy key %q", ErrValidation, p.Key)
+		}
+	}
+	if strings.HasSuffix(p.Key, "]") {
+		return fmt.Errorf("%w rotation policy key %q: array elements can't be rotated", ErrValidation, p.Key)
+	}
+	if strings.HasSuffix(p.Key, PreviousSecretSuffix) {
+		return fmt.Errorf("%w rotation policy key %q: the %s suffix is reserved", ErrValidation, p.Key, PreviousSecretSuffix)
+	}
+	switch p.Generator {
+	case GeneratorHex, GeneratorPassword:
+		if p.Length < 0 || p.Length > MaxSecretLength {
+			return fmt.Errorf("%w rotation policy length %d", ErrValidation, p.Length)
+		}
+	case GeneratorECDSA:
+		if p.Length != 0 {
+			return fmt.Errorf("%w rotation policy: ECDSA keys have no length", ErrValidation)
+		}
+	default:
+		return fmt.Errorf("%w rotation policy generator %q", ErrValidation, p.Generator)
+	}
+	if p.Interval < Duration(time.Minute) {
+		return fmt.Errorf("%w rotation policy interval %s: at least 1m is required", ErrValidation, time.Duration(p.Interval))
+	}
+	if p.Overlap < 0 || p.Overlap >= p.Interval {
+		return fmt.Errorf("%w rotation policy overlap %s: must be shorter than the interval", ErrValidation, time.Duration(p.Overlap))
+	}
+	return nil
+}
+
+// SecretRotation records a rotation of a secret. The values are never recorded.
+type SecretRotation struct {
+	BuilderName       string     `json:"builder_name"`
+	Key               string     `json:"key"`
+	Trigger           string     `json:"trigger"`
+	RotatedAt         time.Time  `json:"rotated_at"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+}
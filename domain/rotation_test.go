@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotationPolicy(t *testing.T) {
+	var p RotationPolicy
+	require.NoError(t, json.Unmarshal([]byte(`{"builder_name": "b-1", "key": "relay.key", "generator": "hex", "interval": "24h", "overlap": "1h30m"}`), &p))
+	require.Equal(t, Duration(24*time.Hour), p.Interval)
+	require.Equal(t, Duration(90*time.Minute), p.Overlap)
+	require.NoError(t, ValidRotationPolicy(p))
+	encoded, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.Contains(t, string(encoded), `"interval":"24h0m0s"`)
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"interval": 60}`), &p), ErrValidation)
+
+	require.True(t, p.Due(time.Now()), "never rotated")
+	last := time.Now().Add(-23 * time.Hour)
+	p.LastRotatedAt = &last
+	require.False(t, p.Due(time.Now()))
+	require.True(t, p.Due(time.Now().Add(time.Hour)))
+	require.Equal(t, "relay.key_previous", p.PreviousKey())
+
+	invalid := []func(p *RotationPolicy){
+		func(p *RotationPolicy) { p.Key = "relay..key" },
+		func(p *RotationPolicy) { p.Key = "relays.[0]" },
+		func(p *RotationPolicy) { p.Key = "relay.key_previous" },
+		func(p *RotationPolicy) { p.Generator = "uuid" },
+		func(p *RotationPolicy) { p.Generator, p.Length = GeneratorECDSA, 32 },
+		func(p *RotationPolicy) { p.Length = MaxSecretLength + 1 },
+		func(p *RotationPolicy) { p.Interval = Duration(time.Second) },
+		func(p *RotationPolicy) { p.Overlap = p.Interval },
+	}
+	for _, change := range invalid {
+		q := p
+		change(&q)
+		require.ErrorIs(t, ValidRotationPolicy(q), ErrValidation, "%+v", q)
+	}
+}
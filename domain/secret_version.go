@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// SecretVersion is a stored version of a builder's secrets, without the values. The version identifier is
+// backend specific: a number for Vault KV v2 and the mock, a version ID for AWS Secrets Manager.
+type SecretVersion struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Current   bool      `json:"current"`
+	// Deleted versions can't be read or restored
+	Deleted bool `json:"deleted,omitempty"`
+	// Stages are the AWS Secrets Manager staging labels, e.g. AWSCURRENT and AWSPREVIOUS
+	Stages []string `json:"stages,omitempty"`
+}
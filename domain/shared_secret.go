@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// SecretRefKey marks a reference to a shared secret in a builder's secrets, e.g. {"$secret": "shared/relay-key"}.
+// References are replaced by the value of the shared secret when the builder fetches its config.
+const (
+	SecretRefKey          = "$secret"
+	SharedSecretNamespace = "shared/"
+)
+
+var sharedSecretName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SecretPolicy lists the builders that may reference a shared secret, by name or network. An empty policy allows
+// no builder.
+type SecretPolicy struct {
+	Builders []string `json:"builders,omitempty"`
+	Networks []string `json:"networks,omitempty"`
+}
+
+func (p SecretPolicy) Allows(builder Builder) bool {
+	for _, name := range p.Builders {
+		if name == builder.Name {
+			return true
+		}
+	}
+	for _, network := range p.Networks {
+		if network == builder.Network {
+			return true
+		}
+	}
+	return false
+}
+
+// SharedSecret is a secret referenced by several builders, so that rotating it updates all of them
+type SharedSecret struct {
+	Name string `json:"name"`
+	// Value is omitted when listing shared secrets
+	Value  string       `json:"value,omitempty"`
+	Policy SecretPolicy `json:"policy"`
+}
+
+func ValidSharedSecretName(name string) error {
+	if !sharedSecretName.MatchString(name) {
+		return fmt.Errorf("%w shared secret name %q", ErrValidation, name)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "encoding/json"
+
+// Actions recorded in the measurement transparency log
+const (
+	MeasurementActionAdd        = "add"
+	MeasurementActionActivate   = "activate"
+	MeasurementActionDeactivate = "deactivate"
+)
+
+// MeasurementChange is the content of one measurement transparency log entry
+type MeasurementChange struct {
+	Action          string          `json:"action"`
+	Name            string          `json:"measurement_id"`
+	AttestationType string          `json:"attestation_type"`
+	Measurements    json.RawMessage `json:"measurements"`
+	// Timestamp is the unix time of the change
+	Timestamp int64 `json:"timestamp"`
+}
+
+// MeasurementLogEntry is a stored transparency log leaf. Data holds the exact bytes that are hashed
+// into the tree, a JSON encoded MeasurementChange.
+type MeasurementLogEntry struct {
+	Index uint64
+	Data  []byte
+}
@@ -3,15 +3,26 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// Error kinds, storage and services wrap them so that handlers can map errors to status codes uniformly
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("already exists")
+	ErrValidation = errors.New("invalid")
+	ErrForbidden  = errors.New("forbidden")
+)
+
 var (
-	ErrNotFound           = errors.New("not found")
 	ErrIncorrectBuilder   = errors.New("incorrect builder")
 	ErrInvalidMeasurement = errors.New("no such active measurement found")
+	// ErrMissingSecret is returned by secrets services for builders that have no secrets, as opposed to empty ones
+	ErrMissingSecret = errors.New("missing secret for builder")
 )
 
 const ProductionNetwork = "production"
@@ -59,6 +70,22 @@ type Builder struct {
 	DNSName   string `json:"dns_name"`
 }
 
+// ValidBuilderName rejects names that would address other secrets. Builder secrets are stored at <prefix>/<name>
+// and shared secrets at <prefix>/shared/<name>, so a name with a slash could read a shared secret directly.
+func ValidBuilderName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") ||
+		name == strings.TrimSuffix(SharedSecretNamespace, "/") {
+		return fmt.Errorf("%w builder name %q", ErrValidation, name)
+	}
+	return nil
+}
+
+// MeasurementWithStatus is a whitelisted measurement with its activation status
+type MeasurementWithStatus struct {
+	Measurement
+	IsActive bool
+}
+
 type BuilderWithServices struct {
 	Builder  Builder
 	Services []BuilderServices
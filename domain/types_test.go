@@ -97,3 +97,10 @@ func TestMeasurement_JSON(t *testing.T) {
 		require.Equal(t, []string{"cccc"}, m["4"].GetExpectedValues())
 	})
 }
+
+func TestValidBuilderName(t *testing.T) {
+	require.NoError(t, ValidBuilderName("builder-1.eu"))
+	for _, name := range []string{"", "shared/relay-key", "a/b", "shared", ".", ".."} {
+		require.ErrorIs(t, ValidBuilderName(name), ErrValidation, name)
+	}
+}
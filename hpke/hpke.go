@@ -0,0 +1,67 @@
+// Package hpke wraps single-shot Hybrid Public Key Encryption (RFC 9180) in base mode of
+// github.com/cloudflare/circl for the suite DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-256-GCM. The hub uses it
+// to encrypt builder configs to a key that only exists inside the attested TEE.
+package hpke
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+
+	circl "github.com/cloudflare/circl/hpke"
+)
+
+// Suite names the KEM, KDF and AEAD
+const Suite = "DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-256-GCM"
+
+var ErrOpen = errors.New("hpke: failed to decrypt")
+
+var suite = circl.NewSuite(circl.KEM_X25519_HKDF_SHA256, circl.KDF_HKDF_SHA256, circl.AEAD_AES256GCM)
+
+// ParsePublicKey parses a raw 32 byte X25519 public key
+func ParsePublicKey(b []byte) (*ecdh.PublicKey, error) {
+	return ecdh.X25519().NewPublicKey(b)
+}
+
+// Seal encrypts the plaintext to the public key and returns the encapsulated key and the ciphertext. info and aad
+// must be the same when opening.
+func Seal(pub *ecdh.PublicKey, info, aad, plaintext []byte) (enc, ciphertext []byte, err error) {
+	pkR, err := circl.KEM_X25519_HKDF_SHA256.Scheme().UnmarshalBinaryPublicKey(pub.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	sender, err := suite.NewSender(pkR, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, sealer, err := sender.Setup(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err = sealer.Seal(plaintext, aad)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, ciphertext, nil
+}
+
+// Open decrypts a ciphertext sealed to the private key's public key
+func Open(priv *ecdh.PrivateKey, enc, info, aad, ciphertext []byte) ([]byte, error) {
+	skR, err := circl.KEM_X25519_HKDF_SHA256.Scheme().UnmarshalBinaryPrivateKey(priv.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := suite.NewReceiver(skR, info)
+	if err != nil {
+		return nil, err
+	}
+	opener, err := receiver.Setup(enc)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := opener.Open(ciphertext, aad)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return plaintext, nil
+}
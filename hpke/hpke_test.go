@@ -0,0 +1,49 @@
+package hpke
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestSealOpen(t *testing.T) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	enc, ciphertext, err := Seal(priv.PublicKey(), []byte("info"), []byte("aad"), []byte("hello world"))
+	require.NoError(t, err)
+
+	plaintext, err := Open(priv, enc, []byte("info"), []byte("aad"), ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(plaintext))
+
+	_, err = Open(priv, enc, []byte("other"), []byte("aad"), ciphertext)
+	require.ErrorIs(t, err, ErrOpen)
+	_, err = Open(priv, enc, []byte("info"), []byte("other"), ciphertext)
+	require.ErrorIs(t, err, ErrOpen)
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = Open(other, enc, []byte("info"), []byte("aad"), ciphertext)
+	require.ErrorIs(t, err, ErrOpen)
+}
+
+// TestInterop opens a message sealed by another RFC 9180 implementation (Go's crypto/hpke)
+func TestInterop(t *testing.T) {
+	priv, err := ecdh.X25519().NewPrivateKey(mustHex(t, "563f0d34e25c46e58f55fb14ca48c440d14d10473302ebff7f827a5f6f1e2598"))
+	require.NoError(t, err)
+	enc := mustHex(t, "c6941594ff509d30d02b5b23f786187b2fd7a1146e363dc61f54423d1db50b52")
+	ciphertext := mustHex(t, "7290196054d7bbd3e26c379a30d1037e1b7ff6fdf9064fa114796e")
+
+	plaintext, err := Open(priv, enc, []byte("info"), []byte("aad"), ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(plaintext))
+}
@@ -339,6 +339,8 @@ func createServer(t *testing.T) (*Server, *database.Service, *domain.InmemorySec
 		AdminAddr:         adminAddr,
 		AdminAuthDisabled: true,
 		Log:               getTestLogger(),
+
+		InternalAllowPlaintext: true,
 	}, bhh, ah)
 	require.NoError(t, err)
 	return s, dbService, mss
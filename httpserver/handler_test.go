@@ -41,6 +41,8 @@ func Test_Handlers_Healthcheck_Drain_Undrain(t *testing.T) {
 		InternalAddr:  internalAddr,
 		AdminAddr:     adminAddr,
 		Log:           getTestLogger(),
+
+		InternalAllowPlaintext: true,
 	}, ports.NewBuilderHubHandler(nil, getTestLogger()), ports.NewAdminHandler(nil, nil, getTestLogger()))
 	require.NoError(t, err)
 
@@ -103,3 +105,14 @@ func Test_Handlers_Healthcheck_Drain_Undrain(t *testing.T) {
 		require.Equal(t, http.StatusOK, resp.StatusCode, "Healthcheck must return `Ok` after undraining")
 	}
 }
+
+func Test_NewHTTPServer_RefusesPlaintextInternalAPI(t *testing.T) {
+	//nolint: exhaustruct
+	_, err := NewHTTPServer(&HTTPServerConfig{
+		ListenAddr:   listenAddr,
+		InternalAddr: internalAddr,
+		AdminAddr:    adminAddr,
+		Log:          getTestLogger(),
+	}, ports.NewBuilderHubHandler(nil, getTestLogger()), ports.NewAdminHandler(nil, nil, getTestLogger()))
+	require.ErrorIs(t, err, ErrInternalPlaintext)
+}
@@ -0,0 +1,324 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid bearer token")
+	ErrNoMatchingRole = errors.New("token does not map to any admin role")
+)
+
+// allowedJWTAlgorithms are the asymmetric algorithms accepted for OIDC tokens.
+// Symmetric algorithms are never accepted since the JWKS is public.
+var allowedJWTAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	minJWKSRefetchInterval     = time.Minute
+	jwtClockLeeway             = 30 * time.Second
+)
+
+// OIDCConfig configures bearer token authentication for the admin API
+type OIDCConfig struct {
+	Issuer   string
+	Audience string
+
+	// JWKS is either an http(s) URL or a path to a local JWKS file
+	JWKS string
+	// JWKSCacheFile, if set, stores the last JWKS fetched from a URL and is used when the URL is unreachable
+	JWKSCacheFile string
+	// JWKSRefreshInterval is how often keys fetched from a URL are refreshed
+	JWKSRefreshInterval time.Duration
+
+	// SubjectClaim is the claim used as the admin identity (defaults to "sub")
+	SubjectClaim string
+	// GroupsClaim is the claim holding the caller's groups (defaults to "groups")
+	GroupsClaim string
+	// RoleMapping maps a group to the admin role granted to its members
+	RoleMapping map[string]domain.AdminRole
+}
+
+// ParseAdminRoleMapping parses "name=role" pairs (OIDC group or certificate common name) into a role mapping
+func ParseAdminRoleMapping(pairs []string) (map[string]domain.AdminRole, error) {
+	mapping := make(map[string]domain.AdminRole, len(pairs))
+	for _, pair := range pairs {
+		name, roleName, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid role mapping %q: expected name=role", pair)
+		}
+		role, ok := domain.ParseAdminRole(roleName)
+		if !ok {
+			return nil, fmt.Errorf("invalid role mapping %q: unknown role %q", pair, roleName)
+		}
+		mapping[name] = role
+	}
+	return mapping, nil
+}
+
+type oidcVerifier struct {
+	cfg        OIDCConfig
+	httpClient *http.Client
+
+	// refreshMu serializes refreshes, mu guards the key set. Keys are fetched
+	// without holding mu, so a slow IdP doesn't block requests with known keys.
+	refreshMu   sync.Mutex
+	mu          sync.Mutex
+	keys        *jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newOIDCVerifier(cfg OIDCConfig) (*oidcVerifier, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("OIDC issuer is required")
+	}
+	if cfg.JWKS == "" {
+		return nil, errors.New("OIDC JWKS location is required")
+	}
+	if len(cfg.RoleMapping) == 0 {
+		return nil, errors.New("OIDC role mapping is required")
+	}
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = "sub"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.JWKSRefreshInterval == 0 {
+		cfg.JWKSRefreshInterval = defaultJWKSRefreshInterval
+	}
+
+	v := &oidcVerifier{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := v.refresh(context.Background(), true); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *oidcVerifier) isRemote() bool {
+	return strings.HasPrefix(v.cfg.JWKS, "https://") || strings.HasPrefix(v.cfg.JWKS, "http://")
+}
+
+// refresh reloads the key set. Remote refreshes are attempted at most once per
+// minJWKSRefetchInterval, also after failures, so that unknown key ids can't make
+// the hub hammer the IdP. If wait is false and another refresh is in progress,
+// refresh returns right away.
+func (v *oidcVerifier) refresh(ctx context.Context, wait bool) error {
+	if !wait {
+		if !v.refreshMu.TryLock() {
+			return nil
+		}
+	} else {
+		v.refreshMu.Lock()
+	}
+	defer v.refreshMu.Unlock()
+
+	v.mu.Lock()
+	attemptedAt := v.attemptedAt
+	v.mu.Unlock()
+	if !attemptedAt.IsZero() && time.Since(attemptedAt) < minJWKSRefetchInterval {
+		return nil
+	}
+
+	keys, err := v.load(ctx)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.attemptedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	v.fetchedAt = v.attemptedAt
+	return nil
+}
+
+// load reads and parses the key set. Remote key sets are written to the cache
+// file only once they parsed, and the cache is used when the fetch fails.
+func (v *oidcVerifier) load(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	if !v.isRemote() {
+		raw, err := os.ReadFile(v.cfg.JWKS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWKS: %w", err)
+		}
+		return parseJWKS(raw)
+	}
+
+	raw, err := v.fetchRemote(ctx)
+	if err == nil {
+		var keys *jose.JSONWebKeySet
+		if keys, err = parseJWKS(raw); err == nil {
+			if v.cfg.JWKSCacheFile != "" {
+				_ = os.WriteFile(v.cfg.JWKSCacheFile, raw, 0o600)
+			}
+			return keys, nil
+		}
+	} else {
+		err = fmt.Errorf("failed to load JWKS: %w", err)
+	}
+	if v.cfg.JWKSCacheFile != "" {
+		if cached, cacheErr := os.ReadFile(v.cfg.JWKSCacheFile); cacheErr == nil {
+			if keys, cacheErr := parseJWKS(cached); cacheErr == nil {
+				return keys, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+func parseJWKS(raw []byte) (*jose.JSONWebKeySet, error) {
+	keys := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(raw, keys); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	return keys, nil
+}
+
+func (v *oidcVerifier) fetchRemote(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKS, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (v *oidcVerifier) lookup(kid string) ([]jose.JSONWebKey, time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.keys.Key(kid), v.fetchedAt
+}
+
+// key returns the verification key for kid, refreshing remote key sets when
+// they are stale or the kid is unknown (e.g. after an IdP key rotation).
+func (v *oidcVerifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	keys, fetchedAt := v.lookup(kid)
+	if v.isRemote() && time.Since(fetchedAt) > v.cfg.JWKSRefreshInterval {
+		// stale keys are still used while another request refreshes them
+		_ = v.refresh(ctx, false)
+		keys, _ = v.lookup(kid)
+	}
+	if len(keys) == 0 && v.isRemote() {
+		_ = v.refresh(ctx, true)
+		keys, _ = v.lookup(kid)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidToken, kid)
+	}
+	return &keys[0], nil
+}
+
+// Verify validates a raw JWT and maps it to an admin principal
+func (v *oidcVerifier) Verify(ctx context.Context, rawToken string) (*domain.AdminPrincipal, error) {
+	tok, err := jwt.ParseSigned(rawToken, allowedJWTAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if len(tok.Headers) != 1 {
+		return nil, fmt.Errorf("%w: expected exactly one signature", ErrInvalidToken)
+	}
+	key, err := v.key(ctx, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		std    jwt.Claims
+		custom map[string]any
+	)
+	if err := tok.Claims(key.Public().Key, &std, &custom); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	expected := jwt.Expected{Issuer: v.cfg.Issuer, Time: time.Now()}
+	if v.cfg.Audience != "" {
+		expected.AnyAudience = jwt.Audience{v.cfg.Audience}
+	}
+	if err := std.ValidateWithLeeway(expected, jwtClockLeeway); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if std.Expiry == nil {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+
+	subject, _ := custom[v.cfg.SubjectClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.cfg.SubjectClaim)
+	}
+
+	role, ok := v.roleFor(claimStrings(custom[v.cfg.GroupsClaim]))
+	if !ok {
+		return nil, ErrNoMatchingRole
+	}
+	return &domain.AdminPrincipal{Subject: subject, Role: role, AuthMethod: "oidc"}, nil
+}
+
+// roleFor returns the most privileged role granted by any of the groups
+func (v *oidcVerifier) roleFor(groups []string) (domain.AdminRole, bool) {
+	var (
+		role  domain.AdminRole
+		found bool
+	)
+	for _, g := range groups {
+		r, ok := v.cfg.RoleMapping[g]
+		if !ok {
+			continue
+		}
+		if !found || r.Allows(role) {
+			role, found = r, true
+		}
+	}
+	return role, found
+}
+
+// claimStrings accepts both a single string and an array of strings
+func claimStrings(v any) []string {
+	switch c := v.(type) {
+	case string:
+		return []string{c}
+	case []any:
+		res := make([]string, 0, len(c))
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
@@ -0,0 +1,221 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testIssuer = "https://idp.example.com"
+
+type testIdP struct {
+	key    *ecdsa.PrivateKey
+	signer jose.Signer
+	jwks   string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: key, KeyID: "test-key"}}, nil)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test-key", Algorithm: string(jose.ES256), Use: "sig"}}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	return &testIdP{key: key, signer: signer, jwks: path}
+}
+
+func (idp *testIdP) token(t *testing.T, issuer string, expiry time.Time, groups ...string) string {
+	t.Helper()
+	tok, err := jwt.Signed(idp.signer).
+		Claims(jwt.Claims{Issuer: issuer, Subject: "alice", Audience: jwt.Audience{"builder-hub"}, Expiry: jwt.NewNumericDate(expiry)}).
+		Claims(map[string]any{"groups": groups}).
+		Serialize()
+	require.NoError(t, err)
+	return tok
+}
+
+func oidcProtectedHandler(t *testing.T, idp *testIdP, bcryptHash string) http.Handler {
+	t.Helper()
+	verifier, err := newOIDCVerifier(OIDCConfig{
+		Issuer:   testIssuer,
+		Audience: "builder-hub",
+		JWKS:     idp.jwks,
+		RoleMapping: map[string]domain.AdminRole{
+			"hub-admins":  domain.AdminRoleAdmin,
+			"hub-viewers": domain.AdminRoleReadOnly,
+		},
+	})
+	require.NoError(t, err)
+	srv := &Server{cfg: &HTTPServerConfig{AdminBasicUser: "admin", AdminPasswordBcrypt: bcryptHash}, log: testLogger(), oidc: verifier}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := domain.AdminPrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, p.Subject)
+	})
+	return srv.adminAuthMiddleware()(next)
+}
+
+func doAdminRequest(h http.Handler, method, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func Test_AdminOIDC_AllowsAdminToken(t *testing.T) {
+	idp := newTestIdP(t)
+	h := oidcProtectedHandler(t, idp, "")
+
+	rr := doAdminRequest(h, http.MethodPost, idp.token(t, testIssuer, time.Now().Add(time.Hour), "hub-admins"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "alice", rr.Body.String())
+}
+
+func Test_AdminOIDC_ReadOnlyRole(t *testing.T) {
+	idp := newTestIdP(t)
+	h := oidcProtectedHandler(t, idp, "")
+	tok := idp.token(t, testIssuer, time.Now().Add(time.Hour), "hub-viewers")
+
+	require.Equal(t, http.StatusOK, doAdminRequest(h, http.MethodGet, tok).Code)
+	require.Equal(t, http.StatusForbidden, doAdminRequest(h, http.MethodPost, tok).Code)
+}
+
+func Test_AdminOIDC_MostPrivilegedGroupWins(t *testing.T) {
+	idp := newTestIdP(t)
+	h := oidcProtectedHandler(t, idp, "")
+	tok := idp.token(t, testIssuer, time.Now().Add(time.Hour), "hub-viewers", "hub-admins")
+
+	require.Equal(t, http.StatusOK, doAdminRequest(h, http.MethodPost, tok).Code)
+}
+
+func Test_AdminOIDC_RejectsInvalidTokens(t *testing.T) {
+	idp := newTestIdP(t)
+	h := oidcProtectedHandler(t, idp, "")
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		rr := doAdminRequest(h, http.MethodGet, idp.token(t, "https://evil.example.com", time.Now().Add(time.Hour), "hub-admins"))
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+	t.Run("expired", func(t *testing.T) {
+		rr := doAdminRequest(h, http.MethodGet, idp.token(t, testIssuer, time.Now().Add(-time.Hour), "hub-admins"))
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+	t.Run("signed by unknown key", func(t *testing.T) {
+		other := newTestIdP(t)
+		rr := doAdminRequest(h, http.MethodGet, other.token(t, testIssuer, time.Now().Add(time.Hour), "hub-admins"))
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+	t.Run("malformed", func(t *testing.T) {
+		rr := doAdminRequest(h, http.MethodGet, "not-a-jwt")
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+	t.Run("no mapped group", func(t *testing.T) {
+		rr := doAdminRequest(h, http.MethodGet, idp.token(t, testIssuer, time.Now().Add(time.Hour), "everyone"))
+		require.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func Test_AdminOIDC_BasicAuthFallback(t *testing.T) {
+	idp := newTestIdP(t)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	h := oidcProtectedHandler(t, idp, string(hash))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "admin", rr.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, doAdminRequest(h, http.MethodGet, "").Code)
+}
+
+func Test_OIDCVerifier_RemoteJWKS(t *testing.T) {
+	idp := newTestIdP(t)
+	jwks, err := os.ReadFile(idp.jwks)
+	require.NoError(t, err)
+
+	var fetches atomic.Int32
+	var broken atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if broken.Load() {
+			_, _ = w.Write([]byte("not a key set"))
+			return
+		}
+		_, _ = w.Write(jwks)
+	}))
+	defer srv.Close()
+
+	cacheFile := filepath.Join(t.TempDir(), "jwks-cache.json")
+	verifier, err := newOIDCVerifier(OIDCConfig{
+		Issuer:        testIssuer,
+		JWKS:          srv.URL,
+		JWKSCacheFile: cacheFile,
+		RoleMapping:   map[string]domain.AdminRole{"hub-admins": domain.AdminRoleAdmin},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), fetches.Load())
+
+	cached, err := os.ReadFile(cacheFile)
+	require.NoError(t, err)
+	require.Equal(t, jwks, cached)
+
+	// unknown key ids don't refetch more than once per interval
+	for range 3 {
+		_, err = verifier.key(context.Background(), "unknown")
+		require.ErrorIs(t, err, ErrInvalidToken)
+	}
+	require.Equal(t, int32(1), fetches.Load())
+
+	// an invalid key set isn't cached and the previous keys stay in use
+	broken.Store(true)
+	verifier.mu.Lock()
+	verifier.attemptedAt = time.Now().Add(-2 * minJWKSRefetchInterval)
+	verifier.mu.Unlock()
+	_, err = verifier.key(context.Background(), "unknown")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Equal(t, int32(2), fetches.Load())
+	cached, err = os.ReadFile(cacheFile)
+	require.NoError(t, err)
+	require.Equal(t, jwks, cached)
+	_, err = verifier.key(context.Background(), "test-key")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), fetches.Load())
+}
+
+func Test_ParseAdminRoleMapping(t *testing.T) {
+	mapping, err := ParseAdminRoleMapping([]string{"ops=admin", "devs=readonly"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]domain.AdminRole{"ops": domain.AdminRoleAdmin, "devs": domain.AdminRoleReadOnly}, mapping)
+
+	_, err = ParseAdminRoleMapping([]string{"ops=root"})
+	require.Error(t, err)
+	_, err = ParseAdminRoleMapping([]string{"ops"})
+	require.Error(t, err)
+}
@@ -3,10 +3,14 @@ package httpserver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/flashbots/builder-hub/domain"
 	"github.com/flashbots/builder-hub/metrics"
 	"github.com/flashbots/builder-hub/ports"
 	"github.com/go-chi/chi/v5"
@@ -16,6 +20,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrInternalPlaintext = errors.New("internal API requires TLS, configure internal-tls-cert or allow plaintext explicitly")
+
 type HTTPServerConfig struct {
 	ListenAddr   string
 	MetricsAddr  string
@@ -33,6 +39,19 @@ type HTTPServerConfig struct {
 	AdminBasicUser      string
 	AdminPasswordBcrypt string
 	AdminAuthDisabled   bool
+	// AdminOIDC enables bearer token auth with OIDC-issued JWTs, Basic Auth remains available as a fallback
+	AdminOIDC *OIDCConfig
+	// AdminClientCertRoles maps verified client certificate common names to admin roles
+	AdminClientCertRoles map[string]domain.AdminRole
+
+	// Optional TLS termination per listener, plain HTTP is served when nil
+	ListenTLS   *TLSConfig
+	AdminTLS    *TLSConfig
+	InternalTLS *TLSConfig
+	// InternalAllowPlaintext allows serving the internal API without TLS, e.g. in local development
+	InternalAllowPlaintext bool
+	// TLSReloadInterval is how often certificate files are checked for changes
+	TLSReloadInterval time.Duration
 }
 
 type Server struct {
@@ -41,6 +60,9 @@ type Server struct {
 	log          *httplog.Logger
 	appHandler   *ports.BuilderHubHandler
 	adminHandler *ports.AdminHandler
+	oidc         *oidcVerifier
+	certs        []*certReloader
+	stopCh       chan struct{}
 
 	srv         *http.Server
 	adminSrv    *http.Server
@@ -53,6 +75,10 @@ type Server struct {
 }
 
 func NewHTTPServer(cfg *HTTPServerConfig, appHandler *ports.BuilderHubHandler, adminHandler *ports.AdminHandler) (srv *Server, err error) {
+	if cfg.InternalTLS == nil && !cfg.InternalAllowPlaintext {
+		return nil, ErrInternalPlaintext
+	}
+
 	srv = &Server{
 		cfg:          cfg,
 		log:          cfg.Log,
@@ -60,9 +86,17 @@ func NewHTTPServer(cfg *HTTPServerConfig, appHandler *ports.BuilderHubHandler, a
 		adminHandler: adminHandler,
 		srv:          nil,
 		metricsSrv:   metrics.NewMetricsServer(cfg.MetricsAddr, nil),
+		stopCh:       make(chan struct{}),
 	}
 	srv.isReady.Swap(true)
 
+	if cfg.AdminOIDC != nil {
+		srv.oidc, err = newOIDCVerifier(*cfg.AdminOIDC)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	srv.srv = &http.Server{
 		Addr:         cfg.ListenAddr,
 		Handler:      srv.GetRouter(),
@@ -82,6 +116,25 @@ func NewHTTPServer(cfg *HTTPServerConfig, appHandler *ports.BuilderHubHandler, a
 		WriteTimeout: cfg.WriteTimeout,
 	}
 
+	for _, l := range []struct {
+		httpSrv *http.Server
+		cfg     *TLSConfig
+	}{
+		{srv.srv, cfg.ListenTLS},
+		{srv.internalSrv, cfg.InternalTLS},
+		{srv.adminSrv, cfg.AdminTLS},
+	} {
+		if l.cfg == nil {
+			continue
+		}
+		reloader, err := newCertReloader(*l.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up TLS for %s: %w", l.httpSrv.Addr, err)
+		}
+		l.httpSrv.TLSConfig = reloader.TLSConfig()
+		srv.certs = append(srv.certs, reloader)
+	}
+
 	return srv, nil
 }
 
@@ -99,11 +152,18 @@ func (srv *Server) GetRouter() http.Handler {
 	mux.Get("/undrain", srv.handleUndrain)
 
 	mux.Get("/api/l1-builder/v1/measurements", srv.appHandler.GetAllowedMeasurements)
+	mux.Get("/api/l1-builder/v1/measurements/signed", srv.appHandler.GetSignedMeasurements)
+	mux.Get("/api/l1-builder/v1/signing-key", srv.appHandler.GetSigningKey)
+	mux.Get("/api/l1-builder/v1/measurements/log/tree-head", srv.appHandler.GetMeasurementLogTreeHead)
+	mux.Get("/api/l1-builder/v1/measurements/log/entries", srv.appHandler.GetMeasurementLogEntries)
+	mux.Get("/api/l1-builder/v1/measurements/log/proof/inclusion", srv.appHandler.GetMeasurementLogInclusionProof)
+	mux.Get("/api/l1-builder/v1/measurements/log/proof/consistency", srv.appHandler.GetMeasurementLogConsistencyProof)
 	mux.Get("/api/l1-builder/v1/configuration", srv.appHandler.GetConfigSecrets)
 	mux.Get("/api/l1-builder/v1/builders", srv.appHandler.GetActiveBuilders)
 	mux.Post("/api/l1-builder/v1/register_credentials/{service}", srv.appHandler.RegisterCredentials)
 	mux.Get("/api/internal/l1-builder/v1/builders", srv.appHandler.GetActiveBuildersNoAuth)
 	mux.Get("/api/internal/l1-builder/v2/network/{network}/builders", srv.appHandler.GetActiveBuildersNoAuthNetworked)
+	mux.Get("/api/internal/l1-builder/v2/network/{network}/builders/signed", srv.appHandler.GetSignedActiveBuildersNoAuthNetworked)
 	if srv.cfg.EnablePprof {
 		srv.log.Info("pprof API enabled")
 		mux.Mount("/debug", middleware.Profiler())
@@ -119,8 +179,8 @@ func (srv *Server) GetAdminRouter() http.Handler {
 	mux.Use(middleware.Recoverer)
 	mux.Use(metrics.Middleware)
 
-	// Require Basic Auth for all admin routes
-	mux.Use(srv.basicAuthMiddleware())
+	// Require Bearer or Basic Auth for all admin routes
+	mux.Use(srv.adminAuthMiddleware())
 
 	mux.Get("/api/admin/v1/builders/configuration/{builderName}/active", srv.adminHandler.GetActiveConfigForBuilder)
 	mux.Get("/api/admin/v1/builders/configuration/{builderName}/full", srv.adminHandler.GetFullConfigForBuilder)
@@ -130,6 +190,42 @@ func (srv *Server) GetAdminRouter() http.Handler {
 	mux.Post("/api/admin/v1/measurements/activation/{measurementName}", srv.adminHandler.ChangeActiveStatusForMeasurement)
 	mux.Post("/api/admin/v1/builders/configuration/{builderName}", srv.adminHandler.AddBuilderConfig)
 	mux.Post("/api/admin/v1/builders/secrets/{builderName}", srv.adminHandler.SetSecrets)
+	mux.Get("/api/admin/v1/manifest", srv.adminHandler.ExportManifest)
+	mux.Post("/api/admin/v1/manifest", srv.adminHandler.ImportManifest)
+	mux.Get("/api/admin/v1/config-schemas", srv.adminHandler.ListConfigSchemas)
+	mux.Post("/api/admin/v1/config-schemas/{scope}", srv.adminHandler.SetConfigSchema)
+	mux.Post("/api/admin/v1/config-schemas/{scope}/{target}", srv.adminHandler.SetConfigSchema)
+	mux.Delete("/api/admin/v1/config-schemas/{scope}", srv.adminHandler.DeleteConfigSchema)
+	mux.Delete("/api/admin/v1/config-schemas/{scope}/{target}", srv.adminHandler.DeleteConfigSchema)
+	mux.Get("/api/admin/v1/builders/secrets/{builderName}/keys", srv.adminHandler.ListSecretKeys)
+	mux.Post("/api/admin/v1/builders/secrets/{builderName}/keys/{key}", srv.adminHandler.SetSecretKey)
+	mux.Post("/api/admin/v1/builders/secrets/{builderName}/keys/{key}/rotate", srv.adminHandler.RotateSecretKey)
+	mux.Delete("/api/admin/v1/builders/secrets/{builderName}/keys/{key}", srv.adminHandler.DeleteSecretKey)
+	mux.Get("/api/admin/v1/builders/secrets/{builderName}/rotations", srv.adminHandler.ListSecretRotations)
+	mux.Get("/api/admin/v1/builders/secrets/{builderName}/versions", srv.adminHandler.ListSecretVersions)
+	mux.Get("/api/admin/v1/builders/secrets/{builderName}/versions/{version}", srv.adminHandler.GetSecretVersion)
+	mux.Post("/api/admin/v1/builders/secrets/{builderName}/versions/{version}/restore", srv.adminHandler.RestoreSecretVersion)
+	mux.Get("/api/admin/v1/rotation-policies", srv.adminHandler.ListRotationPolicies)
+	mux.Post("/api/admin/v1/builders/secrets/{builderName}/keys/{key}/rotation-policy", srv.adminHandler.SetRotationPolicy)
+	mux.Delete("/api/admin/v1/builders/secrets/{builderName}/keys/{key}/rotation-policy", srv.adminHandler.DeleteRotationPolicy)
+	mux.Post("/api/admin/v1/builders/secrets/{builderName}/keys/{key}/rotation-policy/rotate", srv.adminHandler.RotateNow)
+	mux.Get("/api/admin/v1/shared-secrets", srv.adminHandler.ListSharedSecrets)
+	mux.Post("/api/admin/v1/shared-secrets/{name}", srv.adminHandler.SetSharedSecret)
+	mux.Delete("/api/admin/v1/shared-secrets/{name}", srv.adminHandler.DeleteSharedSecret)
+	mux.Get("/api/admin/v1/config-layers", srv.adminHandler.ListConfigLayers)
+	mux.Post("/api/admin/v1/config-layers/{kind}/{name}", srv.adminHandler.SetConfigLayer)
+	mux.Delete("/api/admin/v1/config-layers/{kind}/{name}", srv.adminHandler.DeleteConfigLayer)
+	mux.Get("/api/admin/v1/builders/configuration/{builderName}/effective", srv.adminHandler.GetEffectiveConfigForBuilder)
+	mux.Post("/api/admin/v1/builders/configuration/{builderName}/groups", srv.adminHandler.SetConfigGroups)
+	mux.Get("/api/admin/v1/rollouts", srv.adminHandler.ListRollouts)
+	mux.Post("/api/admin/v1/rollouts", srv.adminHandler.CreateRollout)
+	mux.Get("/api/admin/v1/rollouts/{id}", srv.adminHandler.GetRollout)
+	mux.Post("/api/admin/v1/rollouts/{id}/promote", srv.adminHandler.PromoteRollout)
+	mux.Post("/api/admin/v1/rollouts/{id}/abort", srv.adminHandler.AbortRollout)
+	mux.Get("/api/admin/v1/reconciliation", srv.adminHandler.GetReconciliationStatus)
+	mux.Get("/api/admin/v1/proposals", srv.adminHandler.ListProposals)
+	mux.Post("/api/admin/v1/proposals/{proposalID}/approve", srv.adminHandler.ApproveProposal)
+	mux.Post("/api/admin/v1/proposals/{proposalID}/reject", srv.adminHandler.RejectProposal)
 
 	return mux
 }
@@ -142,12 +238,80 @@ func (srv *Server) GetInternalRouter() http.Handler {
 	mux.Use(metrics.Middleware)
 
 	mux.Get("/api/l1-builder/v1/measurements", srv.appHandler.GetAllowedMeasurements)
+	mux.Get("/api/l1-builder/v1/measurements/signed", srv.appHandler.GetSignedMeasurements)
+	mux.Get("/api/l1-builder/v1/signing-key", srv.appHandler.GetSigningKey)
+	mux.Get("/api/l1-builder/v1/measurements/log/tree-head", srv.appHandler.GetMeasurementLogTreeHead)
+	mux.Get("/api/l1-builder/v1/measurements/log/entries", srv.appHandler.GetMeasurementLogEntries)
+	mux.Get("/api/l1-builder/v1/measurements/log/proof/inclusion", srv.appHandler.GetMeasurementLogInclusionProof)
+	mux.Get("/api/l1-builder/v1/measurements/log/proof/consistency", srv.appHandler.GetMeasurementLogConsistencyProof)
 	mux.Get("/api/internal/l1-builder/v1/builders", srv.appHandler.GetActiveBuildersNoAuth)
 	mux.Get("/api/internal/l1-builder/v2/network/{network}/builders", srv.appHandler.GetActiveBuildersNoAuthNetworked)
+	mux.Get("/api/internal/l1-builder/v2/network/{network}/builders/signed", srv.appHandler.GetSignedActiveBuildersNoAuthNetworked)
 
 	return mux
 }
 
+// adminAuthMiddleware authenticates admin requests with an OIDC bearer token when one is
+// presented and OIDC is configured, then with a mapped client certificate, and falls back to Basic Auth otherwise.
+// Principals with the readonly role may only issue GET and HEAD requests.
+func (srv *Server) adminAuthMiddleware() func(http.Handler) http.Handler {
+	basicAuth := srv.basicAuthMiddleware()
+
+	return func(next http.Handler) http.Handler {
+		authorized := requireAdminRole(next)
+		basicNext := basicAuth(authorized)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if srv.cfg.AdminAuthDisabled {
+				basicNext.ServeHTTP(w, r)
+				return
+			}
+			token, hasToken := bearerToken(r)
+			if srv.oidc == nil || !hasToken {
+				if principal, ok := clientCertPrincipal(r, srv.cfg.AdminClientCertRoles); ok {
+					authorized.ServeHTTP(w, r.WithContext(domain.ContextWithAdminPrincipal(r.Context(), *principal)))
+					return
+				}
+				basicNext.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := srv.oidc.Verify(r.Context(), token)
+			if errors.Is(err, ErrNoMatchingRole) {
+				srv.log.Warn("admin token has no role", "err", err)
+				ports.WriteProblem(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			if err != nil {
+				srv.log.Warn("admin token rejected", "err", err)
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				ports.WriteProblem(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				return
+			}
+			authorized.ServeHTTP(w, r.WithContext(domain.ContextWithAdminPrincipal(r.Context(), *principal)))
+		})
+	}
+}
+
+// requireAdminRole rejects write requests from principals without the admin role
+func requireAdminRole(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := domain.AdminPrincipalFromContext(r.Context())
+		if !ok {
+			ports.WriteProblem(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		required := domain.AdminRoleAdmin
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			required = domain.AdminRoleReadOnly
+		}
+		if !principal.Role.Allows(required) {
+			ports.WriteProblem(w, r, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // basicAuthMiddleware enforces HTTP Basic Auth on admin routes.
 // Username must match cfg.AdminBasicUser and password must match cfg.AdminPasswordBcrypt (bcrypt hash).
 func (srv *Server) basicAuthMiddleware() func(http.Handler) http.Handler {
@@ -158,13 +322,14 @@ func (srv *Server) basicAuthMiddleware() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if srv.cfg.AdminAuthDisabled {
 				// pass-through when disabled (for local development only)
-				next.ServeHTTP(w, r)
+				principal := domain.AdminPrincipal{Subject: "anonymous", Role: domain.AdminRoleAdmin, AuthMethod: "none"}
+				next.ServeHTTP(w, r.WithContext(domain.ContextWithAdminPrincipal(r.Context(), principal)))
 				return
 			}
 
 			deny := func() {
 				w.Header().Set("WWW-Authenticate", "Basic realm=admin")
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				ports.WriteProblem(w, r, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
 			}
 
 			u, p, ok := r.BasicAuth()
@@ -184,7 +349,8 @@ func (srv *Server) basicAuthMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			principal := domain.AdminPrincipal{Subject: u, Role: domain.AdminRoleAdmin, AuthMethod: "basic"}
+			next.ServeHTTP(w, r.WithContext(domain.ContextWithAdminPrincipal(r.Context(), principal)))
 		})
 	}
 }
@@ -225,26 +391,80 @@ func (srv *Server) RunInBackground() {
 
 	// api
 	go func() {
-		srv.log.Info("Starting HTTP server", "listenAddress", srv.cfg.ListenAddr)
-		if err := srv.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		srv.log.Info("Starting HTTP server", "listenAddress", srv.cfg.ListenAddr, "tls", srv.srv.TLSConfig != nil)
+		if err := listenAndServe(srv.srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			srv.log.Error("HTTP server failed", "err", err)
 		}
 	}()
 	go func() {
-		srv.log.Info("Starting internal HTTP server", "listenAddress", srv.cfg.InternalAddr)
-		if err := srv.internalSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if srv.internalSrv.TLSConfig == nil {
+			srv.log.Warn("Internal HTTP server is serving plaintext as allowed, configure TLS to protect the peer list", "listenAddress", srv.cfg.InternalAddr)
+		}
+		srv.log.Info("Starting internal HTTP server", "listenAddress", srv.cfg.InternalAddr, "tls", srv.internalSrv.TLSConfig != nil)
+		if err := listenAndServe(srv.internalSrv); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			srv.log.Error("Internal HTTP server failed", "err", err)
 		}
 	}()
 	go func() {
-		srv.log.Info("Starting admin HTTP server", "listenAddress", srv.cfg.AdminAddr)
-		if err := srv.adminSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		srv.log.Info("Starting admin HTTP server", "listenAddress", srv.cfg.AdminAddr, "tls", srv.adminSrv.TLSConfig != nil)
+		if err := listenAndServe(srv.adminSrv); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			srv.log.Error("Admin HTTP server failed", "err", err)
 		}
 	}()
+
+	if len(srv.certs) > 0 {
+		go srv.watchCertificates()
+	}
+}
+
+func listenAndServe(s *http.Server) error {
+	if s.TLSConfig != nil {
+		// certificates are provided by TLSConfig.GetConfigForClient
+		return s.ListenAndServeTLS("", "")
+	}
+	return s.ListenAndServe()
+}
+
+// watchCertificates reloads TLS certificates on SIGHUP and whenever the files change on disk
+func (srv *Server) watchCertificates() {
+	interval := srv.cfg.TLSReloadInterval
+	if interval == 0 {
+		interval = defaultTLSReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-srv.stopCh:
+			return
+		case <-sighup:
+			srv.reloadCertificates(true)
+		case <-ticker.C:
+			srv.reloadCertificates(false)
+		}
+	}
+}
+
+func (srv *Server) reloadCertificates(force bool) {
+	for _, c := range srv.certs {
+		if !force && !c.changed() {
+			continue
+		}
+		if err := c.Reload(); err != nil {
+			srv.log.Error("Failed to reload TLS certificate, keeping previous one", "cert", c.cfg.CertFile, "err", err)
+			continue
+		}
+		srv.log.Info("Reloaded TLS certificate", "cert", c.cfg.CertFile)
+	}
 }
 
 func (srv *Server) Shutdown() {
+	close(srv.stopCh)
+
 	// api
 	ctx, cancel := context.WithTimeout(context.Background(), srv.cfg.GracefulShutdownDuration)
 	defer cancel()
@@ -0,0 +1,151 @@
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+)
+
+const defaultTLSReloadInterval = 30 * time.Second
+
+// TLSConfig configures TLS termination for a single listener
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle used to verify client certificates
+	ClientCAFile string
+	// RequireClientCert rejects connections without a valid client certificate (requires ClientCAFile)
+	RequireClientCert bool
+}
+
+// certReloader serves the current certificate and client CA pool of a listener and
+// reloads them from disk when the files change or when Reload is called.
+type certReloader struct {
+	cfg TLSConfig
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("both TLS certificate and key files are required")
+	}
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		return nil, errors.New("client CA bundle is required to verify client certificates")
+	}
+	c := &certReloader{cfg: cfg}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.cfg.CertFile, c.cfg.KeyFile}
+	if c.cfg.ClientCAFile != "" {
+		files = append(files, c.cfg.ClientCAFile)
+	}
+	return files
+}
+
+// Reload reads the certificate, key and client CA bundle from disk.
+// On failure the previously loaded material stays in use.
+func (c *certReloader) Reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range c.files() {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = fi.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if c.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", c.cfg.ClientCAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.clientCAs = pool
+	c.modTimes = modTimes
+	return nil
+}
+
+// changed reports whether any of the files was modified since the last successful reload
+func (c *certReloader) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for f, modTime := range c.modTimes {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSConfig returns the config of the listener. The config for each client is a clone of it with the current
+// certificate and client CAs, so it keeps the protocols and versions of the listener.
+func (c *certReloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.Certificates = []tls.Certificate{*c.cert}
+		if c.clientCAs != nil {
+			cfg.ClientCAs = c.clientCAs
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			if c.cfg.RequireClientCert {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		return cfg, nil
+	}
+	return base
+}
+
+// clientCertPrincipal maps the verified client certificate of a request to an admin
+// principal using the certificate subject common name.
+func clientCertPrincipal(r *http.Request, roles map[string]domain.AdminRole) (*domain.AdminPrincipal, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := roles[cn]
+	if !ok {
+		return nil, false
+	}
+	return &domain.AdminPrincipal{Subject: cn, Role: role, AuthMethod: "mtls"}, true
+}
@@ -0,0 +1,223 @@
+package httpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+}
+
+func Test_CertReloader_ReloadsChangedFiles(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+
+	certPEM, keyPEM := ca.issue(t, "server-1", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+
+	c, err := newCertReloader(cfg)
+	require.NoError(t, err)
+	require.False(t, c.changed())
+
+	certPEM, keyPEM = ca.issue(t, "server-2", 3, x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cfg.CertFile, future, future))
+	require.True(t, c.changed())
+
+	require.NoError(t, c.Reload())
+	leaf, err := x509.ParseCertificate(c.cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "server-2", leaf.Subject.CommonName)
+
+	// a broken key pair keeps the previous certificate
+	writeFile(t, cfg.KeyFile, []byte("garbage"))
+	require.Error(t, c.Reload())
+	leaf, err = x509.ParseCertificate(c.cert.Certificate[0])
+	require.NoError(t, err)
+	require.Equal(t, "server-2", leaf.Subject.CommonName)
+}
+
+func Test_CertReloader_NegotiatesHTTP2(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	certPEM, keyPEM := ca.issue(t, "builder-hub", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	reloader, err := newCertReloader(cfg)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	httpSrv := &http.Server{
+		Handler:           http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		TLSConfig:         reloader.TLSConfig(),
+		ReadHeaderTimeout: time.Second,
+	}
+	go httpSrv.ServeTLS(ln, "", "") //nolint:errcheck
+	defer httpSrv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, 2, resp.ProtoMajor)
+	require.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+}
+
+func Test_CertReloader_RequiresClientCA(t *testing.T) {
+	_, err := newCertReloader(TLSConfig{CertFile: "a", KeyFile: "b", RequireClientCert: true})
+	require.Error(t, err)
+}
+
+func Test_AdminMTLS_MapsClientCertToPrincipal(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := TLSConfig{
+		CertFile:          filepath.Join(dir, "tls.crt"),
+		KeyFile:           filepath.Join(dir, "tls.key"),
+		ClientCAFile:      filepath.Join(dir, "ca.crt"),
+		RequireClientCert: true,
+	}
+	certPEM, keyPEM := ca.issue(t, "builder-hub", 2, x509.ExtKeyUsageServerAuth)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	writeFile(t, cfg.ClientCAFile, ca.pem)
+
+	reloader, err := newCertReloader(cfg)
+	require.NoError(t, err)
+	srv := &Server{
+		cfg: &HTTPServerConfig{
+			AdminClientCertRoles: map[string]domain.AdminRole{
+				"alice": domain.AdminRoleAdmin,
+				"bob":   domain.AdminRoleReadOnly,
+			},
+		},
+		log: testLogger(),
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := domain.AdminPrincipalFromContext(r.Context())
+		_, _ = fmt.Fprint(w, p.Subject+"/"+p.AuthMethod)
+	})
+	ts := httptest.NewUnstartedServer(srv.adminAuthMiddleware()(next))
+	ts.TLS = reloader.TLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := func(cn string, serial int64) *http.Client {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		tlsCfg := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+		if cn != "" {
+			certPEM, keyPEM := ca.issue(t, cn, serial, x509.ExtKeyUsageClientAuth)
+			pair, err := tls.X509KeyPair(certPEM, keyPEM)
+			require.NoError(t, err)
+			tlsCfg.Certificates = []tls.Certificate{pair}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+	}
+
+	t.Run("mapped admin cert", func(t *testing.T) {
+		resp, err := client("alice", 10).Post(ts.URL, "application/json", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("readonly cert cannot write", func(t *testing.T) {
+		c := client("bob", 11)
+		resp, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = c.Post(ts.URL, "application/json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+	t.Run("unmapped cert falls back to basic auth", func(t *testing.T) {
+		resp, err := client("mallory", 12).Get(ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+	t.Run("no client cert is rejected during handshake", func(t *testing.T) {
+		_, err := client("", 0).Get(ts.URL) //nolint:bodyclose
+		require.Error(t, err)
+	})
+}
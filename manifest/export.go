@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// Store is the storage surface the manifest is built on, it is a subset of ports.AdminBuilderService
+type Store interface {
+	GetAllMeasurements(ctx context.Context) ([]domain.MeasurementWithStatus, error)
+	GetAllBuilders(ctx context.Context) ([]domain.Builder, error)
+	GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]domain.BuilderWithServices, error)
+	GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]domain.ConfigLayer, error)
+	AddMeasurement(ctx context.Context, measurement domain.Measurement, enabled bool) error
+	AddBuilder(ctx context.Context, builder domain.Builder) error
+	ChangeActiveStatusForBuilder(ctx context.Context, builderName string, isActive bool) error
+	ChangeActiveStatusForMeasurement(ctx context.Context, measurementName string, isActive bool) error
+	AddBuilderConfig(ctx context.Context, builderName string, config json.RawMessage) error
+	RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error
+	GetConfigSchemas(ctx context.Context) ([]domain.ConfigSchema, error)
+}
+
+// Export reads the hub state into a manifest. Builder configs are the builders' own layers, network and group layers
+// aren't part of the manifest. Secret references are only included if secrets is not nil.
+func Export(ctx context.Context, store Store, secrets application.SecretAccessor) (*Manifest, error) {
+	measurements, err := store.GetAllMeasurements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list measurements: %w", err)
+	}
+	builders, err := store.GetAllBuilders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builders: %w", err)
+	}
+	services, err := activeServices(ctx, store, builders)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Version:      Version,
+		Measurements: make([]Measurement, 0, len(measurements)),
+		Builders:     make([]Builder, 0, len(builders)),
+	}
+	for _, measurement := range measurements {
+		m.Measurements = append(m.Measurements, Measurement{
+			Name:            measurement.Name,
+			AttestationType: measurement.AttestationType,
+			Measurements:    measurement.Measurement.Measurement,
+			IsActive:        measurement.IsActive,
+		})
+	}
+	for _, b := range builders {
+		_, config, err := builderLayers(ctx, store, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		mb := Builder{
+			Name:      b.Name,
+			IPAddress: b.IPAddress.String(),
+			DNSName:   b.DNSName,
+			Network:   b.Network,
+			IsActive:  b.IsActive,
+			Config:    config,
+			Services:  services[b.Name],
+		}
+		if secrets != nil {
+			if mb.SecretRefs, err = secretRefs(ctx, secrets, b.Name); err != nil {
+				return nil, err
+			}
+		}
+		m.Builders = append(m.Builders, mb)
+	}
+	return m, nil
+}
+
+// activeServices returns the registered services of the active builders by builder name
+func activeServices(ctx context.Context, store Store, builders []domain.Builder) (map[string][]Service, error) {
+	networks := make(map[string]bool)
+	for _, b := range builders {
+		if b.IsActive {
+			networks[b.Network] = true
+		}
+	}
+	res := make(map[string][]Service)
+	for network := range networks {
+		active, err := store.GetActiveBuildersWithServiceCredentials(ctx, network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services for network %s: %w", network, err)
+		}
+		for _, b := range active {
+			for _, s := range b.Services {
+				svc := Service{Service: s.Service, TLSCert: s.TLSCert, Region: s.Region}
+				if s.ECDSAPubKey != nil {
+					svc.ECDSAPubKey = s.ECDSAPubKey.Hex()
+				}
+				res[b.Builder.Name] = append(res[b.Builder.Name], svc)
+			}
+		}
+	}
+	return res, nil
+}
+
+// secretRefs returns the sorted secret names of the builder
+func secretRefs(ctx context.Context, secrets application.SecretAccessor, builderName string) ([]string, error) {
+	values, err := secrets.GetSecretValues(ctx, builderName)
+	if errors.Is(err, application.ErrMissingSecret) || (err == nil && len(values) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch secrets for builder %s: %w", builderName, err)
+	}
+	var parsed map[string]json.RawMessage
+	if err = json.Unmarshal(values, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets for builder %s: %w", builderName, err)
+	}
+	refs := make([]string, 0, len(parsed))
+	for name := range parsed {
+		refs = append(refs, name)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// builderLayers returns the shared layers that apply to the builder and its own config, which is nil if the
+// builder has none
+func builderLayers(ctx context.Context, store Store, builderName string) ([]domain.ConfigLayer, json.RawMessage, error) {
+	layers, err := store.GetConfigLayersForBuilder(ctx, builderName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch config layers for builder %s: %w", builderName, err)
+	}
+	if len(layers) > 0 && layers[len(layers)-1].Kind == domain.ConfigLayerBuilder {
+		return layers[:len(layers)-1], layers[len(layers)-1].Config, nil
+	}
+	return layers, nil, nil
+}
@@ -0,0 +1,262 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+)
+
+// Change actions, in the order they are applied for a target
+const (
+	ActionAddMeasurement        = "add_measurement"
+	ActionActivateMeasurement   = "activate_measurement"
+	ActionDeactivateMeasurement = "deactivate_measurement"
+	ActionAddBuilder            = "add_builder"
+	ActionSetConfig             = "set_config"
+	ActionRegisterService       = "register_service"
+	ActionActivateBuilder       = "activate_builder"
+	ActionDeactivateBuilder     = "deactivate_builder"
+)
+
+// Change is a single admin operation needed to bring the hub to the manifest state
+type Change struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+
+	apply func(ctx context.Context) error
+}
+
+// Plan is the diff between the hub and a manifest. Conflicts are differences the admin API can't
+// change (measurements are immutable, builders can't be renamed or moved), they block the import.
+// Warnings don't block it.
+type Plan struct {
+	Changes   []Change `json:"changes"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// Diff computes the plan to import m. Entries missing from the manifest are left untouched.
+// If secrets is not nil, missing secret references are reported as warnings.
+func Diff(ctx context.Context, store Store, secrets application.SecretAccessor, m *Manifest) (*Plan, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	measurements, err := store.GetAllMeasurements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list measurements: %w", err)
+	}
+	builders, err := store.GetAllBuilders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builders: %w", err)
+	}
+	services, err := activeServices(ctx, store, builders)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := store.GetConfigSchemas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config schemas: %w", err)
+	}
+
+	plan := &Plan{Changes: []Change{}}
+	existingMeasurements := make(map[string]domain.MeasurementWithStatus, len(measurements))
+	for _, measurement := range measurements {
+		existingMeasurements[measurement.Name] = measurement
+	}
+	for _, measurement := range m.Measurements {
+		diffMeasurement(plan, store, measurement, existingMeasurements)
+	}
+
+	existingBuilders := make(map[string]domain.Builder, len(builders))
+	for _, b := range builders {
+		existingBuilders[b.Name] = b
+	}
+	for _, b := range m.Builders {
+		if err = diffBuilder(ctx, plan, store, b, existingBuilders, services[b.Name], schemas); err != nil {
+			return nil, err
+		}
+		if secrets != nil && len(b.SecretRefs) > 0 {
+			refs, err := secretRefs(ctx, secrets, b.Name)
+			if err != nil {
+				return nil, err
+			}
+			for _, missing := range missingRefs(b.SecretRefs, refs) {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("builder %s is missing secret %s", b.Name, missing))
+			}
+		}
+	}
+	return plan, nil
+}
+
+// Import applies m. With dryRun, or if there are conflicts, nothing is changed and only the plan is returned.
+// Changes are applied in order and a failed import can be retried, applied changes drop out of the next plan.
+func Import(ctx context.Context, store Store, secrets application.SecretAccessor, m *Manifest, dryRun bool) (*Plan, error) {
+	plan, err := Diff(ctx, store, secrets, m)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Conflicts) > 0 {
+		return plan, fmt.Errorf("%w: %s", domain.ErrConflict, strings.Join(plan.Conflicts, "; "))
+	}
+	if dryRun {
+		return plan, nil
+	}
+	for _, c := range plan.Changes {
+		if err = c.apply(ctx); err != nil {
+			return plan, fmt.Errorf("failed to %s %s: %w", strings.ReplaceAll(c.Action, "_", " "), c.Target, err)
+		}
+	}
+	return plan, nil
+}
+
+func (p *Plan) add(action, target string, apply func(ctx context.Context) error) {
+	p.Changes = append(p.Changes, Change{Action: action, Target: target, apply: apply})
+}
+
+func diffMeasurement(plan *Plan, store Store, measurement Measurement, existing map[string]domain.MeasurementWithStatus) {
+	name := measurement.Name
+	current, ok := existing[name]
+	if !ok {
+		dm := domain.NewMeasurement(name, measurement.AttestationType, measurement.Measurements)
+		plan.add(ActionAddMeasurement, name, func(ctx context.Context) error {
+			return store.AddMeasurement(ctx, *dm, measurement.IsActive)
+		})
+		return
+	}
+	if current.AttestationType != measurement.AttestationType || !sameMeasurements(current.Measurement.Measurement, measurement.Measurements) {
+		plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("measurement %s differs from the existing one", name))
+		return
+	}
+	if current.IsActive != measurement.IsActive {
+		action := ActionDeactivateMeasurement
+		if measurement.IsActive {
+			action = ActionActivateMeasurement
+		}
+		plan.add(action, name, func(ctx context.Context) error {
+			return store.ChangeActiveStatusForMeasurement(ctx, name, measurement.IsActive)
+		})
+	}
+}
+
+func diffBuilder(ctx context.Context, plan *Plan, store Store, b Builder, existing map[string]domain.Builder, currentServices []Service, schemas []domain.ConfigSchema) error {
+	name := b.Name
+	ip := net.ParseIP(b.IPAddress)
+	current, ok := existing[name]
+	// the manifest holds the builder's own config, the shared layers are managed through the admin API
+	var (
+		shared        []domain.ConfigLayer
+		currentConfig json.RawMessage
+	)
+	if ok {
+		if !current.IPAddress.Equal(ip) || current.Network != b.Network || current.DNSName != b.DNSName {
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("builder %s differs from the existing one (ip_address, network or dns_name)", name))
+			return nil
+		}
+		var err error
+		if shared, currentConfig, err = builderLayers(ctx, store, name); err != nil {
+			return err
+		}
+	} else {
+		builder := domain.Builder{Name: name, IPAddress: ip, Network: b.Network, DNSName: b.DNSName}
+		plan.add(ActionAddBuilder, name, func(ctx context.Context) error {
+			return store.AddBuilder(ctx, builder)
+		})
+	}
+
+	setConfig := len(b.Config) > 0 && !application.SameJSON(currentConfig, b.Config)
+	if setConfig {
+		plan.add(ActionSetConfig, name, func(ctx context.Context) error {
+			return store.AddBuilderConfig(ctx, name, b.Config)
+		})
+	}
+	// like the admin API, configs are validated on upload and on activation
+	config := b.Config
+	if len(config) == 0 {
+		config = currentConfig
+	}
+	if len(config) > 0 && (setConfig || (b.IsActive && !current.IsActive)) {
+		builder := domain.Builder{Name: name, Network: b.Network}
+		effective, err := domain.MergeConfigLayers(append(shared, domain.ConfigLayer{Kind: domain.ConfigLayerBuilder, Name: name, Config: config}))
+		if err == nil {
+			err = application.ValidateConfig(schemas, builder, effective.Config)
+		}
+		if err != nil {
+			if !errors.Is(err, domain.ErrValidation) {
+				return err
+			}
+			plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("builder %s config: %s", name, err))
+		}
+	}
+	// activation requires a config, like the admin API
+	if b.IsActive && len(b.Config) == 0 && len(currentConfig) == 0 {
+		plan.Conflicts = append(plan.Conflicts, fmt.Sprintf("builder %s is active but has no config", name))
+	}
+
+	// registered services are only visible for active builders, so they can't be diffed for inactive ones
+	if !b.IsActive && len(b.Services) > 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("services of inactive builder %s are not imported", name))
+	}
+	for _, s := range b.Services {
+		if !b.IsActive || containsService(currentServices, s) {
+			continue
+		}
+		var pubKey []byte
+		if s.ECDSAPubKey != "" {
+			pubKey = common.HexToAddress(s.ECDSAPubKey).Bytes()
+		}
+		plan.add(ActionRegisterService, name+"/"+s.Service, func(ctx context.Context) error {
+			// the measurement a service registered with is not part of the manifest
+			return store.RegisterCredentialsForBuilder(ctx, name, s.Service, s.TLSCert, pubKey, "", "", s.Region)
+		})
+	}
+
+	if current.IsActive != b.IsActive {
+		action := ActionDeactivateBuilder
+		if b.IsActive {
+			action = ActionActivateBuilder
+		}
+		plan.add(action, name, func(ctx context.Context) error {
+			return store.ChangeActiveStatusForBuilder(ctx, name, b.IsActive)
+		})
+	}
+	return nil
+}
+
+func sameMeasurements(a, b map[string]domain.SingleMeasurement) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func containsService(services []Service, s Service) bool {
+	for _, current := range services {
+		if current.Service == s.Service && current.TLSCert == s.TLSCert && current.Region == s.Region &&
+			strings.EqualFold(current.ECDSAPubKey, s.ECDSAPubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func missingRefs(want, have []string) []string {
+	present := make(map[string]bool, len(have))
+	for _, ref := range have {
+		present[ref] = true
+	}
+	var missing []string
+	for _, ref := range want {
+		if !present[ref] {
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
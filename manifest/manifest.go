@@ -0,0 +1,187 @@
+// Package manifest exports the hub state as a declarative, versioned manifest and imports it idempotently,
+// to recreate a hub (new region, disaster recovery, staging clone) without replaying admin calls
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/builder-hub/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the manifest format version written by Export and accepted by Import
+const Version = 1
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+type Manifest struct {
+	Version      int           `json:"version"`
+	Measurements []Measurement `json:"measurements"`
+	Builders     []Builder     `json:"builders"`
+}
+
+type Measurement struct {
+	Name            string                              `json:"measurement_id"`
+	AttestationType string                              `json:"attestation_type"`
+	Measurements    map[string]domain.SingleMeasurement `json:"measurements"`
+	IsActive        bool                                `json:"is_active"`
+}
+
+type Builder struct {
+	Name      string `json:"name"`
+	IPAddress string `json:"ip_address"`
+	DNSName   string `json:"dns_name,omitempty"`
+	Network   string `json:"network"`
+	IsActive  bool   `json:"is_active"`
+	// Config is the active config, if any
+	Config json.RawMessage `json:"config,omitempty"`
+	// Services are the registered credentials, only known for active builders
+	Services []Service `json:"services,omitempty"`
+	// SecretRefs are the names of the builder's secrets, values are never exported
+	SecretRefs []string `json:"secret_refs,omitempty"`
+}
+
+type Service struct {
+	Service     string `json:"service"`
+	TLSCert     string `json:"tls_cert,omitempty"`
+	ECDSAPubKey string `json:"ecdsa_pubkey,omitempty"`
+	Region      string `json:"region,omitempty"`
+}
+
+// Encode writes the manifest as indented JSON or as YAML
+func Encode(m *Manifest, format string) ([]byte, error) {
+	bts, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatJSON:
+		return append(bts, '\n'), nil
+	case FormatYAML:
+		// going through JSON keeps the field names and order, and embeds configs as plain YAML
+		var node yaml.Node
+		if err = yaml.Unmarshal(bts, &node); err != nil {
+			return nil, err
+		}
+		resetStyle(&node)
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err = enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w manifest format %q", domain.ErrValidation, format)
+	}
+}
+
+// resetStyle switches a node parsed from JSON to block style
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		resetStyle(n)
+	}
+}
+
+// Decode reads a JSON or YAML manifest and validates it
+func Decode(data []byte) (*Manifest, error) {
+	// YAML is a superset of JSON, converting to JSON lets configs decode into json.RawMessage
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w manifest: %w", domain.ErrValidation, err)
+	}
+	bts, err := json.Marshal(stringKeys(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w manifest: %w", domain.ErrValidation, err)
+	}
+	var m Manifest
+	if err = json.Unmarshal(bts, &m); err != nil {
+		return nil, fmt.Errorf("%w manifest: %w", domain.ErrValidation, err)
+	}
+	if err = m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// stringKeys converts YAML mappings with non-string keys, e.g. unquoted PCR indices, to JSON objects
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+		return v
+	case map[any]any:
+		res := make(map[string]any, len(v))
+		for k, e := range v {
+			res[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return res
+	case []any:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// Validate checks the manifest on its own, without looking at the hub state
+func (m *Manifest) Validate() error {
+	if m.Version != Version {
+		return fmt.Errorf("%w manifest: unsupported version %d, expected %d", domain.ErrValidation, m.Version, Version)
+	}
+	measurements := make(map[string]bool, len(m.Measurements))
+	for _, measurement := range m.Measurements {
+		if measurement.Name == "" || measurement.AttestationType == "" {
+			return fmt.Errorf("%w manifest: measurements need a measurement_id and an attestation_type", domain.ErrValidation)
+		}
+		if measurements[measurement.Name] {
+			return fmt.Errorf("%w manifest: duplicate measurement %s", domain.ErrValidation, measurement.Name)
+		}
+		measurements[measurement.Name] = true
+	}
+	builders := make(map[string]bool, len(m.Builders))
+	for _, b := range m.Builders {
+		if b.Name == "" || b.Network == "" {
+			return fmt.Errorf("%w manifest: builders need a name and a network", domain.ErrValidation)
+		}
+		if err := domain.ValidBuilderName(b.Name); err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+		if builders[b.Name] {
+			return fmt.Errorf("%w manifest: duplicate builder %s", domain.ErrValidation, b.Name)
+		}
+		builders[b.Name] = true
+		if net.ParseIP(b.IPAddress) == nil {
+			return fmt.Errorf("%w manifest: builder %s has an invalid ip_address %q", domain.ErrValidation, b.Name, b.IPAddress)
+		}
+		if len(b.Config) > 0 && !json.Valid(b.Config) {
+			return fmt.Errorf("%w manifest: builder %s has an invalid config", domain.ErrValidation, b.Name)
+		}
+		services := make(map[string]bool, len(b.Services))
+		for _, s := range b.Services {
+			if s.Service == "" {
+				return fmt.Errorf("%w manifest: builder %s has a service without a name", domain.ErrValidation, b.Name)
+			}
+			if services[s.Service] {
+				return fmt.Errorf("%w manifest: builder %s has duplicate service %s", domain.ErrValidation, b.Name, s.Service)
+			}
+			services[s.Service] = true
+			if s.ECDSAPubKey != "" && !common.IsHexAddress(s.ECDSAPubKey) {
+				return fmt.Errorf("%w manifest: builder %s service %s has an invalid ecdsa_pubkey", domain.ErrValidation, b.Name, s.Service)
+			}
+		}
+	}
+	return nil
+}
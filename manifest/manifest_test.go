@@ -0,0 +1,232 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func sourceHub(t *testing.T) (*domain.InmemoryBuilderService, *domain.InmemorySecretService) {
+	t.Helper()
+	ctx := context.Background()
+	store := domain.NewInmemoryBuilderService()
+	require.NoError(t, store.AddMeasurement(ctx, *domain.NewMeasurement("m-1", "azure-tdx", map[string]domain.SingleMeasurement{
+		"4":  {Expected: "0x01"},
+		"11": {ExpectedAny: []string{"0x02", "0x03"}},
+	}), true))
+	require.NoError(t, store.AddMeasurement(ctx, *domain.NewMeasurement("m-2", "dcap-tdx", map[string]domain.SingleMeasurement{
+		"mrtd": {Expected: "0x04"},
+	}), false))
+
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: domain.ProductionNetwork, DNSName: "b-1.example"}))
+	require.NoError(t, store.AddBuilderConfig(ctx, "b-1", json.RawMessage(`{"a": {"b": 1}}`)))
+	require.NoError(t, store.ChangeActiveStatusForBuilder(ctx, "b-1", true))
+	pubKey := common.HexToAddress("0x00000000000000000000000000000000000000aa").Bytes()
+	require.NoError(t, store.RegisterCredentialsForBuilder(ctx, "b-1", "rbuilder", "cert", pubKey, "m-1", "azure-tdx", "eu"))
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-2", IPAddress: net.ParseIP("2001:db8::1"), Network: "testnet"}))
+
+	secrets := domain.NewMockSecretService()
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"key_b": "x", "key_a": "y"}`)))
+	return store, secrets
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source, secrets := sourceHub(t)
+	exported, err := Export(ctx, source, secrets)
+	require.NoError(t, err)
+	require.Len(t, exported.Measurements, 2)
+	require.Len(t, exported.Builders, 2)
+	require.Equal(t, []string{"key_a", "key_b"}, exported.Builders[0].SecretRefs)
+	require.Equal(t, []Service{{Service: "rbuilder", TLSCert: "cert", ECDSAPubKey: "0x00000000000000000000000000000000000000AA", Region: "eu"}}, exported.Builders[0].Services)
+
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			bts, err := Encode(exported, format)
+			require.NoError(t, err)
+			m, err := Decode(bts)
+			require.NoError(t, err)
+
+			target := domain.NewInmemoryBuilderService()
+			targetSecrets := domain.NewMockSecretService()
+			plan, err := Import(ctx, target, targetSecrets, m, true)
+			require.NoError(t, err)
+			var actions []string
+			for _, c := range plan.Changes {
+				actions = append(actions, c.Action+" "+c.Target)
+			}
+			require.Equal(t, []string{
+				"add_measurement m-1",
+				"add_measurement m-2",
+				"add_builder b-1",
+				"set_config b-1",
+				"register_service b-1/rbuilder",
+				"activate_builder b-1",
+				"add_builder b-2",
+			}, actions)
+			require.Equal(t, []string{"builder b-1 is missing secret key_a", "builder b-1 is missing secret key_b"}, plan.Warnings)
+
+			dryRun, err := Export(ctx, target, nil)
+			require.NoError(t, err)
+			require.Empty(t, dryRun.Builders, "dry run changes nothing")
+
+			_, err = Import(ctx, target, nil, m, false)
+			require.NoError(t, err)
+			imported, err := Export(ctx, target, nil)
+			require.NoError(t, err)
+			want := *exported
+			want.Builders = append([]Builder{}, exported.Builders...)
+			for i := range want.Builders {
+				want.Builders[i].SecretRefs = nil
+			}
+			requireSameManifest(t, &want, imported)
+
+			plan, err = Import(ctx, target, nil, m, false)
+			require.NoError(t, err)
+			require.Empty(t, plan.Changes, "import is idempotent")
+		})
+	}
+}
+
+func requireSameManifest(t *testing.T, want, got *Manifest) {
+	t.Helper()
+	wantJSON, err := Encode(want, FormatJSON)
+	require.NoError(t, err)
+	gotJSON, err := Encode(got, FormatJSON)
+	require.NoError(t, err)
+	require.JSONEq(t, string(wantJSON), string(gotJSON))
+}
+
+func TestImportStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	store, _ := sourceHub(t)
+	m, err := Export(ctx, store, nil)
+	require.NoError(t, err)
+	m.Measurements[0].IsActive = false
+	m.Measurements[1].IsActive = true
+	m.Builders[0].IsActive = false
+	m.Builders[0].Services = nil
+	m.Builders[0].Config = json.RawMessage(`{"a": {"b": 2}}`)
+
+	plan, err := Import(ctx, store, nil, m, false)
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Action: ActionDeactivateMeasurement, Target: "m-1"},
+		{Action: ActionActivateMeasurement, Target: "m-2"},
+		{Action: ActionSetConfig, Target: "b-1"},
+		{Action: ActionDeactivateBuilder, Target: "b-1"},
+	}, withoutApply(plan.Changes))
+
+	exported, err := Export(ctx, store, nil)
+	require.NoError(t, err)
+	requireSameManifest(t, m, exported)
+}
+
+func withoutApply(changes []Change) []Change {
+	res := make([]Change, 0, len(changes))
+	for _, c := range changes {
+		res = append(res, Change{Action: c.Action, Target: c.Target})
+	}
+	return res
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+	store, _ := sourceHub(t)
+	m, err := Export(ctx, store, nil)
+	require.NoError(t, err)
+	m.Measurements[0].Measurements = map[string]domain.SingleMeasurement{"4": {Expected: "0xff"}}
+	m.Builders[1].Network = "production"
+	m.Builders = append(m.Builders, Builder{Name: "b-3", IPAddress: "10.0.0.3", Network: "testnet", IsActive: true})
+
+	plan, err := Import(ctx, store, nil, m, false)
+	require.ErrorIs(t, err, domain.ErrConflict)
+	require.Equal(t, []string{
+		"measurement m-1 differs from the existing one",
+		"builder b-2 differs from the existing one (ip_address, network or dns_name)",
+		"builder b-3 is active but has no config",
+	}, plan.Conflicts)
+
+	builders, err := store.GetAllBuilders(ctx)
+	require.NoError(t, err)
+	require.Len(t, builders, 2, "nothing is applied when there are conflicts")
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode([]byte(`
+version: 1
+measurements:
+  - measurement_id: m-1
+    attestation_type: azure-tdx
+    is_active: true
+    measurements:
+      4: {expected: "0x01"}
+builders:
+  - name: b-1
+    ip_address: 10.0.0.1
+    network: production
+    config:
+      nested: {list: [1, 2]}
+`))
+	require.NoError(t, err)
+	require.Equal(t, domain.SingleMeasurement{Expected: "0x01"}, m.Measurements[0].Measurements["4"])
+	require.JSONEq(t, `{"nested": {"list": [1, 2]}}`, string(m.Builders[0].Config))
+
+	for _, invalid := range []string{
+		`version: 2`,
+		`{"version": 1, "builders": [{"name": "b", "ip_address": "not-an-ip", "network": "production"}]}`,
+		`{"version": 1, "builders": [{"name": "b", "ip_address": "10.0.0.1"}]}`,
+		`{"version": 1, "builders": [{"name": "shared/relay-key", "ip_address": "10.0.0.1", "network": "n"}]}`,
+		`{"version": 1, "measurements": [{"measurement_id": "m", "attestation_type": "t"}, {"measurement_id": "m", "attestation_type": "t"}]}`,
+		`{"version": 1, "builders": [{"name": "b", "ip_address": "10.0.0.1", "network": "n", "services": [{"service": "s", "ecdsa_pubkey": "0x1234"}]}]}`,
+		`[`,
+	} {
+		_, err = Decode([]byte(invalid))
+		require.ErrorIs(t, err, domain.ErrValidation, invalid)
+	}
+}
+
+func TestImportValidatesConfigs(t *testing.T) {
+	ctx := context.Background()
+	store, _ := sourceHub(t)
+	require.NoError(t, store.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeNetwork, Target: "testnet", Schema: json.RawMessage(`{"required": ["relays"]}`)}))
+	m, err := Export(ctx, store, nil)
+	require.NoError(t, err)
+	_, err = Import(ctx, store, nil, m, false)
+	require.NoError(t, err, "unchanged configs are not revalidated")
+
+	m.Builders[1].Config = json.RawMessage(`{"rbuilder": {}}`)
+	plan, err := Import(ctx, store, nil, m, false)
+	require.ErrorIs(t, err, domain.ErrConflict)
+	require.Equal(t, []string{"builder b-2 config: invalid config: (root): missing property 'relays' (network testnet schema)"}, plan.Conflicts)
+}
+
+func TestSharedLayersAreNotPartOfTheManifest(t *testing.T) {
+	ctx := context.Background()
+	store, _ := sourceHub(t)
+	require.NoError(t, store.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: domain.ProductionNetwork, Config: json.RawMessage(`{"relays": ["r-1"]}`)}))
+	m, err := Export(ctx, store, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": {"b": 1}}`, string(m.Builders[0].Config))
+
+	// changing a shared layer is no drift
+	require.NoError(t, store.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: domain.ProductionNetwork, Config: json.RawMessage(`{"relays": ["r-2"]}`)}))
+	plan, err := Import(ctx, store, nil, m, false)
+	require.NoError(t, err)
+	require.Empty(t, plan.Changes)
+
+	// imported configs are validated merged over the shared layers and stored as the builder's own layer
+	require.NoError(t, store.SetConfigSchema(ctx, domain.ConfigSchema{Scope: domain.SchemaScopeNetwork, Target: domain.ProductionNetwork, Schema: json.RawMessage(`{"required": ["relays"]}`)}))
+	m.Builders[0].Config = json.RawMessage(`{"a": {"b": 2}}`)
+	_, err = Import(ctx, store, nil, m, false)
+	require.NoError(t, err)
+	layers, err := store.GetConfigLayersForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+	require.JSONEq(t, `{"a": {"b": 2}}`, string(layers[1].Config))
+}
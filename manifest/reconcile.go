@@ -0,0 +1,234 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/metrics"
+)
+
+// Reconciliation results, also used as metric labels
+const (
+	ResultInSync   = "in_sync"
+	ResultApplied  = "applied"
+	ResultConflict = "conflict"
+	ResultError    = "error"
+)
+
+// LoadDir reads all manifests (*.yaml, *.yml, *.json) below dir, skipping hidden files and directories like .git,
+// and merges them in path order. An entry may only be declared once across files.
+// The revision is a hash of the file names and contents.
+func LoadDir(dir string) (*Manifest, string, error) {
+	// the directory is usually a symlink swapped by git-sync, WalkDir doesn't follow it
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(paths)
+
+	merged := &Manifest{Version: Version, Measurements: []Measurement{}, Builders: []Builder{}}
+	hash := sha256.New()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		rel, _ := filepath.Rel(root, path)
+		hash.Write([]byte(rel))
+		hash.Write(data)
+		m, err := Decode(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", rel, err)
+		}
+		merged.Measurements = append(merged.Measurements, m.Measurements...)
+		merged.Builders = append(merged.Builders, m.Builders...)
+	}
+	if err = merged.Validate(); err != nil {
+		return nil, "", err
+	}
+	return merged, hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// Status is the outcome of the last reconciliation
+type Status struct {
+	Dir      string `json:"dir"`
+	Revision string `json:"revision"`
+	Result   string `json:"result"`
+	// Changes is the drift between the hub and the manifests found in the last run, it is applied unless there are conflicts
+	Changes     []Change  `json:"changes"`
+	Conflicts   []string  `json:"conflicts,omitempty"`
+	Warnings    []string  `json:"warnings,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LastRun     time.Time `json:"last_run"`
+	LastApplied time.Time `json:"last_applied"`
+	// ManagedBuilders and ManagedMeasurements are declared in the manifests and can't be changed with the admin API
+	ManagedBuilders     []string `json:"managed_builders"`
+	ManagedMeasurements []string `json:"managed_measurements"`
+}
+
+// Reconciler keeps the hub in sync with a directory of manifests, typically a git checkout.
+// Entries that are not declared in the manifests are left alone.
+type Reconciler struct {
+	store Store
+	dir   string
+	log   *slog.Logger
+
+	mu           sync.RWMutex
+	status       Status
+	builders     map[string]bool
+	measurements map[string]bool
+}
+
+func NewReconciler(store Store, dir string, log *slog.Logger) *Reconciler {
+	return &Reconciler{
+		store:  store,
+		dir:    dir,
+		log:    log,
+		status: Status{Dir: dir, Changes: []Change{}, ManagedBuilders: []string{}, ManagedMeasurements: []string{}},
+	}
+}
+
+// Run reconciles immediately and then every interval until ctx is done
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile loads the manifests and applies the drift. If the manifests can't be loaded,
+// the objects managed by the last loaded revision stay managed.
+func (r *Reconciler) Reconcile(ctx context.Context) Status {
+	now := time.Now().UTC()
+	m, revision, err := LoadDir(r.dir)
+	if err != nil {
+		r.log.Error("failed to load manifests", "dir", r.dir, "err", err)
+		return r.finish(Status{Result: ResultError, Error: err.Error(), LastRun: now})
+	}
+	r.manage(m)
+
+	plan, err := Import(ctx, r.store, nil, m, false)
+	status := Status{Revision: revision, LastRun: now}
+	if plan != nil {
+		status.Changes, status.Conflicts, status.Warnings = plan.Changes, plan.Conflicts, plan.Warnings
+	}
+	switch {
+	case errors.Is(err, domain.ErrConflict):
+		r.log.Error("manifests conflict with the hub state, nothing applied", "revision", revision, "conflicts", plan.Conflicts)
+		status.Result = ResultConflict
+	case err != nil:
+		r.log.Error("failed to reconcile manifests", "revision", revision, "err", err)
+		status.Result, status.Error = ResultError, err.Error()
+	case len(plan.Changes) > 0:
+		r.log.Warn("drift reconciled", "revision", revision, "changes", plan.Changes)
+		status.Result, status.LastApplied = ResultApplied, now
+	default:
+		r.log.Debug("hub in sync with manifests", "revision", revision)
+		status.Result = ResultInSync
+	}
+	return r.finish(status)
+}
+
+func (r *Reconciler) manage(m *Manifest) {
+	builders := make(map[string]bool, len(m.Builders))
+	for _, b := range m.Builders {
+		builders[b.Name] = true
+	}
+	measurements := make(map[string]bool, len(m.Measurements))
+	for _, measurement := range m.Measurements {
+		measurements[measurement.Name] = true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.builders, r.measurements = builders, measurements
+}
+
+func (r *Reconciler) finish(status Status) Status {
+	metrics.RecordReconciliation(status.Result, len(status.Changes), len(status.Conflicts))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status.Dir = r.dir
+	if status.Changes == nil {
+		status.Changes = []Change{}
+	}
+	if status.Revision == "" {
+		status.Revision = r.status.Revision
+	}
+	if status.LastApplied.IsZero() {
+		status.LastApplied = r.status.LastApplied
+	}
+	status.ManagedBuilders = sortedKeys(r.builders)
+	status.ManagedMeasurements = sortedKeys(r.measurements)
+	r.status = status
+	return status
+}
+
+// Status returns the outcome of the last reconciliation
+func (r *Reconciler) Status() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+// IsManagedBuilder reports whether the builder is declared in the manifests
+func (r *Reconciler) IsManagedBuilder(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.builders[name]
+}
+
+// IsManagedMeasurement reports whether the measurement is declared in the manifests
+func (r *Reconciler) IsManagedMeasurement(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.measurements[name]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "measurements.yaml"), `
+version: 1
+measurements:
+  - {measurement_id: m-1, attestation_type: azure-tdx, is_active: true}
+`)
+	writeFile(t, filepath.Join(dir, "builders", "production.json"), `{"version": 1, "builders": [{"name": "b-1", "ip_address": "10.0.0.1", "network": "production"}]}`)
+	writeFile(t, filepath.Join(dir, ".git", "config.yaml"), `not a manifest`)
+	writeFile(t, filepath.Join(dir, "README.md"), `# manifests`)
+
+	m, revision, err := LoadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, m.Measurements, 1)
+	require.Len(t, m.Builders, 1)
+	require.Len(t, revision, 12)
+
+	writeFile(t, filepath.Join(dir, "builders", "staging.yaml"), `{version: 1, builders: [{name: b-1, ip_address: 10.0.0.2, network: staging}]}`)
+	_, _, err = LoadDir(dir)
+	require.ErrorIs(t, err, domain.ErrValidation, "an entry can only be declared once")
+
+	writeFile(t, filepath.Join(dir, "builders", "staging.yaml"), `version: 2`)
+	_, _, err = LoadDir(dir)
+	require.ErrorContains(t, err, "staging.yaml")
+}
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hub.yaml"), `
+version: 1
+measurements:
+  - {measurement_id: m-1, attestation_type: azure-tdx, is_active: true}
+builders:
+  - {name: b-1, ip_address: 10.0.0.1, network: production, is_active: true, config: {a: 1}}
+`)
+	store := domain.NewInmemoryBuilderService()
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "unmanaged", IPAddress: []byte{10, 0, 0, 9}, Network: "production"}))
+	r := NewReconciler(store, dir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	status := r.Reconcile(ctx)
+	require.Equal(t, ResultApplied, status.Result)
+	require.Len(t, status.Changes, 4)
+	require.Equal(t, []string{"b-1"}, status.ManagedBuilders)
+	require.Equal(t, []string{"m-1"}, status.ManagedMeasurements)
+	require.True(t, r.IsManagedBuilder("b-1"))
+	require.False(t, r.IsManagedBuilder("unmanaged"))
+	require.True(t, r.IsManagedMeasurement("m-1"))
+
+	status = r.Reconcile(ctx)
+	require.Equal(t, ResultInSync, status.Result)
+	require.Empty(t, status.Changes)
+	require.False(t, status.LastApplied.IsZero())
+
+	// out of band changes are reverted
+	require.NoError(t, store.ChangeActiveStatusForBuilder(ctx, "b-1", false))
+	status = r.Reconcile(ctx)
+	require.Equal(t, ResultApplied, status.Result)
+	require.Equal(t, []Change{{Action: ActionActivateBuilder, Target: "b-1"}}, withoutApply(status.Changes))
+
+	// conflicting manifests are not applied
+	writeFile(t, filepath.Join(dir, "hub.yaml"), `
+version: 1
+builders:
+  - {name: b-1, ip_address: 10.0.0.2, network: production, is_active: false}
+`)
+	status = r.Reconcile(ctx)
+	require.Equal(t, ResultConflict, status.Result)
+	require.Len(t, status.Conflicts, 1)
+	builders, err := store.GetAllBuilders(ctx)
+	require.NoError(t, err)
+	require.True(t, builders[0].IsActive)
+
+	// the last loaded revision stays managed while the manifests are broken
+	revision := status.Revision
+	writeFile(t, filepath.Join(dir, "hub.yaml"), `[`)
+	status = r.Reconcile(ctx)
+	require.Equal(t, ResultError, status.Result)
+	require.NotEmpty(t, status.Error)
+	require.Equal(t, revision, status.Revision)
+	require.True(t, r.IsManagedBuilder("b-1"))
+	require.Equal(t, status, r.Status())
+}
@@ -13,3 +13,30 @@ func recordRequestDuration(route string, duration int64) {
 	l := fmt.Sprintf(requestDurationLabel, route)
 	metrics.GetOrCreateSummary(l).Update(float64(duration))
 }
+
+const (
+	reconciliationsLabel        = `gitops_reconciliations_total{result="%s"}`
+	reconciliationDriftLabel    = `gitops_reconciliation_drift`
+	reconciliationConflictLabel = `gitops_reconciliation_conflicts`
+)
+
+// RecordReconciliation records the result of a GitOps reconciliation run, drift is the number of changes applied
+func RecordReconciliation(result string, drift, conflicts int) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(reconciliationsLabel, result)).Inc()
+	metrics.GetOrCreateGauge(reconciliationDriftLabel, nil).Set(float64(drift))
+	metrics.GetOrCreateGauge(reconciliationConflictLabel, nil).Set(float64(conflicts))
+}
+
+const missingSecretsLabel = `config_fetch_missing_secrets_total`
+
+// RecordMissingSecret records a config fetch refused because the builder has no secrets
+func RecordMissingSecret() {
+	metrics.GetOrCreateCounter(missingSecretsLabel).Inc()
+}
+
+const secretRotationsLabel = `secret_rotations_total{trigger="%s",result="%s"}`
+
+// RecordSecretRotation records a rotation of a builder secret, trigger is scheduled or manual
+func RecordSecretRotation(trigger, result string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(secretRotationsLabel, trigger, result)).Inc()
+}
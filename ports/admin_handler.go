@@ -4,50 +4,111 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/flashbots/builder-hub/application"
 	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/manifest"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httplog/v2"
 )
 
 type AdminBuilderService interface {
+	GetAllMeasurements(ctx context.Context) ([]domain.MeasurementWithStatus, error)
+	GetAllBuilders(ctx context.Context) ([]domain.Builder, error)
+	GetActiveBuildersWithServiceCredentials(ctx context.Context, network string) ([]domain.BuilderWithServices, error)
 	GetActiveConfigForBuilder(ctx context.Context, builderName string) (json.RawMessage, error)
 	AddMeasurement(ctx context.Context, measurement domain.Measurement, enabled bool) error
 	AddBuilder(ctx context.Context, builder domain.Builder) error
 	ChangeActiveStatusForBuilder(ctx context.Context, builderName string, isActive bool) error
 	ChangeActiveStatusForMeasurement(ctx context.Context, measurementName string, isActive bool) error
 	AddBuilderConfig(ctx context.Context, builderName string, config json.RawMessage) error
+	RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error
+	SetConfigSchema(ctx context.Context, schema domain.ConfigSchema) error
+	DeleteConfigSchema(ctx context.Context, scope, target string) error
+	GetConfigSchemas(ctx context.Context) ([]domain.ConfigSchema, error)
+	GetConfigLayersForBuilder(ctx context.Context, builderName string) ([]domain.ConfigLayer, error)
+	SetConfigLayer(ctx context.Context, layer domain.ConfigLayer) error
+	DeleteConfigLayer(ctx context.Context, kind, name string) error
+	GetConfigLayers(ctx context.Context) ([]domain.ConfigLayer, error)
+	SetConfigGroupsForBuilder(ctx context.Context, builderName string, groups []string) error
+	GetConfigGroupsForBuilder(ctx context.Context, builderName string) ([]string, error)
+	CreateRollout(ctx context.Context, rollout domain.Rollout) (*domain.Rollout, error)
+	GetRollouts(ctx context.Context) ([]domain.Rollout, error)
+	GetRollout(ctx context.Context, id int64) (*domain.Rollout, error)
+	PromoteRollout(ctx context.Context, id int64) error
+	AbortRollout(ctx context.Context, id int64) error
+	GetRolloutFetches(ctx context.Context, id int64) (map[string]time.Time, error)
+	application.RotationStore
 }
 
 type AdminSecretService interface {
 	SetSecretValues(ctx context.Context, builderName string, message json.RawMessage) error
+	SetSharedSecret(ctx context.Context, secret domain.SharedSecret) error
+	DeleteSharedSecret(ctx context.Context, name string) error
+	ListSharedSecrets(ctx context.Context) ([]domain.SharedSecret, error)
+	// ListSecretVersions returns the versions of a builder's secrets, newest first
+	ListSecretVersions(ctx context.Context, builderName string) ([]domain.SecretVersion, error)
+	GetSecretVersion(ctx context.Context, builderName, version string) (json.RawMessage, error)
+	// RestoreSecretVersion makes an earlier version of a builder's secrets the current one by writing it again
+	RestoreSecretVersion(ctx context.Context, builderName, version string) error
 	application.SecretAccessor
 }
 
 type AdminHandler struct {
 	builderService AdminBuilderService
 	secretService  AdminSecretService
+	approvals      *ApprovalStore
+	reconciler     *manifest.Reconciler
+	rotator        *application.Rotator
+	// secretsMu serializes read-modify-write updates of single secrets
+	secretsMu *sync.Mutex
 	handler
 }
 
 func NewAdminHandler(service AdminBuilderService, secretService AdminSecretService, log *httplog.Logger) *AdminHandler {
-	return &AdminHandler{builderService: service, secretService: secretService, handler: handler{log: log}}
+	return &AdminHandler{builderService: service, secretService: secretService, secretsMu: &sync.Mutex{}, handler: handler{log: log}}
+}
+
+// WithApprovals requires measurement activations and secret updates to be approved by a second admin
+func (s *AdminHandler) WithApprovals(approvals *ApprovalStore) *AdminHandler {
+	s.approvals = approvals
+	return s
+}
+
+// WithReconciler refuses admin API writes to builders and measurements declared in the GitOps manifests
+func (s *AdminHandler) WithReconciler(reconciler *manifest.Reconciler) *AdminHandler {
+	s.reconciler = reconciler
+	return s
+}
+
+// managedBuilder responds with 403 if the builder is managed by the GitOps manifests
+func (s *AdminHandler) managedBuilder(w http.ResponseWriter, r *http.Request, builderName string) bool {
+	if s.reconciler == nil || !s.reconciler.IsManagedBuilder(builderName) {
+		return false
+	}
+	s.Problem(w, r, http.StatusForbidden, fmt.Sprintf("builder %s is managed by GitOps manifests, change it in the manifest repository", builderName))
+	return true
+}
+
+// managedMeasurement responds with 403 if the measurement is managed by the GitOps manifests
+func (s *AdminHandler) managedMeasurement(w http.ResponseWriter, r *http.Request, measurementName string) bool {
+	if s.reconciler == nil || !s.reconciler.IsManagedMeasurement(measurementName) {
+		return false
+	}
+	s.Problem(w, r, http.StatusForbidden, fmt.Sprintf("measurement %s is managed by GitOps manifests, change it in the manifest repository", measurementName))
+	return true
 }
 
 func (s *AdminHandler) GetActiveConfigForBuilder(w http.ResponseWriter, r *http.Request) {
 	builderName := chi.URLParam(r, "builderName")
 	bts, err := s.builderService.GetActiveConfigForBuilder(r.Context(), builderName)
-	if errors.Is(err, domain.ErrNotFound) {
-		s.log.Warn("no active config for builder found", "error", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
 	if err != nil {
-		s.log.Error("failed to fetch active config for builder", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to fetch active config for builder", err)
 		return
 	}
 	_, err = w.Write(bts)
@@ -60,18 +121,16 @@ func (s *AdminHandler) GetActiveConfigForBuilder(w http.ResponseWriter, r *http.
 func (s *AdminHandler) GetFullConfigForBuilder(w http.ResponseWriter, r *http.Request) {
 	builderName := chi.URLParam(r, "builderName")
 	_, err := s.builderService.GetActiveConfigForBuilder(r.Context(), builderName)
-	if errors.Is(err, domain.ErrNotFound) {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	} else if err != nil {
-		s.log.Error("failed to get config for builder", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if err != nil {
+		s.WriteError(w, r, "failed to get config for builder", err)
 		return
 	}
 	secr, err := s.secretService.GetSecretValues(r.Context(), builderName)
+	if errors.Is(err, application.ErrMissingSecret) {
+		secr, err = json.RawMessage("{}"), nil
+	}
 	if err != nil {
-		s.log.Error("failed to get secrets", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to get secrets", err)
 		return
 	}
 	_, err = w.Write(secr)
@@ -84,8 +143,7 @@ func (s *AdminHandler) AddMeasurement(w http.ResponseWriter, r *http.Request) {
 	// read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.log.Error("Failed to read request body", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "Failed to read request body", err)
 		return
 	}
 	measurement := Measurement{}
@@ -94,10 +152,12 @@ func (s *AdminHandler) AddMeasurement(w http.ResponseWriter, r *http.Request) {
 		s.BadRequest(w, r, "failed to unmarshal request body", err)
 		return
 	}
+	if s.managedMeasurement(w, r, measurement.Name) {
+		return
+	}
 	err = s.builderService.AddMeasurement(r.Context(), toDomainMeasurement(measurement), false)
 	if err != nil {
-		s.log.Error("failed to add measurement", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to add measurement", err)
 		return
 	}
 }
@@ -106,8 +166,7 @@ func (s *AdminHandler) AddBuilder(w http.ResponseWriter, r *http.Request) {
 	// read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.log.Error("Failed to read request body", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "Failed to read request body", err)
 		return
 	}
 	builder := Builder{}
@@ -120,6 +179,9 @@ func (s *AdminHandler) AddBuilder(w http.ResponseWriter, r *http.Request) {
 		s.BadRequest(w, r, "network field is required")
 		return
 	}
+	if s.managedBuilder(w, r, builder.Name) {
+		return
+	}
 	dBuilder, err := toDomainBuilder(builder, false)
 	if err != nil {
 		s.BadRequest(w, r, "failed to convert builder to domain builder", err)
@@ -127,8 +189,7 @@ func (s *AdminHandler) AddBuilder(w http.ResponseWriter, r *http.Request) {
 	}
 	err = s.builderService.AddBuilder(r.Context(), dBuilder)
 	if err != nil {
-		s.log.Error("failed to add builder", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to add builder", err)
 		return
 	}
 }
@@ -139,6 +200,9 @@ type ActivationRequest struct {
 
 func (s *AdminHandler) ChangeActiveStatusForBuilder(w http.ResponseWriter, r *http.Request) {
 	builderName := chi.URLParam(r, "builderName")
+	if s.managedBuilder(w, r, builderName) {
+		return
+	}
 	activationRequest := ActivationRequest{}
 	err := json.NewDecoder(r.Body).Decode(&activationRequest)
 	if err != nil {
@@ -155,55 +219,73 @@ func (s *AdminHandler) ChangeActiveStatusForBuilder(w http.ResponseWriter, r *ht
 			return
 		}
 		if err != nil {
-			s.log.Error("failed to fetch active config for builder", "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			s.WriteError(w, r, "failed to fetch active config for builder", err)
+			return
+		}
+		// schemas and shared layers may have changed since the config was uploaded
+		if err = s.validateActiveConfig(r.Context(), builderName); err != nil {
+			s.WriteError(w, r, "active config is invalid", err)
 			return
 		}
 	}
 
 	err = s.builderService.ChangeActiveStatusForBuilder(r.Context(), builderName, activationRequest.Enabled)
 	if err != nil {
-		s.log.Error("failed to change active status for builder", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to change active status for builder", err)
 		return
 	}
 }
 
 func (s *AdminHandler) ChangeActiveStatusForMeasurement(w http.ResponseWriter, r *http.Request) {
 	measurementName := chi.URLParam(r, "measurementName")
+	if s.managedMeasurement(w, r, measurementName) {
+		return
+	}
 	activationRequest := ActivationRequest{}
 	err := json.NewDecoder(r.Body).Decode(&activationRequest)
 	if err != nil {
 		s.BadRequest(w, r, "failed to decode request body", err)
 		return
 	}
+
+	// deactivation is never gated so that a bad measurement can be revoked immediately
+	if s.approvals != nil && activationRequest.Enabled {
+		s.propose(w, r, ProposalMeasurementActivation, measurementName, func(ctx context.Context) error {
+			return s.builderService.ChangeActiveStatusForMeasurement(ctx, measurementName, true)
+		})
+		return
+	}
+
 	err = s.builderService.ChangeActiveStatusForMeasurement(r.Context(), measurementName, activationRequest.Enabled)
 	if err != nil {
-		s.log.Error("failed to change active status for measurement", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to change active status for measurement", err)
 		return
 	}
 }
 
 func (s *AdminHandler) AddBuilderConfig(w http.ResponseWriter, r *http.Request) {
 	builderName := chi.URLParam(r, "builderName")
+	if s.managedBuilder(w, r, builderName) {
+		return
+	}
 	// read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.log.Error("Failed to read request body", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "Failed to read request body", err)
 		return
 	}
 	if !json.Valid(body) {
 		s.BadRequest(w, r, "invalid json")
 		return
 	}
+	if err = s.validateConfig(r.Context(), builderName, body); err != nil {
+		s.WriteError(w, r, "invalid builder config", err)
+		return
+	}
 
-	// validate valid json
 	err = s.builderService.AddBuilderConfig(r.Context(), builderName, body)
 	if err != nil {
-		s.log.Error("failed to add builder config", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to add builder config", err)
 		return
 	}
 }
@@ -213,15 +295,33 @@ func (s *AdminHandler) SetSecrets(w http.ResponseWriter, r *http.Request) {
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		s.log.Error("Failed to read request body", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "Failed to read request body", err)
+		return
+	}
+
+	if _, err = application.FlattenJSONFromBytes(body); errors.Is(err, application.ErrNonStringSecret) {
+		s.BadRequest(w, r, "invalid secrets", err)
+		return
+	}
+	if err = s.validateSecrets(r.Context(), builderName, body); err != nil {
+		s.WriteError(w, r, "invalid secrets", err)
+		return
+	}
+
+	// the rotator and the single secret endpoints read and write the whole secrets too
+	set := func(ctx context.Context) error {
+		s.secretsMu.Lock()
+		defer s.secretsMu.Unlock()
+		return s.secretService.SetSecretValues(ctx, builderName, body)
+	}
+	if s.approvals != nil {
+		s.propose(w, r, ProposalSetSecrets, builderName, set)
 		return
 	}
 
-	err = s.secretService.SetSecretValues(r.Context(), builderName, body)
+	err = set(r.Context())
 	if err != nil {
-		s.log.Error("failed to set secret", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.WriteError(w, r, "failed to set secret", err)
 		return
 	}
 }
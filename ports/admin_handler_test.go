@@ -0,0 +1,136 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/common"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAdmin returns an admin handler on in-memory storage and do, which serves a request to the handler's
+// routes as an OIDC admin
+func newTestAdmin(t *testing.T) (*AdminHandler, *domain.InmemoryBuilderService, *domain.InmemorySecretService, func(method, path, body string) *httptest.ResponseRecorder) {
+	t.Helper()
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	store := domain.NewInmemoryBuilderService()
+	secrets := domain.NewMockSecretService()
+	h := NewAdminHandler(store, secrets, log)
+
+	mux := chi.NewRouter()
+	mux.Post("/builders/activation/{builderName}", h.ChangeActiveStatusForBuilder)
+	mux.Post("/builders/configuration/{builderName}", h.AddBuilderConfig)
+	mux.Post("/builders/configuration/{builderName}/groups", h.SetConfigGroups)
+	mux.Get("/builders/configuration/{builderName}/effective", h.GetEffectiveConfigForBuilder)
+	mux.Post("/builders/secrets/{builderName}", h.SetSecrets)
+	mux.Get("/builders/secrets/{builderName}/keys", h.ListSecretKeys)
+	mux.Post("/builders/secrets/{builderName}/keys/{key}", h.SetSecretKey)
+	mux.Delete("/builders/secrets/{builderName}/keys/{key}", h.DeleteSecretKey)
+	mux.Post("/builders/secrets/{builderName}/keys/{key}/rotate", h.RotateSecretKey)
+	mux.Post("/builders/secrets/{builderName}/keys/{key}/rotation-policy", h.SetRotationPolicy)
+	mux.Delete("/builders/secrets/{builderName}/keys/{key}/rotation-policy", h.DeleteRotationPolicy)
+	mux.Post("/builders/secrets/{builderName}/keys/{key}/rotation-policy/rotate", h.RotateNow)
+	mux.Get("/builders/secrets/{builderName}/rotations", h.ListSecretRotations)
+	mux.Get("/builders/secrets/{builderName}/versions", h.ListSecretVersions)
+	mux.Get("/builders/secrets/{builderName}/versions/{version}", h.GetSecretVersion)
+	mux.Post("/builders/secrets/{builderName}/versions/{version}/restore", h.RestoreSecretVersion)
+	mux.Get("/config-layers", h.ListConfigLayers)
+	mux.Post("/config-layers/{kind}/{name}", h.SetConfigLayer)
+	mux.Delete("/config-layers/{kind}/{name}", h.DeleteConfigLayer)
+	mux.Get("/config-schemas", h.ListConfigSchemas)
+	mux.Post("/config-schemas/{scope}", h.SetConfigSchema)
+	mux.Post("/config-schemas/{scope}/{target}", h.SetConfigSchema)
+	mux.Delete("/config-schemas/{scope}/{target}", h.DeleteConfigSchema)
+	mux.Get("/rollouts", h.ListRollouts)
+	mux.Post("/rollouts", h.CreateRollout)
+	mux.Get("/rollouts/{id}", h.GetRollout)
+	mux.Post("/rollouts/{id}/promote", h.PromoteRollout)
+	mux.Post("/rollouts/{id}/abort", h.AbortRollout)
+	mux.Get("/rotation-policies", h.ListRotationPolicies)
+	mux.Get("/shared-secrets", h.ListSharedSecrets)
+	mux.Post("/shared-secrets/{name}", h.SetSharedSecret)
+	mux.Delete("/shared-secrets/{name}", h.DeleteSharedSecret)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, asAdmin(httptest.NewRequest(method, path, strings.NewReader(body)), "alice"))
+		return rr
+	}
+	return h, store, secrets, do
+}
+
+// asAdmin authenticates the request as an OIDC admin
+func asAdmin(r *http.Request, subject string) *http.Request {
+	return r.WithContext(domain.ContextWithAdminPrincipal(r.Context(), domain.AdminPrincipal{Subject: subject, Role: domain.AdminRoleAdmin, AuthMethod: "oidc"}))
+}
+
+// newTestInstance returns a config fetch of the instance at ip, authenticated by a hub on top of store and secrets
+func newTestInstance(t *testing.T, store *domain.InmemoryBuilderService, secrets *domain.InmemorySecretService) func(ip string) *httptest.ResponseRecorder {
+	t.Helper()
+	measurement := domain.Measurement{Name: "m-1", AttestationType: "azure-tdx", Measurement: map[string]domain.SingleMeasurement{}}
+	require.NoError(t, store.AddMeasurement(context.Background(), measurement, true))
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	h := NewBuilderHubHandler(application.NewBuilderHub(store, secrets), log)
+	return func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/l1-builder/v1/configuration", nil)
+		req.Header.Set(AttestationTypeHeader, "azure-tdx")
+		req.Header.Set(MeasurementHeader, "{}")
+		req.Header.Set(ForwardedHeader, ip)
+		rr := httptest.NewRecorder()
+		h.GetConfigSecrets(rr, req)
+		return rr
+	}
+}
+
+func TestAdminHandlerErrors(t *testing.T) {
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	h := NewAdminHandler(domain.NewInmemoryBuilderService(), domain.NewMockSecretService(), log)
+
+	mux := chi.NewRouter()
+	mux.Post("/measurements", h.AddMeasurement)
+	mux.Post("/builders", h.AddBuilder)
+	mux.Post("/builders/configuration/{builderName}", h.AddBuilderConfig)
+	mux.Post("/measurements/activation/{measurementName}", h.ChangeActiveStatusForMeasurement)
+	mux.Post("/builders/activation/{builderName}", h.ChangeActiveStatusForBuilder)
+
+	do := func(path string, body any) int {
+		bts, err := json.Marshal(body)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bts)))
+		if rr.Code != http.StatusOK {
+			require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			var p Problem
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+			require.Equal(t, rr.Code, p.Status)
+			require.Equal(t, http.StatusText(rr.Code), p.Title)
+			require.Equal(t, path, p.Instance)
+		}
+		return rr.Code
+	}
+
+	measurement := Measurement{Name: "m-1", AttestationType: "azure-tdx", Measurements: map[string]domain.SingleMeasurement{}}
+	require.Equal(t, http.StatusOK, do("/measurements", measurement))
+	require.Equal(t, http.StatusConflict, do("/measurements", measurement))
+
+	builder := Builder{Name: "b-1", IPAddress: "10.0.0.1", Network: domain.ProductionNetwork}
+	require.Equal(t, http.StatusOK, do("/builders", builder))
+	require.Equal(t, http.StatusConflict, do("/builders", builder))
+	// a builder named after a shared secret would read it without its policy
+	require.Equal(t, http.StatusBadRequest, do("/builders", Builder{Name: "shared/relay-key", IPAddress: "10.0.0.2", Network: domain.ProductionNetwork}))
+
+	require.Equal(t, http.StatusOK, do("/builders/configuration/b-1", map[string]string{"a": "b"}))
+	require.Equal(t, http.StatusNotFound, do("/builders/configuration/unknown", map[string]string{"a": "b"}))
+
+	require.Equal(t, http.StatusNotFound, do("/measurements/activation/unknown", ActivationRequest{Enabled: true}))
+	require.Equal(t, http.StatusNotFound, do("/builders/activation/unknown", ActivationRequest{Enabled: false}))
+	require.Equal(t, http.StatusBadRequest, do("/builders/activation/b-1", "not an activation request"))
+}
@@ -0,0 +1,229 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrProposalNotFound = fmt.Errorf("proposal %w", domain.ErrNotFound)
+	ErrProposalExpired  = errors.New("proposal expired")
+	ErrSelfApproval     = fmt.Errorf("%w: proposal must be approved by a different admin", domain.ErrForbidden)
+	ErrSharedIdentity   = fmt.Errorf("%w: approvals need an individual admin identity, use OIDC or client certificate auth", domain.ErrForbidden)
+)
+
+type ProposalKind string
+
+const (
+	ProposalMeasurementActivation ProposalKind = "measurement_activation"
+	ProposalSetSecrets            ProposalKind = "set_secrets"
+	ProposalManifestImport        ProposalKind = "manifest_import"
+	ProposalRotationPolicy        ProposalKind = "rotation_policy"
+	ProposalRotateSecret          ProposalKind = "rotate_secret"
+)
+
+// Proposal is a pending sensitive admin change waiting for approval by a second admin
+type Proposal struct {
+	ID         string       `json:"id"`
+	Kind       ProposalKind `json:"kind"`
+	Target     string       `json:"target"`
+	ProposedBy string       `json:"proposed_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+
+	apply func(ctx context.Context) error
+}
+
+// ApprovalStore keeps pending proposals in memory. Proposals are intentionally not
+// persisted: a restart drops all pending changes, which then have to be proposed again.
+type ApprovalStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	proposals map[string]*Proposal
+}
+
+func NewApprovalStore(ttl time.Duration) *ApprovalStore {
+	return &ApprovalStore{
+		ttl:       ttl,
+		now:       time.Now,
+		proposals: make(map[string]*Proposal),
+	}
+}
+
+// Propose registers a change that is applied once a different admin approves it
+func (a *ApprovalStore) Propose(kind ProposalKind, target, proposedBy string, apply func(ctx context.Context) error) Proposal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	p := &Proposal{
+		ID:         uuid.NewString(),
+		Kind:       kind,
+		Target:     target,
+		ProposedBy: proposedBy,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(a.ttl),
+		apply:      apply,
+	}
+	a.proposals[p.ID] = p
+	return *p
+}
+
+// Pending returns all unexpired proposals, oldest first
+func (a *ApprovalStore) Pending() []Proposal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pruneExpired()
+
+	res := make([]Proposal, 0, len(a.proposals))
+	for _, p := range a.proposals {
+		res = append(res, *p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res
+}
+
+// Approve applies the proposal on behalf of approvedBy. The proposal is removed
+// regardless of the outcome of apply, so a failed change has to be proposed again.
+func (a *ApprovalStore) Approve(ctx context.Context, id, approvedBy string) (Proposal, error) {
+	a.mu.Lock()
+	p, ok := a.proposals[id]
+	if !ok {
+		a.mu.Unlock()
+		return Proposal{}, ErrProposalNotFound
+	}
+	if a.now().After(p.ExpiresAt) {
+		delete(a.proposals, id)
+		a.mu.Unlock()
+		return *p, ErrProposalExpired
+	}
+	if p.ProposedBy == approvedBy {
+		a.mu.Unlock()
+		return *p, ErrSelfApproval
+	}
+	delete(a.proposals, id)
+	a.mu.Unlock()
+
+	return *p, p.apply(ctx)
+}
+
+// Reject discards a pending proposal
+func (a *ApprovalStore) Reject(id string) (Proposal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.proposals[id]
+	if !ok {
+		return Proposal{}, ErrProposalNotFound
+	}
+	delete(a.proposals, id)
+	return *p, nil
+}
+
+func (a *ApprovalStore) pruneExpired() {
+	now := a.now()
+	for id, p := range a.proposals {
+		if now.After(p.ExpiresAt) {
+			delete(a.proposals, id)
+		}
+	}
+}
+
+func adminSubject(r *http.Request) string {
+	p, ok := domain.AdminPrincipalFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	return p.Subject
+}
+
+// adminIdentity is the identity admins are told apart by for approvals: the subject qualified by the auth method,
+// since an OIDC subject may equal a certificate common name. Basic Auth users share one identity and requests with
+// auth disabled have none, so they can't propose or approve changes.
+func adminIdentity(r *http.Request) (string, error) {
+	p, ok := domain.AdminPrincipalFromContext(r.Context())
+	if !ok || p.Subject == "" {
+		return "", ErrSharedIdentity
+	}
+	switch p.AuthMethod {
+	case "oidc", "mtls":
+		return p.AuthMethod + ":" + p.Subject, nil
+	default:
+		return "", ErrSharedIdentity
+	}
+}
+
+// propose creates a proposal instead of applying the change directly and responds with 202 Accepted
+func (s *AdminHandler) propose(w http.ResponseWriter, r *http.Request, kind ProposalKind, target string, apply func(ctx context.Context) error) {
+	proposedBy, err := adminIdentity(r)
+	if err != nil {
+		s.Problem(w, r, http.StatusForbidden, "proposal rejected", err)
+		return
+	}
+	p := s.approvals.Propose(kind, target, proposedBy, apply)
+	s.log.Info("admin change proposed", "proposal", p.ID, "kind", p.Kind, "target", p.Target, "proposed_by", p.ProposedBy, "expires_at", p.ExpiresAt)
+	s.writeJSON(w, http.StatusAccepted, p)
+}
+
+func (s *AdminHandler) ListProposals(w http.ResponseWriter, r *http.Request) {
+	if s.approvals == nil {
+		s.writeJSON(w, http.StatusOK, []Proposal{})
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.approvals.Pending())
+}
+
+func (s *AdminHandler) ApproveProposal(w http.ResponseWriter, r *http.Request) {
+	if s.approvals == nil {
+		s.Problem(w, r, http.StatusNotFound, "approvals are not enabled")
+		return
+	}
+	approvedBy, err := adminIdentity(r)
+	if err != nil {
+		s.Problem(w, r, http.StatusForbidden, "approval rejected", err)
+		return
+	}
+	p, err := s.approvals.Approve(r.Context(), chi.URLParam(r, "proposalID"), approvedBy)
+	switch {
+	case errors.Is(err, ErrProposalNotFound):
+		s.Problem(w, r, http.StatusNotFound, "proposal not found")
+		return
+	case errors.Is(err, ErrProposalExpired):
+		s.log.Warn("expired proposal not applied", "proposal", p.ID, "kind", p.Kind, "target", p.Target, "proposed_by", p.ProposedBy)
+		s.Problem(w, r, http.StatusGone, "proposal expired")
+		return
+	case errors.Is(err, ErrSelfApproval):
+		s.log.Warn("self approval rejected", "proposal", p.ID, "admin", approvedBy)
+		s.Problem(w, r, http.StatusForbidden, "self approval rejected", err)
+		return
+	case err != nil:
+		s.log.Error("failed to apply approved change", "proposal", p.ID, "kind", p.Kind, "target", p.Target, "proposed_by", p.ProposedBy, "approved_by", approvedBy, "error", err)
+		// the change may fail on its own merits, e.g. the measurement was removed meanwhile
+		s.Problem(w, r, statusForError(err), "failed to apply approved change", err)
+		return
+	}
+	s.log.Info("approved admin change applied", "proposal", p.ID, "kind", p.Kind, "target", p.Target, "proposed_by", p.ProposedBy, "approved_by", approvedBy)
+}
+
+func (s *AdminHandler) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	if s.approvals == nil {
+		s.Problem(w, r, http.StatusNotFound, "approvals are not enabled")
+		return
+	}
+	p, err := s.approvals.Reject(chi.URLParam(r, "proposalID"))
+	if errors.Is(err, ErrProposalNotFound) {
+		s.Problem(w, r, http.StatusNotFound, "proposal not found")
+		return
+	}
+	s.log.Info("admin change rejected", "proposal", p.ID, "kind", p.Kind, "target", p.Target, "proposed_by", p.ProposedBy, "rejected_by", adminSubject(r))
+}
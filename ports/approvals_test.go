@@ -0,0 +1,112 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/common"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMeasurementService struct {
+	AdminBuilderService
+	active map[string]bool
+}
+
+func (f *fakeMeasurementService) ChangeActiveStatusForMeasurement(_ context.Context, name string, isActive bool) error {
+	f.active[name] = isActive
+	return nil
+}
+
+func TestApprovalStore(t *testing.T) {
+	store := NewApprovalStore(time.Hour)
+	applied := 0
+	apply := func(context.Context) error {
+		applied++
+		return nil
+	}
+
+	t.Run("self approval is rejected", func(t *testing.T) {
+		p := store.Propose(ProposalSetSecrets, "builder", "alice", apply)
+		_, err := store.Approve(context.Background(), p.ID, "alice")
+		require.ErrorIs(t, err, ErrSelfApproval)
+		require.Len(t, store.Pending(), 1)
+
+		_, err = store.Approve(context.Background(), p.ID, "bob")
+		require.NoError(t, err)
+		require.Equal(t, 1, applied)
+		require.Empty(t, store.Pending())
+
+		_, err = store.Approve(context.Background(), p.ID, "bob")
+		require.ErrorIs(t, err, ErrProposalNotFound)
+		require.Equal(t, 1, applied)
+	})
+
+	t.Run("expired proposals are not applied", func(t *testing.T) {
+		p := store.Propose(ProposalSetSecrets, "builder", "alice", apply)
+		store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		defer func() { store.now = time.Now }()
+
+		_, err := store.Approve(context.Background(), p.ID, "bob")
+		require.ErrorIs(t, err, ErrProposalExpired)
+		require.Equal(t, 1, applied)
+	})
+
+	t.Run("rejected proposals are discarded", func(t *testing.T) {
+		p := store.Propose(ProposalSetSecrets, "builder", "alice", apply)
+		_, err := store.Reject(p.ID)
+		require.NoError(t, err)
+		_, err = store.Approve(context.Background(), p.ID, "bob")
+		require.ErrorIs(t, err, ErrProposalNotFound)
+	})
+}
+
+func TestMeasurementActivationRequiresApproval(t *testing.T) {
+	svc := &fakeMeasurementService{active: make(map[string]bool)}
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	h := NewAdminHandler(svc, nil, log).WithApprovals(NewApprovalStore(time.Hour))
+
+	mux := chi.NewRouter()
+	mux.Post("/measurements/activation/{measurementName}", h.ChangeActiveStatusForMeasurement)
+	mux.Post("/proposals/{proposalID}/approve", h.ApproveProposal)
+
+	authMethod := "oidc"
+	do := func(path, admin string, body any) *httptest.ResponseRecorder {
+		bts, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bts))
+		req = req.WithContext(domain.ContextWithAdminPrincipal(req.Context(), domain.AdminPrincipal{Subject: admin, Role: domain.AdminRoleAdmin, AuthMethod: authMethod}))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("/measurements/activation/m1", "alice", ActivationRequest{Enabled: true})
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.False(t, svc.active["m1"])
+
+	var p Proposal
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	require.Equal(t, "oidc:alice", p.ProposedBy)
+
+	require.Equal(t, http.StatusForbidden, do("/proposals/"+p.ID+"/approve", "alice", nil).Code)
+	// Basic Auth users share one identity and can't approve
+	authMethod = "basic"
+	require.Equal(t, http.StatusForbidden, do("/proposals/"+p.ID+"/approve", "bob", nil).Code)
+	require.Equal(t, http.StatusForbidden, do("/measurements/activation/m2", "bob", ActivationRequest{Enabled: true}).Code)
+	require.False(t, svc.active["m1"])
+	authMethod = "oidc"
+	require.Equal(t, http.StatusOK, do("/proposals/"+p.ID+"/approve", "bob", nil).Code)
+	require.True(t, svc.active["m1"])
+
+	// deactivation is applied immediately
+	require.Equal(t, http.StatusOK, do("/measurements/activation/m1", "alice", ActivationRequest{Enabled: false}).Code)
+	require.False(t, svc.active["m1"])
+}
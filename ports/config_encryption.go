@@ -0,0 +1,155 @@
+package ports
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/builder-hub/hpke"
+	"github.com/google/go-tdx-guest/abi"
+	tdxpb "github.com/google/go-tdx-guest/proto/tdx"
+	"github.com/google/go-tdx-guest/verify"
+	"github.com/google/go-tdx-guest/verify/trust"
+)
+
+// ConfigEncryptionInfo is the HPKE info of encrypted configs
+const ConfigEncryptionInfo = "builder-hub config v1"
+
+// DCAPTDXAttestation is the attestation type of TDX quotes, the only one that can bind config encryption keys
+const DCAPTDXAttestation = "dcap-tdx"
+
+var (
+	ErrConfigEncryptionRequired = errors.New("config encryption key is required")
+	ErrUnboundEncryptionKey     = errors.New("config encryption key is not bound to the attestation")
+)
+
+// EncryptedConfig is a config with secrets encrypted with HPKE to the instance's attested key. The plaintext is
+// the config as returned without encryption.
+type EncryptedConfig struct {
+	Suite      string        `json:"suite"`
+	Enc        hexutil.Bytes `json:"enc"`
+	Ciphertext hexutil.Bytes `json:"ciphertext"`
+}
+
+// WithConfigEncryption rejects config requests without an attested encryption key. Without it configs are only
+// encrypted for instances that send a key.
+func (bhs *BuilderHubHandler) WithConfigEncryption(required bool) *BuilderHubHandler {
+	bhs.requireConfigEncryption = required
+	return bhs
+}
+
+// WithQuoteCollateral also checks the TCB status and the revocation of the quotes binding encryption keys, with
+// collateral fetched from Intel's PCS
+func (bhs *BuilderHubHandler) WithQuoteCollateral() *BuilderHubHandler {
+	bhs.verifyQuote = tdxQuoteVerifier(true, time.Now)
+	return bhs
+}
+
+// quoteVerifier verifies the signature and certificate chain of a raw TDX quote and returns its body
+type quoteVerifier func(raw []byte) (*tdxpb.TDQuoteBody, error)
+
+func tdxQuoteVerifier(collateral bool, now func() time.Time) quoteVerifier {
+	return func(raw []byte) (*tdxpb.TDQuoteBody, error) {
+		quote, err := abi.QuoteToProto(raw)
+		if err != nil {
+			return nil, err
+		}
+		opts := &verify.Options{GetCollateral: collateral, CheckRevocations: collateral, Now: now()}
+		if collateral {
+			opts.Getter = trust.DefaultHTTPSGetter()
+		}
+		if err = verify.TdxQuote(quote, opts); err != nil {
+			return nil, err
+		}
+		v4, ok := quote.(*tdxpb.QuoteV4)
+		if !ok {
+			return nil, fmt.Errorf("unsupported quote %T", quote)
+		}
+		return v4.GetTdQuoteBody(), nil
+	}
+}
+
+// tdxRegisters are the quote registers by measurement index of the dcap-tdx attestation
+var tdxRegisters = map[string]func(*tdxpb.TDQuoteBody) []byte{
+	"0": (*tdxpb.TDQuoteBody).GetMrTd,
+	"1": func(b *tdxpb.TDQuoteBody) []byte { return rtmr(b, 0) },
+	"2": func(b *tdxpb.TDQuoteBody) []byte { return rtmr(b, 1) },
+	"3": func(b *tdxpb.TDQuoteBody) []byte { return rtmr(b, 2) },
+	"4": func(b *tdxpb.TDQuoteBody) []byte { return rtmr(b, 3) },
+}
+
+func rtmr(b *tdxpb.TDQuoteBody, i int) []byte {
+	if rtmrs := b.GetRtmrs(); i < len(rtmrs) {
+		return rtmrs[i]
+	}
+	return nil
+}
+
+// attestedEncryptionKey returns the instance's config encryption key, nil if it sent none. The hub doesn't trust
+// the proxy to bind the key: the instance sends its own TDX quote, which the hub verifies against Intel's root.
+// The report data of the quote must end with the SHA-256 hash of the raw key, and its registers must match the
+// measurements the instance was authenticated with, so that the key is held by the measured image.
+func (bhs *BuilderHubHandler) attestedEncryptionKey(r *http.Request, authData *AuthData) (*ecdh.PublicKey, error) {
+	keyHeader := r.Header.Get(ConfigEncryptionKeyHeader)
+	if keyHeader == "" {
+		if bhs.requireConfigEncryption {
+			return nil, ErrConfigEncryptionRequired
+		}
+		return nil, nil
+	}
+	key, err := hexutil.Decode(keyHeader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed config encryption key: %w", ErrInvalidAuthData, err)
+	}
+	pub, err := hpke.ParsePublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: config encryption key must be an X25519 public key: %w", ErrInvalidAuthData, err)
+	}
+	if authData.AttestationType != DCAPTDXAttestation {
+		return nil, fmt.Errorf("%w: only %s attestations can bind a key", ErrUnboundEncryptionKey, DCAPTDXAttestation)
+	}
+	quote, err := base64.StdEncoding.DecodeString(r.Header.Get(ConfigEncryptionQuoteHeader))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed quote: %w", ErrUnboundEncryptionKey, err)
+	}
+	body, err := bhs.verifyQuote(quote)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid quote: %w", ErrUnboundEncryptionKey, err)
+	}
+	keyHash := sha256.Sum256(key)
+	if !bytes.HasSuffix(body.GetReportData(), keyHash[:]) {
+		return nil, fmt.Errorf("%w: the quote's report data doesn't commit to the key", ErrUnboundEncryptionKey)
+	}
+	if len(authData.MeasurementData) == 0 {
+		return nil, fmt.Errorf("%w: no measurements", ErrUnboundEncryptionKey)
+	}
+	for index, value := range authData.MeasurementData {
+		register, ok := tdxRegisters[index]
+		if !ok {
+			return nil, fmt.Errorf("%w: measurement %s isn't part of the quote", ErrUnboundEncryptionKey, index)
+		}
+		expected, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+		if err != nil || !bytes.Equal(register(body), expected) {
+			return nil, fmt.Errorf("%w: measurement %s doesn't match the quote", ErrUnboundEncryptionKey, index)
+		}
+	}
+	return pub, nil
+}
+
+// encryptConfig seals the config to the key
+func encryptConfig(pub *ecdh.PublicKey, config []byte) ([]byte, error) {
+	enc, ciphertext, err := hpke.Seal(pub, []byte(ConfigEncryptionInfo), nil, config)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(EncryptedConfig{Suite: hpke.Suite, Enc: enc, Ciphertext: ciphertext})
+}
@@ -0,0 +1,129 @@
+package ports
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/builder-hub/common"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/hpke"
+	tdxpb "github.com/google/go-tdx-guest/proto/tdx"
+	"github.com/google/go-tdx-guest/testing/testdata"
+	"github.com/stretchr/testify/require"
+)
+
+type configService struct {
+	BuilderHubService
+}
+
+func (configService) VerifyIPAndMeasurements(context.Context, net.IP, map[string]string, string) (*domain.Builder, string, error) {
+	return &domain.Builder{Name: "b-1"}, "m-1", nil
+}
+
+func (configService) GetConfigWithSecrets(context.Context, domain.Builder) ([]byte, []int64, error) {
+	return []byte(`{"relay": {"key": "secret"}}`), nil, nil
+}
+
+func (configService) LogRolloutFetches(context.Context, string, []int64) error {
+	return nil
+}
+
+func (configService) LogEvent(context.Context, string, string, string) error {
+	return nil
+}
+
+func TestConfigEncryption(t *testing.T) {
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	h := NewBuilderHubHandler(configService{}, log)
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := priv.PublicKey().Bytes()
+	keyHash := sha256.Sum256(key)
+	mrtd, rtmr0 := bytes.Repeat([]byte{1}, 48), bytes.Repeat([]byte{2}, 48)
+	// the quote is verified by the hub, stand in for Intel's signature
+	h.verifyQuote = func(raw []byte) (*tdxpb.TDQuoteBody, error) {
+		if string(raw) != "quote" {
+			return nil, errors.New("bad signature")
+		}
+		return &tdxpb.TDQuoteBody{
+			MrTd:       mrtd,
+			Rtmrs:      [][]byte{rtmr0, make([]byte, 48), make([]byte, 48), make([]byte, 48)},
+			ReportData: append(make([]byte, 32), keyHash[:]...),
+		}, nil
+	}
+	measurements := fmt.Sprintf(`{"0": "%x", "1": "%x"}`, mrtd, rtmr0)
+	quote := base64.StdEncoding.EncodeToString([]byte("quote"))
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/l1-builder/v1/configuration", nil)
+		req.Header.Set(AttestationTypeHeader, DCAPTDXAttestation)
+		req.Header.Set(MeasurementHeader, measurements)
+		req.Header.Set(ForwardedHeader, "10.0.0.1")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.GetConfigSecrets(rr, req)
+		return rr
+	}
+
+	rr := get(nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"relay": {"key": "secret"}}`, rr.Body.String())
+
+	bound := map[string]string{ConfigEncryptionKeyHeader: hexutil.Encode(key), ConfigEncryptionQuoteHeader: quote}
+	rr = get(bound)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "secret")
+	var encrypted EncryptedConfig
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &encrypted))
+	require.Equal(t, hpke.Suite, encrypted.Suite)
+	plaintext, err := hpke.Open(priv, encrypted.Enc, []byte(ConfigEncryptionInfo), nil, encrypted.Ciphertext)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "secret"}}`, string(plaintext))
+
+	// keys that aren't bound by a valid quote of the measured image are rejected, a proxy could have replaced them
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	for _, headers := range []map[string]string{
+		{ConfigEncryptionKeyHeader: hexutil.Encode(key)},
+		{ConfigEncryptionKeyHeader: hexutil.Encode(key), ConfigEncryptionQuoteHeader: base64.StdEncoding.EncodeToString([]byte("forged"))},
+		{ConfigEncryptionKeyHeader: hexutil.Encode(other.PublicKey().Bytes()), ConfigEncryptionQuoteHeader: quote},
+		{ConfigEncryptionKeyHeader: "0x1234", ConfigEncryptionQuoteHeader: quote},
+		{ConfigEncryptionKeyHeader: hexutil.Encode(key), ConfigEncryptionQuoteHeader: quote, AttestationTypeHeader: "azure-tdx"},
+		{ConfigEncryptionKeyHeader: hexutil.Encode(key), ConfigEncryptionQuoteHeader: quote, MeasurementHeader: fmt.Sprintf(`{"0": "%x"}`, rtmr0)},
+		{ConfigEncryptionKeyHeader: hexutil.Encode(key), ConfigEncryptionQuoteHeader: quote, MeasurementHeader: fmt.Sprintf(`{"0": "%x", "8": "00"}`, mrtd)},
+	} {
+		require.Equal(t, http.StatusForbidden, get(headers).Code, headers)
+	}
+
+	h.WithConfigEncryption(true)
+	require.Equal(t, http.StatusForbidden, get(nil).Code)
+	require.Equal(t, http.StatusOK, get(bound).Code)
+}
+
+func TestTDXQuoteVerifier(t *testing.T) {
+	verifyQuote := tdxQuoteVerifier(false, func() time.Time { return time.Date(2023, time.July, 1, 1, 0, 0, 0, time.UTC) })
+	body, err := verifyQuote(testdata.RawQuote)
+	require.NoError(t, err)
+	require.Len(t, body.GetMrTd(), 48)
+	require.Len(t, body.GetReportData(), 64)
+
+	tampered := bytes.Clone(testdata.RawQuote)
+	tampered[600] ^= 1 // report data
+	_, err = verifyQuote(tampered)
+	require.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListConfigLayers returns the network and group config layers
+func (s *AdminHandler) ListConfigLayers(w http.ResponseWriter, r *http.Request) {
+	layers, err := s.builderService.GetConfigLayers(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list config layers", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, layers)
+}
+
+// SetConfigLayer creates or replaces a network or group layer. The effective configs of the builders it applies to
+// are validated against their schemas first.
+func (s *AdminHandler) SetConfigLayer(w http.ResponseWriter, r *http.Request) {
+	layer := domain.ConfigLayer{Kind: chi.URLParam(r, "kind"), Name: chi.URLParam(r, "name")}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.WriteError(w, r, "failed to read request body", err)
+		return
+	}
+	layer.Config = body
+	if err = domain.ValidSharedConfigLayer(layer); err != nil {
+		s.BadRequest(w, r, "invalid config layer", err)
+		return
+	}
+	if err = s.activeRollout(r.Context(), layer); err != nil {
+		s.WriteError(w, r, "failed to set config layer", err)
+		return
+	}
+	if err = s.validateLayerChange(r.Context(), layer, false); err != nil {
+		s.WriteError(w, r, "config layer would make a builder config invalid", err)
+		return
+	}
+	if err = s.builderService.SetConfigLayer(r.Context(), layer); err != nil {
+		s.WriteError(w, r, "failed to set config layer", err)
+		return
+	}
+	s.log.Info("config layer set", "kind", layer.Kind, "name", layer.Name, "admin", adminSubject(r))
+}
+
+func (s *AdminHandler) DeleteConfigLayer(w http.ResponseWriter, r *http.Request) {
+	layer := domain.ConfigLayer{Kind: chi.URLParam(r, "kind"), Name: chi.URLParam(r, "name")}
+	if err := s.activeRollout(r.Context(), layer); err != nil {
+		s.WriteError(w, r, "failed to delete config layer", err)
+		return
+	}
+	if err := s.validateLayerChange(r.Context(), layer, true); err != nil {
+		s.WriteError(w, r, "removing the config layer would make a builder config invalid", err)
+		return
+	}
+	if err := s.builderService.DeleteConfigLayer(r.Context(), layer.Kind, layer.Name); err != nil {
+		s.WriteError(w, r, "failed to delete config layer", err)
+		return
+	}
+	s.log.Info("config layer deleted", "kind", layer.Kind, "name", layer.Name, "admin", adminSubject(r))
+}
+
+// SetConfigGroups assigns the group layers of a builder, the body is the ordered list of group names
+func (s *AdminHandler) SetConfigGroups(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	if s.managedBuilder(w, r, builderName) {
+		return
+	}
+	var groups []string
+	if err := json.NewDecoder(r.Body).Decode(&groups); err != nil {
+		s.BadRequest(w, r, "failed to decode request body", err)
+		return
+	}
+	state, err := s.configState(r.Context(), builderName)
+	if err != nil {
+		s.WriteError(w, r, "failed to load builder config", err)
+		return
+	}
+	state.groups = groups
+	if state.own != nil {
+		if err = s.validateLayers(r.Context(), state, true); err != nil {
+			s.WriteError(w, r, "config groups would make the builder config invalid", err)
+			return
+		}
+	}
+	if err = s.builderService.SetConfigGroupsForBuilder(r.Context(), builderName, groups); err != nil {
+		s.WriteError(w, r, "failed to set config groups", err)
+		return
+	}
+	s.log.Info("config groups set", "builder", builderName, "groups", groups, "admin", adminSubject(r))
+}
+
+type effectiveConfigResponse struct {
+	*domain.EffectiveConfig
+	Groups []string `json:"groups"`
+}
+
+// GetEffectiveConfigForBuilder returns the merged config of a builder and which layer each value comes from
+func (s *AdminHandler) GetEffectiveConfigForBuilder(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	layers, err := s.builderService.GetConfigLayersForBuilder(r.Context(), builderName)
+	if err != nil {
+		s.WriteError(w, r, "failed to fetch config layers", err)
+		return
+	}
+	groups, err := s.builderService.GetConfigGroupsForBuilder(r.Context(), builderName)
+	if err != nil {
+		s.WriteError(w, r, "failed to fetch config groups", err)
+		return
+	}
+	effective, err := domain.MergeConfigLayers(layers)
+	if err != nil {
+		s.WriteError(w, r, "failed to merge config layers", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, effectiveConfigResponse{EffectiveConfig: effective, Groups: groups})
+}
+
+// validateActiveConfig validates the current effective config of a builder
+func (s *AdminHandler) validateActiveConfig(ctx context.Context, builderName string) error {
+	state, err := s.configState(ctx, builderName)
+	if err != nil {
+		return err
+	}
+	return s.validateLayers(ctx, state, true)
+}
+
+// validateLayerChange validates the effective configs of all builders with a config after replacing or removing a
+// shared layer. Secrets are not checked, that would fetch the secrets of every builder.
+func (s *AdminHandler) validateLayerChange(ctx context.Context, layer domain.ConfigLayer, remove bool) error {
+	builders, err := s.builderService.GetAllBuilders(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list builders: %w", err)
+	}
+	shared, err := s.builderService.GetConfigLayers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list config layers: %w", err)
+	}
+	changed := make([]domain.ConfigLayer, 0, len(shared)+1)
+	for _, l := range shared {
+		if l.ID() != layer.ID() {
+			changed = append(changed, l)
+		}
+	}
+	if !remove {
+		changed = append(changed, layer)
+	}
+	schemas, err := s.builderService.GetConfigSchemas(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list config schemas: %w", err)
+	}
+
+	for _, b := range builders {
+		if layer.Kind == domain.ConfigLayerNetwork && b.Network != layer.Name {
+			continue
+		}
+		state := &builderConfigState{builder: b, shared: changed, schemas: schemas}
+		if err = s.loadBuilderLayers(ctx, state); err != nil {
+			return err
+		}
+		if state.own == nil || (layer.Kind == domain.ConfigLayerGroup && !contains(state.groups, layer.Name)) {
+			continue
+		}
+		if err = s.validateLayers(ctx, state, false); err != nil {
+			return fmt.Errorf("builder %s: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLayers(t *testing.T) {
+	ctx := context.Background()
+	_, store, _, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/config-layers/region/eu", `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/config-layers/group/eu", `[1]`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-layers/network/testnet", `{"relays": ["a"], "log": {"level": "info", "json": true}}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-layers/group/eu", `{"log": {"level": "debug"}}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/configuration/b-1", `{"log": {"json": null}, "name": "b-1"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/configuration/b-1/groups", `["eu"]`).Code)
+
+	rr := do(http.MethodGet, "/builders/configuration/b-1/effective", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var effective effectiveConfigResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &effective))
+	require.JSONEq(t, `{"relays": ["a"], "log": {"level": "debug"}, "name": "b-1"}`, string(effective.Config))
+	require.Equal(t, map[string]string{
+		"/relays":    "network testnet",
+		"/log/level": "group eu",
+		"/name":      "builder b-1",
+	}, effective.Sources)
+	require.Equal(t, []string{"eu"}, effective.Groups)
+
+	config, err := store.GetActiveConfigForBuilder(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, string(effective.Config), string(config))
+
+	// layer changes are validated against the schemas of the builders they apply to
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-schemas/global", `{"required": ["relays"]}`).Code)
+	rr = do(http.MethodDelete, "/config-layers/network/testnet", "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "b-1")
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-layers/network/mainnet", `{}`).Code, "no builders on mainnet")
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/config-layers/group/eu", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/config-layers/group/eu", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/configuration/unknown/groups", `[]`).Code)
+}
+
+func TestInstanceFetchesLayeredConfig(t *testing.T) {
+	ctx := context.Background()
+	_, store, secrets, do := newTestAdmin(t)
+	fetch := newTestInstance(t, store, secrets)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet", IsActive: true}))
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-layers/network/testnet", `{"rpc": "http://rpc-1", "relay": {"url": "http://relay"}}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/configuration/b-1", `{"name": "b-1"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1", `{"relay": {"key": "secret"}}`).Code)
+
+	rr := fetch("10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"rpc": "http://rpc-1", "relay": {"url": "http://relay", "key": "secret"}, "name": "b-1"}`, rr.Body.String())
+
+	// changing the shared layer changes what the instance fetches
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-layers/network/testnet", `{"rpc": "http://rpc-2", "relay": {"url": "http://relay"}}`).Code)
+	rr = fetch("10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"rpc": "http://rpc-2", "relay": {"url": "http://relay", "key": "secret"}, "name": "b-1"}`, rr.Body.String())
+}
+
+func TestInstanceWithoutSecrets(t *testing.T) {
+	ctx := context.Background()
+	_, store, secrets, do := newTestAdmin(t)
+	fetch := newTestInstance(t, store, secrets)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet", IsActive: true}))
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/configuration/b-1", `{"name": "b-1"}`).Code)
+
+	rr := fetch("10.0.0.1")
+	require.Equal(t, http.StatusNotFound, rr.Code, "builders don't boot without their secrets")
+	require.NotContains(t, rr.Body.String(), "b-1")
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1", `{}`).Code)
+	rr = fetch("10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code, "empty secrets are fine")
+	require.JSONEq(t, `{"name": "b-1"}`, rr.Body.String())
+}
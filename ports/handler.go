@@ -2,8 +2,10 @@ package ports
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/flashbots/builder-hub/domain"
 	"github.com/go-chi/httplog/v2"
 )
 
@@ -11,21 +13,95 @@ type handler struct {
 	log *httplog.Logger
 }
 
-type badRequestParams struct {
-	Message string `json:"message"`
+// Problem is an RFC 9457 problem details body
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Violations lists the invalid locations of a rejected document
+	Violations []domain.Violation `json:"violations,omitempty"`
+
+	// Message and Error are kept for clients of the previous error body
+	Message string `json:"message,omitempty"`
 	Error   string `json:"error,omitempty"`
 }
 
+// statusForError maps the domain error kinds to status codes, anything else is an internal error
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Problem writes a problem details response. The first of errs is included for client errors only.
+func (h *handler) Problem(w http.ResponseWriter, r *http.Request, status int, detail string, errs ...error) {
+	WriteProblem(w, r, status, detail, errs...)
+}
+
+// WriteProblem is Problem for middleware outside of the handlers
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string, errs ...error) {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Message:  detail,
+	}
+	if len(errs) > 0 && errs[0] != nil && status < http.StatusInternalServerError {
+		p.Error = errs[0].Error()
+		var verr *domain.ValidationError
+		if errors.As(errs[0], &verr) {
+			p.Violations = verr.Violations
+		}
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&p)
+}
+
 func (h *handler) BadRequest(w http.ResponseWriter, r *http.Request, msg string, errs ...error) {
-	w.WriteHeader(http.StatusBadRequest)
 	if errs == nil {
 		h.log.Warn(msg)
-		_ = json.NewEncoder(w).Encode(&badRequestParams{Message: msg})
+	} else {
+		h.log.Warn(msg, "err", errs[0])
+	}
+	h.Problem(w, r, http.StatusBadRequest, msg, errs...)
+}
+
+// WriteError responds with the status of the error kind. Internal errors are logged and their details hidden.
+func (h *handler) WriteError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	status := statusForError(err)
+	if status >= http.StatusInternalServerError {
+		h.log.Error(msg, "error", err)
+		h.Problem(w, r, status, msg)
 		return
 	}
-	h.log.Warn(msg, "err", errs[0])
-	_ = json.NewEncoder(w).Encode(&badRequestParams{
-		Message: msg,
-		Error:   errs[0].Error(),
-	})
+	h.log.Warn(msg, "error", err)
+	h.Problem(w, r, status, msg, err)
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		h.log.Error("failed to marshal response", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err = w.Write(bts); err != nil {
+		h.log.Error("failed to write response", "error", err)
+	}
 }
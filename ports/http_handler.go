@@ -8,9 +8,18 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/builder-hub/application"
 	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/metrics"
+	"github.com/flashbots/builder-hub/signing"
+	"github.com/flashbots/builder-hub/transparency"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httplog/v2"
 )
@@ -19,17 +28,39 @@ type BuilderHubService interface {
 	GetAllowedMeasurements(ctx context.Context) ([]domain.Measurement, error)
 	GetActiveBuilders(ctx context.Context, network string) ([]domain.BuilderWithServices, error)
 	VerifyIPAndMeasurements(ctx context.Context, ip net.IP, measurement map[string]string, attestationType string) (*domain.Builder, string, error)
-	GetConfigWithSecrets(ctx context.Context, builderName string) ([]byte, error)
+	GetConfigWithSecrets(ctx context.Context, builder domain.Builder) ([]byte, []int64, error)
+	LogRolloutFetches(ctx context.Context, builderName string, rollouts []int64) error
 	RegisterCredentialsForBuilder(ctx context.Context, builderName, service, tlsCert string, ecdsaPubKey []byte, measurementName, attestationType, region string) error
 	LogEvent(ctx context.Context, eventName, builderName, name string) error
 }
 type BuilderHubHandler struct {
 	builderHubService BuilderHubService
+	publisher         *signing.Publisher
+	tlog              *transparency.Log
+	// requireConfigEncryption rejects config requests without an attested encryption key
+	requireConfigEncryption bool
+	verifyQuote             quoteVerifier
 	handler
 }
 
 func NewBuilderHubHandler(builderHubService BuilderHubService, log *httplog.Logger) *BuilderHubHandler {
-	return &BuilderHubHandler{builderHubService: builderHubService, handler: handler{log: log}}
+	return &BuilderHubHandler{
+		builderHubService: builderHubService,
+		verifyQuote:       tdxQuoteVerifier(false, time.Now),
+		handler:           handler{log: log},
+	}
+}
+
+// WithSignedLists enables the signed measurement and peer list endpoints
+func (bhs *BuilderHubHandler) WithSignedLists(publisher *signing.Publisher) *BuilderHubHandler {
+	bhs.publisher = publisher
+	return bhs
+}
+
+// WithTransparencyLog enables the measurement transparency log endpoints
+func (bhs *BuilderHubHandler) WithTransparencyLog(tlog *transparency.Log) *BuilderHubHandler {
+	bhs.tlog = tlog
+	return bhs
 }
 
 type AuthData struct {
@@ -74,14 +105,12 @@ func (bhs *BuilderHubHandler) getAuthData(r *http.Request) (*AuthData, error) {
 func (bhs *BuilderHubHandler) GetAllowedMeasurements(w http.ResponseWriter, r *http.Request) {
 	_, err := io.ReadAll(r.Body)
 	if err != nil {
-		bhs.log.Error("failed to read request body", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to read request body", err)
 		return
 	}
 	measurements, err := bhs.builderHubService.GetAllowedMeasurements(r.Context())
 	if err != nil {
-		bhs.log.Error("failed to fetch allowed measurements from db", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to fetch allowed measurements from db", err)
 		return
 	}
 	pMeasurements := make([]Measurement, 0, len(measurements))
@@ -91,8 +120,7 @@ func (bhs *BuilderHubHandler) GetAllowedMeasurements(w http.ResponseWriter, r *h
 
 	btsM, err := json.Marshal(pMeasurements)
 	if err != nil {
-		bhs.log.Error("failed to marshal measurements", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to marshal measurements", err)
 		return
 	}
 	_, err = w.Write(btsM)
@@ -105,25 +133,23 @@ func (bhs *BuilderHubHandler) GetActiveBuilders(w http.ResponseWriter, r *http.R
 	authData, err := bhs.getAuthData(r)
 	if err != nil {
 		bhs.log.Warn("malformed auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "malformed auth data")
 		return
 	}
 	builder, _, err := bhs.builderHubService.VerifyIPAndMeasurements(r.Context(), authData.IP, authData.MeasurementData, authData.AttestationType)
 	if errors.Is(err, domain.ErrNotFound) {
 		bhs.log.Warn("invalid auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "invalid auth data")
 		return
 	}
 	if err != nil {
-		bhs.log.Error("failed to verify ip and measurements", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to verify ip and measurements", err)
 		return
 	}
 
 	builders, err := bhs.builderHubService.GetActiveBuilders(r.Context(), builder.Network)
 	if err != nil {
-		bhs.log.Error("failed to fetch active builders from db", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to fetch active builders from db", err)
 		return
 	}
 	pBuilders := make([]BuilderWithServiceCreds, 0, len(builders))
@@ -132,8 +158,7 @@ func (bhs *BuilderHubHandler) GetActiveBuilders(w http.ResponseWriter, r *http.R
 	}
 	bts, err := json.Marshal(pBuilders)
 	if err != nil {
-		bhs.log.Error("failed to marshal builders", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to marshal builders", err)
 		return
 	}
 	_, err = w.Write(bts)
@@ -145,8 +170,7 @@ func (bhs *BuilderHubHandler) GetActiveBuilders(w http.ResponseWriter, r *http.R
 func (bhs *BuilderHubHandler) GetActiveBuildersNoAuth(w http.ResponseWriter, r *http.Request) {
 	builders, err := bhs.builderHubService.GetActiveBuilders(r.Context(), domain.ProductionNetwork)
 	if err != nil {
-		bhs.log.Error("failed to fetch active builders from db", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to fetch active builders from db", err)
 		return
 	}
 	pBuilders := make([]BuilderWithServiceCreds, 0, len(builders))
@@ -155,8 +179,7 @@ func (bhs *BuilderHubHandler) GetActiveBuildersNoAuth(w http.ResponseWriter, r *
 	}
 	bts, err := json.Marshal(pBuilders)
 	if err != nil {
-		bhs.log.Error("failed to marshal builders", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to marshal builders", err)
 		return
 	}
 	_, err = w.Write(bts)
@@ -174,8 +197,7 @@ func (bhs *BuilderHubHandler) GetActiveBuildersNoAuthNetworked(w http.ResponseWr
 
 	builders, err := bhs.builderHubService.GetActiveBuilders(r.Context(), network)
 	if err != nil {
-		bhs.log.Error("failed to fetch active builders from db", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to fetch active builders from db", err)
 		return
 	}
 	pBuilders := make([]BuilderWithServiceCreds, 0, len(builders))
@@ -184,8 +206,7 @@ func (bhs *BuilderHubHandler) GetActiveBuildersNoAuthNetworked(w http.ResponseWr
 	}
 	bts, err := json.Marshal(pBuilders)
 	if err != nil {
-		bhs.log.Error("failed to marshal builders", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to marshal builders", err)
 		return
 	}
 	_, err = w.Write(bts)
@@ -198,26 +219,53 @@ func (bhs *BuilderHubHandler) GetConfigSecrets(w http.ResponseWriter, r *http.Re
 	authData, err := bhs.getAuthData(r)
 	if err != nil {
 		bhs.log.Warn("malformed auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "malformed auth data")
 		return
 	}
 	builder, measurementName, err := bhs.builderHubService.VerifyIPAndMeasurements(r.Context(), authData.IP, authData.MeasurementData, authData.AttestationType)
 	if errors.Is(err, domain.ErrNotFound) {
 		bhs.log.Warn("invalid auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "invalid auth data")
+		return
+	}
+	if err != nil {
+		bhs.WriteError(w, r, "failed to verify ip and measurements", err)
 		return
 	}
-	bts, err := bhs.builderHubService.GetConfigWithSecrets(r.Context(), builder.Name)
+	encryptionKey, err := bhs.attestedEncryptionKey(r, authData)
 	if err != nil {
-		bhs.log.Error("failed to get config with secrets", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.log.Warn("rejected config encryption key", "builder", builder.Name, "error", err)
+		bhs.Problem(w, r, http.StatusForbidden, "invalid config encryption key", err)
 		return
 	}
+	bts, rollouts, err := bhs.builderHubService.GetConfigWithSecrets(r.Context(), *builder)
+	if errors.Is(err, application.ErrMissingSecret) {
+		// a builder booting without its secrets would fail in less obvious ways
+		bhs.log.Warn("builder has no secrets", "builder", builder.Name)
+		metrics.RecordMissingSecret()
+		bhs.Problem(w, r, http.StatusNotFound, "no secrets for builder")
+		return
+	}
+	if err != nil {
+		bhs.WriteError(w, r, "failed to get config with secrets", err)
+		return
+	}
+	if encryptionKey != nil {
+		if bts, err = encryptConfig(encryptionKey, bts); err != nil {
+			bhs.WriteError(w, r, "failed to encrypt config", err)
+			return
+		}
+	}
 	// add event log
 	err = bhs.builderHubService.LogEvent(r.Context(), domain.EventGetConfig, builder.Name, measurementName)
 	if err != nil {
-		bhs.log.Error("failed to get log event", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to get log event", err)
+		return
+	}
+	// rollouts count a fetch only if the builder was served their new layer
+	err = bhs.builderHubService.LogRolloutFetches(r.Context(), builder.Name, rollouts)
+	if err != nil {
+		bhs.WriteError(w, r, "failed to log rollout fetches", err)
 		return
 	}
 
@@ -231,18 +279,17 @@ func (bhs *BuilderHubHandler) RegisterCredentials(w http.ResponseWriter, r *http
 	authData, err := bhs.getAuthData(r)
 	if err != nil {
 		bhs.log.Warn("malformed auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "malformed auth data")
 		return
 	}
 	builder, measurementName, err := bhs.builderHubService.VerifyIPAndMeasurements(r.Context(), authData.IP, authData.MeasurementData, authData.AttestationType)
 	if errors.Is(err, domain.ErrNotFound) {
 		bhs.log.Warn("invalid auth data", "error", err)
-		w.WriteHeader(http.StatusForbidden)
+		bhs.Problem(w, r, http.StatusForbidden, "invalid auth data")
 		return
 	}
 	if err != nil {
-		bhs.log.Error("failed to verify ip and measurements", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "failed to verify ip and measurements", err)
 		return
 	}
 
@@ -256,8 +303,7 @@ func (bhs *BuilderHubHandler) RegisterCredentials(w http.ResponseWriter, r *http
 	// read request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		bhs.log.Error("Failed to read request body", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "Failed to read request body", err)
 		return
 	}
 	sc := ServiceCred{}
@@ -281,10 +327,96 @@ func (bhs *BuilderHubHandler) RegisterCredentials(w http.ResponseWriter, r *http
 
 	err = bhs.builderHubService.RegisterCredentialsForBuilder(r.Context(), builder.Name, service, tlsCert, ecdsaPubkey, measurementName, authData.AttestationType, sc.Region)
 	if err != nil {
-		bhs.log.Error("Failed to register credentials", "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		bhs.WriteError(w, r, "Failed to register credentials", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// SigningKey is the public key clients pin to verify signed lists
+type SigningKey struct {
+	Algorithm string          `json:"algorithm"`
+	PublicKey hexutil.Bytes   `json:"public_key"`
+	Address   *common.Address `json:"address,omitempty"`
+}
+
+func (bhs *BuilderHubHandler) GetSigningKey(w http.ResponseWriter, r *http.Request) {
+	if bhs.publisher == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "signed lists are not enabled")
+		return
+	}
+	signer := bhs.publisher.Signer()
+	key := SigningKey{Algorithm: signer.Algorithm(), PublicKey: signer.PublicKey()}
+	if signer.Algorithm() == signing.AlgorithmSecp256k1 {
+		pub, err := crypto.UnmarshalPubkey(signer.PublicKey())
+		if err != nil {
+			bhs.WriteError(w, r, "failed to decode signing key", err)
+			return
+		}
+		addr := crypto.PubkeyToAddress(*pub)
+		key.Address = &addr
+	}
+	bhs.writeJSON(w, http.StatusOK, key)
+}
+
+func (bhs *BuilderHubHandler) GetSignedMeasurements(w http.ResponseWriter, r *http.Request) {
+	if bhs.publisher == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "signed lists are not enabled")
+		return
+	}
+	measurements, err := bhs.builderHubService.GetAllowedMeasurements(r.Context())
+	if err != nil {
+		bhs.WriteError(w, r, "failed to fetch allowed measurements from db", err)
+		return
+	}
+	pMeasurements := make([]Measurement, 0, len(measurements))
+	for _, m := range measurements {
+		pMeasurements = append(pMeasurements, fromDomainMeasurement(m))
+	}
+	sort.Slice(pMeasurements, func(i, j int) bool { return pMeasurements[i].Name < pMeasurements[j].Name })
+
+	signed, err := bhs.publisher.Publish(r.Context(), "measurements", pMeasurements)
+	if err != nil {
+		bhs.WriteError(w, r, "failed to sign measurements", err)
+		return
+	}
+	bhs.writeJSON(w, http.StatusOK, signed)
+}
+
+func (bhs *BuilderHubHandler) GetSignedActiveBuildersNoAuthNetworked(w http.ResponseWriter, r *http.Request) {
+	if bhs.publisher == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "signed lists are not enabled")
+		return
+	}
+	network := chi.URLParam(r, "network")
+	if network == "" {
+		bhs.BadRequest(w, r, "network is empty")
+		return
+	}
+
+	builders, err := bhs.builderHubService.GetActiveBuilders(r.Context(), network)
+	if err != nil {
+		bhs.WriteError(w, r, "failed to fetch active builders from db", err)
+		return
+	}
+	pBuilders := make([]BuilderWithServiceCreds, 0, len(builders))
+	for _, b := range builders {
+		pBuilders = append(pBuilders, fromDomainBuilderWithServices(b))
+	}
+	sort.Slice(pBuilders, func(i, j int) bool { return pBuilders[i].Name < pBuilders[j].Name })
+
+	// only networks with builders are signed, so that arbitrary network names can't grow the publisher's lists.
+	// Networks published before keep being signed once empty, clients have to learn that the builders are gone.
+	list := "builders/" + network
+	if len(pBuilders) == 0 && !bhs.publisher.Published(list) {
+		bhs.Problem(w, r, http.StatusNotFound, "unknown network")
+		return
+	}
+	signed, err := bhs.publisher.Publish(r.Context(), list, pBuilders)
+	if err != nil {
+		bhs.WriteError(w, r, "failed to sign builders", err)
+		return
+	}
+	bhs.writeJSON(w, http.StatusOK, signed)
+}
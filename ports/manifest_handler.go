@@ -0,0 +1,109 @@
+package ports
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/manifest"
+)
+
+// ExportManifest returns the hub state as a manifest, in YAML with ?format=yaml.
+// Secret names are included with ?secret_refs=true, secret values never are.
+func (s *AdminHandler) ExportManifest(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = manifest.FormatJSON
+	}
+	if format != manifest.FormatJSON && format != manifest.FormatYAML {
+		s.BadRequest(w, r, "format must be json or yaml")
+		return
+	}
+	var secrets application.SecretAccessor
+	if r.URL.Query().Get("secret_refs") == "true" {
+		secrets = s.secretService
+	}
+
+	m, err := manifest.Export(r.Context(), s.builderService, secrets)
+	if err != nil {
+		s.WriteError(w, r, "failed to export manifest", err)
+		return
+	}
+	bts, err := manifest.Encode(m, format)
+	if err != nil {
+		s.WriteError(w, r, "failed to encode manifest", err)
+		return
+	}
+	if format == manifest.FormatYAML {
+		w.Header().Set("Content-Type", "application/yaml")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if _, err = w.Write(bts); err != nil {
+		s.log.Error("failed to write response", "error", err)
+	}
+}
+
+// ImportManifest applies a JSON or YAML manifest and responds with the plan. With ?dry_run=true only the plan is computed.
+func (s *AdminHandler) ImportManifest(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.WriteError(w, r, "failed to read request body", err)
+		return
+	}
+	m, err := manifest.Decode(body)
+	if err != nil {
+		s.WriteError(w, r, "invalid manifest", err)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun && s.managedByManifests(w, r, m) {
+		return
+	}
+
+	// an import can activate measurements, so it goes through approval like a single activation
+	if s.approvals != nil && !dryRun {
+		if _, err = manifest.Import(r.Context(), s.builderService, s.secretService, m, true); err != nil {
+			s.WriteError(w, r, "failed to plan manifest import", err)
+			return
+		}
+		s.propose(w, r, ProposalManifestImport, "manifest", func(ctx context.Context) error {
+			_, err := manifest.Import(ctx, s.builderService, s.secretService, m, false)
+			return err
+		})
+		return
+	}
+
+	plan, err := manifest.Import(r.Context(), s.builderService, s.secretService, m, dryRun)
+	if err != nil {
+		s.WriteError(w, r, "failed to import manifest", err)
+		return
+	}
+	s.log.Info("manifest imported", "dry_run", dryRun, "changes", len(plan.Changes), "admin", adminSubject(r))
+	s.writeJSON(w, http.StatusOK, plan)
+}
+
+// managedByManifests responds with 403 if the manifest declares objects managed by the GitOps manifests
+func (s *AdminHandler) managedByManifests(w http.ResponseWriter, r *http.Request, m *manifest.Manifest) bool {
+	for _, measurement := range m.Measurements {
+		if s.managedMeasurement(w, r, measurement.Name) {
+			return true
+		}
+	}
+	for _, b := range m.Builders {
+		if s.managedBuilder(w, r, b.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReconciliationStatus returns the outcome of the last GitOps reconciliation
+func (s *AdminHandler) GetReconciliationStatus(w http.ResponseWriter, r *http.Request) {
+	if s.reconciler == nil {
+		s.Problem(w, r, http.StatusNotFound, "GitOps reconciliation is not enabled")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.reconciler.Status())
+}
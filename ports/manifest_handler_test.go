@@ -0,0 +1,117 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/common"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/manifest"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestImportExport(t *testing.T) {
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	store := domain.NewInmemoryBuilderService()
+	h := NewAdminHandler(store, domain.NewMockSecretService(), log)
+
+	mux := chi.NewRouter()
+	mux.Get("/manifest", h.ExportManifest)
+	mux.Post("/manifest", h.ImportManifest)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, asAdmin(httptest.NewRequest(method, path, strings.NewReader(body)), "alice"))
+		return rr
+	}
+
+	manifest := `
+version: 1
+measurements:
+  - {measurement_id: m-1, attestation_type: azure-tdx, is_active: true, measurements: {"4": {expected: "0x01"}}}
+builders:
+  - {name: b-1, ip_address: 10.0.0.1, network: production, is_active: true, config: {a: 1}}
+`
+	rr := do(http.MethodPost, "/manifest?dry_run=true", manifest)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"action":"add_measurement"`)
+	measurements, err := store.GetAllMeasurements(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, measurements)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/manifest", manifest).Code)
+	rr = do(http.MethodGet, "/manifest?format=yaml", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/yaml", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Body.String(), "measurement_id: m-1")
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/manifest", "version: 2").Code)
+	conflicting := strings.Replace(manifest, "10.0.0.1", "10.0.0.2", 1)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/manifest", conflicting).Code)
+
+	// with approvals the import is proposed instead of applied
+	h.WithApprovals(NewApprovalStore(time.Hour))
+	rr = do(http.MethodPost, "/manifest", strings.Replace(manifest, "is_active: true, measurements", "is_active: false, measurements", 1))
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.Contains(t, rr.Body.String(), string(ProposalManifestImport))
+	measurements, err = store.GetAllMeasurements(context.Background())
+	require.NoError(t, err)
+	require.True(t, measurements[0].IsActive)
+}
+
+func TestManagedObjectsAreReadOnly(t *testing.T) {
+	log := common.SetupLogger(&common.LoggingOpts{Service: "test"})
+	store := domain.NewInmemoryBuilderService()
+	h := NewAdminHandler(store, domain.NewMockSecretService(), log)
+
+	mux := chi.NewRouter()
+	mux.Post("/measurements", h.AddMeasurement)
+	mux.Post("/measurements/activation/{measurementName}", h.ChangeActiveStatusForMeasurement)
+	mux.Post("/builders", h.AddBuilder)
+	mux.Post("/builders/activation/{builderName}", h.ChangeActiveStatusForBuilder)
+	mux.Post("/builders/configuration/{builderName}", h.AddBuilderConfig)
+	mux.Post("/manifest", h.ImportManifest)
+	mux.Get("/reconciliation", h.GetReconciliationStatus)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/reconciliation", "").Code)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hub.yaml"), []byte(`
+version: 1
+measurements:
+  - {measurement_id: m-1, attestation_type: azure-tdx, is_active: false}
+builders:
+  - {name: b-1, ip_address: 10.0.0.1, network: production}
+`), 0o600))
+	reconciler := manifest.NewReconciler(store, dir, log.Logger)
+	reconciler.Reconcile(context.Background())
+	h.WithReconciler(reconciler)
+
+	for _, tc := range []struct{ path, body string }{
+		{"/measurements", `{"measurement_id": "m-1", "attestation_type": "azure-tdx"}`},
+		{"/measurements/activation/m-1", `{"enabled": true}`},
+		{"/builders", `{"name": "b-1", "ip_address": "10.0.0.1", "network": "production"}`},
+		{"/builders/activation/b-1", `{"enabled": true}`},
+		{"/builders/configuration/b-1", `{"a": 1}`},
+		{"/manifest", `{"version": 1, "builders": [{"name": "b-1", "ip_address": "10.0.0.1", "network": "production"}]}`},
+	} {
+		rr := do(http.MethodPost, tc.path, tc.body)
+		require.Equal(t, http.StatusForbidden, rr.Code, tc.path)
+		require.Contains(t, rr.Body.String(), "managed by GitOps manifests", tc.path)
+	}
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders", `{"name": "b-2", "ip_address": "10.0.0.2", "network": "production"}`).Code)
+
+	rr := do(http.MethodGet, "/reconciliation", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"managed_builders":["b-1"]`)
+}
@@ -0,0 +1,185 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+type rolloutTarget struct {
+	Builder   string     `json:"builder"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+}
+
+// rolloutStatus is a rollout with the active builders it targets and when they first fetched their config since
+// it started
+type rolloutStatus struct {
+	domain.Rollout
+	Targets []rolloutTarget `json:"targets"`
+	Fetched int             `json:"fetched"`
+}
+
+func (s *AdminHandler) ListRollouts(w http.ResponseWriter, r *http.Request) {
+	rollouts, err := s.builderService.GetRollouts(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list rollouts", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, rollouts)
+}
+
+// CreateRollout starts serving a new version of a shared config layer to named builders or a percentage of the
+// builders it applies to. The new version is validated for all of them, as promoting applies it to everyone.
+func (s *AdminHandler) CreateRollout(w http.ResponseWriter, r *http.Request) {
+	var rollout domain.Rollout
+	if err := json.NewDecoder(r.Body).Decode(&rollout); err != nil {
+		s.BadRequest(w, r, "failed to decode request body", err)
+		return
+	}
+	if err := domain.ValidRollout(rollout); err != nil {
+		s.BadRequest(w, r, "invalid rollout", err)
+		return
+	}
+	for _, name := range rollout.Builders {
+		if _, err := s.findBuilder(r.Context(), name); err != nil {
+			s.WriteError(w, r, "invalid rollout", err)
+			return
+		}
+	}
+	if err := s.validateLayerChange(r.Context(), rollout.Layer, false); err != nil {
+		s.WriteError(w, r, "config layer would make a builder config invalid", err)
+		return
+	}
+	created, err := s.builderService.CreateRollout(r.Context(), rollout)
+	if err != nil {
+		s.WriteError(w, r, "failed to create rollout", err)
+		return
+	}
+	s.log.Info("rollout created", "id", created.ID, "layer", created.Layer.ID(), "builders", created.Builders, "percent", created.Percent, "admin", adminSubject(r))
+	s.writeJSON(w, http.StatusOK, created)
+}
+
+// GetRollout returns the rollout with its targets and their fetches from the event log
+func (s *AdminHandler) GetRollout(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.rolloutID(w, r)
+	if !ok {
+		return
+	}
+	status, err := s.rolloutStatus(r.Context(), id)
+	if err != nil {
+		s.WriteError(w, r, "failed to fetch rollout", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, status)
+}
+
+// PromoteRollout applies the new layer version to all builders
+func (s *AdminHandler) PromoteRollout(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.rolloutID(w, r)
+	if !ok {
+		return
+	}
+	rollout, err := s.builderService.GetRollout(r.Context(), id)
+	if err != nil {
+		s.WriteError(w, r, "failed to fetch rollout", err)
+		return
+	}
+	// builders, configs and schemas may have changed since the rollout started
+	if err = s.validateLayerChange(r.Context(), rollout.Layer, false); err != nil {
+		s.WriteError(w, r, "config layer would make a builder config invalid", err)
+		return
+	}
+	if err = s.builderService.PromoteRollout(r.Context(), id); err != nil {
+		s.WriteError(w, r, "failed to promote rollout", err)
+		return
+	}
+	s.log.Info("rollout promoted", "id", id, "layer", rollout.Layer.ID(), "admin", adminSubject(r))
+}
+
+// AbortRollout serves the current layer to the targeted builders again
+func (s *AdminHandler) AbortRollout(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.rolloutID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.builderService.AbortRollout(r.Context(), id); err != nil {
+		s.WriteError(w, r, "failed to abort rollout", err)
+		return
+	}
+	s.log.Info("rollout aborted", "id", id, "admin", adminSubject(r))
+}
+
+func (s *AdminHandler) rolloutID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		s.BadRequest(w, r, "invalid rollout id", err)
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *AdminHandler) rolloutStatus(ctx context.Context, id int64) (*rolloutStatus, error) {
+	rollout, err := s.builderService.GetRollout(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	fetches, err := s.builderService.GetRolloutFetches(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config fetches: %w", err)
+	}
+	builders, err := s.builderService.GetAllBuilders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builders: %w", err)
+	}
+	status := &rolloutStatus{Rollout: *rollout, Targets: []rolloutTarget{}}
+	for _, b := range builders {
+		if !b.IsActive || !rollout.Targets(b.Name) {
+			continue
+		}
+		applies, err := s.layerAppliesTo(ctx, rollout.Layer, b)
+		if err != nil {
+			return nil, err
+		}
+		if !applies {
+			continue
+		}
+		target := rolloutTarget{Builder: b.Name}
+		if fetchedAt, ok := fetches[b.Name]; ok {
+			target.FetchedAt = &fetchedAt
+			status.Fetched++
+		}
+		status.Targets = append(status.Targets, target)
+	}
+	return status, nil
+}
+
+func (s *AdminHandler) layerAppliesTo(ctx context.Context, layer domain.ConfigLayer, builder domain.Builder) (bool, error) {
+	if layer.Kind == domain.ConfigLayerNetwork {
+		return builder.Network == layer.Name, nil
+	}
+	groups, err := s.builderService.GetConfigGroupsForBuilder(ctx, builder.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch config groups of builder %s: %w", builder.Name, err)
+	}
+	return contains(groups, layer.Name), nil
+}
+
+// activeRollout returns an error if the layer has an active rollout, it has to be promoted or aborted first
+func (s *AdminHandler) activeRollout(ctx context.Context, layer domain.ConfigLayer) error {
+	rollouts, err := s.builderService.GetRollouts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list rollouts: %w", err)
+	}
+	for _, r := range rollouts {
+		if r.Status == domain.RolloutActive && r.Layer.ID() == layer.ID() {
+			return fmt.Errorf("%w: %s has an active rollout (%d), promote or abort it first", domain.ErrConflict, layer.ID(), r.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollouts(t *testing.T) {
+	ctx := context.Background()
+	_, store, secrets, do := newTestAdmin(t)
+	fetch := newTestInstance(t, store, secrets)
+	for i, name := range []string{"b-1", "b-2", "b-3"} {
+		require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: name, IPAddress: net.IPv4(10, 0, 0, byte(i+1)), Network: "testnet", IsActive: true}))
+		require.NoError(t, store.AddBuilderConfig(ctx, name, json.RawMessage(`{}`)))
+		require.NoError(t, secrets.SetSecretValues(ctx, name, json.RawMessage(`{}`)))
+	}
+	require.NoError(t, store.SetConfigLayer(ctx, domain.ConfigLayer{Kind: domain.ConfigLayerNetwork, Name: "testnet", Config: json.RawMessage(`{"relays": ["a"]}`)}))
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-schemas/global", `{"required": ["relays"]}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/rollouts", `{"layer": {"kind": "network", "name": "testnet", "config": {}}, "percent": 50}`).Code, "the new layer is validated")
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/rollouts", `{"layer": {"kind": "network", "name": "testnet", "config": {"relays": []}}, "percent": 150}`).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/rollouts", `{"layer": {"kind": "network", "name": "testnet", "config": {"relays": []}}, "builders": ["unknown"]}`).Code)
+
+	rr := do(http.MethodPost, "/rollouts", `{"layer": {"kind": "network", "name": "testnet", "config": {"relays": ["b"]}}, "builders": ["b-1", "b-2"]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var rollout domain.Rollout
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rollout))
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/config-layers/network/testnet", `{"relays": ["c"]}`).Code, "the layer is locked during the rollout")
+
+	rr = fetch("10.0.0.1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"relays": ["b"]}`, rr.Body.String())
+	rr = fetch("10.0.0.3")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"relays": ["a"]}`, rr.Body.String(), "b-3 is not targeted")
+	require.NoError(t, store.LogEvent(ctx, domain.EventGetConfig, "b-2", ""), "only fetches served the new layer count")
+	rr = do(http.MethodGet, "/rollouts/1", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var status rolloutStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	require.Len(t, status.Targets, 2)
+	require.Equal(t, 1, status.Fetched)
+	require.NotNil(t, status.Targets[0].FetchedAt)
+	require.Nil(t, status.Targets[1].FetchedAt)
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/rollouts/x", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/rollouts/9/promote", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/rollouts/1/promote", "").Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/rollouts/1/abort", "").Code)
+	config, err := store.GetActiveConfigForBuilder(ctx, "b-3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relays": ["b"]}`, string(config))
+
+	rr = do(http.MethodGet, "/rollouts", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"status":"promoted"`)
+}
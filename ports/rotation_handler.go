@@ -0,0 +1,165 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+type rotationPolicyRequest struct {
+	Generator string          `json:"generator"`
+	Length    int             `json:"length,omitempty"`
+	Interval  domain.Duration `json:"interval"`
+	Overlap   domain.Duration `json:"overlap,omitempty"`
+}
+
+// WithRotator enables manual rotations. The rotator and the single secret endpoints share a lock on the secrets.
+func (s *AdminHandler) WithRotator(rotator *application.Rotator) *AdminHandler {
+	s.rotator = rotator
+	s.secretsMu = rotator.SecretsMutex()
+	return s
+}
+
+// ListRotationPolicies returns the rotation policies of all builder secrets with their rotation state
+func (s *AdminHandler) ListRotationPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := s.builderService.GetRotationPolicies(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list rotation policies", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, policies)
+}
+
+// SetRotationPolicy creates or updates the rotation policy of a builder secret. A new policy is due immediately,
+// so the secret is generated at the next rotator run if it doesn't exist yet.
+func (s *AdminHandler) SetRotationPolicy(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	key, ok := s.secretKeyParam(w, r)
+	if !ok {
+		return
+	}
+	var req rotationPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.BadRequest(w, r, "failed to decode request body", err)
+		return
+	}
+	policy := domain.RotationPolicy{
+		BuilderName: builderName,
+		Key:         key,
+		Generator:   req.Generator,
+		Length:      req.Length,
+		Interval:    req.Interval,
+		Overlap:     req.Overlap,
+	}
+	if err := domain.ValidRotationPolicy(policy); err != nil {
+		s.BadRequest(w, r, "invalid rotation policy", err)
+		return
+	}
+	// the policy decides the values the secret gets, so it is approved like the secret itself
+	if s.approvals != nil {
+		s.propose(w, r, ProposalRotationPolicy, builderName+" "+key, func(ctx context.Context) error {
+			return s.builderService.SetRotationPolicy(ctx, policy)
+		})
+		return
+	}
+	if err := s.builderService.SetRotationPolicy(r.Context(), policy); err != nil {
+		s.WriteError(w, r, "failed to set rotation policy", err)
+		return
+	}
+	s.log.Info("rotation policy set", "builder", builderName, "key", key, "generator", policy.Generator,
+		"interval", time.Duration(policy.Interval), "overlap", time.Duration(policy.Overlap), "admin", adminSubject(r))
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteRotationPolicy stops rotating a secret, the secret itself is kept
+func (s *AdminHandler) DeleteRotationPolicy(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	key, ok := s.secretKeyParam(w, r)
+	if !ok {
+		return
+	}
+	if s.approvals != nil {
+		s.propose(w, r, ProposalRotationPolicy, builderName+" "+key, func(ctx context.Context) error {
+			return s.builderService.DeleteRotationPolicy(ctx, builderName, key)
+		})
+		return
+	}
+	if err := s.builderService.DeleteRotationPolicy(r.Context(), builderName, key); err != nil {
+		s.WriteError(w, r, "failed to delete rotation policy", err)
+		return
+	}
+	s.log.Info("rotation policy deleted", "builder", builderName, "key", key, "admin", adminSubject(r))
+	w.WriteHeader(http.StatusOK)
+}
+
+// RotateNow rotates a secret with a policy immediately, e.g. after a leak
+func (s *AdminHandler) RotateNow(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	key, ok := s.secretKeyParam(w, r)
+	if !ok {
+		return
+	}
+	if s.rotator == nil {
+		s.Problem(w, r, http.StatusConflict, "secret rotation is disabled")
+		return
+	}
+	policy, err := s.rotationPolicy(r.Context(), builderName, key)
+	if err != nil {
+		s.WriteError(w, r, "failed to rotate secret", err)
+		return
+	}
+	if s.approvals != nil {
+		s.propose(w, r, ProposalRotateSecret, builderName+" "+key, func(ctx context.Context) error {
+			// the policy may have changed since the proposal
+			policy, err := s.rotationPolicy(ctx, builderName, key)
+			if err != nil {
+				return err
+			}
+			_, err = s.rotator.Rotate(ctx, policy, domain.RotationManual, time.Now())
+			return err
+		})
+		return
+	}
+	rotation, err := s.rotator.Rotate(r.Context(), policy, domain.RotationManual, time.Now())
+	if err != nil {
+		s.WriteError(w, r, "failed to rotate secret", err)
+		return
+	}
+	s.log.Info("secret rotated manually", "builder", builderName, "key", key, "admin", adminSubject(r))
+	s.writeJSON(w, http.StatusOK, rotation)
+}
+
+// rotationPolicy returns the rotation policy of a builder secret
+func (s *AdminHandler) rotationPolicy(ctx context.Context, builderName, key string) (domain.RotationPolicy, error) {
+	policies, err := s.builderService.GetRotationPolicies(ctx)
+	if err != nil {
+		return domain.RotationPolicy{}, err
+	}
+	for _, p := range policies {
+		if p.BuilderName == builderName && p.Key == key {
+			return p, nil
+		}
+	}
+	return domain.RotationPolicy{}, fmt.Errorf("rotation policy of %s %s: %w", builderName, key, domain.ErrNotFound)
+}
+
+// ListSecretRotations returns the rotation history of a builder's secrets, without the values
+func (s *AdminHandler) ListSecretRotations(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to list secret rotations", err)
+		return
+	}
+	rotations, err := s.builderService.GetSecretRotations(r.Context(), builderName)
+	if err != nil {
+		s.WriteError(w, r, "failed to list secret rotations", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, rotations)
+}
@@ -0,0 +1,147 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotationPolicies(t *testing.T) {
+	ctx := context.Background()
+	h, store, secrets, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "old"}}`)))
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy", `{"generator": "password", "interval": "720h", "overlap": "24h"}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy", `{"generator": "uuid", "interval": "720h"}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy", `{"generator": "hex", "interval": "1s"}`).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/unknown/keys/relay.key/rotation-policy", `{"generator": "hex", "interval": "720h"}`).Code)
+
+	rr := do(http.MethodGet, "/rotation-policies", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `[{"builder_name": "b-1", "key": "relay.key", "generator": "password", "interval": "720h0m0s", "overlap": "24h0m0s"}]`, rr.Body.String())
+
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy/rotate", "").Code, "rotation is disabled")
+	h.WithRotator(application.NewRotator(store, secrets, h.log.Logger))
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy/rotate", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.other/rotation-policy/rotate", "").Code)
+	values, err := secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	flat, err := application.FlattenJSONFromBytes(values)
+	require.NoError(t, err)
+	require.Len(t, flat["relay.key"], 32)
+	require.Equal(t, "old", flat["relay.key_previous"])
+
+	rr = do(http.MethodGet, "/builders/secrets/b-1/rotations", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var rotations []domain.SecretRotation
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rotations))
+	require.Len(t, rotations, 1)
+	require.Equal(t, domain.RotationManual, rotations[0].Trigger)
+	require.NotContains(t, rr.Body.String(), flat["relay.key"])
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/builders/secrets/b-1/keys/relay.key/rotation-policy", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/builders/secrets/b-1/keys/relay.key/rotation-policy", "").Code)
+}
+
+func TestRotationRequiresApproval(t *testing.T) {
+	ctx := context.Background()
+	h, store, secrets, do := newTestAdmin(t)
+	h.WithRotator(application.NewRotator(store, secrets, h.log.Logger))
+	h.WithApprovals(NewApprovalStore(time.Hour))
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "old"}}`)))
+	approve := func(rr *httptest.ResponseRecorder) {
+		t.Helper()
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		var p Proposal
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+		_, err := h.approvals.Approve(ctx, p.ID, "oidc:bob")
+		require.NoError(t, err)
+	}
+
+	rr := do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy", `{"generator": "password", "interval": "720h"}`)
+	policies, err := store.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Empty(t, policies, "the policy is set once approved")
+	approve(rr)
+	policies, err = store.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.other/rotation-policy/rotate", "").Code)
+	rr = do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy/rotate", "")
+	values, err := secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "old"}}`, string(values), "the secret is rotated once approved")
+	approve(rr)
+	values, err = secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	flat, err := application.FlattenJSONFromBytes(values)
+	require.NoError(t, err)
+	require.Len(t, flat["relay.key"], 32)
+
+	rr = do(http.MethodDelete, "/builders/secrets/b-1/keys/relay.key/rotation-policy", "")
+	policies, err = store.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 1, "the policy is deleted once approved")
+	approve(rr)
+	policies, err = store.GetRotationPolicies(ctx)
+	require.NoError(t, err)
+	require.Empty(t, policies)
+}
+
+// slowSecrets holds the read of a rotation until the test lets it continue
+type slowSecrets struct {
+	*domain.InmemorySecretService
+	reading chan struct{}
+	resume  chan struct{}
+}
+
+func (s slowSecrets) GetSecretValues(ctx context.Context, builderName string) (json.RawMessage, error) {
+	values, err := s.InmemorySecretService.GetSecretValues(ctx, builderName)
+	close(s.reading)
+	<-s.resume
+	return values, err
+}
+
+func TestRotationAndSetSecretsDontOverwriteEachOther(t *testing.T) {
+	ctx := context.Background()
+	h, store, secrets, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "old"}}`)))
+	require.NoError(t, store.SetRotationPolicy(ctx, domain.RotationPolicy{BuilderName: "b-1", Key: "relay.key", Generator: "hex", Interval: domain.Duration(time.Hour)}))
+	slow := slowSecrets{InmemorySecretService: secrets, reading: make(chan struct{}), resume: make(chan struct{})}
+	h.WithRotator(application.NewRotator(store, slow, h.log.Logger))
+
+	rotated := make(chan int)
+	go func() {
+		rotated <- do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotation-policy/rotate", "").Code
+	}()
+	<-slow.reading
+	set := make(chan int)
+	go func() {
+		set <- do(http.MethodPost, "/builders/secrets/b-1", `{"relay": {"key": "set"}, "other": "value"}`).Code
+	}()
+	select {
+	case <-set:
+		t.Fatal("secrets were set during the rotation")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(slow.resume)
+	require.Equal(t, http.StatusOK, <-rotated)
+	require.Equal(t, http.StatusOK, <-set)
+
+	// the secrets set after the rotation replace the rotated ones instead of being lost
+	values, err := secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "set"}, "other": "value"}`, string(values))
+}
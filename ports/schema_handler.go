@@ -0,0 +1,175 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListConfigSchemas returns the registered builder config schemas
+func (s *AdminHandler) ListConfigSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas, err := s.builderService.GetConfigSchemas(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list config schemas", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, schemas)
+}
+
+// SetConfigSchema registers the JSON Schema of the global scope, a network or a builder. Configs are validated
+// against it on upload and on builder activation, existing configs are not revalidated.
+func (s *AdminHandler) SetConfigSchema(w http.ResponseWriter, r *http.Request) {
+	scope, target := chi.URLParam(r, "scope"), chi.URLParam(r, "target")
+	if err := domain.ValidSchemaScope(scope, target); err != nil {
+		s.BadRequest(w, r, "invalid schema scope", err)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.WriteError(w, r, "failed to read request body", err)
+		return
+	}
+	if _, err = application.CompileConfigSchema(body); err != nil {
+		s.BadRequest(w, r, "invalid JSON Schema", err)
+		return
+	}
+	err = s.builderService.SetConfigSchema(r.Context(), domain.ConfigSchema{Scope: scope, Target: target, Schema: body})
+	if err != nil {
+		s.WriteError(w, r, "failed to set config schema", err)
+		return
+	}
+	s.log.Info("config schema set", "scope", scope, "target", target, "admin", adminSubject(r))
+}
+
+func (s *AdminHandler) DeleteConfigSchema(w http.ResponseWriter, r *http.Request) {
+	scope, target := chi.URLParam(r, "scope"), chi.URLParam(r, "target")
+	if err := s.builderService.DeleteConfigSchema(r.Context(), scope, target); err != nil {
+		s.WriteError(w, r, "failed to delete config schema", err)
+		return
+	}
+	s.log.Info("config schema deleted", "scope", scope, "target", target, "admin", adminSubject(r))
+}
+
+// validateConfig validates the effective config of a builder with config as its own layer
+func (s *AdminHandler) validateConfig(ctx context.Context, builderName string, config json.RawMessage) error {
+	state, err := s.configState(ctx, builderName)
+	if err != nil {
+		return err
+	}
+	state.own = config
+	return s.validateLayers(ctx, state, true)
+}
+
+// builderConfigState is what the effective config of a builder is made of
+type builderConfigState struct {
+	builder domain.Builder
+	groups  []string
+	shared  []domain.ConfigLayer
+	own     json.RawMessage
+	schemas []domain.ConfigSchema
+}
+
+func (st *builderConfigState) layers() []domain.ConfigLayer {
+	return domain.BuilderConfigLayers(st.builder, st.groups, st.shared, st.own)
+}
+
+func (s *AdminHandler) configState(ctx context.Context, builderName string) (*builderConfigState, error) {
+	builder, err := s.findBuilder(ctx, builderName)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.builderService.GetConfigLayers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config layers: %w", err)
+	}
+	schemas, err := s.builderService.GetConfigSchemas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config schemas: %w", err)
+	}
+	state := &builderConfigState{builder: *builder, shared: shared, schemas: schemas}
+	if err = s.loadBuilderLayers(ctx, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// loadBuilderLayers sets the groups and the own config of the state's builder
+func (s *AdminHandler) loadBuilderLayers(ctx context.Context, state *builderConfigState) error {
+	name := state.builder.Name
+	groups, err := s.builderService.GetConfigGroupsForBuilder(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch config groups of builder %s: %w", name, err)
+	}
+	layers, err := s.builderService.GetConfigLayersForBuilder(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to fetch config layers of builder %s: %w", name, err)
+	}
+	state.groups, state.own = groups, nil
+	if len(layers) > 0 && layers[len(layers)-1].Kind == domain.ConfigLayerBuilder {
+		state.own = layers[len(layers)-1].Config
+	}
+	return nil
+}
+
+// validateLayers validates the effective config against the schemas of the builder. With checkSecrets, it also checks
+// that the builder's secrets fit into it.
+func (s *AdminHandler) validateLayers(ctx context.Context, state *builderConfigState, checkSecrets bool) error {
+	effective, err := domain.MergeConfigLayers(state.layers())
+	if err != nil {
+		return err
+	}
+	if err = application.ValidateConfig(state.schemas, state.builder, effective.Config); err != nil {
+		return err
+	}
+	if !checkSecrets {
+		return nil
+	}
+	secrets, err := s.secretService.GetSecretValues(ctx, state.builder.Name)
+	if errors.Is(err, application.ErrMissingSecret) || (err == nil && len(secrets) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch secrets: %w", err)
+	}
+	return application.CheckSecretPaths(effective.Config, secrets)
+}
+
+// validateSecrets checks that the builder may use the shared secrets referenced and that the secrets fit into its
+// active config, if there is one
+func (s *AdminHandler) validateSecrets(ctx context.Context, builderName string, secrets json.RawMessage) error {
+	builder, err := s.findBuilder(ctx, builderName)
+	if err != nil {
+		return err
+	}
+	if err = application.CheckSecretRefs(ctx, secrets, *builder, s.secretService); err != nil {
+		return err
+	}
+	config, err := s.builderService.GetActiveConfigForBuilder(ctx, builderName)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch active config: %w", err)
+	}
+	return application.CheckSecretPaths(config, secrets)
+}
+
+func (s *AdminHandler) findBuilder(ctx context.Context, builderName string) (*domain.Builder, error) {
+	builders, err := s.builderService.GetAllBuilders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builders: %w", err)
+	}
+	for _, b := range builders {
+		if b.Name == builderName {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("builder %s: %w", builderName, domain.ErrNotFound)
+}
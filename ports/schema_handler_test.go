@@ -0,0 +1,56 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchemaValidation(t *testing.T) {
+	ctx := context.Background()
+	_, store, _, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/config-schemas/global", `{"type": 1}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/config-schemas/network", `{}`).Code, "network schemas need a target")
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/config-schemas/region/eu", `{}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-schemas/network/testnet", `{
+		"type": "object",
+		"properties": {"rbuilder": {"type": "object", "properties": {"always_seal": {"type": "boolean"}}, "additionalProperties": false}}
+	}`).Code)
+	rr := do(http.MethodGet, "/config-schemas", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"target":"testnet"`)
+
+	rr = do(http.MethodPost, "/builders/configuration/b-1", `{"rbuilder": {"alwys_seal": true}}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var p Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &p))
+	require.Len(t, p.Violations, 1)
+	require.Equal(t, "/rbuilder", p.Violations[0].Path)
+	require.Contains(t, p.Violations[0].Message, "alwys_seal")
+
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/configuration/unknown", `{}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/configuration/b-1", `{"rbuilder": {"always_seal": true}, "key": "placeholder"}`).Code)
+
+	// secrets may replace placeholders, but not change the config structure
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1", `{"key": "secret"}`).Code)
+	rr = do(http.MethodPost, "/builders/secrets/b-1", `{"rbuilder.always_seal": "secret"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), `"path":"/rbuilder/always_seal"`)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/builders/configuration/b-1", `{"key": {"nested": 1}}`).Code, "the config can't break existing secrets either")
+
+	// activation validates against the schemas registered since the upload
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/config-schemas/global", `{"required": ["relays"]}`).Code)
+	rr = do(http.MethodPost, "/builders/activation/b-1", `{"enabled": true}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "relays")
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/config-schemas/network/testnet", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/config-schemas/network/testnet", "").Code)
+}
@@ -0,0 +1,140 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+// ListSecretKeys returns the secret paths of a builder and their lengths, never the values
+func (s *AdminHandler) ListSecretKeys(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to list secret keys", err)
+		return
+	}
+	secrets, err := s.secretService.GetSecretValues(r.Context(), builderName)
+	if err != nil && !errors.Is(err, application.ErrMissingSecret) {
+		s.WriteError(w, r, "failed to get secrets", err)
+		return
+	}
+	keys, err := application.SecretKeys(secrets)
+	if err != nil {
+		s.WriteError(w, r, "failed to list secret keys", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, keys)
+}
+
+// SetSecretKey sets a single secret, the body is its value as a JSON string
+func (s *AdminHandler) SetSecretKey(w http.ResponseWriter, r *http.Request) {
+	s.changeSecretKey(w, r, false)
+}
+
+// RotateSecretKey replaces the value of an existing secret, the body is the new value as a JSON string
+func (s *AdminHandler) RotateSecretKey(w http.ResponseWriter, r *http.Request) {
+	s.changeSecretKey(w, r, true)
+}
+
+func (s *AdminHandler) changeSecretKey(w http.ResponseWriter, r *http.Request, mustExist bool) {
+	builderName := chi.URLParam(r, "builderName")
+	key, ok := s.secretKeyParam(w, r)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.WriteError(w, r, "failed to read request body", err)
+		return
+	}
+	value, err := application.SecretValue(body)
+	if err != nil {
+		s.BadRequest(w, r, "invalid secret value", err)
+		return
+	}
+	s.updateSecrets(w, r, builderName, key, func(secrets json.RawMessage) (json.RawMessage, error) {
+		if mustExist {
+			exists, err := application.HasSecretKey(secrets, key)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, fmt.Errorf("secret %s: %w", key, domain.ErrNotFound)
+			}
+		}
+		return application.SetSecretKey(secrets, key, value)
+	})
+}
+
+func (s *AdminHandler) DeleteSecretKey(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	key, ok := s.secretKeyParam(w, r)
+	if !ok {
+		return
+	}
+	s.updateSecrets(w, r, builderName, key, func(secrets json.RawMessage) (json.RawMessage, error) {
+		return application.DeleteSecretKey(secrets, key)
+	})
+}
+
+func (s *AdminHandler) secretKeyParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil || key == "" {
+		s.BadRequest(w, r, "invalid secret key", err)
+		return "", false
+	}
+	return key, true
+}
+
+// updateSecrets applies a change to the secrets of a builder. The change is checked against the current secrets
+// first and applied again to the secrets at that time, so that approved changes don't overwrite other keys set
+// in the meantime.
+func (s *AdminHandler) updateSecrets(w http.ResponseWriter, r *http.Request, builderName, key string, change func(json.RawMessage) (json.RawMessage, error)) {
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to update secret", err)
+		return
+	}
+	apply := func(ctx context.Context, dryRun bool) error {
+		s.secretsMu.Lock()
+		defer s.secretsMu.Unlock()
+		secrets, err := s.secretService.GetSecretValues(ctx, builderName)
+		if err != nil && !errors.Is(err, application.ErrMissingSecret) {
+			return fmt.Errorf("failed to get secrets: %w", err)
+		}
+		updated, err := change(secrets)
+		if err != nil {
+			return err
+		}
+		if err = s.validateSecrets(ctx, builderName, updated); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		return s.secretService.SetSecretValues(ctx, builderName, updated)
+	}
+
+	if s.approvals != nil {
+		if err := apply(r.Context(), true); err != nil {
+			s.WriteError(w, r, "failed to update secret", err)
+			return
+		}
+		s.propose(w, r, ProposalSetSecrets, builderName+" "+key, func(ctx context.Context) error {
+			return apply(ctx, false)
+		})
+		return
+	}
+	if err := apply(r.Context(), false); err != nil {
+		s.WriteError(w, r, "failed to update secret", err)
+		return
+	}
+	s.log.Info("secret updated", "builder", builderName, "key", key, "admin", adminSubject(r))
+}
@@ -0,0 +1,42 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretKeys(t *testing.T) {
+	ctx := context.Background()
+	_, store, secrets, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "old"}, "github_token": "gh"}`)))
+
+	rr := do(http.MethodGet, "/builders/secrets/b-1/keys", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `[{"key": "github_token", "length": 2}, {"key": "relay.key", "length": 3}]`, rr.Body.String())
+	require.NotContains(t, rr.Body.String(), "old")
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.key/rotate", `"new"`).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.other/rotate", `"new"`).Code, "only existing secrets can be rotated")
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.other", `"other"`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/builders/secrets/b-1/keys/relay.port", `8080`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/builders/secrets/b-1/keys/github_token", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/builders/secrets/b-1/keys/github_token", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/builders/secrets/unknown/keys", "").Code)
+
+	values, err := secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "new", "other": "other"}}`, string(values))
+
+	// whole documents with non-string values are rejected up front
+	rr = do(http.MethodPost, "/builders/secrets/b-1", `{"relay": {"port": 8080}}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), application.ErrNonStringSecret.Error())
+}
@@ -0,0 +1,93 @@
+package ports
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/go-chi/chi/v5"
+)
+
+// secretVersionKeys describes a version of a builder's secrets without the values
+type secretVersionKeys struct {
+	Version string                  `json:"version"`
+	Keys    []application.SecretKey `json:"keys"`
+}
+
+// ListSecretVersions returns the versions of a builder's secrets kept by the secrets backend, newest first
+func (s *AdminHandler) ListSecretVersions(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to list secret versions", err)
+		return
+	}
+	versions, err := s.secretService.ListSecretVersions(r.Context(), builderName)
+	if err != nil {
+		s.WriteError(w, r, "failed to list secret versions", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, versions)
+}
+
+// GetSecretVersion returns the secret paths of a version and their lengths, never the values
+func (s *AdminHandler) GetSecretVersion(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	version := chi.URLParam(r, "version")
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to get secret version", err)
+		return
+	}
+	secrets, err := s.secretService.GetSecretVersion(r.Context(), builderName, version)
+	if err != nil {
+		s.WriteError(w, r, "failed to get secret version", err)
+		return
+	}
+	keys, err := application.SecretKeys(secrets)
+	if err != nil {
+		s.WriteError(w, r, "failed to list secret keys", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, secretVersionKeys{Version: version, Keys: keys})
+}
+
+// RestoreSecretVersion writes an earlier version of a builder's secrets as the current one, e.g. to undo a bad
+// secrets update. The restored secrets are validated like a new update.
+func (s *AdminHandler) RestoreSecretVersion(w http.ResponseWriter, r *http.Request) {
+	builderName := chi.URLParam(r, "builderName")
+	version := chi.URLParam(r, "version")
+	if _, err := s.findBuilder(r.Context(), builderName); err != nil {
+		s.WriteError(w, r, "failed to restore secret version", err)
+		return
+	}
+	apply := func(ctx context.Context, dryRun bool) error {
+		s.secretsMu.Lock()
+		defer s.secretsMu.Unlock()
+		secrets, err := s.secretService.GetSecretVersion(ctx, builderName, version)
+		if err != nil {
+			return err
+		}
+		if err = s.validateSecrets(ctx, builderName, secrets); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		return s.secretService.RestoreSecretVersion(ctx, builderName, version)
+	}
+
+	if s.approvals != nil {
+		if err := apply(r.Context(), true); err != nil {
+			s.WriteError(w, r, "failed to restore secret version", err)
+			return
+		}
+		s.propose(w, r, ProposalSetSecrets, builderName+" version "+version, func(ctx context.Context) error {
+			return apply(ctx, false)
+		})
+		return
+	}
+	if err := apply(r.Context(), false); err != nil {
+		s.WriteError(w, r, "failed to restore secret version", err)
+		return
+	}
+	s.log.Info("secret version restored", "builder", builderName, "version", version, "admin", adminSubject(r))
+}
@@ -0,0 +1,58 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretVersions(t *testing.T) {
+	ctx := context.Background()
+	h, store, secrets, do := newTestAdmin(t)
+	require.NoError(t, store.AddBuilder(ctx, domain.Builder{Name: "b-1", IPAddress: net.ParseIP("10.0.0.1"), Network: "testnet"}))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "good"}}`)))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": "bad"}}`)))
+
+	rr := do(http.MethodGet, "/builders/secrets/b-1/versions", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var versions []domain.SecretVersion
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &versions))
+	require.Len(t, versions, 2)
+	require.Equal(t, "2", versions[0].Version)
+	require.True(t, versions[0].Current)
+	require.False(t, versions[1].Current)
+
+	rr = do(http.MethodGet, "/builders/secrets/b-1/versions/1", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"version": "1", "keys": [{"key": "relay.key", "length": 4}]}`, rr.Body.String())
+	require.NotContains(t, rr.Body.String(), "good", "values are never returned")
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/builders/secrets/b-1/versions/7", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/builders/secrets/unknown/versions", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1/versions/1/restore", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1/versions/7/restore", "").Code)
+	values, err := secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "good"}}`, string(values))
+	versions, err = secrets.ListSecretVersions(ctx, "b-1")
+	require.NoError(t, err)
+	require.Len(t, versions, 3, "restoring adds a version")
+
+	// restored secrets are validated like new ones
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{"relay": {"key": {"$secret": "shared/missing"}}}`)))
+	require.NoError(t, secrets.SetSecretValues(ctx, "b-1", json.RawMessage(`{}`)))
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1/versions/4/restore", "").Code)
+
+	// with approvals, restoring is proposed
+	h.WithApprovals(NewApprovalStore(time.Hour))
+	require.Equal(t, http.StatusAccepted, do(http.MethodPost, "/builders/secrets/b-1/versions/2/restore", "").Code)
+	values, err = secrets.GetSecretValues(ctx, "b-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(values))
+}
@@ -0,0 +1,159 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/go-chi/chi/v5"
+)
+
+type sharedSecretRequest struct {
+	Value  string              `json:"value"`
+	Policy domain.SecretPolicy `json:"policy"`
+}
+
+// sharedSecretStatus is a shared secret without its value and the builders referencing it
+type sharedSecretStatus struct {
+	domain.SharedSecret
+	UsedBy []string `json:"used_by"`
+}
+
+// ListSharedSecrets returns the shared secrets with their policies and users, never the values
+func (s *AdminHandler) ListSharedSecrets(w http.ResponseWriter, r *http.Request) {
+	shared, err := s.secretService.ListSharedSecrets(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list shared secrets", err)
+		return
+	}
+	users, err := s.sharedSecretUsers(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list shared secret users", err)
+		return
+	}
+	res := make([]sharedSecretStatus, 0, len(shared))
+	for _, secret := range shared {
+		res = append(res, sharedSecretStatus{SharedSecret: secret, UsedBy: usersOf(users, secret.Name)})
+	}
+	s.writeJSON(w, http.StatusOK, res)
+}
+
+// SetSharedSecret creates or rotates a shared secret, builders referencing it get the new value at their next
+// config fetch. The policy must still allow all builders referencing it.
+func (s *AdminHandler) SetSharedSecret(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	var req sharedSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.BadRequest(w, r, "failed to decode request body", err)
+		return
+	}
+	secret := domain.SharedSecret{Name: name, Value: req.Value, Policy: req.Policy}
+	if err := domain.ValidSharedSecretName(name); err != nil {
+		s.BadRequest(w, r, "invalid shared secret", err)
+		return
+	}
+	apply := func(ctx context.Context) error {
+		if err := s.checkSharedSecretPolicy(ctx, secret); err != nil {
+			return err
+		}
+		return s.secretService.SetSharedSecret(ctx, secret)
+	}
+	if s.approvals != nil {
+		if err := s.checkSharedSecretPolicy(r.Context(), secret); err != nil {
+			s.WriteError(w, r, "invalid shared secret policy", err)
+			return
+		}
+		s.propose(w, r, ProposalSetSecrets, domain.SharedSecretNamespace+name, apply)
+		return
+	}
+	if err := apply(r.Context()); err != nil {
+		s.WriteError(w, r, "failed to set shared secret", err)
+		return
+	}
+	s.log.Info("shared secret set", "name", name, "admin", adminSubject(r))
+}
+
+// DeleteSharedSecret removes a shared secret that no builder references
+func (s *AdminHandler) DeleteSharedSecret(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	users, err := s.sharedSecretUsers(r.Context())
+	if err != nil {
+		s.WriteError(w, r, "failed to list shared secret users", err)
+		return
+	}
+	if used := usersOf(users, name); len(used) > 0 {
+		s.WriteError(w, r, "failed to delete shared secret", fmt.Errorf("%w: shared secret %s is referenced by %v", domain.ErrConflict, name, used))
+		return
+	}
+	if err = s.secretService.DeleteSharedSecret(r.Context(), name); err != nil {
+		s.WriteError(w, r, "failed to delete shared secret", err)
+		return
+	}
+	s.log.Info("shared secret deleted", "name", name, "admin", adminSubject(r))
+}
+
+// secretUser is a builder with the shared secrets its secrets reference
+type secretUser struct {
+	builder domain.Builder
+	refs    []string
+}
+
+func (s *AdminHandler) sharedSecretUsers(ctx context.Context) ([]secretUser, error) {
+	builders, err := s.builderService.GetAllBuilders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builders: %w", err)
+	}
+	var res []secretUser
+	for _, b := range builders {
+		secrets, err := s.secretService.GetSecretValues(ctx, b.Name)
+		if errors.Is(err, application.ErrMissingSecret) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secrets of builder %s: %w", b.Name, err)
+		}
+		keys, err := application.SecretKeys(secrets)
+		if err != nil {
+			// documents with non-string values predate the check and can't reference shared secrets
+			continue
+		}
+		user := secretUser{builder: b}
+		for _, key := range keys {
+			if key.Ref != "" {
+				user.refs = append(user.refs, key.Ref)
+			}
+		}
+		if len(user.refs) > 0 {
+			res = append(res, user)
+		}
+	}
+	return res, nil
+}
+
+// usersOf returns the names of the builders referencing the shared secret, ordered like GetAllBuilders
+func usersOf(users []secretUser, name string) []string {
+	res := []string{}
+	for _, u := range users {
+		if contains(u.refs, domain.SharedSecretNamespace+name) {
+			res = append(res, u.builder.Name)
+		}
+	}
+	return res
+}
+
+func (s *AdminHandler) checkSharedSecretPolicy(ctx context.Context, secret domain.SharedSecret) error {
+	users, err := s.sharedSecretUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if contains(u.refs, domain.SharedSecretNamespace+secret.Name) && !secret.Policy.Allows(u.builder) {
+			return fmt.Errorf("%w: the policy of shared secret %s doesn't allow builder %s, which references it", domain.ErrValidation, secret.Name, u.builder.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package ports
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/flashbots/builder-hub/application"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedSecrets(t *testing.T) {
+	ctx := context.Background()
+	_, store, secrets, do := newTestAdmin(t)
+	hub := application.NewBuilderHub(store, secrets)
+	for i, b := range []domain.Builder{{Name: "b-1", Network: "testnet"}, {Name: "b-2", Network: "testnet"}, {Name: "b-3", Network: "production"}} {
+		b.IPAddress = net.IPv4(10, 0, 0, byte(i+1))
+		require.NoError(t, store.AddBuilder(ctx, b))
+		require.NoError(t, store.AddBuilderConfig(ctx, b.Name, json.RawMessage(`{}`)))
+	}
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/shared-secrets/..", `{"value": "x"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/shared-secrets/relay-key", `{"value": "v1", "policy": {"networks": ["testnet"]}}`).Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/builders/secrets/b-1", `{"relay": {"key": {"$secret": "shared/missing"}}}`).Code)
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "/builders/secrets/b-3", `{"relay": {"key": {"$secret": "shared/relay-key"}}}`).Code, "the policy doesn't allow b-3")
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1", `{"relay": {"key": {"$secret": "shared/relay-key"}}, "own": "b-1"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-2/keys/relay.key", `{"$secret": "shared/relay-key"}`).Code)
+
+	for _, name := range []string{"b-1", "b-2"} {
+		bts, _, err := hub.GetConfigWithSecrets(ctx, domain.Builder{Name: name, Network: "testnet"})
+		require.NoError(t, err)
+		require.Contains(t, string(bts), `"key":"v1"`)
+	}
+
+	// one rotation updates all builders
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/shared-secrets/relay-key", `{"value": "v2", "policy": {"networks": ["testnet"]}}`).Code)
+	bts, _, err := hub.GetConfigWithSecrets(ctx, domain.Builder{Name: "b-2", Network: "testnet"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"relay": {"key": "v2"}}`, string(bts))
+
+	rr := do(http.MethodGet, "/shared-secrets", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `[{"name": "relay-key", "policy": {"networks": ["testnet"]}, "used_by": ["b-1", "b-2"]}]`, rr.Body.String())
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/shared-secrets/relay-key", `{"value": "v3", "policy": {"builders": ["b-1"]}}`).Code, "b-2 references it")
+	require.Equal(t, http.StatusConflict, do(http.MethodDelete, "/shared-secrets/relay-key", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-1", `{"own": "b-1"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/builders/secrets/b-2", `{}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, "/shared-secrets/relay-key", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/shared-secrets/relay-key", "").Code)
+}
@@ -0,0 +1,147 @@
+package ports
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/builder-hub/transparency"
+)
+
+const maxLogEntriesPerRequest = 1000
+
+// LogEntry is a measurement transparency log leaf. Data is the exact leaf content hashed into the tree,
+// Change is the same content decoded for convenience.
+type LogEntry struct {
+	Index    uint64          `json:"index"`
+	Data     []byte          `json:"data"`
+	LeafHash hexutil.Bytes   `json:"leaf_hash"`
+	Change   json.RawMessage `json:"change,omitempty"`
+}
+
+// MerkleProof is an inclusion or consistency proof
+type MerkleProof struct {
+	Index    *uint64         `json:"index,omitempty"`
+	First    *uint64         `json:"first,omitempty"`
+	TreeSize uint64          `json:"tree_size"`
+	Hashes   []hexutil.Bytes `json:"hashes"`
+}
+
+func toHexHashes(hashes [][]byte) []hexutil.Bytes {
+	res := make([]hexutil.Bytes, 0, len(hashes))
+	for _, h := range hashes {
+		res = append(res, h)
+	}
+	return res
+}
+
+func uintQueryParam(r *http.Request, name string) (uint64, error) {
+	return strconv.ParseUint(r.URL.Query().Get(name), 10, 64)
+}
+
+func (bhs *BuilderHubHandler) GetMeasurementLogTreeHead(w http.ResponseWriter, r *http.Request) {
+	if bhs.tlog == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "measurement transparency log is not enabled")
+		return
+	}
+	sth, err := bhs.tlog.SignedTreeHead(r.Context())
+	if err != nil {
+		bhs.WriteError(w, r, "failed to sign measurement log tree head", err)
+		return
+	}
+	bhs.writeJSON(w, http.StatusOK, sth)
+}
+
+func (bhs *BuilderHubHandler) GetMeasurementLogEntries(w http.ResponseWriter, r *http.Request) {
+	if bhs.tlog == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "measurement transparency log is not enabled")
+		return
+	}
+	start, err := uintQueryParam(r, "start")
+	if err != nil {
+		bhs.BadRequest(w, r, "invalid start", err)
+		return
+	}
+	limit := maxLogEntriesPerRequest
+	if r.URL.Query().Has("limit") {
+		l, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || l <= 0 {
+			bhs.BadRequest(w, r, "invalid limit")
+			return
+		}
+		limit = min(l, maxLogEntriesPerRequest)
+	}
+
+	entries, err := bhs.tlog.Entries(r.Context(), start, limit)
+	if err != nil {
+		bhs.WriteError(w, r, "failed to fetch measurement log entries", err)
+		return
+	}
+	res := make([]LogEntry, 0, len(entries))
+	for _, e := range entries {
+		entry := LogEntry{Index: e.Index, Data: e.Data, LeafHash: transparency.LeafHash(e.Data)}
+		if json.Valid(e.Data) {
+			entry.Change = e.Data
+		}
+		res = append(res, entry)
+	}
+	bhs.writeJSON(w, http.StatusOK, res)
+}
+
+func (bhs *BuilderHubHandler) GetMeasurementLogInclusionProof(w http.ResponseWriter, r *http.Request) {
+	if bhs.tlog == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "measurement transparency log is not enabled")
+		return
+	}
+	index, err := uintQueryParam(r, "index")
+	if err != nil {
+		bhs.BadRequest(w, r, "invalid index", err)
+		return
+	}
+	treeSize, err := uintQueryParam(r, "tree_size")
+	if err != nil {
+		bhs.BadRequest(w, r, "invalid tree_size", err)
+		return
+	}
+
+	proof, err := bhs.tlog.InclusionProof(r.Context(), index, treeSize)
+	if errors.Is(err, transparency.ErrInvalidRange) {
+		bhs.BadRequest(w, r, "invalid proof range", err)
+		return
+	}
+	if err != nil {
+		bhs.WriteError(w, r, "failed to build inclusion proof", err)
+		return
+	}
+	bhs.writeJSON(w, http.StatusOK, MerkleProof{Index: &index, TreeSize: treeSize, Hashes: toHexHashes(proof)})
+}
+
+func (bhs *BuilderHubHandler) GetMeasurementLogConsistencyProof(w http.ResponseWriter, r *http.Request) {
+	if bhs.tlog == nil {
+		bhs.Problem(w, r, http.StatusNotFound, "measurement transparency log is not enabled")
+		return
+	}
+	first, err := uintQueryParam(r, "first")
+	if err != nil {
+		bhs.BadRequest(w, r, "invalid first", err)
+		return
+	}
+	second, err := uintQueryParam(r, "second")
+	if err != nil {
+		bhs.BadRequest(w, r, "invalid second", err)
+		return
+	}
+
+	proof, err := bhs.tlog.ConsistencyProof(r.Context(), first, second)
+	if errors.Is(err, transparency.ErrInvalidRange) {
+		bhs.BadRequest(w, r, "invalid proof range", err)
+		return
+	}
+	if err != nil {
+		bhs.WriteError(w, r, "failed to build consistency proof", err)
+		return
+	}
+	bhs.writeJSON(w, http.StatusOK, MerkleProof{First: &first, TreeSize: second, Hashes: toHexHashes(proof)})
+}
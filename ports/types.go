@@ -14,6 +14,10 @@ const (
 	AttestationTypeHeader string = "X-Flashbots-Attestation-Type"
 	MeasurementHeader     string = "X-Flashbots-Measurement"
 	ForwardedHeader       string = "X-Forwarded-For"
+	// ConfigEncryptionKeyHeader carries the hex encoded X25519 public key the config is encrypted to
+	ConfigEncryptionKeyHeader string = "X-Flashbots-Config-Encryption-Key"
+	// ConfigEncryptionQuoteHeader carries the base64 encoded TDX quote of the instance binding the encryption key
+	ConfigEncryptionQuoteHeader string = "X-Flashbots-Config-Encryption-Quote"
 )
 
 var (
@@ -146,6 +150,9 @@ type Builder struct {
 }
 
 func toDomainBuilder(builder Builder, enabled bool) (domain.Builder, error) {
+	if err := domain.ValidBuilderName(builder.Name); err != nil {
+		return domain.Builder{}, err
+	}
 	ip := net.ParseIP(builder.IPAddress)
 	if ip == nil {
 		return domain.Builder{}, ErrInvalidIPAddress
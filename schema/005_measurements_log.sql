@@ -0,0 +1,38 @@
+-- Append-only transparency log of measurement whitelist changes.
+-- leaf_index is dense (no gaps), it is the position of the entry in the Merkle tree.
+CREATE TABLE measurements_log
+(
+    leaf_index BIGINT PRIMARY KEY CHECK (leaf_index >= 0),
+    data       BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE FUNCTION measurements_log_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'measurements_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER measurements_log_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE
+    ON measurements_log
+    FOR EACH STATEMENT
+EXECUTE FUNCTION measurements_log_append_only();
+
+-- Seed the log with the measurements that existed before it was introduced
+INSERT INTO measurements_log (leaf_index, data)
+SELECT ROW_NUMBER() OVER (ORDER BY id, step) - 1,
+       convert_to(json_build_object(
+                          'action', action,
+                          'measurement_id', name,
+                          'attestation_type', attestation_type,
+                          'measurements', measurement,
+                          'timestamp', EXTRACT(EPOCH FROM created_at)::BIGINT
+                  )::TEXT, 'UTF8')
+FROM (SELECT id, 0 AS step, 'add' AS action, name, attestation_type, measurement, created_at
+      FROM measurements_whitelist
+      UNION ALL
+      SELECT id, 1 AS step, 'activate' AS action, name, attestation_type, measurement, created_at
+      FROM measurements_whitelist
+      WHERE is_active) existing;
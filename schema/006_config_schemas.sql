@@ -0,0 +1,11 @@
+-- JSON Schemas builder configs are validated against. target is the network or builder name, empty for the global scope.
+CREATE TABLE config_schemas
+(
+    scope      TEXT                     NOT NULL CHECK (scope IN ('global', 'network', 'builder')),
+    target     TEXT                     NOT NULL DEFAULT '',
+    schema     JSONB                    NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, target),
+    CONSTRAINT target_only_for_scoped_schemas CHECK ((scope = 'global') = (target = ''))
+);
@@ -0,0 +1,20 @@
+-- Shared config layers, merged below the builder's own config (builder_configs)
+CREATE TABLE config_layers
+(
+    kind       TEXT                     NOT NULL CHECK (kind IN ('network', 'group')),
+    name       TEXT                     NOT NULL,
+    config     JSONB                    NOT NULL CHECK (jsonb_typeof(config) = 'object'),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, name)
+);
+
+-- Group layers of a builder, merged in position order
+CREATE TABLE builder_config_groups
+(
+    builder_name VARCHAR(255) NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    group_name   TEXT         NOT NULL,
+    position     INT          NOT NULL,
+    PRIMARY KEY (builder_name, group_name),
+    CONSTRAINT unique_group_position UNIQUE (builder_name, position)
+);
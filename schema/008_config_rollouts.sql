@@ -0,0 +1,16 @@
+-- Staged versions of shared config layers, served to the targeted builders until promoted or aborted
+CREATE TABLE config_rollouts
+(
+    id          SERIAL PRIMARY KEY,
+    layer_kind  TEXT                     NOT NULL CHECK (layer_kind IN ('network', 'group')),
+    layer_name  TEXT                     NOT NULL,
+    config      JSONB                    NOT NULL CHECK (jsonb_typeof(config) = 'object'),
+    builders    TEXT[]                   NOT NULL DEFAULT '{}',
+    percent     INT                      NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+    status      TEXT                     NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'promoted', 'aborted')),
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- One active rollout per layer
+CREATE UNIQUE INDEX unique_active_rollout ON config_rollouts (layer_kind, layer_name) WHERE status = 'active';
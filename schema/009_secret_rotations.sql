@@ -0,0 +1,28 @@
+-- Rotation policies of builder secrets, the secret values stay in the secrets backend
+CREATE TABLE secret_rotation_policies
+(
+    builder_name        VARCHAR(255)             NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    key                 TEXT                     NOT NULL,
+    generator           TEXT                     NOT NULL CHECK (generator IN ('hex', 'ecdsa', 'password')),
+    length              INT                      NOT NULL DEFAULT 0,
+    interval_seconds    BIGINT                   NOT NULL CHECK (interval_seconds > 0),
+    overlap_seconds     BIGINT                   NOT NULL DEFAULT 0 CHECK (overlap_seconds >= 0),
+    last_rotated_at     TIMESTAMP WITH TIME ZONE,
+    previous_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (builder_name, key)
+);
+
+-- Rotations of builder secrets, without the values
+CREATE TABLE secret_rotations
+(
+    id                  SERIAL PRIMARY KEY,
+    builder_name        VARCHAR(255)             NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    key                 TEXT                     NOT NULL,
+    trigger             TEXT                     NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    rotated_at          TIMESTAMP WITH TIME ZONE NOT NULL,
+    previous_expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX secret_rotations_builder ON secret_rotations (builder_name, id);
@@ -0,0 +1,16 @@
+-- Builder and shared secrets of the Postgres secrets backend. Every version is encrypted with its own data key,
+-- the data keys are wrapped by a master key that is never stored in the database.
+CREATE TABLE encrypted_secrets
+(
+    kind          TEXT                     NOT NULL CHECK (kind IN ('builder', 'shared')),
+    name          TEXT                     NOT NULL,
+    version       INT                      NOT NULL CHECK (version > 0),
+    master_key_id TEXT                     NOT NULL,
+    wrapped_key   BYTEA                    NOT NULL,
+    ciphertext    BYTEA                    NOT NULL,
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, name, version)
+);
+
+-- Re-wrapping finds the data keys of retired master keys
+CREATE INDEX encrypted_secrets_master_key ON encrypted_secrets (master_key_id);
@@ -0,0 +1,8 @@
+-- The first config fetch of each builder that was served the new layer of a rollout
+CREATE TABLE config_rollout_fetches
+(
+    rollout_id   INT                      NOT NULL REFERENCES config_rollouts (id) ON DELETE CASCADE,
+    builder_name TEXT                     NOT NULL REFERENCES builders (name) ON DELETE CASCADE,
+    fetched_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (rollout_id, builder_name)
+);
@@ -0,0 +1,6 @@
+DROP TABLE event_log;
+DROP TABLE builder_configs;
+DROP TABLE service_credential_registrations;
+DROP TABLE measurements_whitelist;
+DROP TABLE builders;
+DROP FUNCTION update_builders_updated_at();
@@ -0,0 +1,5 @@
+ALTER TABLE measurements_whitelist
+    DROP CONSTRAINT unique_name;
+
+ALTER TABLE measurements_whitelist
+    ADD CONSTRAINT unique_hash_attestation_type UNIQUE (name, attestation_type);
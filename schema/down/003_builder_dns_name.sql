@@ -0,0 +1,2 @@
+ALTER TABLE builders
+    DROP COLUMN dns_name;
@@ -0,0 +1 @@
+ALTER TABLE service_credential_registrations DROP COLUMN region;
@@ -0,0 +1,2 @@
+DROP TABLE measurements_log;
+DROP FUNCTION measurements_log_append_only();
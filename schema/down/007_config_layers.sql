@@ -0,0 +1,2 @@
+DROP TABLE builder_config_groups;
+DROP TABLE config_layers;
@@ -0,0 +1,2 @@
+DROP TABLE secret_rotations;
+DROP TABLE secret_rotation_policies;
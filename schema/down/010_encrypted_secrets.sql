@@ -0,0 +1 @@
+DROP TABLE encrypted_secrets;
@@ -0,0 +1 @@
+DROP TABLE config_rollout_fetches;
@@ -0,0 +1,8 @@
+// Package schema embeds the database migrations. Up migrations are the NNN_name.sql files in this
+// directory, applied in order; the matching down migration lives in down/NNN_name.sql.
+package schema
+
+import "embed"
+
+//go:embed *.sql down/*.sql
+var Migrations embed.FS
@@ -0,0 +1,154 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	ErrUnexpectedKey  = errors.New("list is not signed by the pinned key")
+	ErrUnexpectedList = errors.New("signed payload belongs to a different list")
+	ErrRollback       = errors.New("list version is older than the last seen version")
+)
+
+// SignedList is a versioned list together with the hub signature over the exact payload bytes.
+// Clients must verify the payload bytes as received and only then decode them.
+type SignedList struct {
+	Payload   json.RawMessage `json:"payload"`
+	Signature hexutil.Bytes   `json:"signature"`
+	Algorithm string          `json:"algorithm"`
+	PublicKey hexutil.Bytes   `json:"public_key"`
+}
+
+// ListPayload is the signed content of a SignedList
+type ListPayload struct {
+	// List names the list (e.g. "measurements"), so a signed list can't be passed off as another one
+	List string `json:"list"`
+	// Version increases whenever the items change
+	Version uint64 `json:"version"`
+	// Timestamp is the unix time the payload was signed at, clients can reject stale lists with it
+	Timestamp int64           `json:"timestamp"`
+	Items     json.RawMessage `json:"items"`
+}
+
+// Publisher signs lists and keeps their versions monotonically increasing. Versions are
+// derived from the wall clock in milliseconds when the content changes, so they keep increasing
+// across restarts as long as the clock does.
+type Publisher struct {
+	signer Signer
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	lists map[string]*publishedList
+}
+
+type publishedList struct {
+	digest   [32]byte
+	version  uint64
+	signedAt time.Time
+	signed   *SignedList
+}
+
+// NewPublisher returns a publisher re-signing unchanged lists once they are older than maxAge
+func NewPublisher(signer Signer, maxAge time.Duration) *Publisher {
+	return &Publisher{
+		signer: signer,
+		maxAge: maxAge,
+		now:    time.Now,
+		lists:  make(map[string]*publishedList),
+	}
+}
+
+func (p *Publisher) Signer() Signer {
+	return p.signer
+}
+
+// Published reports whether the list was published before
+func (p *Publisher) Published(list string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lists[list] != nil
+}
+
+// Publish returns the signed version of the list. Items must be deterministically ordered,
+// otherwise every call looks like a content change and bumps the version.
+func (p *Publisher) Publish(ctx context.Context, list string, items any) (*SignedList, error) {
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(itemsJSON)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	cur := p.lists[list]
+	if cur != nil && cur.digest == digest && now.Sub(cur.signedAt) < p.maxAge {
+		return cur.signed, nil
+	}
+
+	version := uint64(now.UnixMilli())
+	if cur != nil {
+		switch {
+		case cur.digest == digest:
+			version = cur.version
+		case version <= cur.version:
+			version = cur.version + 1
+		}
+	}
+
+	payload, err := json.Marshal(ListPayload{
+		List:      list,
+		Version:   version,
+		Timestamp: now.Unix(),
+		Items:     itemsJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sig, err := p.signer.Sign(ctx, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s list: %w", list, err)
+	}
+
+	signed := &SignedList{
+		Payload:   payload,
+		Signature: sig,
+		Algorithm: p.signer.Algorithm(),
+		PublicKey: p.signer.PublicKey(),
+	}
+	p.lists[list] = &publishedList{digest: digest, version: version, signedAt: now, signed: signed}
+	return signed, nil
+}
+
+// VerifySignedList checks that the list is signed by the pinned key, belongs to the expected
+// list and is not older than minVersion, the last version the client has seen.
+func VerifySignedList(signed *SignedList, algorithm string, pinnedKey []byte, list string, minVersion uint64) (*ListPayload, error) {
+	if signed.Algorithm != algorithm || !bytes.Equal(signed.PublicKey, pinnedKey) {
+		return nil, ErrUnexpectedKey
+	}
+	if err := Verify(algorithm, pinnedKey, signed.Payload, signed.Signature); err != nil {
+		return nil, err
+	}
+	var payload ListPayload
+	if err := json.Unmarshal(signed.Payload, &payload); err != nil {
+		return nil, err
+	}
+	if payload.List != list {
+		return nil, ErrUnexpectedList
+	}
+	if payload.Version < minVersion {
+		return nil, ErrRollback
+	}
+	return &payload, nil
+}
@@ -0,0 +1,115 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func testSigners(t *testing.T) []Signer {
+	t.Helper()
+	ecKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return []Signer{NewSecp256k1Signer(ecKey), NewEd25519Signer(edKey)}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, signer := range testSigners(t) {
+		t.Run(signer.Algorithm(), func(t *testing.T) {
+			sig, err := signer.Sign(context.Background(), []byte("hello"))
+			require.NoError(t, err)
+			require.NoError(t, Verify(signer.Algorithm(), signer.PublicKey(), []byte("hello"), sig))
+			require.ErrorIs(t, Verify(signer.Algorithm(), signer.PublicKey(), []byte("hellO"), sig), ErrInvalidSignature)
+		})
+	}
+}
+
+func TestLoadSigner(t *testing.T) {
+	dir := t.TempDir()
+	ecKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	path := filepath.Join(dir, "secp256k1.key")
+	require.NoError(t, os.WriteFile(path, []byte("0x"+hex.EncodeToString(crypto.FromECDSA(ecKey))+"\n"), 0o600))
+
+	signer, err := LoadSigner(AlgorithmSecp256k1, path)
+	require.NoError(t, err)
+	require.Equal(t, crypto.FromECDSAPub(&ecKey.PublicKey), signer.PublicKey())
+
+	_, err = LoadSigner(AlgorithmEd25519, path)
+	require.NoError(t, err, "a 32 byte secp256k1 key is also a valid ed25519 seed")
+	_, err = LoadSigner("rsa", path)
+	require.Error(t, err)
+}
+
+func TestPublisher(t *testing.T) {
+	for _, signer := range testSigners(t) {
+		t.Run(signer.Algorithm(), func(t *testing.T) {
+			p := NewPublisher(signer, time.Minute)
+			now := time.Now()
+			p.now = func() time.Time { return now }
+			ctx := context.Background()
+
+			require.False(t, p.Published("measurements"))
+			first, err := p.Publish(ctx, "measurements", []string{"a"})
+			require.NoError(t, err)
+			require.True(t, p.Published("measurements"))
+			payload, err := VerifySignedList(first, signer.Algorithm(), signer.PublicKey(), "measurements", 0)
+			require.NoError(t, err)
+			require.JSONEq(t, `["a"]`, string(payload.Items))
+
+			// unchanged content keeps the version
+			now = now.Add(2 * time.Minute)
+			second, err := p.Publish(ctx, "measurements", []string{"a"})
+			require.NoError(t, err)
+			payload2, err := VerifySignedList(second, signer.Algorithm(), signer.PublicKey(), "measurements", payload.Version)
+			require.NoError(t, err)
+			require.Equal(t, payload.Version, payload2.Version)
+			require.Greater(t, payload2.Timestamp, payload.Timestamp)
+
+			// changed content bumps the version even if the clock went backwards
+			now = now.Add(-time.Hour)
+			third, err := p.Publish(ctx, "measurements", []string{"a", "b"})
+			require.NoError(t, err)
+			payload3, err := VerifySignedList(third, signer.Algorithm(), signer.PublicKey(), "measurements", payload2.Version)
+			require.NoError(t, err)
+			require.Greater(t, payload3.Version, payload2.Version)
+
+			// rollback to an older list is detected
+			_, err = VerifySignedList(first, signer.Algorithm(), signer.PublicKey(), "measurements", payload3.Version)
+			require.ErrorIs(t, err, ErrRollback)
+
+			// list substitution is detected
+			_, err = VerifySignedList(first, signer.Algorithm(), signer.PublicKey(), "builders/production", 0)
+			require.ErrorIs(t, err, ErrUnexpectedList)
+
+			// tampering is detected
+			var tampered SignedList
+			bts, err := json.Marshal(third)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(bts, &tampered))
+			tampered.Payload = json.RawMessage(`{"list":"measurements","version":99999999999999,"timestamp":1,"items":[]}`)
+			_, err = VerifySignedList(&tampered, signer.Algorithm(), signer.PublicKey(), "measurements", 0)
+			require.ErrorIs(t, err, ErrInvalidSignature)
+		})
+	}
+
+	t.Run("unpinned key", func(t *testing.T) {
+		signers := testSigners(t)
+		signed, err := NewPublisher(signers[0], time.Minute).Publish(context.Background(), "measurements", []string{})
+		require.NoError(t, err)
+		other := testSigners(t)[0]
+		_, err = VerifySignedList(signed, other.Algorithm(), other.PublicKey(), "measurements", 0)
+		require.ErrorIs(t, err, ErrUnexpectedKey)
+	})
+}
@@ -0,0 +1,122 @@
+// Package signing signs artifacts published by the hub, so that clients pinning the hub key can verify them
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// AlgorithmSecp256k1 signs keccak256(message) with a recoverable 65 byte [R || S || V] signature,
+	// the same scheme builders use for their registered ECDSA keys
+	AlgorithmSecp256k1 = "secp256k1"
+	// AlgorithmEd25519 signs the message with Ed25519 (RFC 8032)
+	AlgorithmEd25519 = "ed25519"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer signs hub artifacts such as the measurement list
+type Signer interface {
+	Algorithm() string
+	// PublicKey returns the key clients pin: the uncompressed point for secp256k1, the raw key for ed25519
+	PublicKey() []byte
+	Sign(ctx context.Context, message []byte) ([]byte, error)
+}
+
+type secp256k1Signer struct {
+	key *ecdsa.PrivateKey
+}
+
+func NewSecp256k1Signer(key *ecdsa.PrivateKey) Signer {
+	return &secp256k1Signer{key: key}
+}
+
+func (s *secp256k1Signer) Algorithm() string {
+	return AlgorithmSecp256k1
+}
+
+func (s *secp256k1Signer) PublicKey() []byte {
+	return crypto.FromECDSAPub(&s.key.PublicKey)
+}
+
+func (s *secp256k1Signer) Sign(_ context.Context, message []byte) ([]byte, error) {
+	return crypto.Sign(crypto.Keccak256(message), s.key)
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{key: key}
+}
+
+func (s *ed25519Signer) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+func (s *ed25519Signer) PublicKey() []byte {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+func (s *ed25519Signer) Sign(_ context.Context, message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+// LoadSigner reads a hex encoded private key (secp256k1 scalar or ed25519 seed) from a file
+func LoadSigner(algorithm, path string) (Signer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(raw)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("signing key must be hex encoded: %w", err)
+	}
+
+	switch algorithm {
+	case AlgorithmSecp256k1:
+		key, err := crypto.ToECDSA(keyBytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSecp256k1Signer(key), nil
+	case AlgorithmEd25519:
+		if len(keyBytes) != ed25519.SeedSize {
+			return nil, fmt.Errorf("ed25519 seed must be %d bytes", ed25519.SeedSize)
+		}
+		return NewEd25519Signer(ed25519.NewKeyFromSeed(keyBytes)), nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
+
+// Verify checks a signature produced by a Signer of the given algorithm
+func Verify(algorithm string, publicKey, message, signature []byte) error {
+	switch algorithm {
+	case AlgorithmSecp256k1:
+		recovered, err := crypto.SigToPub(crypto.Keccak256(message), signature)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+		}
+		if !bytes.Equal(crypto.FromECDSAPub(recovered), publicKey) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case AlgorithmEd25519:
+		if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, message, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
@@ -0,0 +1,147 @@
+package transparency
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/signing"
+)
+
+// treeHeadPrefix domain-separates tree head signatures from other artifacts signed with the hub key
+const treeHeadPrefix = "builder-hub/measurements-log/tree-head/v1\x00"
+
+const syncBatchSize = 1000
+
+var ErrLogCorrupted = errors.New("transparency log entries are not contiguous")
+
+// EntryStore reads the persisted log entries
+type EntryStore interface {
+	GetMeasurementLogEntries(ctx context.Context, start uint64, limit int) ([]domain.MeasurementLogEntry, error)
+}
+
+// SignedTreeHead commits the hub to the log contents of a given size
+type SignedTreeHead struct {
+	TreeSize uint64 `json:"tree_size"`
+	// Timestamp is the unix time in milliseconds the tree head was signed at
+	Timestamp int64         `json:"timestamp"`
+	RootHash  hexutil.Bytes `json:"root_hash"`
+	Signature hexutil.Bytes `json:"signature"`
+	Algorithm string        `json:"algorithm"`
+	PublicKey hexutil.Bytes `json:"public_key"`
+}
+
+// TreeHeadMessage returns the bytes signed for a tree head
+func TreeHeadMessage(treeSize uint64, timestamp int64, rootHash []byte) []byte {
+	msg := make([]byte, 0, len(treeHeadPrefix)+16+len(rootHash))
+	msg = append(msg, treeHeadPrefix...)
+	msg = binary.BigEndian.AppendUint64(msg, treeSize)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(timestamp)) //nolint:gosec
+	return append(msg, rootHash...)
+}
+
+// Log serves tree heads and proofs over the entries in the store. Leaf hashes are cached in memory,
+// which is safe because the store is append-only.
+type Log struct {
+	store  EntryStore
+	signer signing.Signer
+	now    func() time.Time
+
+	mu     sync.Mutex
+	leaves [][]byte
+}
+
+func NewLog(store EntryStore, signer signing.Signer) *Log {
+	return &Log{
+		store:  store,
+		signer: signer,
+		now:    time.Now,
+	}
+}
+
+// sync loads entries appended since the last call and returns the current leaf hashes. Callers must hold l.mu.
+func (l *Log) sync(ctx context.Context) ([][]byte, error) {
+	for {
+		entries, err := l.store.GetMeasurementLogEntries(ctx, uint64(len(l.leaves)), syncBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Index != uint64(len(l.leaves)) {
+				return nil, fmt.Errorf("%w: expected leaf %d, got %d", ErrLogCorrupted, len(l.leaves), entry.Index)
+			}
+			l.leaves = append(l.leaves, LeafHash(entry.Data))
+		}
+		if len(entries) < syncBatchSize {
+			return l.leaves, nil
+		}
+	}
+}
+
+// SignedTreeHead signs the root of the current tree
+func (l *Log) SignedTreeHead(ctx context.Context) (*SignedTreeHead, error) {
+	l.mu.Lock()
+	leaves, err := l.sync(ctx)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	size := uint64(len(leaves))
+	root := RootHash(leaves)
+	timestamp := l.now().UnixMilli()
+	sig, err := l.signer.Sign(ctx, TreeHeadMessage(size, timestamp, root))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tree head: %w", err)
+	}
+	return &SignedTreeHead{
+		TreeSize:  size,
+		Timestamp: timestamp,
+		RootHash:  root,
+		Signature: sig,
+		Algorithm: l.signer.Algorithm(),
+		PublicKey: l.signer.PublicKey(),
+	}, nil
+}
+
+// prefix returns the leaf hashes of the tree of the given size
+func (l *Log) prefix(ctx context.Context, size uint64) ([][]byte, error) {
+	l.mu.Lock()
+	leaves, err := l.sync(ctx)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(len(leaves)) {
+		return nil, fmt.Errorf("%w: tree size %d exceeds log size %d", ErrInvalidRange, size, len(leaves))
+	}
+	return leaves[:size], nil
+}
+
+// InclusionProof returns the audit path of the leaf at index in the tree of the given size
+func (l *Log) InclusionProof(ctx context.Context, index, treeSize uint64) ([][]byte, error) {
+	leaves, err := l.prefix(ctx, treeSize)
+	if err != nil {
+		return nil, err
+	}
+	return InclusionProof(index, leaves)
+}
+
+// ConsistencyProof proves that the tree of size first is a prefix of the tree of size second
+func (l *Log) ConsistencyProof(ctx context.Context, first, second uint64) ([][]byte, error) {
+	leaves, err := l.prefix(ctx, second)
+	if err != nil {
+		return nil, err
+	}
+	return ConsistencyProof(first, leaves)
+}
+
+// Entries returns up to limit entries starting at start
+func (l *Log) Entries(ctx context.Context, start uint64, limit int) ([]domain.MeasurementLogEntry, error) {
+	return l.store.GetMeasurementLogEntries(ctx, start, limit)
+}
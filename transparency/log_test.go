@@ -0,0 +1,90 @@
+package transparency
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/flashbots/builder-hub/domain"
+	"github.com/flashbots/builder-hub/signing"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStore struct {
+	entries []domain.MeasurementLogEntry
+}
+
+func (m *memoryStore) append(data string) {
+	m.entries = append(m.entries, domain.MeasurementLogEntry{Index: uint64(len(m.entries)), Data: []byte(data)})
+}
+
+func (m *memoryStore) GetMeasurementLogEntries(_ context.Context, start uint64, limit int) ([]domain.MeasurementLogEntry, error) {
+	if start >= uint64(len(m.entries)) {
+		return nil, nil
+	}
+	end := min(start+uint64(limit), uint64(len(m.entries)))
+	return m.entries[start:end], nil
+}
+
+func TestLogAndVerifier(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer := signing.NewEd25519Signer(key)
+	ctx := context.Background()
+
+	store := &memoryStore{}
+	log := NewLog(store, signer)
+	verifier := NewVerifier(signer.Algorithm(), signer.PublicKey(), nil)
+
+	for i := 0; i < 5; i++ {
+		store.append(fmt.Sprintf(`{"action":"add","measurement_id":"m-%d"}`, i))
+	}
+	sth, err := log.SignedTreeHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), sth.TreeSize)
+	require.NoError(t, verifier.Update(sth, nil))
+
+	proof, err := log.InclusionProof(ctx, 2, sth.TreeSize)
+	require.NoError(t, err)
+	require.NoError(t, verifier.VerifyEntry(store.entries[2].Data, 2, proof))
+	require.ErrorIs(t, verifier.VerifyEntry([]byte(`{"action":"add","measurement_id":"forged"}`), 2, proof), ErrInvalidProof)
+
+	// the log grows and the verifier follows with a consistency proof
+	for i := 5; i < 2*syncBatchSize+3; i++ {
+		store.append(fmt.Sprintf(`{"action":"activate","measurement_id":"m-%d"}`, i))
+	}
+	sth2, err := log.SignedTreeHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2*syncBatchSize+3), sth2.TreeSize)
+	consistency, err := log.ConsistencyProof(ctx, sth.TreeSize, sth2.TreeSize)
+	require.NoError(t, err)
+	require.NoError(t, verifier.Update(sth2, consistency))
+
+	// going back to the older tree head is refused
+	require.ErrorIs(t, verifier.Update(sth, nil), ErrStaleTreeHead)
+
+	// a tree head signed by another key is refused
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forged, err := NewLog(store, signing.NewEd25519Signer(otherKey)).SignedTreeHead(ctx)
+	require.NoError(t, err)
+	require.ErrorIs(t, verifier.Update(forged, nil), ErrUnexpectedKey)
+
+	// a tampered signature is refused
+	tampered := *sth2
+	tampered.TreeSize++
+	require.ErrorIs(t, verifier.Update(&tampered, nil), signing.ErrInvalidSignature)
+
+	_, err = log.InclusionProof(ctx, 0, sth2.TreeSize+1)
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestLogDetectsGaps(t *testing.T) {
+	store := &memoryStore{entries: []domain.MeasurementLogEntry{{Index: 0}, {Index: 2}}}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = NewLog(store, signing.NewEd25519Signer(key)).SignedTreeHead(context.Background())
+	require.ErrorIs(t, err, ErrLogCorrupted)
+}
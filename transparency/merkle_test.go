@@ -0,0 +1,67 @@
+package transparency
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = LeafHash([]byte(fmt.Sprintf("entry-%d", i)))
+	}
+	return leaves
+}
+
+func TestRootHashKnownValues(t *testing.T) {
+	// empty tree and single leaf values from RFC 6962
+	require.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(RootHash(nil)))
+	require.Equal(t, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d", hex.EncodeToString(LeafHash(nil)))
+}
+
+func TestInclusionProofs(t *testing.T) {
+	for size := 1; size <= 33; size++ {
+		leaves := testLeaves(size)
+		root := RootHash(leaves)
+		for index := 0; index < size; index++ {
+			proof, err := InclusionProof(uint64(index), leaves)
+			require.NoError(t, err)
+			require.NoError(t, VerifyInclusion(leaves[index], uint64(index), uint64(size), proof, root), "size %d index %d", size, index)
+
+			// a proof for another leaf or position must fail
+			other := (index + 1) % size
+			if other != index {
+				require.ErrorIs(t, VerifyInclusion(leaves[other], uint64(index), uint64(size), proof, root), ErrInvalidProof)
+			}
+		}
+	}
+
+	_, err := InclusionProof(3, testLeaves(3))
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
+
+func TestConsistencyProofs(t *testing.T) {
+	leaves := testLeaves(33)
+	for second := 1; second <= len(leaves); second++ {
+		secondRoot := RootHash(leaves[:second])
+		for first := 0; first <= second; first++ {
+			firstRoot := RootHash(leaves[:first])
+			proof, err := ConsistencyProof(uint64(first), leaves[:second])
+			require.NoError(t, err)
+			require.NoError(t, VerifyConsistency(uint64(first), uint64(second), firstRoot, secondRoot, proof), "%d -> %d", first, second)
+
+			if first > 0 && first < second {
+				// a rewritten history must not verify
+				forked := append([][]byte{}, leaves[:second]...)
+				forked[first-1] = LeafHash([]byte("rewritten"))
+				require.ErrorIs(t, VerifyConsistency(uint64(first), uint64(second), firstRoot, RootHash(forked), proof), ErrInvalidProof)
+			}
+		}
+	}
+
+	_, err := ConsistencyProof(4, testLeaves(3))
+	require.ErrorIs(t, err, ErrInvalidRange)
+}
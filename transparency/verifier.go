@@ -0,0 +1,82 @@
+package transparency
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/flashbots/builder-hub/signing"
+)
+
+var (
+	ErrUnexpectedKey = errors.New("tree head is not signed by the pinned key")
+	ErrStaleTreeHead = errors.New("tree head is older than the trusted tree head")
+)
+
+// VerifySignedTreeHead checks that the tree head is signed by the pinned key
+func VerifySignedTreeHead(sth *SignedTreeHead, algorithm string, pinnedKey []byte) error {
+	if sth.Algorithm != algorithm || !bytes.Equal(sth.PublicKey, pinnedKey) {
+		return ErrUnexpectedKey
+	}
+	return signing.Verify(algorithm, pinnedKey, TreeHeadMessage(sth.TreeSize, sth.Timestamp, sth.RootHash), sth.Signature)
+}
+
+// Verifier is the client side of the log. It pins the hub key, remembers the last tree head it
+// accepted and only moves forward to tree heads proven to extend it, so the hub can neither
+// rewrite nor fork the history it has shown to this client.
+type Verifier struct {
+	algorithm string
+	pinnedKey []byte
+
+	mu      sync.Mutex
+	trusted *SignedTreeHead
+}
+
+// NewVerifier returns a verifier starting from trusted, which may be nil to trust on first use
+func NewVerifier(algorithm string, pinnedKey []byte, trusted *SignedTreeHead) *Verifier {
+	return &Verifier{
+		algorithm: algorithm,
+		pinnedKey: pinnedKey,
+		trusted:   trusted,
+	}
+}
+
+// Trusted returns the last accepted tree head
+func (v *Verifier) Trusted() *SignedTreeHead {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.trusted
+}
+
+// Update accepts sth if it is signed by the pinned key and consistent with the trusted tree head.
+// proof is the consistency proof from the trusted tree size to sth.TreeSize.
+func (v *Verifier) Update(sth *SignedTreeHead, proof [][]byte) error {
+	if err := VerifySignedTreeHead(sth, v.algorithm, v.pinnedKey); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.trusted != nil {
+		if sth.TreeSize < v.trusted.TreeSize {
+			return fmt.Errorf("%w: size %d < %d", ErrStaleTreeHead, sth.TreeSize, v.trusted.TreeSize)
+		}
+		if err := VerifyConsistency(v.trusted.TreeSize, sth.TreeSize, v.trusted.RootHash, sth.RootHash, proof); err != nil {
+			return err
+		}
+	}
+	v.trusted = sth
+	return nil
+}
+
+// VerifyEntry checks that data is the leaf at index of the trusted tree
+func (v *Verifier) VerifyEntry(data []byte, index uint64, proof [][]byte) error {
+	v.mu.Lock()
+	trusted := v.trusted
+	v.mu.Unlock()
+	if trusted == nil {
+		return errors.New("no trusted tree head")
+	}
+	return VerifyInclusion(LeafHash(data), index, trusted.TreeSize, proof, trusted.RootHash)
+}